@@ -31,7 +31,9 @@ load(
     _GoArchive = "GoArchive",
     _GoArchiveData = "GoArchiveData",
     _GoLibrary = "GoLibrary",
+    _GoPackageMetadata = "GoPackageMetadata",
     _GoPath = "GoPath",
+    _GoPlugin = "GoPlugin",
     _GoSDK = "GoSDK",
     _GoSource = "GoSource",
 )
@@ -46,14 +48,28 @@ load(
 )
 load(
     "@io_bazel_rules_go//go/private:rules/wrappers.bzl",
+    _go_benchmark_macro = "go_benchmark_macro",
     _go_binary_macro = "go_binary_macro",
+    _go_fuzz_test_macro = "go_fuzz_test_macro",
     _go_library_macro = "go_library_macro",
     _go_test_macro = "go_test_macro",
 )
+load(
+    "@io_bazel_rules_go//go/private:rules/cross.bzl",
+    _go_cross_binary = "go_cross_binary",
+)
+load(
+    "@io_bazel_rules_go//go/private:rules/multiarch.bzl",
+    _go_multiarch_binary = "go_multiarch_binary",
+)
 load(
     "@io_bazel_rules_go//go/private:rules/source.bzl",
     _go_source = "go_source",
 )
+load(
+    "@io_bazel_rules_go//go/private:rules/gc_goopts.bzl",
+    _go_package_gc_goopts = "go_package_gc_goopts",
+)
 load(
     "@io_bazel_rules_go//extras:embed_data.bzl",
     _go_embed_data = "go_embed_data",
@@ -72,9 +88,15 @@ load(
 )
 load(
     "@io_bazel_rules_go//go/private:rules/nogo.bzl",
+    _DEFAULT_VET_ANALYZERS = "DEFAULT_VET_ANALYZERS",
     _nogo = "nogo_wrapper",
+    _nogo_analyzer_bundle = "nogo_analyzer_bundle",
 )
 
+# DEFAULT_VET_ANALYZERS is the list of analyzers run by "go vet" in Go 1.14,
+# except cgocall. Setting vet = True in a nogo rule adds these to its deps.
+DEFAULT_VET_ANALYZERS = _DEFAULT_VET_ANALYZERS
+
 # TOOLS_NOGO is a list of all analysis passes in
 # golang.org/x/tools/go/analysis/passes.
 # This is not backward compatible, so use caution when depending on this --
@@ -128,6 +150,7 @@ go_sdk = _go_sdk
 go_tool_library = _go_tool_library
 go_toolchain = _go_toolchain
 nogo = _nogo
+nogo_analyzer_bundle = _nogo_analyzer_bundle
 
 # See go/providers.rst#GoLibrary for full documentation.
 GoLibrary = _GoLibrary
@@ -144,6 +167,12 @@ GoArchive = _GoArchive
 # See go/providers.rst#GoArchiveData for full documentation.
 GoArchiveData = _GoArchiveData
 
+# See go/providers.rst#GoPlugin for full documentation.
+GoPlugin = _GoPlugin
+
+# See go/providers.rst#GoPackageMetadata for full documentation.
+GoPackageMetadata = _GoPackageMetadata
+
 # See go/providers.rst#GoSDK for full documentation.
 GoSDK = _GoSDK
 
@@ -156,6 +185,21 @@ go_binary = _go_binary_macro
 # See go/core.rst#go_test for full documentation.
 go_test = _go_test_macro
 
+# See go/core.rst#go_cross_binary for full documentation.
+go_cross_binary = _go_cross_binary
+
+# See go/core.rst#go_multiarch_binary for full documentation.
+go_multiarch_binary = _go_multiarch_binary
+
+# See go/core.rst#go_package_gc_goopts for full documentation.
+go_package_gc_goopts = _go_package_gc_goopts
+
+# See go/core.rst#go_fuzz_test for full documentation.
+go_fuzz_test = _go_fuzz_test_macro
+
+# See go/core.rst#go_benchmark for full documentation.
+go_benchmark = _go_benchmark_macro
+
 # See go/core.rst#go_test for full documentation.
 go_source = _go_source
 
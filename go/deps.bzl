@@ -28,6 +28,7 @@ load(
     _go_host_sdk = "go_host_sdk",
     _go_local_sdk = "go_local_sdk",
     _go_register_toolchains = "go_register_toolchains",
+    _go_source_sdk = "go_source_sdk",
     _go_wrap_sdk = "go_wrap_sdk",
 )
 
@@ -36,4 +37,5 @@ go_register_toolchains = _go_register_toolchains
 go_download_sdk = _go_download_sdk
 go_host_sdk = _go_host_sdk
 go_local_sdk = _go_local_sdk
+go_source_sdk = _go_source_sdk
 go_wrap_sdk = _go_wrap_sdk
@@ -36,7 +36,10 @@ load(
 )
 load(
     "@io_bazel_rules_go//go/private:actions/compilepkg.bzl",
+    "emit_check_constraints",
     "emit_compilepkg",
+    "emit_nogo",
+    "emit_nogo_validation",
 )
 
 def emit_archive(go, source = None):
@@ -55,13 +58,43 @@ def emit_archive(go, source = None):
     elif testfilter == "only":
         pre_ext = ".external"
     out_lib = go.declare_file(go, ext = pre_ext + ".a")
-    if go.nogo:
+
+    # Test archives are not analyzed if the nogo rule disables analyze_tests.
+    nogo = go.nogo if go.nogo_analyze_tests or not testfilter else None
+    if nogo:
         # TODO(#1847): write nogo data into a new section in the .a file instead
         # of writing a separate file.
         out_export = go.declare_file(go, ext = pre_ext + ".x")
+        out_nogo_sarif = go.declare_file(go, ext = pre_ext + ".nogo.sarif")
+        out_nogo_findings = go.declare_file(go, ext = pre_ext + ".nogo.json")
+        out_export_data = go.declare_file(go, ext = pre_ext + ".export")
     else:
         out_export = None
+        out_nogo_sarif = None
+        out_nogo_findings = None
+        out_export_data = None
     out_cgo_export_h = None  # set if cgo used in c-shared or c-archive mode
+    if source.embedsrcs:
+        out_embedcfg = go.declare_file(go, ext = pre_ext + ".embedcfg")
+    else:
+        out_embedcfg = None
+
+    # External test sources are also sources of the internal test archive, so
+    # they're only checked once.
+    if split.go and testfilter != "only":
+        out_build_constraints = go.declare_file(go, ext = pre_ext + ".build_constraints")
+        emit_check_constraints(
+            go,
+            sources = split.go,
+            strict = source.strict_build_tags,
+            out = out_build_constraints,
+        )
+    else:
+        out_build_constraints = None
+
+    # The report is only an input of the compile action in strict mode, so
+    # that unknown tags fail the build. Otherwise it's only built on request.
+    build_constraints = out_build_constraints if source.strict_build_tags else None
 
     direct = [get_archive(dep) for dep in source.deps]
     runfiles = source.runfiles
@@ -74,6 +107,26 @@ def emit_archive(go, source = None):
     importmap = "main" if source.library.is_main else source.library.importmap
     importpath, _ = effective_importpath_pkgpath(source.library)
 
+    # nogo is told which sources are produced by other rules, so analyzers can
+    # be configured to skip or downgrade findings in them.
+    generated = [f for f in source.generated_srcs if f.extension == "go"]
+
+    # With Go 1.20+ binary coverage, the main package registers the hook that
+    # writes coverage data when the program exits, even if none of its own
+    # sources are instrumented.
+    cover_main = None
+    if source.library.is_main and go.coverage_enabled and go.cover_format == "gocoverdir":
+        cover_main = "testmain" if getattr(source.library, "is_test_main", False) else "regonly"
+
+    # Only deps listed by the target itself are checked. Deps of embedded
+    # libraries may be used by other targets that embed them, and the deps of
+    # a test are split between its internal and external test packages.
+    unused_deps = []
+    out_unused_deps = None
+    if go.unused_deps != "off" and getattr(source.library, "check_unused_deps", False):
+        unused_deps = [get_archive(dep) for dep in source.attr_deps]
+        out_unused_deps = go.declare_file(go, ext = pre_ext + ".unused_deps.json")
+
     if source.cgo and not go.mode.pure:
         # TODO(jayconrod): do we need to do full Bourne tokenization here?
         cppopts = [f for fs in source.cppopts for f in fs.split(" ")]
@@ -91,18 +144,35 @@ def emit_archive(go, source = None):
         )
         if go.mode.link in (LINKMODE_C_SHARED, LINKMODE_C_ARCHIVE):
             out_cgo_export_h = go.declare_file(go, path = "_cgo_install.h")
+
+        # The Go files generated by cgo are kept so that go_path can include
+        # them for tools that can't run cgo.
+        out_cgo_srcs = go.declare_directory(go, ext = pre_ext + ".cgo_srcs")
         cgo_deps = cgo.deps
         runfiles = runfiles.merge(cgo.runfiles)
         emit_compilepkg(
             go,
             sources = split.go + split.c + split.asm + split.cxx + split.objc + split.headers,
+            generated = generated,
             cover = source.cover,
+            cover_main = cover_main,
+            embedsrcs = source.embedsrcs,
             importpath = importpath,
             importmap = importmap,
+            label = source.library.label,
             archives = direct,
+            nogo = nogo,
             out_lib = out_lib,
             out_export = out_export,
+            out_nogo_sarif = out_nogo_sarif,
+            out_nogo_findings = out_nogo_findings,
+            out_export_data = out_export_data,
             out_cgo_export_h = out_cgo_export_h,
+            out_cgo_srcs = out_cgo_srcs,
+            out_embedcfg = out_embedcfg,
+            build_constraints = build_constraints,
+            unused_deps = unused_deps,
+            out_unused_deps = out_unused_deps,
             gc_goopts = source.gc_goopts,
             cgo = True,
             cgo_inputs = cgo.inputs,
@@ -116,20 +186,60 @@ def emit_archive(go, source = None):
         )
     else:
         cgo_deps = depset()
+        out_cgo_srcs = None
+        if nogo:
+            # Without cgo, nogo runs in a separate action that only depends on
+            # the export data of dependencies.
+            emit_nogo(
+                go,
+                sources = split.go,
+                generated = generated,
+                importpath = importpath,
+                importmap = importmap,
+                label = source.library.label,
+                archives = direct,
+                out_export = out_export,
+                out_nogo_sarif = out_nogo_sarif,
+                out_nogo_findings = out_nogo_findings,
+                testfilter = testfilter,
+            )
         emit_compilepkg(
             go,
             sources = split.go + split.c + split.asm + split.cxx + split.objc + split.headers,
             cover = source.cover,
+            cover_main = cover_main,
+            embedsrcs = source.embedsrcs,
             importpath = importpath,
             importmap = importmap,
+            label = source.library.label,
             archives = direct,
             out_lib = out_lib,
-            out_export = out_export,
+            out_export_data = out_export_data,
+            out_embedcfg = out_embedcfg,
+            build_constraints = build_constraints,
+            unused_deps = unused_deps,
+            out_unused_deps = out_unused_deps,
             gc_goopts = source.gc_goopts,
             cgo = False,
             testfilter = testfilter,
         )
 
+    # Findings are checked by a validation action, built through the
+    # _validation output group, so compilation doesn't wait for analysis.
+    # Bazel before 4.0 doesn't build that group by default, so the check is
+    # then a default output of libraries and an input of links instead. It's
+    # skipped when findings are collected for a baseline.
+    if nogo and not go.nogo_write_baseline:
+        out_nogo_validation = go.declare_file(go, ext = pre_ext + ".nogo_validation")
+        emit_nogo_validation(
+            go,
+            findings = out_nogo_findings,
+            deps = [a.data.nogo_validation for a in direct if a.data.nogo_validation],
+            out = out_nogo_validation,
+        )
+    else:
+        out_nogo_validation = None
+
     data = GoArchiveData(
         name = source.library.name,
         label = source.library.label,
@@ -139,8 +249,17 @@ def emit_archive(go, source = None):
         pathtype = source.library.pathtype,
         file = out_lib,
         export_file = out_export,
+        nogo_sarif = out_nogo_sarif,
+        nogo_findings = out_nogo_findings,
+        nogo_validation = out_nogo_validation,
+        export_data = out_export_data,
+        embedcfg = out_embedcfg,
+        build_constraints = out_build_constraints,
+        unused_deps = out_unused_deps,
+        cgo_srcs = out_cgo_srcs,
         srcs = as_tuple(source.srcs),
         orig_srcs = as_tuple(source.orig_srcs),
+        embedsrcs = as_tuple(source.embedsrcs),
         data_files = as_tuple(data_files),
     )
     x_defs = dict(source.x_defs)
@@ -150,6 +269,17 @@ def emit_archive(go, source = None):
     if out_cgo_export_h:
         cgo_exports_direct.append(out_cgo_export_h)
     cgo_exports = depset(direct = cgo_exports_direct, transitive = [a.cgo_exports for a in direct])
+
+    # The C/C++ dependencies of this archive and its dependencies are needed
+    # to link a c-archive or c-shared binary into a C/C++ program.
+    cc_infos = [d[CcInfo] for d in source.cdeps if CcInfo in d]
+    cc_infos.extend([a.cc_info for a in direct if a.cc_info])
+    if len(cc_infos) > 1:
+        cc_info = cc_common.merge_cc_infos(cc_infos = cc_infos)
+    elif cc_infos:
+        cc_info = cc_infos[0]
+    else:
+        cc_info = None
     return GoArchive(
         source = source,
         data = data,
@@ -159,6 +289,7 @@ def emit_archive(go, source = None):
         x_defs = x_defs,
         cgo_deps = depset(transitive = [cgo_deps] + [a.cgo_deps for a in direct]),
         cgo_exports = cgo_exports,
+        cc_info = cc_info,
         runfiles = runfiles,
         mode = go.mode,
     )
@@ -16,6 +16,14 @@ load(
     "@io_bazel_rules_go//go/private:mode.bzl",
     "link_mode_args",
 )
+load(
+    "@io_bazel_rules_go//go/private:platforms.bzl",
+    "GOOS_GOARCH",
+)
+load(
+    "@io_bazel_rules_go//go/private:rules/gc_goopts.bzl",
+    "package_gc_goopts",
+)
 load(
     "@bazel_skylib//lib:shell.bzl",
     "shell",
@@ -31,12 +39,33 @@ def _archive(v):
         v.data.export_file.path if v.data.export_file else "",
     )
 
+def _check_dep(v):
+    importpaths = [v.data.importpath]
+    importpaths.extend(v.data.importpath_aliases)
+    return "{}={}".format(v.data.label, ":".join(importpaths))
+
+def _nogo_archive(v):
+    importpaths = [v.data.importpath]
+    importpaths.extend(v.data.importpath_aliases)
+
+    # Archives built without nogo don't have separate export data.
+    return "{}={}={}={}".format(
+        ":".join(importpaths),
+        v.data.importmap,
+        v.data.export_data.path if v.data.export_data else v.data.file.path,
+        v.data.export_file.path if v.data.export_file else "",
+    )
+
 def emit_compilepkg(
         go,
         sources = None,
+        generated = [],
         cover = None,
+        cover_main = None,
+        embedsrcs = [],
         importpath = "",
         importmap = "",
+        label = None,
         archives = [],
         cgo = False,
         cgo_inputs = depset(),
@@ -46,18 +75,47 @@ def emit_compilepkg(
         objcopts = [],
         objcxxopts = [],
         clinkopts = [],
+        nogo = None,
         out_lib = None,
         out_export = None,
+        out_nogo_sarif = None,
+        out_nogo_findings = None,
+        out_export_data = None,
         out_cgo_export_h = None,
+        out_cgo_srcs = None,
+        out_embedcfg = None,
+        build_constraints = None,
+        unused_deps = [],
+        out_unused_deps = None,
         gc_goopts = [],
         testfilter = None):  # TODO: remove when test action compiles packages
-    """Compiles a complete Go package."""
+    """Compiles a complete Go package.
+
+    If nogo is set, it is run in the same action. Its findings don't fail the
+    action; they're checked by emit_nogo_validation. Otherwise, the package may
+    be analyzed by emit_nogo, which doesn't block compilation.
+    build_constraints may be set to the report written by
+    emit_check_constraints. It is not read by the compiler, but it is an input
+    so that unknown build tags fail the build whenever the package is compiled.
+
+    generated lists the files in sources that are produced by other rules
+    rather than checked in. nogo handles findings in them as configured by
+    each analyzer's "generated_files" setting.
+
+    cover_main is set for main packages when go.cover_format is "gocoverdir".
+    It's the coverage mode used to register the hook that writes coverage data
+    if none of the package's own sources are in cover: "regonly" for binaries
+    or "testmain" for test binaries.
+
+    If out_unused_deps is set, the archives in unused_deps that no source
+    imports are written to it, and they fail the action if go.unused_deps is
+    "error"."""
     if sources == None:
         fail("sources is a required parameter")
     if out_lib == None:
         fail("out_lib is a required parameter")
 
-    inputs = (sources + [go.package_list] +
+    inputs = (sources + embedsrcs + [go.package_list] +
               [archive.data.file for archive in archives] +
               go.sdk.tools + go.sdk.headers + go.stdlib.libs)
     outputs = [out_lib]
@@ -65,7 +123,25 @@ def emit_compilepkg(
 
     args = go.builder_args(go, "compilepkg")
     args.add_all(sources, before_each = "-src")
-    if cover and go.coverdata:
+    if embedsrcs:
+        args.add_all(embedsrcs, before_each = "-embedsrc")
+
+        # //go:embed patterns are relative to the directory of the source file
+        # containing them. Generated files are under an output root, so the
+        # builder needs the roots to find which embedsrcs are in that directory.
+        roots = {f.root.path: None for f in sources + embedsrcs if f.root.path}
+        args.add_all(sorted(roots.keys()), before_each = "-embedroot")
+    if out_embedcfg:
+        args.add("-embedcfg", out_embedcfg)
+        outputs.append(out_embedcfg)
+    if go.cover_format == "gocoverdir":
+        if cover or cover_main:
+            args.add("-cover_format", "gocoverdir")
+            args.add("-cover_mode", "atomic" if go.mode.race else "set")
+            args.add_all(cover, before_each = "-cover")
+            if cover_main:
+                args.add("-cover_main_mode", cover_main)
+    elif cover and go.coverdata:
         inputs.append(go.coverdata.data.file)
         args.add("-arc", _archive(go.coverdata))
         args.add("-cover_mode", "set")
@@ -78,22 +154,53 @@ def emit_compilepkg(
     args.add("-package_list", go.package_list)
 
     args.add("-o", out_lib)
-    if go.nogo:
-        args.add("-nogo", go.nogo)
+    if label:
+        args.add("-label", str(label))
+    if nogo:
+        args.add("-nogo", nogo)
+        args.add_all(generated, before_each = "-generated_src")
         args.add("-x", out_export)
-        inputs.append(go.nogo)
+        inputs.append(nogo)
         inputs.extend([archive.data.export_file for archive in archives if archive.data.export_file])
         outputs.append(out_export)
+        if out_nogo_sarif:
+            args.add("-nogo_sarif", out_nogo_sarif)
+            outputs.append(out_nogo_sarif)
+        if out_nogo_findings:
+            args.add("-nogo_findings", out_nogo_findings)
+            outputs.append(out_nogo_findings)
+        if go.nogo_timing:
+            args.add("-nogo_timing")
+    if build_constraints:
+        inputs.append(build_constraints)
+    if out_unused_deps:
+        args.add_all(unused_deps, before_each = "-check_dep", map_each = _check_dep)
+        args.add("-unused_deps", go.unused_deps)
+        args.add("-unused_deps_report", out_unused_deps)
+        outputs.append(out_unused_deps)
+    if out_export_data:
+        args.add("-export_data", out_export_data)
+        outputs.append(out_export_data)
     if out_cgo_export_h:
         args.add("-cgoexport", out_cgo_export_h)
         outputs.append(out_cgo_export_h)
+    if out_cgo_srcs:
+        args.add("-cgo_srcs", out_cgo_srcs.path)
+        outputs.append(out_cgo_srcs)
     if testfilter:
         args.add("-testfilter", testfilter)
 
-    gc_flags = [
+    # Flags are layered from the most general to the most specific, so flags
+    # set on a target come after flags for its package group, which come
+    # after flags for every package. The compiler uses the last value of a
+    # flag that's set more than once.
+    gc_flags = list(go.gc_goopts)
+    if label:
+        gc_flags.extend(package_gc_goopts(go.package_gc_goopts, label))
+    gc_flags.extend([
         go._ctx.expand_make_variables("gc_goopts", f, {})
         for f in gc_goopts
-    ]
+    ])
     asm_flags = []
     if go.mode.race:
         gc_flags.append("-race")
@@ -134,5 +241,173 @@ def emit_compilepkg(
         env = go.env,
     )
 
+def emit_nogo(
+        go,
+        sources = None,
+        generated = [],
+        importpath = "",
+        importmap = "",
+        label = None,
+        archives = [],
+        out_export = None,
+        out_nogo_sarif = None,
+        out_nogo_findings = None,
+        testfilter = None):
+    """Runs nogo on a Go package in its own action.
+
+    Dependencies are loaded from their export data rather than their archives,
+    so the action isn't rerun when a dependency changes in a way that doesn't
+    affect its API or its facts. Packages that use cgo must be analyzed by
+    emit_compilepkg instead.
+
+    Findings don't fail the action, so the reports are kept. They're checked
+    by emit_nogo_validation."""
+    if sources == None:
+        fail("sources is a required parameter")
+    if out_export == None:
+        fail("out_export is a required parameter")
+
+    inputs = (sources + [go.package_list, go.nogo] +
+              [archive.data.export_data or archive.data.file for archive in archives] +
+              [archive.data.export_file for archive in archives if archive.data.export_file] +
+              go.stdlib.libs)
+    outputs = [out_export]
+
+    args = go.builder_args(go, "nogo")
+    args.add_all(sources, before_each = "-src")
+    args.add_all(generated, before_each = "-generated_src")
+    args.add_all(archives, before_each = "-arc", map_each = _nogo_archive)
+    if importpath:
+        args.add("-importpath", importpath)
+    if importmap:
+        args.add("-p", importmap)
+    args.add("-package_list", go.package_list)
+    args.add("-nogo", go.nogo)
+    if label:
+        args.add("-label", str(label))
+    args.add("-x", out_export)
+    if out_nogo_sarif:
+        args.add("-nogo_sarif", out_nogo_sarif)
+        outputs.append(out_nogo_sarif)
+    if out_nogo_findings:
+        args.add("-nogo_findings", out_nogo_findings)
+        outputs.append(out_nogo_findings)
+    if go.nogo_timing:
+        args.add("-nogo_timing")
+    if testfilter:
+        args.add("-testfilter", testfilter)
+
+    go.actions.run(
+        inputs = inputs,
+        outputs = outputs,
+        mnemonic = "GoNogo",
+        executable = go.toolchain._builder,
+        arguments = [args],
+        env = go.env,
+    )
+
+def emit_nogo_validation(
+        go,
+        findings = None,
+        deps = [],
+        out = None):
+    """Fails if nogo reported findings in a package that should fail the build.
+
+    findings is the JSON findings file written by emit_nogo or
+    emit_compilepkg. deps are the files written by this action for the direct
+    dependencies of the package. They're not read, but they're inputs so that
+    building out checks dependencies too, even with versions of Bazel that
+    only build the _validation output group of top-level targets. out is
+    written if the check passes.
+
+    If go.nogo_diff is set, only findings on the lines it lists are checked.
+    It's only an input of this action, so changing it doesn't invalidate
+    analysis or compilation."""
+    if findings == None:
+        fail("findings is a required parameter")
+    if out == None:
+        fail("out is a required parameter")
+
+    args = go.builder_args(go, "nogovalidation")
+    args.add("-findings", findings)
+    args.add("-o", out)
+    inputs = [findings] + deps
+    if go.nogo_diff:
+        args.add("-nogo_diff", go.nogo_diff)
+        inputs.append(go.nogo_diff)
+
+    go.actions.run(
+        inputs = inputs,
+        outputs = [out],
+        mnemonic = "GoNogoValidation",
+        executable = go.toolchain._builder,
+        arguments = [args],
+        env = go.env,
+    )
+
 def _quote_opts(opts):
     return " ".join([shell.quote(opt) if " " in opt else opt for opt in opts])
+
+def emit_check_constraints(
+        go,
+        sources = None,
+        strict = False,
+        out = None):
+    """Checks the build constraints of the .go files in sources.
+
+    Files excluded on every platform in GOOS_GOARCH are reported as warnings.
+    Unknown build tags are reported as warnings, or as errors that fail the
+    action if strict is set. The diagnostics are written to out."""
+    if sources == None:
+        fail("sources is a required parameter")
+    if out == None:
+        fail("out is a required parameter")
+
+    args = go.builder_args(go, "checkconstraints")
+    args.add_all(sources, before_each = "-src")
+    args.add_all(["{}_{}".format(goos, goarch) for goos, goarch in GOOS_GOARCH], before_each = "-platform")
+    if strict:
+        args.add("-strict")
+    args.add("-o", out)
+
+    go.actions.run(
+        inputs = sources,
+        outputs = [out],
+        mnemonic = "GoCheckConstraints",
+        executable = go.toolchain._builder,
+        arguments = [args],
+        env = go.env,
+    )
+
+def emit_package_metadata(
+        go,
+        sources = None,
+        generated = [],
+        importpath = "",
+        out = None):
+    """Writes a JSON description of the .go files in sources to out.
+
+    For each file, the description has its package name, imports and build
+    tags, the platforms in GOOS_GOARCH it's built on, with and without cgo,
+    and whether it's in generated.
+    The format is documented in go/providers.rst#GoPackageMetadata."""
+    if sources == None:
+        fail("sources is a required parameter")
+    if out == None:
+        fail("out is a required parameter")
+
+    args = go.builder_args(go, "pkgmetadata")
+    args.add_all(sources, before_each = "-src")
+    args.add_all(generated, before_each = "-generated_src")
+    args.add_all(["{}_{}".format(goos, goarch) for goos, goarch in GOOS_GOARCH], before_each = "-platform")
+    args.add("-importpath", importpath)
+    args.add("-o", out)
+
+    go.actions.run(
+        inputs = sources,
+        outputs = [out],
+        mnemonic = "GoPackageMetadata",
+        executable = go.toolchain._builder,
+        arguments = [args],
+        env = go.env,
+    )
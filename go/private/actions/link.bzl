@@ -123,11 +123,16 @@ def emit_link(
         else:
             builder_args.add("-X", "%s=%s" % (k, v))
 
-    # Stamping support
+    # Stamping support. When stamping and buildvcs are enabled, binaries (but
+    # not tests) also record VCS information from the workspace status files
+    # in their build information.
+    stamp_buildinfo = go.stamp and go.buildvcs and not test_archives
     stamp_inputs = []
-    if stamp_x_defs:
+    if stamp_x_defs or stamp_buildinfo:
         stamp_inputs = [info_file, version_file]
         builder_args.add_all(stamp_inputs, before_each = "-stamp")
+    if stamp_buildinfo:
+        builder_args.add("-buildinfo_path", archive.data.importpath)
 
     builder_args.add("-o", executable)
     builder_args.add("-main", archive.data.file)
@@ -144,6 +149,17 @@ def emit_link(
     inputs_direct = stamp_inputs + [go.sdk.package_list]
     if go.coverage_enabled and go.coverdata:
         inputs_direct.append(go.coverdata.data.file)
+
+    # Bazel before 4.0 doesn't build the _validation output group by default,
+    # so nogo checks of the main package and the packages it imports directly
+    # are linker inputs instead. Those checks depend on the checks of their
+    # own dependencies.
+    if not go.toolchain._validation_actions:
+        inputs_direct.extend([
+            a.data.nogo_validation
+            for a in [archive] + archive.direct
+            if a.data.nogo_validation
+        ])
     inputs_transitive = [
         archive.libs,
         archive.cgo_deps,
@@ -69,6 +69,24 @@ cgo_exts = [
     ".mm",
 ]
 
+def wasm_exec_js(sdk):
+    """Returns the script from the SDK that runs a js/wasm program with
+    Node.js: wasm_exec_node.js in Go 1.21 and later, or wasm_exec.js before.
+    The script must be run next to the other wasm_exec files."""
+    scripts = {f.basename: f for f in sdk.wasm_exec}
+    script = scripts.get("wasm_exec_node.js") or scripts.get("wasm_exec.js")
+    if not script:
+        fail("the Go SDK has no wasm_exec.js, which is needed to run programs built for js/wasm")
+    return script
+
+def nogo_validation_outputs(go, archive):
+    """Returns the nogo check of archive if it must be a default output of
+    the target that built it, because Bazel is older than 4.0 and doesn't
+    build the _validation output group by default."""
+    if archive.data.nogo_validation and not go.toolchain._validation_actions:
+        return [archive.data.nogo_validation]
+    return []
+
 def pkg_dir(workspace_root, package_name):
     """Returns a path to a package directory from the root of the sandbox."""
     if workspace_root and package_name:
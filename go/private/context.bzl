@@ -35,11 +35,17 @@ load(
     "GoConfigInfo",
     "GoContextInfo",
     "GoLibrary",
+    "GoPackageGcGooptsInfo",
     "GoSource",
     "GoStdLib",
     "INFERRED_PATH",
+    "NogoInfo",
     "get_source",
 )
+load(
+    ":skylib/lib/versions.bzl",
+    "versions",
+)
 load(
     ":mode.bzl",
     "get_mode",
@@ -168,7 +174,10 @@ def _merge_embed(source, embed):
     source["srcs"] = s.srcs + source["srcs"]
     source["orig_srcs"] = s.orig_srcs + source["orig_srcs"]
     source["orig_src_map"].update(s.orig_src_map)
+    source["generated_srcs"] = s.generated_srcs + source["generated_srcs"]
     source["cover"] = source["cover"] + s.cover
+    source["embedsrcs"] = source["embedsrcs"] + s.embedsrcs
+    source["strict_build_tags"] = source["strict_build_tags"] or s.strict_build_tags
     source["deps"] = source["deps"] + s.deps
     source["x_defs"].update(s.x_defs)
     source["gc_goopts"] = source["gc_goopts"] + s.gc_goopts
@@ -217,9 +226,13 @@ def _library_to_source(go, attr, library, coverage_instrumented):
         "srcs": srcs,
         "orig_srcs": srcs,
         "orig_src_map": {},
+        "generated_srcs": [f for f in srcs if not f.is_source],
         "cover": [],
+        "embedsrcs": [f for t in getattr(attr, "embedsrcs", []) for f in as_iterable(t.files)],
+        "strict_build_tags": getattr(attr, "strict_build_tags", False),
         "x_defs": {},
         "deps": getattr(attr, "deps", []),
+        "attr_deps": getattr(attr, "deps", []),
         "gc_goopts": getattr(attr, "gc_goopts", []),
         "runfiles": _collect_runfiles(go, getattr(attr, "data", []), getattr(attr, "deps", [])),
         "cgo": getattr(attr, "cgo", False),
@@ -350,6 +363,7 @@ def go_context(ctx, attr = None):
     stdlib = None
     coverdata = None
     nogo = None
+    nogo_analyze_tests = True
     if hasattr(attr, "_go_context_data"):
         if CgoContextInfo in attr._go_context_data:
             cgo_context_info = attr._go_context_data[CgoContextInfo]
@@ -357,6 +371,7 @@ def go_context(ctx, attr = None):
         stdlib = attr._go_context_data[GoStdLib]
         coverdata = attr._go_context_data[GoContextInfo].coverdata
         nogo = attr._go_context_data[GoContextInfo].nogo
+        nogo_analyze_tests = attr._go_context_data[GoContextInfo].nogo_analyze_tests
     if getattr(attr, "_cgo_context_data", None) and CgoContextInfo in attr._cgo_context_data:
         cgo_context_info = attr._cgo_context_data[CgoContextInfo]
     if getattr(attr, "cgo_context_data", None) and CgoContextInfo in attr.cgo_context_data:
@@ -390,6 +405,11 @@ def go_context(ctx, attr = None):
         # happen. See #2291 for more information.
         "GOPATH": "",
     }
+    if go_config_info.goexperiment:
+        # The compiler, linker, and go command read experiments from the
+        # environment, so they're set for every action, which also makes them
+        # part of the action keys.
+        env["GOEXPERIMENT"] = ",".join(go_config_info.goexperiment)
     if mode.pure:
         crosstool = []
         cgo_tools = None
@@ -436,7 +456,21 @@ def go_context(ctx, attr = None):
 
     _check_importpaths(ctx)
     importpath, importmap, pathtype = _infer_importpath(ctx)
-    importpath_aliases = tuple(getattr(attr, "importpath_aliases", ()))
+    importpath_aliases = list(getattr(attr, "importpath_aliases", ()))
+
+    # A library keeps the aliases of libraries it embeds with the same import
+    # path, so a wrapper around a library that's being renamed can still be
+    # imported under its old path.
+    for embed in getattr(attr, "embed", []):
+        if GoLibrary not in embed:
+            continue
+        lib = embed[GoLibrary]
+        if lib.importpath != importpath:
+            continue
+        for alias in lib.importpath_aliases:
+            if alias not in importpath_aliases:
+                importpath_aliases.append(alias)
+    importpath_aliases = tuple(importpath_aliases)
 
     return struct(
         # Fields
@@ -460,7 +494,19 @@ def go_context(ctx, attr = None):
         pathtype = pathtype,
         cgo_tools = cgo_tools,
         nogo = nogo,
+        nogo_analyze_tests = nogo_analyze_tests,
+        nogo_write_baseline = go_config_info.nogo_write_baseline,
+        nogo_timing = go_config_info.nogo_timing,
+        nogo_diff = go_config_info.nogo_diff,
+        unused_deps = go_config_info.unused_deps,
         coverdata = coverdata,
+        cover_format = go_config_info.cover_format,
+        gc_goopts = go_config_info.gc_goopts,
+        package_gc_goopts = go_config_info.package_gc_goopts,
+        sbom_format = go_config_info.sbom_format,
+        sbom_modules = go_config_info.sbom_modules,
+        godebug = go_config_info.godebug,
+        buildvcs = go_config_info.buildvcs,
         coverage_enabled = ctx.configuration.coverage_enabled,
         coverage_instrumented = ctx.coverage_instrumented(),
         env = env,
@@ -490,12 +536,21 @@ def go_context(ctx, attr = None):
     )
 
 def _go_context_data_impl(ctx):
+    _check_sdk_version(
+        ctx.toolchains["@io_bazel_rules_go//go:toolchain"],
+        ctx.attr.go_config[GoConfigInfo].sdk_version,
+    )
+    _check_sdk_goos(ctx.toolchains["@io_bazel_rules_go//go:toolchain"])
     coverdata = ctx.attr.coverdata[GoArchive]
     nogo = ctx.files.nogo[0] if ctx.files.nogo else None
+    nogo_analyze_tests = True
+    if NogoInfo in ctx.attr.nogo:
+        nogo_analyze_tests = ctx.attr.nogo[NogoInfo].analyze_tests
     providers = [
         GoContextInfo(
             coverdata = ctx.attr.coverdata[GoArchive],
             nogo = nogo,
+            nogo_analyze_tests = nogo_analyze_tests,
         ),
         ctx.attr.stdlib[GoStdLib],
         ctx.attr.go_config[GoConfigInfo],
@@ -504,6 +559,50 @@ def _go_context_data_impl(ctx):
         providers.append(ctx.attr.cgo_context_data[CgoContextInfo])
     return providers
 
+def _check_sdk_version(toolchain, sdk_version):
+    """Fails if the @io_bazel_rules_go//go/toolchain:sdk_version flag is set
+    but toolchains can't be selected with it, because Bazel is too old to
+    select toolchains with target_settings, or if the SDK chosen by toolchain
+    resolution doesn't match it, because the SDK's version is unknown."""
+    if not sdk_version:
+        return
+    if not toolchain._sdk_version_settings:
+        fail(("sdk_version is {}, but selecting Go SDKs by version requires Bazel 5.0 or newer. " +
+              "Remove the sdk_version attribute or flag, or upgrade Bazel.").format(sdk_version))
+    sdk = toolchain.sdk
+    if sdk.version == sdk_version or sdk.version.startswith(sdk_version + "."):
+        return
+    fail(("sdk_version is {} but the Go SDK selected for this configuration has version {}. " +
+          "Selecting SDKs by version requires SDKs declared with go_download_sdk or a " +
+          "similar rule.").format(
+        sdk_version,
+        sdk.version or "unknown",
+    ))
+
+# Operating systems added after the oldest supported Go release, and the
+# release that added them.
+_GOOS_MIN_SDK_VERSIONS = {
+    "wasip1": "1.21",
+}
+
+def _check_sdk_goos(toolchain):
+    """Fails with a clear message if the SDK is too old to build for the
+    toolchain's target GOOS, rather than with an error from building the
+    standard library."""
+    goos = toolchain.default_goos
+    min_version = _GOOS_MIN_SDK_VERSIONS.get(goos)
+    version = toolchain.sdk.version
+
+    # Development versions have no number and are assumed to be new enough.
+    if not min_version or not version or not version[0].isdigit():
+        return
+    if not versions.is_at_least(min_version, version):
+        fail("GOOS={} requires Go {} or later, but the Go SDK selected for this configuration has version {}".format(
+            goos,
+            min_version,
+            version,
+        ))
+
 go_context_data = rule(
     _go_context_data_impl,
     attrs = {
@@ -703,6 +802,8 @@ def _cgo_context_data_impl(ctx):
         tags = tags,
         env = env,
         cgo_tools = struct(
+            cc_toolchain = cc_toolchain,
+            feature_configuration = feature_configuration,
             c_compiler_path = c_compiler_path,
             c_compile_options = c_compile_options,
             cxx_compile_options = cxx_compile_options,
@@ -748,6 +849,10 @@ cgo_context_data_proxy = rule(
 )
 
 def _go_config_impl(ctx):
+    nogo_diff_files = ctx.files.nogo_diff
+    if len(nogo_diff_files) > 1:
+        fail("nogo_diff must refer to a single file", attr = "nogo_diff")
+    nogo_diff = nogo_diff_files[0] if nogo_diff_files else None
     return [GoConfigInfo(
         static = ctx.attr.static[BuildSettingInfo].value,
         race = ctx.attr.race[BuildSettingInfo].value,
@@ -758,6 +863,19 @@ def _go_config_impl(ctx):
         linkmode = ctx.attr.linkmode[BuildSettingInfo].value,
         tags = ctx.attr.gotags[BuildSettingInfo].value,
         stamp = ctx.attr.stamp,
+        nogo_write_baseline = ctx.attr.nogo_write_baseline[BuildSettingInfo].value,
+        nogo_timing = ctx.attr.nogo_timing[BuildSettingInfo].value,
+        nogo_diff = nogo_diff,
+        sdk_version = ctx.attr.sdk_version[BuildSettingInfo].value,
+        unused_deps = ctx.attr.unused_deps[BuildSettingInfo].value,
+        cover_format = ctx.attr.cover_format[BuildSettingInfo].value,
+        gc_goopts = ctx.attr.gc_goopts[BuildSettingInfo].value,
+        package_gc_goopts = ctx.attr.package_gc_goopts[GoPackageGcGooptsInfo].layers,
+        sbom_format = ctx.attr.sbom_format[BuildSettingInfo].value,
+        sbom_modules = ctx.files.sbom_modules,
+        goexperiment = ctx.attr.goexperiment[BuildSettingInfo].value,
+        godebug = ctx.attr.godebug[BuildSettingInfo].value,
+        buildvcs = ctx.attr.buildvcs[BuildSettingInfo].value,
     )]
 
 go_config = rule(
@@ -796,6 +914,58 @@ go_config = rule(
             providers = [BuildSettingInfo],
         ),
         "stamp": attr.bool(mandatory = True),
+        "nogo_write_baseline": attr.label(
+            mandatory = True,
+            providers = [BuildSettingInfo],
+        ),
+        "nogo_timing": attr.label(
+            mandatory = True,
+            providers = [BuildSettingInfo],
+        ),
+        "nogo_diff": attr.label(
+            mandatory = True,
+            allow_files = True,
+        ),
+        "sdk_version": attr.label(
+            mandatory = True,
+            providers = [BuildSettingInfo],
+        ),
+        "unused_deps": attr.label(
+            mandatory = True,
+            providers = [BuildSettingInfo],
+        ),
+        "cover_format": attr.label(
+            mandatory = True,
+            providers = [BuildSettingInfo],
+        ),
+        "gc_goopts": attr.label(
+            mandatory = True,
+            providers = [BuildSettingInfo],
+        ),
+        "package_gc_goopts": attr.label(
+            mandatory = True,
+            providers = [GoPackageGcGooptsInfo],
+        ),
+        "sbom_format": attr.label(
+            mandatory = True,
+            providers = [BuildSettingInfo],
+        ),
+        "sbom_modules": attr.label(
+            mandatory = True,
+            allow_files = True,
+        ),
+        "goexperiment": attr.label(
+            mandatory = True,
+            providers = [BuildSettingInfo],
+        ),
+        "godebug": attr.label(
+            mandatory = True,
+            providers = [BuildSettingInfo],
+        ),
+        "buildvcs": attr.label(
+            mandatory = True,
+            providers = [BuildSettingInfo],
+        ),
     },
     provides = [GoConfigInfo],
     doc = """Collects information about build settings in the current
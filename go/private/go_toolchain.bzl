@@ -15,6 +15,7 @@
 Toolchain rules used by go.
 """
 
+load("@bazel_skylib//lib:selects.bzl", "selects")
 load("@io_bazel_rules_go//go/private:platforms.bzl", "PLATFORMS")
 load("@io_bazel_rules_go//go/private:providers.bzl", "GoSDK")
 load("@io_bazel_rules_go//go/private:actions/archive.bzl", "emit_archive")
@@ -54,6 +55,8 @@ def _go_toolchain_impl(ctx):
 
         # Internal fields -- may be read by emit functions.
         _builder = ctx.executable.builder,
+        _sdk_version_settings = ctx.attr.sdk_version_settings,
+        _validation_actions = ctx.attr.validation_actions,
     )]
 
 go_toolchain = rule(
@@ -87,16 +90,38 @@ go_toolchain = rule(
         "cgo_link_flags": attr.string_list(
             doc = "Flags passed to the external linker (if it is used)",
         ),
+        "sdk_version_settings": attr.bool(
+            default = True,
+            doc = "Whether the toolchain may be selected with the sdk_version flag",
+        ),
+        "validation_actions": attr.bool(
+            default = True,
+            doc = "Whether Bazel builds the _validation output group by default",
+        ),
     },
     doc = "Defines a Go toolchain based on an SDK",
     provides = [platform_common.ToolchainInfo],
 )
 
-def declare_toolchains(host, sdk, builder):
-    """Declares go_toolchain and toolchain targets for each platform."""
+def declare_toolchains(host, sdk, builder, sdk_version = "", sdk_version_settings = True, validation_actions = True):
+    """Declares go_toolchain and toolchain targets for each platform.
+
+    If sdk_version is set, the toolchains are only selected when the
+    @io_bazel_rules_go//go/toolchain:sdk_version flag is empty or matches
+    sdk_version. This uses the target_settings attribute of toolchain, which
+    requires Bazel 5.0 or newer. With older versions, sdk_version_settings
+    must be False, and builds that set the flag fail.
+
+    validation_actions must be False with versions of Bazel older than 4.0,
+    which don't build the _validation output group by default. nogo checks
+    are then built with the outputs of each target instead.
+    """
 
     # keep in sync with generate_toolchain_names
     host_goos, _, host_goarch = host.partition("_")
+    toolchain_kwargs = {}
+    if sdk_version and sdk_version_settings:
+        toolchain_kwargs["target_settings"] = [_declare_sdk_version_settings(sdk_version)]
     for p in PLATFORMS:
         if p.cgo:
             # Don't declare separate toolchains for cgo_on / cgo_off.
@@ -128,6 +153,8 @@ def declare_toolchains(host, sdk, builder):
             builder = builder,
             link_flags = link_flags,
             cgo_link_flags = cgo_link_flags,
+            sdk_version_settings = sdk_version_settings,
+            validation_actions = validation_actions,
             tags = ["manual"],
             visibility = ["//visibility:public"],
         )
@@ -140,4 +167,34 @@ def declare_toolchains(host, sdk, builder):
             ],
             target_compatible_with = constraints,
             toolchain = ":" + impl_name,
+            **toolchain_kwargs
+        )
+
+def _declare_sdk_version_settings(sdk_version):
+    """Declares config_settings matching the sdk_version flag against an SDK
+    with the given version. The flag may be empty, the full version, or a
+    prefix ending at a ".", so "1" and "1.14" match "1.14.2".
+
+    Returns:
+        The label of a config_setting_group matching any of them.
+    """
+    flag = "@io_bazel_rules_go//go/toolchain:sdk_version"
+    names = ["match_all_sdk_versions"]
+    native.config_setting(
+        name = names[0],
+        flag_values = {flag: ""},
+    )
+    parts = sdk_version.split(".")
+    for i in range(len(parts)):
+        prefix = ".".join(parts[:i + 1])
+        name = "match_sdk_version_" + prefix
+        native.config_setting(
+            name = name,
+            flag_values = {flag: prefix},
         )
+        names.append(name)
+    selects.config_setting_group(
+        name = "sdk_version_setting",
+        match_any = [":" + name for name in names],
+    )
+    return ":sdk_version_setting"
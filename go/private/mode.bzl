@@ -100,6 +100,11 @@ def get_mode(ctx, go_toolchain, cgo_context_info, go_config_info):
         tags.append("race")
     if msan:
         tags.append("msan")
+    for experiment in go_config_info.goexperiment:
+        # The go command sets these tags for enabled experiments, so files
+        # are filtered the same way.
+        if not experiment.startswith("no"):
+            tags.append("goexperiment." + experiment)
 
     return struct(
         static = static,
@@ -34,6 +34,18 @@ GoArchiveData = provider()
 # See go/providers.rst#GoArchive for full documentation.
 GoArchive = provider()
 
+# A Go plugin built by go_binary with linkmode = "plugin", along with what's
+# needed to check that a binary can load it.
+# This is a configuration specific provider.
+# See go/providers.rst#GoPlugin for full documentation.
+GoPlugin = provider()
+
+# The package name, imports and build constraints of the sources of a
+# go_library or go_source, parsed by an action.
+# This is a configuration specific provider.
+# See go/providers.rst#GoPackageMetadata for full documentation.
+GoPackageMetadata = provider()
+
 GoAspectProviders = provider()
 
 GoPath = provider()
@@ -43,6 +55,8 @@ GoSDK = provider(
     fields = {
         "goos": "The host OS the SDK was built for.",
         "goarch": "The host architecture the SDK was built for.",
+        "version": ("The Go version of the SDK, like \"1.14.2\", or an " +
+                    "empty string if it's not known."),
         "root_file": "A file in the SDK root directory",
         "libs": ("List of pre-compiled .a files for the standard library " +
                  "built for the execution platform."),
@@ -56,6 +70,8 @@ GoSDK = provider(
         "tools": ("List of executable files in the SDK built for " +
                   "the execution platform, excluding the go binary file"),
         "go": "The go binary file",
+        "wasm_exec": ("List of wasm_exec.js and related files, used to run " +
+                      "programs built for js/wasm with Node.js."),
     },
 )
 
@@ -65,6 +81,12 @@ GoConfigInfo = provider()
 
 GoContextInfo = provider()
 
+GoPackageGcGooptsInfo = provider()
+
+NogoInfo = provider()
+
+NogoAnalyzerBundleInfo = provider()
+
 CgoContextInfo = provider()
 
 EXPLICIT_PATH = "explicit"
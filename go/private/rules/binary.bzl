@@ -21,18 +21,29 @@ load(
     "asm_exts",
     "cgo_exts",
     "go_exts",
+    "wasm_exec_js",
 )
 load(
     ":providers.bzl",
     "GoLibrary",
+    "GoPlugin",
     "GoSDK",
 )
+load(
+    ":rules/plugin.bzl",
+    "check_plugins",
+    "go_plugin_info",
+)
 load(
     ":rules/transition.bzl",
     "go_transition_rule",
 )
 load(
     ":mode.bzl",
+    "LINKMODE_C_ARCHIVE",
+    "LINKMODE_C_SHARED",
+    "LINKMODE_NORMAL",
+    "LINKMODE_PIE",
     "LINKMODE_PLUGIN",
     "LINKMODE_SHARED",
 )
@@ -46,7 +57,7 @@ def _go_binary_impl(ctx):
     go = go_context(ctx)
 
     is_main = go.mode.link not in (LINKMODE_SHARED, LINKMODE_PLUGIN)
-    library = go.new_library(go, importable = False, is_main = is_main)
+    library = go.new_library(go, importable = False, is_main = is_main, check_unused_deps = True)
     source = go.library_to_source(go, ctx.attr, library, ctx.coverage_instrumented())
     name = ctx.attr.basename
     if not name:
@@ -57,38 +68,369 @@ def _go_binary_impl(ctx):
         # directly, Bazel warns them not to use the same name as the rule, which is
         # the common case with go_binary.
         executable = ctx.actions.declare_file(ctx.attr.out)
-    archive, executable, runfiles = go.binary(
+    debug_file = None
+    if ctx.attr.split_debug:
+        _check_split_debug(go)
+        if not executable:
+            executable = go.declare_file(go, name = name)
+
+        # The binary is linked next to the stripped copy, so the copy's debug
+        # link finds it, and so rpaths to cgo dependencies work for both.
+        debug_file = go.actions.declare_file(executable.basename + ".debug", sibling = executable)
+    archive, linked, runfiles = go.binary(
         go,
         name = name,
         source = source,
         gc_linkopts = gc_linkopts(ctx),
         version_file = ctx.version_file,
         info_file = ctx.info_file,
-        executable = executable,
+        executable = debug_file or executable,
     )
-    return [
+    if debug_file:
+        _split_debug(go, debug_file, executable)
+    else:
+        executable = linked
+    plugin_files = check_plugins(go, archive, ctx.attr.plugins)
+    if plugin_files:
+        runfiles = runfiles.merge(ctx.runfiles(files = plugin_files))
+    run_executable = executable
+    runner, wasm_exec = wasm_runner(go)
+    if runner:
+        runfiles = runfiles.merge(runner[DefaultInfo].default_runfiles)
+    if wasm_exec:
+        runfiles = runfiles.merge(ctx.runfiles(files = go.sdk.wasm_exec))
+    if ctx.attr.env or runner or go.godebug:
+        run_executable = _emit_launcher(
+            go,
+            executable,
+            runner[DefaultInfo].files_to_run.executable if runner else None,
+            wasm_exec,
+        )
+        runfiles = runfiles.merge(ctx.runfiles(files = [executable]))
+    providers = []
+    c_header = []
+    size_report = []
+    if go.mode.link != LINKMODE_C_ARCHIVE:
+        size_report.append(_size_report(go, linked))
+    sbom = _sbom(go, archive, name)
+    if go.mode.link == LINKMODE_PLUGIN:
+        providers.append(go_plugin_info(go, archive, executable))
+    elif go.mode.link in (LINKMODE_C_ARCHIVE, LINKMODE_C_SHARED):
+        header, cc_info = _c_library_info(go, archive, executable)
+        c_header.append(header)
+        if cc_info:
+            providers.append(cc_info)
+    return providers + [
         library,
         source,
         archive,
         OutputGroupInfo(
+            c_header = c_header,
             cgo_exports = archive.cgo_exports,
             compilation_outputs = [archive.data.file],
+            nogo_sarif = [archive.data.nogo_sarif] if archive.data.nogo_sarif else [],
+            nogo_findings = [archive.data.nogo_findings] if archive.data.nogo_findings else [],
+            embedcfg = [archive.data.embedcfg] if archive.data.embedcfg else [],
+            build_constraints = [archive.data.build_constraints] if archive.data.build_constraints else [],
+            unused_deps = [archive.data.unused_deps] if archive.data.unused_deps else [],
+            size_report = size_report,
+            sbom = [sbom],
+            debug_info = [debug_file] if debug_file else [],
+            _validation = [archive.data.nogo_validation] if archive.data.nogo_validation else [],
         ),
         DefaultInfo(
             files = depset([executable]),
             runfiles = runfiles,
-            executable = executable,
+            executable = run_executable,
         ),
     ]
 
+def wasm_runner(go):
+    """Returns the target that runs programs built for WebAssembly in the
+    current mode, and for js/wasm, the SDK's wasm_exec.js, which the runner
+    finds through GO_JS_WASM_EXEC. Returns None, None for other platforms,
+    whose programs the host executes directly."""
+    ctx = go._ctx
+    if go.mode.link != LINKMODE_NORMAL:
+        return None, None
+    if go.mode.goos == "wasip1":
+        return ctx.attr._wasip1_exec, None
+    if go.mode.goos == "js":
+        return ctx.attr._js_wasm_exec, wasm_exec_js(go.sdk)
+    return None, None
+
+# Shell code that defines rlocation, which prints the path of a file given
+# its runfiles path. Files are found in the launcher's own runfiles tree, in
+# RUNFILES_DIR or the tree the launcher is in when it's another program's
+# runfile, or through a runfiles manifest when there's no tree.
+_RLOCATION_SH = [
+    "if [ -d \"$0.runfiles\" ]; then",
+    "  runfiles=\"$0.runfiles\"",
+    "elif [ -n \"${RUNFILES_DIR:-}\" ]; then",
+    "  runfiles=\"$RUNFILES_DIR\"",
+    "else",
+    "  case \"$0\" in",
+    "    *.runfiles/*) runfiles=\"${0%%.runfiles/*}.runfiles\" ;;",
+    "    *) runfiles= ;;",
+    "  esac",
+    "fi",
+    "manifest=\"${RUNFILES_MANIFEST_FILE:-$0.runfiles_manifest}\"",
+    "rlocation() {",
+    "  if [ -n \"$runfiles\" ] && [ -e \"$runfiles/$1\" ]; then",
+    "    echo \"$runfiles/$1\"",
+    "  elif [ -f \"$manifest\" ]; then",
+    "    awk -v p=\"$1\" '$1 == p { print substr($0, length(p) + 2); exit }' \"$manifest\"",
+    "  fi",
+    "}",
+]
+
+def _emit_launcher(go, executable, runner = None, wasm_exec = None):
+    """Declares a script that sets the variables in the env attribute, and
+    GODEBUG from --@io_bazel_rules_go//go/config:godebug, and executes the
+    binary with its arguments. If runner is set, the script
+    executes runner with the path of the binary instead, for binaries the host
+    can't execute directly, like WebAssembly modules. wasm_exec is the SDK's
+    wasm_exec.js, passed to the runner for js/wasm.
+
+    The script is the executable Bazel runs, so the environment is set by
+    "bazel run" and by rules that execute the target's files_to_run. It
+    replaces itself with the binary, so wrappers given with --run_under that
+    execute their arguments see the binary's process. The script finds the
+    binary and runner in its runfiles, so it still works when it's linked
+    elsewhere, for example by go_cross_binary, or when it's run from another
+    program's runfiles. The binary is found next to the script if runfiles
+    weren't built.
+    """
+    ctx = go._ctx
+    env = {
+        k: ctx.expand_location(ctx.expand_make_variables("env", v, {}), ctx.attr.data)
+        for k, v in ctx.attr.env.items()
+    }
+    if go.godebug and "GODEBUG" not in env:
+        env["GODEBUG"] = ",".join(go.godebug)
+    if go.mode.goos == "windows":
+        launcher = go.declare_file(go, ext = ".run.bat")
+    else:
+        launcher = go.declare_file(go, ext = ".run")
+    package_dir = launcher.short_path[:-len(launcher.basename)]
+    if not executable.short_path.startswith(package_dir):
+        fail("binary {} is not in the package of {}".format(executable.short_path, ctx.label))
+    binary_path = executable.short_path[len(package_dir):]
+
+    runfiles_path = ctx.workspace_name + "/" + executable.short_path
+    if go.mode.goos == "windows":
+        lines = ["@echo off", "setlocal"]
+        for k, v in sorted(env.items()):
+            lines.append("set \"{}={}\"".format(k, v.replace("%", "%%")))
+        lines.extend([
+            "set \"bin=%~f0.runfiles\\{}\"".format(runfiles_path.replace("/", "\\")),
+            "if not exist \"%bin%\" set \"bin=%~dp0{}\"".format(binary_path.replace("/", "\\")),
+            "\"%bin%\" %*",
+            "exit /b %ERRORLEVEL%",
+        ])
+    else:
+        lines = ["#!/bin/sh"]
+        for k, v in sorted(env.items()):
+            lines.append("export {}={}".format(k, shell.quote(v)))
+        lines.extend(_RLOCATION_SH)
+        lines.extend([
+            "bin=\"$(rlocation {})\"".format(shell.quote(runfiles_path)),
+            "if [ ! -x \"$bin\" ]; then",
+            "  bin=\"$(dirname \"$0\")\"/{}".format(shell.quote(binary_path)),
+            "fi",
+        ])
+        if wasm_exec:
+            lines.append("export GO_JS_WASM_EXEC=\"$(rlocation {})\"".format(
+                shell.quote(ctx.workspace_name + "/" + wasm_exec.short_path),
+            ))
+        if runner:
+            # The runner is only found in runfiles.
+            lines.append("exec \"$(rlocation {})\" \"$bin\" \"$@\"".format(
+                shell.quote(ctx.workspace_name + "/" + runner.short_path),
+            ))
+        else:
+            lines.append("exec \"$bin\" \"$@\"")
+    go.actions.write(launcher, "\n".join(lines) + "\n", is_executable = True)
+    return launcher
+
+# Operating systems whose binaries aren't ELF files.
+_NON_ELF_GOOS = ("aix", "darwin", "ios", "js", "plan9", "wasip1", "windows")
+
+def _check_split_debug(go):
+    if go.mode.goos in _NON_ELF_GOOS:
+        fail("split_debug is only supported for ELF binaries, not for GOOS={}".format(go.mode.goos))
+    if go.mode.link not in (LINKMODE_NORMAL, LINKMODE_PIE):
+        fail("split_debug is only supported for executables, not for linkmode {}".format(go.mode.link))
+    if go.mode.strip:
+        fail("split_debug can't be used when binaries are stripped, since there's no debug information to split")
+
+def _split_debug(go, debug_file, executable):
+    """Writes a copy of debug_file without DWARF sections or a symbol table to
+    executable. debug_file keeps everything, so it can be used as a symbol file
+    for the copy; the copy's .gnu_debuglink section names it.
+    """
+    args = go.builder_args(go, "splitdebug")
+    args.add("-binary", debug_file)
+    args.add("-o", executable)
+    go.actions.run(
+        inputs = [debug_file],
+        outputs = [executable],
+        mnemonic = "GoSplitDebug",
+        executable = go.toolchain._builder,
+        arguments = [args],
+        env = go.env,
+    )
+
+def _size_report(go, executable):
+    """Declares a JSON report of how much each package contributes to the size
+    of executable, built with --output_groups=size_report.
+
+    Sizes come from the symbol table, so a binary linked with -s only has its
+    file size reported. C archives aren't supported, since they're archives of
+    objects in the C toolchain's format.
+    """
+    report = go.declare_file(go, ext = ".size_report.json")
+    args = go.builder_args(go, "sizereport")
+    args.add("-binary", executable)
+    args.add("-o", report)
+    go.actions.run(
+        inputs = [executable, go.sdk.go] + go.sdk.tools,
+        outputs = [report],
+        mnemonic = "GoSizeReport",
+        executable = go.toolchain._builder,
+        arguments = [args],
+        env = go.env,
+    )
+    return report
+
+def _format_sbom_package(d):
+    return "{}={}".format(d.importpath, d.label.workspace_name)
+
+def _sbom(go, archive, name):
+    """Declares a software bill of materials for the binary, built with
+    --output_groups=sbom.
+
+    The SBOM lists the modules the binary's packages come from, with the
+    versions and checksums in the files named by
+    --@io_bazel_rules_go//go/config:sbom_modules, and the version of the
+    standard library. Packages from external repositories that match no
+    module are listed by repository name.
+    """
+    if go.sbom_format == "spdx":
+        ext = ".spdx.json"
+    else:
+        ext = ".cdx.json"
+    sbom = go.declare_file(go, ext = ext)
+    args = go.builder_args(go, "sbom")
+    args.add("-format", go.sbom_format)
+    args.add("-name", name)
+    args.add("-main", archive.data.importpath)
+    if go.sdk.version:
+        args.add("-go_version", go.sdk.version)
+    args.add_all(archive.transitive, before_each = "-package", map_each = _format_sbom_package)
+    args.add_all(go.sbom_modules, before_each = "-modfile")
+    args.add("-o", sbom)
+    go.actions.run(
+        inputs = go.sbom_modules,
+        outputs = [sbom],
+        mnemonic = "GoSBOM",
+        executable = go.toolchain._builder,
+        arguments = [args],
+        env = go.env,
+    )
+    return sbom
+
+def _c_library_info(go, archive, executable):
+    """Returns the C header for a binary built in c-archive or c-shared mode
+    and a CcInfo provider that lets cc rules depend on the binary.
+
+    The header is named after the target, so C code can include it as
+    "path/to/pkg/name.h". The CcInfo provider is None when cgo is disabled,
+    since there's no C/C++ toolchain to link with.
+    """
+    ctx = go._ctx
+    header = go.actions.declare_file(ctx.label.name + ".h")
+    cgo_exports = archive.cgo_exports.to_list()
+    if len(cgo_exports) == 1:
+        go.actions.symlink(output = header, target_file = cgo_exports[0])
+    elif cgo_exports:
+        go.actions.run_shell(
+            inputs = cgo_exports,
+            outputs = [header],
+            command = "cat \"$@\" >" + shell.quote(header.path),
+            arguments = ["--"] + [f.path for f in cgo_exports],
+            mnemonic = "GoCgoHeader",
+        )
+    else:
+        # Nothing is exported, but the header is still produced, so C code
+        # that includes it builds (#2132).
+        go.actions.write(header, "")
+    if not go.cgo_tools:
+        return header, None
+
+    library_kwargs = {}
+    if go.mode.link == LINKMODE_C_ARCHIVE:
+        library_kwargs["static_library"] = executable
+        library_kwargs["alwayslink"] = True
+    else:
+        library_kwargs["dynamic_library"] = executable
+    library = cc_common.create_library_to_link(
+        actions = go.actions,
+        cc_toolchain = go.cgo_tools.cc_toolchain,
+        feature_configuration = go.cgo_tools.feature_configuration,
+        **library_kwargs
+    )
+
+    # The Go runtime needs threads. This matches the flags cmd/go passes to
+    # the external linker.
+    if go.mode.goos == "windows":
+        linkopts = ["-mthreads"]
+    elif go.mode.goos in ("darwin", "ios"):
+        linkopts = []
+    else:
+        linkopts = ["-pthread"]
+
+    compilation_context = cc_common.create_compilation_context(
+        headers = depset([header]),
+        includes = depset([header.root.path]),
+    )
+    if hasattr(cc_common, "create_linker_input"):
+        linking_context = cc_common.create_linking_context(
+            linker_inputs = depset([cc_common.create_linker_input(
+                owner = ctx.label,
+                libraries = depset([library]),
+                user_link_flags = depset(linkopts),
+            )]),
+        )
+    else:
+        # Older versions of Bazel don't have linker inputs.
+        linking_context = cc_common.create_linking_context(
+            libraries_to_link = [library],
+            user_link_flags = linkopts,
+        )
+    cc_info = CcInfo(
+        compilation_context = compilation_context,
+        linking_context = linking_context,
+    )
+    if go.mode.link == LINKMODE_C_ARCHIVE and archive.cc_info:
+        # Unlike a shared library, the archive doesn't include the C/C++
+        # dependencies of the Go code, so they're linked separately.
+        cc_info = cc_common.merge_cc_infos(cc_infos = [cc_info, archive.cc_info])
+    return header, cc_info
+
 _go_binary_kwargs = {
     "implementation": _go_binary_impl,
     "attrs": {
         "srcs": attr.label_list(allow_files = go_exts + asm_exts + cgo_exts),
         "data": attr.label_list(allow_files = True),
+        "env": attr.string_dict(),
+        "embedsrcs": attr.label_list(allow_files = True),
         "deps": attr.label_list(
             providers = [GoLibrary],
         ),
+        "plugins": attr.label_list(
+            providers = [GoPlugin],
+        ),
         "embed": attr.label_list(
             providers = [GoLibrary],
         ),
@@ -96,8 +438,10 @@ _go_binary_kwargs = {
         "gc_goopts": attr.string_list(),
         "gc_linkopts": attr.string_list(),
         "x_defs": attr.string_dict(),
+        "strict_build_tags": attr.bool(),
         "basename": attr.string(),
         "out": attr.string(),
+        "split_debug": attr.bool(),
         "cgo": attr.bool(),
         "cdeps": attr.label_list(),
         "cppopts": attr.string_list(),
@@ -105,6 +449,16 @@ _go_binary_kwargs = {
         "cxxopts": attr.string_list(),
         "clinkopts": attr.string_list(),
         "_go_context_data": attr.label(default = "//:go_context_data"),
+        "_js_wasm_exec": attr.label(
+            default = "//go/config:js_wasm_exec",
+            executable = True,
+            cfg = "host",
+        ),
+        "_wasip1_exec": attr.label(
+            default = "//go/config:wasip1_exec",
+            executable = True,
+            cfg = "host",
+        ),
     },
     "executable": True,
     "toolchains": ["@io_bazel_rules_go//go:toolchain"],
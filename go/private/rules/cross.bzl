@@ -0,0 +1,79 @@
+# Copyright 2020 The Bazel Authors. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#    http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+load(
+    ":platforms.bzl",
+    "GOOS_GOARCH",
+)
+load(
+    ":rules/transition.bzl",
+    "go_cross_transition",
+)
+
+def _go_cross_binary_impl(ctx):
+    # Attributes with transitions are lists in some versions of Bazel.
+    target = ctx.attr.target
+    if type(target) == "list":
+        target = target[0]
+    info = target[DefaultInfo]
+    binary = info.files_to_run.executable
+    if not binary:
+        fail("{} is not executable".format(target.label), attr = "target")
+
+    name = ctx.label.name
+    if binary.extension == "exe":
+        name += ".exe"
+    out = ctx.actions.declare_file(name)
+    ctx.actions.symlink(
+        output = out,
+        target_file = binary,
+        is_executable = True,
+    )
+    return [DefaultInfo(
+        files = depset([out]),
+        executable = out,
+        runfiles = info.default_runfiles,
+    )]
+
+go_cross_binary = rule(
+    implementation = _go_cross_binary_impl,
+    attrs = {
+        "target": attr.label(
+            mandatory = True,
+            cfg = go_cross_transition,
+            doc = "The go_binary to build for another platform or SDK.",
+        ),
+        "platform": attr.label(
+            doc = "The platform to build the target for.",
+        ),
+        "goos": attr.string(
+            default = "auto",
+            values = ["auto"] + {goos: None for goos, _ in GOOS_GOARCH}.keys(),
+        ),
+        "goarch": attr.string(
+            default = "auto",
+            values = ["auto"] + {goarch: None for _, goarch in GOOS_GOARCH}.keys(),
+        ),
+        "sdk_version": attr.string(
+            doc = "The version of the Go SDK to build the target with.",
+        ),
+        "_whitelist_function_transition": attr.label(
+            default = "@bazel_tools//tools/whitelists/function_transition_whitelist",
+        ),
+    },
+    executable = True,
+    doc = """Builds a go_binary for another platform or with another Go SDK.
+
+    See go/core.rst#go_cross_binary for full documentation.""",
+)
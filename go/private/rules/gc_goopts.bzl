@@ -0,0 +1,107 @@
+# Copyright 2020 The Bazel Authors. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#    http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+load(
+    "@io_bazel_rules_go//go/private:providers.bzl",
+    "GoPackageGcGooptsInfo",
+)
+
+def _parse_package_spec(spec):
+    """Parses a package specification like the ones in package_group.
+
+    Returns a struct with the workspace name ("" for the main workspace), the
+    package, whether subpackages match too, and whether the specification
+    excludes packages instead of including them.
+    """
+    exclude = spec.startswith("-")
+    pattern = spec[1:] if exclude else spec
+    workspace = ""
+    if pattern.startswith("@"):
+        workspace, sep, pattern = pattern[1:].partition("//")
+        pattern = sep + pattern
+    if not pattern.startswith("//") or ":" in pattern:
+        fail("invalid package specification {}; want //pkg, //pkg/..., or one of those preceded by @repo or -".format(spec))
+    pkg = pattern[len("//"):]
+    recursive = pkg == "..." or pkg.endswith("/...")
+    if recursive:
+        pkg = pkg[:-len("...")].rstrip("/")
+    return struct(
+        workspace = workspace,
+        package = pkg,
+        recursive = recursive,
+        exclude = exclude,
+    )
+
+def _spec_matches(spec, label):
+    if spec.workspace != label.workspace_name:
+        return False
+    if spec.package == label.package:
+        return True
+    return spec.recursive and (spec.package == "" or label.package.startswith(spec.package + "/"))
+
+def package_gc_goopts(layers, label):
+    """Returns the compiler flags from layers that apply to the package built
+    for label, in the order the layers are listed.
+
+    A layer applies to a package that matches any of its positive package
+    specifications and none of its negative ones."""
+    flags = []
+    for layer in layers:
+        included = False
+        for spec in layer.packages:
+            if _spec_matches(spec, label):
+                if spec.exclude:
+                    included = False
+                    break
+                included = True
+        if included:
+            flags.extend(layer.gc_goopts)
+    return flags
+
+def _go_package_gc_goopts_impl(ctx):
+    layers = []
+    seen = {}
+    for dep in ctx.attr.deps:
+        for layer in dep[GoPackageGcGooptsInfo].layers:
+            if layer.label not in seen:
+                seen[layer.label] = None
+                layers.append(layer)
+    if ctx.attr.packages:
+        layers.append(struct(
+            label = ctx.label,
+            packages = [_parse_package_spec(spec) for spec in ctx.attr.packages],
+            gc_goopts = ctx.attr.gc_goopts,
+        ))
+    return [GoPackageGcGooptsInfo(layers = layers)]
+
+go_package_gc_goopts = rule(
+    implementation = _go_package_gc_goopts_impl,
+    attrs = {
+        "packages": attr.string_list(
+            doc = "Specifications of the packages the flags apply to, as in package_group.",
+        ),
+        "gc_goopts": attr.string_list(
+            doc = "Flags to add to the Go compilation command for matching packages.",
+        ),
+        "deps": attr.label_list(
+            providers = [GoPackageGcGooptsInfo],
+            doc = "Other go_package_gc_goopts targets whose flags are added before these.",
+        ),
+    },
+    doc = """Adds compiler flags to groups of packages, when selected with
+    --@io_bazel_rules_go//go/config:package_gc_goopts or the package_gc_goopts
+    attribute of go_binary and go_test.
+
+    See go/core.rst#go_package_gc_goopts for full documentation.""",
+)
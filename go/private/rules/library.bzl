@@ -17,6 +17,7 @@ load(
     "asm_exts",
     "cgo_exts",
     "go_exts",
+    "nogo_validation_outputs",
 )
 load(
     "@io_bazel_rules_go//go/private:context.bzl",
@@ -27,26 +28,39 @@ load(
     "GoLibrary",
     "INFERRED_PATH",
 )
+load(
+    "@io_bazel_rules_go//go/private:rules/source.bzl",
+    "package_metadata",
+)
 
 def _go_library_impl(ctx):
     """Implements the go_library() rule."""
     go = go_context(ctx)
     if go.pathtype == INFERRED_PATH:
         fail("importpath must be specified in this library or one of its embedded libraries")
-    library = go.new_library(go)
+    library = go.new_library(go, check_unused_deps = True)
     source = go.library_to_source(go, ctx.attr, library, ctx.coverage_instrumented())
     archive = go.archive(go, source)
+    metadata = package_metadata(go, source)
 
     return [
         library,
         source,
         archive,
+        metadata,
         DefaultInfo(
-            files = depset([archive.data.file]),
+            files = depset([archive.data.file] + nogo_validation_outputs(go, archive)),
         ),
         OutputGroupInfo(
             cgo_exports = archive.cgo_exports,
             compilation_outputs = [archive.data.file],
+            nogo_sarif = [archive.data.nogo_sarif] if archive.data.nogo_sarif else [],
+            nogo_findings = [archive.data.nogo_findings] if archive.data.nogo_findings else [],
+            embedcfg = [archive.data.embedcfg] if archive.data.embedcfg else [],
+            build_constraints = [archive.data.build_constraints] if archive.data.build_constraints else [],
+            unused_deps = [archive.data.unused_deps] if archive.data.unused_deps else [],
+            package_metadata = [metadata.metadata],
+            _validation = [archive.data.nogo_validation] if archive.data.nogo_validation else [],
         ),
     ]
 
@@ -55,13 +69,15 @@ go_library = rule(
     attrs = {
         "data": attr.label_list(allow_files = True),
         "srcs": attr.label_list(allow_files = go_exts + asm_exts + cgo_exts),
+        "embedsrcs": attr.label_list(allow_files = True),
         "deps": attr.label_list(providers = [GoLibrary]),
         "importpath": attr.string(),
         "importmap": attr.string(),
-        "importpath_aliases": attr.string_list(),  # experimental, undocumented
+        "importpath_aliases": attr.string_list(),
         "embed": attr.label_list(providers = [GoLibrary]),
         "gc_goopts": attr.string_list(),
         "x_defs": attr.string_dict(),
+        "strict_build_tags": attr.bool(),
         "cgo": attr.bool(),
         "cdeps": attr.label_list(),
         "cppopts": attr.string_list(),
@@ -0,0 +1,103 @@
+# Copyright 2020 The Bazel Authors. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#    http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+load(
+    "@io_bazel_rules_go//go/private:context.bzl",
+    "go_context",
+)
+load(
+    ":rules/transition.bzl",
+    "go_multiarch_transition",
+    "multiarch_platform_key",
+)
+
+def _go_multiarch_binary_impl(ctx):
+    binaries = []
+    for platform in ctx.attr.platforms:
+        key = multiarch_platform_key(platform)
+        target = ctx.split_attr.target[key]
+        binary = target[DefaultInfo].files_to_run.executable
+        if not binary:
+            fail("{} is not executable".format(target.label), attr = "target")
+        binaries.append((key, binary))
+
+    if ctx.attr.format == "directory":
+        outs = []
+        for key, binary in binaries:
+            out = ctx.actions.declare_file("{}/{}/{}".format(ctx.label.name, key, binary.basename))
+            ctx.actions.symlink(
+                output = out,
+                target_file = binary,
+                is_executable = True,
+            )
+            outs.append(out)
+        return [DefaultInfo(files = depset(outs))]
+
+    if ctx.attr.format == "universal":
+        for key, _ in binaries:
+            if not key.startswith("darwin_"):
+                fail("universal binaries can only be built for darwin; got {}".format(key), attr = "platforms")
+        out = ctx.actions.declare_file(ctx.label.name)
+    else:
+        out = ctx.actions.declare_file(ctx.label.name + ".tar")
+
+    go = go_context(ctx)
+    args = go.builder_args(go, "multiarch")
+    args.add("-format", ctx.attr.format)
+    args.add_all(
+        ["{}/{}={}".format(key, binary.basename, binary.path) for key, binary in binaries],
+        before_each = "-binary",
+    )
+    args.add("-o", out)
+    go.actions.run(
+        inputs = [binary for _, binary in binaries],
+        outputs = [out],
+        mnemonic = "GoMultiarch",
+        executable = go.toolchain._builder,
+        arguments = [args],
+        env = go.env,
+    )
+    return [DefaultInfo(files = depset([out]))]
+
+go_multiarch_binary = rule(
+    implementation = _go_multiarch_binary_impl,
+    attrs = {
+        "target": attr.label(
+            mandatory = True,
+            cfg = go_multiarch_transition,
+            doc = "The go_binary to build for each platform.",
+        ),
+        "platforms": attr.string_list(
+            mandatory = True,
+            doc = "The GOOS_GOARCH pairs to build the target for, optionally with a _cgo suffix.",
+        ),
+        "format": attr.string(
+            default = "directory",
+            values = ["directory", "tar", "universal"],
+            doc = "How the binaries are combined.",
+        ),
+        "sdk_version": attr.string(
+            doc = "The version of the Go SDK to build the target with.",
+        ),
+        "_go_config": attr.label(default = "//:go_config"),
+        "_cgo_context_data": attr.label(default = "//:cgo_context_data_proxy"),
+        "_whitelist_function_transition": attr.label(
+            default = "@bazel_tools//tools/whitelists/function_transition_whitelist",
+        ),
+    },
+    toolchains = ["@io_bazel_rules_go//go:toolchain"],
+    doc = """Builds a go_binary for several platforms and combines the results.
+
+    See go/core.rst#go_multiarch_binary for full documentation.""",
+)
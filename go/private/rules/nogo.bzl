@@ -21,6 +21,9 @@ load(
     "EXPORT_PATH",
     "GoArchive",
     "GoLibrary",
+    "GoSource",
+    "NogoAnalyzerBundleInfo",
+    "NogoInfo",
     "get_archive",
 )
 
@@ -38,11 +41,22 @@ def _nogo_impl(ctx):
     nogo_args.add("-output", nogo_main)
     nogo_inputs = []
     analyzer_archives = [get_archive(dep) for dep in ctx.attr.deps]
-    analyzer_importpaths = [archive.data.importpath for archive in analyzer_archives]
-    nogo_args.add_all(analyzer_importpaths, before_each = "-analyzer_importpath")
+    for dep, archive in zip(ctx.attr.deps, analyzer_archives):
+        if NogoAnalyzerBundleInfo in dep:
+            bundle = dep[NogoAnalyzerBundleInfo]
+            nogo_args.add("-analyzer_bundle", "{}={}={}".format(
+                archive.data.importpath,
+                bundle.variable,
+                ",".join(bundle.analyzers),
+            ))
+        else:
+            nogo_args.add("-analyzer_importpath", archive.data.importpath)
     if ctx.file.config:
         nogo_args.add("-config", ctx.file.config)
         nogo_inputs.append(ctx.file.config)
+    if ctx.file.baseline:
+        nogo_args.add("-baseline", ctx.file.baseline)
+        nogo_inputs.append(ctx.file.baseline)
     ctx.actions.run(
         inputs = nogo_inputs,
         outputs = [nogo_main],
@@ -73,11 +87,14 @@ def _nogo_impl(ctx):
         name = ctx.label.name,
         source = nogo_source,
     )
-    return [DefaultInfo(
-        files = depset([executable]),
-        runfiles = nogo_archive.runfiles,
-        executable = executable,
-    )]
+    return [
+        DefaultInfo(
+            files = depset([executable]),
+            runfiles = nogo_archive.runfiles,
+            executable = executable,
+        ),
+        NogoInfo(analyze_tests = ctx.attr.analyze_tests),
+    ]
 
 nogo = rule(
     implementation = _nogo_impl,
@@ -88,6 +105,10 @@ nogo = rule(
         "config": attr.label(
             allow_single_file = True,
         ),
+        "baseline": attr.label(
+            allow_single_file = True,
+        ),
+        "analyze_tests": attr.bool(default = True),
         "_nogo_srcs": attr.label(
             default = "@io_bazel_rules_go//go/tools/builders:nogo_srcs",
         ),
@@ -98,14 +119,73 @@ nogo = rule(
     toolchains = ["@io_bazel_rules_go//go:toolchain"],
 )
 
+# DEFAULT_VET_ANALYZERS is the list of analyzers run by "go vet" in Go 1.14,
+# the default version of Go (see DEFAULT_VERSION in go/private/sdk_list.bzl).
+# It doesn't depend on the SDK in use, and it's updated together with the
+# default version. cgocall is left out: it checks the raw sources of cgo
+# packages, but nogo analyzes the files cgo generates from them.
+DEFAULT_VET_ANALYZERS = [
+    "@org_golang_x_tools//go/analysis/passes/asmdecl:go_tool_library",
+    "@org_golang_x_tools//go/analysis/passes/assign:go_tool_library",
+    "@org_golang_x_tools//go/analysis/passes/atomic:go_tool_library",
+    "@org_golang_x_tools//go/analysis/passes/bools:go_tool_library",
+    "@org_golang_x_tools//go/analysis/passes/buildtag:go_tool_library",
+    "@org_golang_x_tools//go/analysis/passes/composite:go_tool_library",
+    "@org_golang_x_tools//go/analysis/passes/copylock:go_tool_library",
+    "@org_golang_x_tools//go/analysis/passes/errorsas:go_tool_library",
+    "@org_golang_x_tools//go/analysis/passes/httpresponse:go_tool_library",
+    "@org_golang_x_tools//go/analysis/passes/loopclosure:go_tool_library",
+    "@org_golang_x_tools//go/analysis/passes/lostcancel:go_tool_library",
+    "@org_golang_x_tools//go/analysis/passes/nilfunc:go_tool_library",
+    "@org_golang_x_tools//go/analysis/passes/printf:go_tool_library",
+    "@org_golang_x_tools//go/analysis/passes/shift:go_tool_library",
+    "@org_golang_x_tools//go/analysis/passes/stdmethods:go_tool_library",
+    "@org_golang_x_tools//go/analysis/passes/structtag:go_tool_library",
+    "@org_golang_x_tools//go/analysis/passes/tests:go_tool_library",
+    "@org_golang_x_tools//go/analysis/passes/unmarshal:go_tool_library",
+    "@org_golang_x_tools//go/analysis/passes/unreachable:go_tool_library",
+    "@org_golang_x_tools//go/analysis/passes/unsafeptr:go_tool_library",
+    "@org_golang_x_tools//go/analysis/passes/unusedresult:go_tool_library",
+]
+
+def _nogo_analyzer_bundle_impl(ctx):
+    for name in ctx.attr.analyzers:
+        if not name or "," in name or "=" in name:
+            fail("invalid analyzer name: {}".format(repr(name)))
+    library = ctx.attr.library
+    return [
+        library[GoLibrary],
+        library[GoSource],
+        library[GoArchive],
+        NogoAnalyzerBundleInfo(
+            variable = ctx.attr.variable,
+            analyzers = ctx.attr.analyzers,
+        ),
+    ]
+
+nogo_analyzer_bundle = rule(
+    implementation = _nogo_analyzer_bundle_impl,
+    attrs = {
+        "library": attr.label(
+            mandatory = True,
+            providers = [GoArchive],
+        ),
+        "variable": attr.string(default = "Analyzers"),
+        "analyzers": attr.string_list(),
+    },
+    doc = """Lets nogo run several analyzers exported by one library.
+
+The library exports a variable that lists its analyzers, like the analyzer
+suites of staticcheck. The nogo rule accepts this rule in its deps.""",
+)
+
 def nogo_wrapper(**kwargs):
     if kwargs.get("vet"):
-        kwargs["deps"] = kwargs.get("deps", []) + [
-            "@org_golang_x_tools//go/analysis/passes/atomic:go_tool_library",
-            "@org_golang_x_tools//go/analysis/passes/bools:go_tool_library",
-            "@org_golang_x_tools//go/analysis/passes/buildtag:go_tool_library",
-            "@org_golang_x_tools//go/analysis/passes/nilfunc:go_tool_library",
-            "@org_golang_x_tools//go/analysis/passes/printf:go_tool_library",
-        ]
-        kwargs = {k: v for k, v in kwargs.items() if k != "vet"}
+        deps = kwargs.get("deps", [])
+        if type(deps) == "list":
+            # Analyzers that are already listed would be duplicate labels.
+            kwargs["deps"] = deps + [dep for dep in DEFAULT_VET_ANALYZERS if dep not in deps]
+        else:
+            kwargs["deps"] = deps + DEFAULT_VET_ANALYZERS
+    kwargs = {k: v for k, v in kwargs.items() if k != "vet"}
     nogo(**kwargs)
@@ -0,0 +1,104 @@
+# Copyright 2020 The Bazel Authors. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#    http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+load(
+    ":providers.bzl",
+    "GoPlugin",
+)
+
+# The Go runtime refuses to load a plugin if any package linked into both the
+# plugin and the binary loading it was built differently ("plugin was built
+# with a different version of package"). The plugin and the binary are usually
+# built in different configurations (plugins are compiled with -dynlink), so
+# their archives are never the same files. Instead, we compare the settings
+# that affect the standard library and the label and sources of each package
+# they have in common, and report mismatches during analysis.
+
+def _plugin_config(go):
+    """Returns a string describing the settings a binary and the plugins it
+    loads must agree on."""
+    return "goos={} goarch={} race={} msan={} pure={} tags={} sdk={}".format(
+        go.mode.goos,
+        go.mode.goarch,
+        go.mode.race,
+        go.mode.msan,
+        go.mode.pure,
+        ",".join(sorted({t: None for t in go.mode.tags}.keys())),
+        go.sdk.root_file.dirname,
+    )
+
+def _package_fingerprints(archive):
+    """Returns a dict mapping the package path of each dependency of archive
+    to a string identifying how it was built."""
+    packages = {}
+    for d in archive.transitive.to_list():
+        if d.importmap == archive.data.importmap:
+            continue
+        packages[d.importmap] = "{} [{}]".format(
+            d.label,
+            " ".join(sorted([f.short_path for f in d.orig_srcs])),
+        )
+    return packages
+
+def go_plugin_info(go, archive, plugin):
+    """Returns a GoPlugin provider for a go_binary with linkmode = "plugin"."""
+    return GoPlugin(
+        plugin = plugin,
+        importpath = archive.data.importpath,
+        label = go._ctx.label,
+        config = _plugin_config(go),
+        packages = _package_fingerprints(archive),
+    )
+
+def check_plugins(go, archive, plugins):
+    """Fails if the binary linked from archive can't load any of plugins.
+
+    Args:
+      go: the go context of the binary.
+      archive: the GoArchive of the binary's main package.
+      plugins: targets with the GoPlugin provider.
+
+    Returns:
+      A list of the plugin files, to be added to the binary's runfiles.
+    """
+    if not plugins:
+        return []
+    if go.mode.pure:
+        fail("{}: binaries that load plugins must be built with cgo; set pure = \"off\"".format(go._ctx.label))
+    config = _plugin_config(go)
+    packages = None
+    files = []
+    for target in plugins:
+        plugin = target[GoPlugin]
+        if plugin.config != config:
+            fail("{}: plugin {} is built in a different configuration, so it can't be loaded:\n  binary: {}\n  plugin: {}".format(
+                go._ctx.label,
+                plugin.label,
+                config,
+                plugin.config,
+            ))
+        if packages == None:
+            packages = _package_fingerprints(archive)
+        for importmap, fingerprint in plugin.packages.items():
+            binary_fingerprint = packages.get(importmap)
+            if binary_fingerprint != None and binary_fingerprint != fingerprint:
+                fail("{}: plugin {} is built with a different version of package {}, so it can't be loaded:\n  binary: {}\n  plugin: {}".format(
+                    go._ctx.label,
+                    plugin.label,
+                    importmap,
+                    binary_fingerprint,
+                    fingerprint,
+                ))
+        files.append(plugin.plugin)
+    return files
@@ -25,6 +25,7 @@ def _go_sdk_impl(ctx):
     return [GoSDK(
         goos = ctx.attr.goos,
         goarch = ctx.attr.goarch,
+        version = ctx.attr.version,
         root_file = ctx.file.root_file,
         package_list = package_list,
         libs = ctx.files.libs,
@@ -32,6 +33,7 @@ def _go_sdk_impl(ctx):
         srcs = ctx.files.srcs,
         tools = ctx.files.tools,
         go = ctx.executable.go,
+        wasm_exec = ctx.files.wasm_exec,
     )]
 
 go_sdk = rule(
@@ -45,6 +47,9 @@ go_sdk = rule(
             mandatory = True,
             doc = "The host architecture the SDK was built for",
         ),
+        "version": attr.string(
+            doc = "The Go version of the SDK, like \"1.14.2\", if known",
+        ),
         "root_file": attr.label(
             mandatory = True,
             allow_single_file = True,
@@ -82,6 +87,12 @@ go_sdk = rule(
             cfg = "exec",
             doc = "The go binary",
         ),
+        "wasm_exec": attr.label_list(
+            allow_files = [".js"],
+            doc = ("wasm_exec.js and related files from misc/wasm or " +
+                   "lib/wasm, used to run programs built for js/wasm " +
+                   "with Node.js"),
+        ),
     },
     doc = ("Collects information about a Go SDK. The SDK must have a normal " +
            "GOROOT directory structure."),
@@ -19,19 +19,52 @@ load(
 load(
     "@io_bazel_rules_go//go/private:providers.bzl",
     "GoLibrary",
+    "GoPackageMetadata",
 )
+load(
+    "@io_bazel_rules_go//go/private:actions/compilepkg.bzl",
+    "emit_package_metadata",
+)
+
+def package_metadata(go, source):
+    """Returns a GoPackageMetadata provider describing the .go files of source.
+
+    The metadata file is only written when a rule or output group requests it.
+    """
+    srcs = [f for f in source.srcs if f.extension == "go"]
+    generated_srcs = [f for f in source.generated_srcs if f.extension == "go"]
+    metadata = go.declare_file(go, ext = ".pkgmetadata.json")
+    emit_package_metadata(
+        go,
+        sources = srcs,
+        generated = generated_srcs,
+        importpath = source.library.importpath,
+        out = metadata,
+    )
+    return GoPackageMetadata(
+        label = source.library.label,
+        importpath = source.library.importpath,
+        srcs = srcs,
+        generated_srcs = generated_srcs,
+        metadata = metadata,
+    )
 
 def _go_source_impl(ctx):
     """Implements the go_source() rule."""
     go = go_context(ctx)
     library = go.new_library(go)
     source = go.library_to_source(go, ctx.attr, library, ctx.coverage_instrumented())
+    metadata = package_metadata(go, source)
     return [
         library,
         source,
+        metadata,
         DefaultInfo(
             files = depset(source.srcs),
         ),
+        OutputGroupInfo(
+            package_metadata = [metadata.metadata],
+        ),
     ]
 
 go_source = rule(
@@ -39,6 +72,7 @@ go_source = rule(
     attrs = {
         "data": attr.label_list(allow_files = True),
         "srcs": attr.label_list(allow_files = True),
+        "embedsrcs": attr.label_list(allow_files = True),
         "deps": attr.label_list(providers = [GoLibrary]),
         "embed": attr.label_list(providers = [GoLibrary]),
         "gc_goopts": attr.string_list(),
@@ -27,12 +27,18 @@ load(
 load(
     ":rules/binary.bzl",
     "gc_linkopts",
+    "wasm_runner",
 )
 load(
     ":providers.bzl",
     "GoLibrary",
+    "GoPlugin",
     "INFERRED_PATH",
 )
+load(
+    ":rules/plugin.bzl",
+    "check_plugins",
+)
 load(
     ":rules/transition.bzl",
     "go_transition_rule",
@@ -41,6 +47,13 @@ load(
     ":mode.bzl",
     "LINKMODE_NORMAL",
 )
+load(
+    "@bazel_skylib//lib:shell.bzl",
+    "shell",
+)
+
+# Hermeticity checks that may be listed in the sandbox_checks attribute.
+_SANDBOX_CHECKS = ("network", "filesystem")
 
 def _testmain_library_to_source(go, attr, source, merge):
     source["deps"] = source["deps"] + [attr.library]
@@ -68,6 +81,7 @@ def _go_test_impl(ctx):
     )
     external_source = go.library_to_source(go, struct(
         srcs = [struct(files = go_srcs)],
+        embedsrcs = [struct(files = internal_source.embedsrcs)],
         deps = internal_archive.direct + [internal_archive],
         x_defs = ctx.attr.x_defs,
     ), external_library, ctx.coverage_instrumented())
@@ -87,8 +101,13 @@ def _go_test_impl(ctx):
     arguments = go.builder_args(go, "gentestmain")
     arguments.add("-rundir", run_dir)
     arguments.add("-output", main_go)
+    covdata = None
     if ctx.configuration.coverage_enabled:
-        arguments.add("-coverage")
+        if go.cover_format == "gocoverdir":
+            covdata = _covdata_tool(go)
+            arguments.add("-covdata", covdata.short_path)
+        else:
+            arguments.add("-coverage")
     arguments.add(
         # the l is the alias for the package under test, the l_test must be the
         # same with the test suffix
@@ -100,6 +119,12 @@ def _go_test_impl(ctx):
         "l_test=" + external_source.library.importpath,
     )
     arguments.add("-pkgname", internal_source.library.importpath)
+    if ctx.attr.fuzz_corpus and not ctx.label.workspace_root:
+        arguments.add("-fuzzsrcdir", ctx.label.package or ".")
+        arguments.add("-fuzzcorpus", ctx.attr.fuzz_corpus)
+    if ctx.file.benchmark_baseline:
+        arguments.add("-benchbaseline", ctx.file.benchmark_baseline.short_path)
+        arguments.add("-benchthreshold", str(ctx.attr.benchmark_threshold))
     arguments.add_all(go_srcs, before_each = "-src", format_each = "l=%s")
     ctx.actions.run(
         inputs = go_srcs,
@@ -121,13 +146,16 @@ def _go_test_impl(ctx):
         importpath_aliases = (),
         pathtype = INFERRED_PATH,
         is_main = True,
+        is_test_main = True,
         resolve = None,
     )
     test_deps = external_archive.direct + [external_archive]
-    if ctx.configuration.coverage_enabled:
+    if ctx.configuration.coverage_enabled and not covdata:
         test_deps.append(go.coverdata)
+    sandbox_checks = _sandbox_checks(ctx)
+    network_srcs = ctx.files._testmain_network_srcs if "network" in sandbox_checks else []
     test_source = go.library_to_source(go, struct(
-        srcs = [struct(files = [main_go] + ctx.files._testmain_additional_srcs)],
+        srcs = [struct(files = [main_go] + ctx.files._testmain_additional_srcs + network_srcs)],
         deps = test_deps,
     ), test_library, False)
     test_archive, executable, runfiles = go.binary(
@@ -139,6 +167,61 @@ def _go_test_impl(ctx):
         version_file = ctx.version_file,
         info_file = ctx.info_file,
     )
+    if ctx.file.benchmark_baseline:
+        runfiles = runfiles.merge(ctx.runfiles(files = [ctx.file.benchmark_baseline]))
+    if covdata:
+        runfiles = runfiles.merge(ctx.runfiles(files = [covdata]))
+    plugin_files = check_plugins(go, test_archive, ctx.attr.plugins)
+    if plugin_files:
+        runfiles = runfiles.merge(ctx.runfiles(files = plugin_files))
+    run_executable = executable
+    wrapper = ctx.attr.test_wrapper
+
+    # The host can't execute WebAssembly modules, so they're run with a
+    # WebAssembly runtime, unless the test has its own wrapper.
+    runner, wasm_exec = wasm_runner(go)
+    if runner:
+        wrapper = wrapper or runner
+    if wasm_exec:
+        runfiles = runfiles.merge(ctx.runfiles(files = go.sdk.wasm_exec))
+    if wrapper:
+        run_executable = _emit_wrapper_launcher(go, executable, wrapper[DefaultInfo].files_to_run.executable, wasm_exec)
+        wrapper_info = wrapper[DefaultInfo]
+        runfiles = runfiles.merge(ctx.runfiles(files = [executable]))
+        runfiles = runfiles.merge(wrapper_info.default_runfiles)
+
+    env = {
+        k: ctx.expand_location(ctx.expand_make_variables("env", v, {}), ctx.attr.data)
+        for k, v in ctx.attr.env.items()
+    }
+    if ctx.attr.json_events and "GO_TEST_JSON" not in env:
+        env["GO_TEST_JSON"] = "1"
+    reruns = _test_reruns(ctx)
+    if reruns and "GO_TEST_RERUNS" not in env:
+        env["GO_TEST_RERUNS"] = str(reruns)
+    if ctx.attr.race_stress < 0:
+        fail("race_stress must not be negative")
+    if ctx.attr.race_stress:
+        if not go.mode.race:
+            fail("{}: race_stress requires the race detector; set race = \"on\"".format(ctx.label))
+        env["GO_TEST_RACE_STRESS"] = str(ctx.attr.race_stress)
+    if ctx.attr.test_timeout and "GO_TEST_TIMEOUT" not in env:
+        env["GO_TEST_TIMEOUT"] = ctx.attr.test_timeout
+    if go.godebug and "GODEBUG" not in env:
+        env["GODEBUG"] = ",".join(go.godebug)
+    if sandbox_checks and "GO_TEST_SANDBOX_CHECKS" not in env:
+        env["GO_TEST_SANDBOX_CHECKS"] = ",".join(sandbox_checks)
+    if ctx.attr.env_inherit:
+        # inherited_environment is not supported by older versions of Bazel,
+        # so it's only passed when needed.
+        test_environment = testing.TestEnvironment(env, inherited_environment = ctx.attr.env_inherit)
+    else:
+        test_environment = testing.TestEnvironment(env)
+    providers = [test_environment]
+    if "network" in sandbox_checks:
+        # Sandboxes that support it also block the test's network access
+        # outside Go, for example from subprocesses or raw connections.
+        providers.append(testing.ExecutionInfo({"block-network": ""}))
 
     # Bazel only looks for coverage data if the test target has an
     # InstrumentedFilesProvider. If the provider is found and at least one
@@ -150,10 +233,35 @@ def _go_test_impl(ctx):
         DefaultInfo(
             files = depset([executable]),
             runfiles = runfiles,
-            executable = executable,
+            executable = run_executable,
         ),
         OutputGroupInfo(
             compilation_outputs = [internal_archive.data.file],
+            nogo_sarif = [
+                a.data.nogo_sarif
+                for a in (internal_archive, external_archive)
+                if a.data.nogo_sarif
+            ],
+            nogo_findings = [
+                a.data.nogo_findings
+                for a in (internal_archive, external_archive)
+                if a.data.nogo_findings
+            ],
+            embedcfg = [
+                a.data.embedcfg
+                for a in (internal_archive, external_archive)
+                if a.data.embedcfg
+            ],
+            build_constraints = [
+                a.data.build_constraints
+                for a in (internal_archive, external_archive)
+                if a.data.build_constraints
+            ],
+            _validation = [
+                a.data.nogo_validation
+                for a in (internal_archive, external_archive, test_archive)
+                if a.data.nogo_validation
+            ],
         ),
         coverage_common.instrumented_files_info(
             ctx,
@@ -161,20 +269,105 @@ def _go_test_impl(ctx):
             dependency_attributes = ["deps", "embed"],
             extensions = ["go"],
         ),
-    ]
+    ] + providers
+
+def _covdata_tool(go):
+    """Returns the SDK's covdata tool, which the test wrapper uses to convert
+    coverage data written to GOCOVERDIR into a coverage profile."""
+    for f in go.sdk_tools:
+        if f.basename in ("covdata", "covdata.exe"):
+            return f
+    fail("{}: --@io_bazel_rules_go//go/config:cover_format=gocoverdir requires a Go SDK with the covdata tool (Go 1.20 or later)".format(go._ctx.label))
+
+def _emit_wrapper_launcher(go, executable, wrapper, wasm_exec = None):
+    """Declares a script that executes wrapper, the test_wrapper attribute or
+    the runner for WebAssembly modules, with the path of the test binary,
+    followed by the test's arguments. wasm_exec is the SDK's wasm_exec.js,
+    passed to the wrapper for js/wasm.
+
+    Bazel executes the script instead of the binary, so the wrapper decides
+    how the binary is run and the test's result is the wrapper's exit code.
+    Both are found in the test's runfiles.
+    """
+    ctx = go._ctx
+    binary_path = ctx.workspace_name + "/" + executable.short_path
+    wrapper_path = ctx.workspace_name + "/" + wrapper.short_path
+    if go.mode.goos == "windows":
+        launcher = go.declare_file(go, ext = ".wrapper.bat")
+        content = "\n".join([
+            "@echo off",
+            "setlocal",
+            "set \"runfiles=%TEST_SRCDIR%\"",
+            "if \"%runfiles%\" == \"\" set \"runfiles=%~f0.runfiles\"",
+            "\"%runfiles%\\{}\" \"%runfiles%\\{}\" %*".format(
+                wrapper_path.replace("/", "\\"),
+                binary_path.replace("/", "\\"),
+            ),
+            "exit /b %ERRORLEVEL%",
+        ])
+    else:
+        launcher = go.declare_file(go, ext = ".wrapper")
+        lines = [
+            "#!/bin/sh",
+            "runfiles=\"${TEST_SRCDIR:-$0.runfiles}\"",
+            "case \"$runfiles\" in",
+            "  /*) ;;",
+            "  *) runfiles=\"$PWD/$runfiles\" ;;",
+            "esac",
+        ]
+        if wasm_exec:
+            lines.append("export GO_JS_WASM_EXEC=\"$runfiles\"/{}".format(
+                shell.quote(ctx.workspace_name + "/" + wasm_exec.short_path),
+            ))
+        lines.append("exec \"$runfiles\"/{} \"$runfiles\"/{} \"$@\"".format(
+            shell.quote(wrapper_path),
+            shell.quote(binary_path),
+        ))
+        content = "\n".join(lines)
+    go.actions.write(launcher, content + "\n", is_executable = True)
+    return launcher
+
+def _sandbox_checks(ctx):
+    """Returns the hermeticity checks listed in the sandbox_checks attribute,
+    after checking they're known."""
+    for check in ctx.attr.sandbox_checks:
+        if check not in _SANDBOX_CHECKS:
+            fail("unknown sandbox check {}; want one of {}".format(repr(check), ", ".join(_SANDBOX_CHECKS)), attr = "sandbox_checks")
+    return ctx.attr.sandbox_checks
+
+def _test_reruns(ctx):
+    """Returns the number of times the test wrapper should rerun failed tests.
+
+    The reruns attribute takes precedence. If it's not set, the value of
+    --define=gotest_reruns=N is used, so reruns can be enabled for all tests
+    in a build, for example when triaging flaky tests in CI.
+    """
+    if ctx.attr.reruns >= 0:
+        return ctx.attr.reruns
+    value = ctx.var.get("gotest_reruns", "")
+    if not value:
+        return 0
+    if not value.isdigit():
+        fail("--define=gotest_reruns must be a non-negative integer; got {}".format(repr(value)))
+    return int(value)
 
 _go_test_kwargs = {
     "implementation": _go_test_impl,
     "attrs": {
         "data": attr.label_list(allow_files = True),
+        "env": attr.string_dict(),
+        "env_inherit": attr.string_list(),
         "srcs": attr.label_list(allow_files = go_exts + asm_exts + cgo_exts),
+        "embedsrcs": attr.label_list(allow_files = True),
         "deps": attr.label_list(providers = [GoLibrary]),
         "embed": attr.label_list(providers = [GoLibrary]),
+        "plugins": attr.label_list(providers = [GoPlugin]),
         "importpath": attr.string(),
         "gc_goopts": attr.string_list(),
         "gc_linkopts": attr.string_list(),
         "rundir": attr.string(),
         "x_defs": attr.string_dict(),
+        "strict_build_tags": attr.bool(),
         "linkmode": attr.string(default = LINKMODE_NORMAL),
         "cgo": attr.bool(),
         "cdeps": attr.label_list(),
@@ -182,7 +375,33 @@ _go_test_kwargs = {
         "copts": attr.string_list(),
         "cxxopts": attr.string_list(),
         "clinkopts": attr.string_list(),
+        "fuzz_corpus": attr.string(),
+        "benchmark_baseline": attr.label(allow_single_file = True),
+        "benchmark_threshold": attr.int(default = 10),
+        "reruns": attr.int(default = -1),
+        "json_events": attr.bool(),
+        "race_stress": attr.int(),
+        "test_timeout": attr.string(),
+        "sandbox_checks": attr.string_list(),
+        "test_wrapper": attr.label(
+            executable = True,
+            cfg = "target",
+        ),
+        "_js_wasm_exec": attr.label(
+            default = "//go/config:js_wasm_exec",
+            executable = True,
+            cfg = "host",
+        ),
+        "_wasip1_exec": attr.label(
+            default = "//go/config:wasip1_exec",
+            executable = True,
+            cfg = "host",
+        ),
         "_go_context_data": attr.label(default = "//:go_context_data"),
+        "_testmain_network_srcs": attr.label_list(
+            default = ["@io_bazel_rules_go//go/tools/testwrapper:network_srcs"],
+            allow_files = go_exts,
+        ),
         "_testmain_additional_srcs": attr.label_list(
             default = ["@io_bazel_rules_go//go/tools/testwrapper:srcs"],
             allow_files = go_exts,
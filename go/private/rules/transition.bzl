@@ -21,6 +21,10 @@ load(
     "CGO_GOOS_GOARCH",
     "GOOS_GOARCH",
 )
+load(
+    ":providers.bzl",
+    "GoPackageGcGooptsInfo",
+)
 load(
     "@io_bazel_rules_go_name_hack//:def.bzl",
     "IS_RULES_GO",
@@ -54,7 +58,7 @@ def go_transition_wrapper(kind, transition_kind, name, **kwargs):
     regular rule. This prevents targets from being rebuilt for an alternative
     configuration identical to the default configuration.
     """
-    transition_keys = ("goos", "goarch", "pure", "static", "msan", "race", "gotags", "linkmode")
+    transition_keys = ("goos", "goarch", "pure", "static", "msan", "race", "gotags", "linkmode", "package_gc_goopts", "sdk_version")
     need_transition = any([key in kwargs for key in transition_keys])
     if need_transition:
         transition_kind(name = name, **kwargs)
@@ -94,6 +98,10 @@ def go_transition_rule(**kwargs):
             default = "auto",
             values = ["auto"] + LINKMODES,
         ),
+        "package_gc_goopts": attr.label(
+            providers = [GoPackageGcGooptsInfo],
+        ),
+        "sdk_version": attr.string(),
         "_whitelist_function_transition": attr.label(
             default = "@bazel_tools//tools/whitelists/function_transition_whitelist",
         ),
@@ -108,17 +116,7 @@ def _go_transition_impl(settings, attr):
     pure = getattr(attr, "pure", "auto")
     _check_ternary("pure", pure)
     if goos != "auto" or goarch != "auto":
-        if goos == "auto":
-            fail("goos must be set if goarch is set")
-        if goarch == "auto":
-            fail("goarch must be set if goos is set")
-        if (goos, goarch) not in GOOS_GOARCH:
-            fail("invalid goos, goarch pair: {}, {}".format(goos, goarch))
-        cgo = pure == "off"
-        if cgo and (goos, goarch) not in CGO_GOOS_GOARCH:
-            fail('pure is "off" but cgo is not supported on {} {}'.format(goos, goarch))
-        platform = "@io_bazel_rules_go//go/toolchain:{}_{}{}".format(goos, goarch, "_cgo" if cgo else "")
-        settings["//command_line_option:platforms"] = platform
+        settings["//command_line_option:platforms"] = _platform(goos, goarch, pure == "off")
     if pure != "auto":
         pure_label = _filter_transition_label("@io_bazel_rules_go//go/config:pure")
         settings[pure_label] = pure == "on"
@@ -139,6 +137,16 @@ def _go_transition_impl(settings, attr):
         linkmode_label = _filter_transition_label("@io_bazel_rules_go//go/config:linkmode")
         settings[linkmode_label] = linkmode
 
+    package_gc_goopts = getattr(attr, "package_gc_goopts", None)
+    if package_gc_goopts:
+        package_gc_goopts_label = _filter_transition_label("@io_bazel_rules_go//go/config:package_gc_goopts")
+        settings[package_gc_goopts_label] = str(package_gc_goopts)
+
+    sdk_version = getattr(attr, "sdk_version", "")
+    if sdk_version:
+        sdk_version_label = _filter_transition_label("@io_bazel_rules_go//go/toolchain:sdk_version")
+        settings[sdk_version_label] = sdk_version
+
     return settings
 
 go_transition = transition(
@@ -151,6 +159,8 @@ go_transition = transition(
         "@io_bazel_rules_go//go/config:pure",
         "@io_bazel_rules_go//go/config:tags",
         "@io_bazel_rules_go//go/config:linkmode",
+        "@io_bazel_rules_go//go/config:package_gc_goopts",
+        "@io_bazel_rules_go//go/toolchain:sdk_version",
     ]],
     outputs = [_filter_transition_label(label) for label in [
         "//command_line_option:platforms",
@@ -160,9 +170,92 @@ go_transition = transition(
         "@io_bazel_rules_go//go/config:pure",
         "@io_bazel_rules_go//go/config:tags",
         "@io_bazel_rules_go//go/config:linkmode",
+        "@io_bazel_rules_go//go/config:package_gc_goopts",
+        "@io_bazel_rules_go//go/toolchain:sdk_version",
     ]],
 )
 
+def _go_cross_transition_impl(settings, attr):
+    settings = dict(settings)
+    if attr.platform:
+        if attr.goos != "auto" or attr.goarch != "auto":
+            fail("goos and goarch must not be set if platform is set")
+        settings["//command_line_option:platforms"] = str(attr.platform)
+    elif attr.goos != "auto" or attr.goarch != "auto":
+        settings["//command_line_option:platforms"] = _platform(attr.goos, attr.goarch, False)
+    if attr.sdk_version:
+        sdk_version_label = _filter_transition_label("@io_bazel_rules_go//go/toolchain:sdk_version")
+        settings[sdk_version_label] = attr.sdk_version
+    return settings
+
+# go_cross_transition is applied to the target of go_cross_binary. Unlike
+# go_transition, it only changes the platform and the Go SDK, so the target
+# is built with its own mode attributes.
+go_cross_transition = transition(
+    implementation = _go_cross_transition_impl,
+    inputs = [_filter_transition_label(label) for label in [
+        "//command_line_option:platforms",
+        "@io_bazel_rules_go//go/toolchain:sdk_version",
+    ]],
+    outputs = [_filter_transition_label(label) for label in [
+        "//command_line_option:platforms",
+        "@io_bazel_rules_go//go/toolchain:sdk_version",
+    ]],
+)
+
+def _go_multiarch_transition_impl(settings, attr):
+    if not attr.platforms:
+        fail("platforms must not be empty")
+    splits = {}
+    for platform in attr.platforms:
+        key = multiarch_platform_key(platform)
+        goos, _, goarch = key.partition("_")
+        if not goarch:
+            fail("invalid platform {}; want GOOS_GOARCH or GOOS_GOARCH_cgo".format(platform))
+        if key in splits:
+            fail("platform {}_{} is listed more than once".format(goos, goarch))
+        split = dict(settings)
+        split["//command_line_option:platforms"] = _platform(goos, goarch, platform.endswith("_cgo"))
+        if attr.sdk_version:
+            sdk_version_label = _filter_transition_label("@io_bazel_rules_go//go/toolchain:sdk_version")
+            split[sdk_version_label] = attr.sdk_version
+        splits[key] = split
+    return splits
+
+# go_multiarch_transition is a split transition applied to the target of
+# go_multiarch_binary. Like go_cross_transition, it only changes the platform
+# and the Go SDK, but the target is built once for each platform listed, keyed
+# by multiarch_platform_key.
+go_multiarch_transition = transition(
+    implementation = _go_multiarch_transition_impl,
+    inputs = [_filter_transition_label(label) for label in [
+        "//command_line_option:platforms",
+        "@io_bazel_rules_go//go/toolchain:sdk_version",
+    ]],
+    outputs = [_filter_transition_label(label) for label in [
+        "//command_line_option:platforms",
+        "@io_bazel_rules_go//go/toolchain:sdk_version",
+    ]],
+)
+
+def multiarch_platform_key(platform):
+    """Returns the GOOS_GOARCH pair for a platform in go_multiarch_binary."""
+    if platform.endswith("_cgo"):
+        return platform[:-len("_cgo")]
+    return platform
+
+def _platform(goos, goarch, cgo):
+    """Returns the label of the rules_go platform for goos and goarch."""
+    if goos == "auto":
+        fail("goos must be set if goarch is set")
+    if goarch == "auto":
+        fail("goarch must be set if goos is set")
+    if (goos, goarch) not in GOOS_GOARCH:
+        fail("invalid goos, goarch pair: {}, {}".format(goos, goarch))
+    if cgo and (goos, goarch) not in CGO_GOOS_GOARCH:
+        fail('pure is "off" but cgo is not supported on {} {}'.format(goos, goarch))
+    return "@io_bazel_rules_go//go/toolchain:{}_{}{}".format(goos, goarch, "_cgo" if cgo else "")
+
 def _check_ternary(name, value):
     if value not in ("on", "off", "auto"):
         fail('{}: must be "on", "off", or "auto"'.format(name))
@@ -53,4 +53,39 @@ def go_binary_macro(name, **kwargs):
 def go_test_macro(name, **kwargs):
     """See go/core.rst#go_test for full documentation."""
     _cgo(name, kwargs)
+    if kwargs.get("race_stress") and "race" not in kwargs:
+        kwargs["race"] = "on"
     go_transition_wrapper(go_test, go_transition_test, name = name, **kwargs)
+
+def go_fuzz_test_macro(name, fuzz, fuzztime = "10s", corpus = None, **kwargs):
+    """See go/core.rst#go_fuzz_test for full documentation."""
+    if not fuzz.startswith("Fuzz"):
+        fail("//{}:{}: fuzz must name a fuzz target like FuzzXxx, got {}".format(native.package_name(), name, fuzz))
+    kwargs["args"] = [
+        "-test.fuzz=^{}$".format(fuzz),
+        "-test.fuzztime=" + fuzztime,
+    ] + kwargs.get("args", [])
+    if not corpus:
+        corpus = "testdata/fuzz"
+        kwargs["data"] = kwargs.get("data", []) + native.glob(["testdata/fuzz/**"])
+    kwargs["gc_goopts"] = kwargs.get("gc_goopts", []) + ["-d=libfuzzer"]
+    go_test_macro(name = name, fuzz_corpus = corpus, **kwargs)
+
+def go_benchmark_macro(name, bench = ".", benchtime = None, count = None, baseline = None, threshold = 10, **kwargs):
+    """See go/core.rst#go_benchmark for full documentation."""
+    args = [
+        "-test.run=^$",
+        "-test.bench=" + bench,
+        "-test.benchmem",
+    ]
+    if benchtime:
+        args.append("-test.benchtime=" + benchtime)
+    if count:
+        args.append("-test.count={}".format(count))
+    kwargs["args"] = args + kwargs.get("args", [])
+    go_test_macro(
+        name = name,
+        benchmark_baseline = baseline,
+        benchmark_threshold = threshold,
+        **kwargs
+    )
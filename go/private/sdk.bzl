@@ -38,25 +38,38 @@ load(
 def _go_host_sdk_impl(ctx):
     goroot = _detect_host_sdk(ctx)
     platform = _detect_sdk_platform(ctx, goroot)
-    _sdk_build_file(ctx, platform)
+    _sdk_build_file(ctx, platform, _detect_sdk_version(ctx, goroot))
     _local_sdk(ctx, goroot)
 
 _go_host_sdk = repository_rule(
     _go_host_sdk_impl,
+    attrs = {
+        "sdk_version_settings": attr.bool(),
+        "validation_actions": attr.bool(),
+    },
     environ = ["GOROOT"],
 )
 
 def go_host_sdk(name, **kwargs):
-    _go_host_sdk(name = name, **kwargs)
+    _go_host_sdk(
+        name = name,
+        sdk_version_settings = _supports_sdk_version_settings(),
+        validation_actions = _supports_validation_actions(),
+        **kwargs
+    )
     _register_toolchains(name)
 
 def _go_download_sdk_impl(ctx):
+    checksums = _read_checksums(ctx, ctx.attr.checksums) if ctx.attr.checksums else {}
     if ctx.attr.version:
         if ctx.attr.sdks:
             fail("version and sdks must not both be set")
-        if ctx.attr.version not in SDK_REPOSITORIES:
-            fail("unknown Go version: {}".format(ctx.attr.version))
-        sdks = SDK_REPOSITORIES[ctx.attr.version]
+        if ctx.attr.version in SDK_REPOSITORIES:
+            sdks = SDK_REPOSITORIES[ctx.attr.version]
+        elif checksums:
+            sdks = _sdks_from_checksums(ctx.attr.version, checksums)
+        else:
+            fail("unknown Go version: {}; set checksums to download a version rules_go doesn't know about".format(ctx.attr.version))
     elif ctx.attr.sdks:
         sdks = ctx.attr.sdks
     else:
@@ -73,8 +86,16 @@ def _go_download_sdk_impl(ctx):
     if platform not in sdks:
         fail("unsupported platform {}".format(platform))
     filename, sha256 = sdks[platform]
-    _sdk_build_file(ctx, platform)
-    _remote_sdk(ctx, [url.format(filename) for url in ctx.attr.urls], ctx.attr.strip_prefix, sha256)
+    if not sha256 and ctx.attr.checksums:
+        # Don't download without verifying when a checksum file was given.
+        if filename not in checksums:
+            fail("go_download_sdk: no SHA-256 sum for {} in {}".format(filename, ctx.attr.checksums))
+        sha256 = checksums[filename]
+    goos, _, goarch = platform.partition("_")
+    version = ctx.attr.version or _version_from_filename(filename)
+    urls = [_format_url(url, filename, version, goos, goarch) for url in ctx.attr.urls]
+    _remote_sdk(ctx, urls, ctx.attr.strip_prefix, sha256, _download_auth(ctx, urls))
+    _sdk_build_file(ctx, platform, ctx.attr.version or _detect_sdk_version(ctx, "."))
 
 _go_download_sdk = repository_rule(
     _go_download_sdk_impl,
@@ -85,34 +106,52 @@ _go_download_sdk = repository_rule(
         "urls": attr.string_list(default = ["https://dl.google.com/go/{}"]),
         "version": attr.string(),
         "strip_prefix": attr.string(default = "go"),
+        "checksums": attr.label(allow_single_file = True),
+        "netrc": attr.string(),
+        "auth_patterns": attr.string_dict(),
+        "sdk_version_settings": attr.bool(),
+        "validation_actions": attr.bool(),
     },
+    environ = ["HOME", "NETRC", "USERPROFILE"],
 )
 
 def go_download_sdk(name, **kwargs):
-    _go_download_sdk(name = name, **kwargs)
+    _go_download_sdk(
+        name = name,
+        sdk_version_settings = _supports_sdk_version_settings(),
+        validation_actions = _supports_validation_actions(),
+        **kwargs
+    )
     _register_toolchains(name)
 
 def _go_local_sdk_impl(ctx):
     goroot = ctx.attr.path
     platform = _detect_sdk_platform(ctx, goroot)
-    _sdk_build_file(ctx, platform)
+    _sdk_build_file(ctx, platform, _detect_sdk_version(ctx, goroot))
     _local_sdk(ctx, goroot)
 
 _go_local_sdk = repository_rule(
     _go_local_sdk_impl,
     attrs = {
         "path": attr.string(),
+        "sdk_version_settings": attr.bool(),
+        "validation_actions": attr.bool(),
     },
 )
 
 def go_local_sdk(name, **kwargs):
-    _go_local_sdk(name = name, **kwargs)
+    _go_local_sdk(
+        name = name,
+        sdk_version_settings = _supports_sdk_version_settings(),
+        validation_actions = _supports_validation_actions(),
+        **kwargs
+    )
     _register_toolchains(name)
 
 def _go_wrap_sdk_impl(ctx):
     goroot = str(ctx.path(ctx.attr.root_file).dirname)
     platform = _detect_sdk_platform(ctx, goroot)
-    _sdk_build_file(ctx, platform)
+    _sdk_build_file(ctx, platform, _detect_sdk_version(ctx, goroot))
     _local_sdk(ctx, goroot)
 
 _go_wrap_sdk = repository_rule(
@@ -122,11 +161,118 @@ _go_wrap_sdk = repository_rule(
             mandatory = True,
             doc = "A file in the SDK root direcotry. Used to determine GOROOT.",
         ),
+        "sdk_version_settings": attr.bool(),
+        "validation_actions": attr.bool(),
     },
 )
 
 def go_wrap_sdk(name, **kwargs):
-    _go_wrap_sdk(name = name, **kwargs)
+    _go_wrap_sdk(
+        name = name,
+        sdk_version_settings = _supports_sdk_version_settings(),
+        validation_actions = _supports_validation_actions(),
+        **kwargs
+    )
+    _register_toolchains(name)
+
+def _go_source_sdk_impl(ctx):
+    if ctx.os.name.startswith("windows"):
+        fail("go_source_sdk: building Go from source is not supported on Windows")
+    if ctx.attr.path and ctx.attr.urls:
+        fail("go_source_sdk: path and urls must not both be set")
+    if ctx.attr.path:
+        # make.bash writes into GOROOT, so the checkout is copied rather than
+        # linked. The .git directory is only needed to name the version.
+        res = ctx.execute([
+            "sh",
+            "-c",
+            'tar -C "$1" --exclude=./.git -cf - . | tar -xf -',
+            "sh",
+            ctx.attr.path,
+        ])
+        if res.return_code:
+            fail("error copying Go source from {}:\n{}{}".format(ctx.attr.path, res.stdout, res.stderr))
+    elif ctx.attr.urls:
+        ctx.download_and_extract(
+            url = ctx.attr.urls,
+            stripPrefix = ctx.attr.strip_prefix,
+            sha256 = ctx.attr.sha256,
+        )
+    else:
+        fail("go_source_sdk: one of path or urls must be set")
+
+    # Archives of tip may already be built, in which case they're used as is.
+    if not ctx.path("bin/go").exists:
+        _write_source_version(ctx)
+        _build_sdk_from_source(ctx)
+    platform = _detect_sdk_platform(ctx, ".")
+    _sdk_build_file(ctx, platform, ctx.attr.version or _detect_sdk_version(ctx, "."))
+
+def _write_source_version(ctx):
+    """Writes a VERSION file if the source doesn't have one. cmd/dist fails
+    without it outside of a git checkout."""
+    if ctx.path("VERSION").exists:
+        return
+    if ctx.attr.version:
+        version = "go" + ctx.attr.version
+    elif ctx.attr.path and ctx.path(ctx.attr.path + "/.git").exists:
+        # This is how cmd/dist names development versions.
+        res = ctx.execute(["git", "-C", ctx.attr.path, "log", "-n", "1", "--format=format:+%h %cd", "HEAD"])
+        if res.return_code:
+            fail("error reading Go version from {}:\n{}".format(ctx.attr.path, res.stderr))
+        version = "devel " + res.stdout.strip()
+    else:
+        version = "devel"
+    ctx.file("VERSION", version, executable = False)
+
+def _build_sdk_from_source(ctx):
+    if ctx.attr.goroot_bootstrap:
+        bootstrap = ctx.attr.goroot_bootstrap
+    elif "GOROOT_BOOTSTRAP" in ctx.os.environ:
+        bootstrap = ctx.os.environ["GOROOT_BOOTSTRAP"]
+    else:
+        bootstrap = _detect_host_sdk(ctx)
+    ctx.report_progress("Building Go from source")
+    res = ctx.execute(
+        ["./make.bash"],
+        working_directory = "src",
+        environment = {
+            "GOROOT_BOOTSTRAP": bootstrap,
+            "GOROOT": "",
+            "GOPATH": "",
+            "GOFLAGS": "",
+        },
+        timeout = ctx.attr.timeout,
+    )
+    if res.return_code:
+        fail("error building Go from source with make.bash:\n" + res.stdout + res.stderr)
+
+    # The build cache of the bootstrap isn't part of the SDK.
+    ctx.delete("pkg/obj")
+
+_go_source_sdk = repository_rule(
+    _go_source_sdk_impl,
+    attrs = {
+        "path": attr.string(),
+        "urls": attr.string_list(),
+        "sha256": attr.string(),
+        "strip_prefix": attr.string(default = "go"),
+        "version": attr.string(),
+        "goroot_bootstrap": attr.string(),
+        "timeout": attr.int(default = 3600),
+        "sdk_version_settings": attr.bool(),
+        "validation_actions": attr.bool(),
+    },
+    environ = ["GOROOT", "GOROOT_BOOTSTRAP"],
+)
+
+def go_source_sdk(name, **kwargs):
+    _go_source_sdk(
+        name = name,
+        sdk_version_settings = _supports_sdk_version_settings(),
+        validation_actions = _supports_validation_actions(),
+        **kwargs
+    )
     _register_toolchains(name)
 
 def _register_toolchains(repo):
@@ -136,7 +282,11 @@ def _register_toolchains(repo):
     ]
     native.register_toolchains(*labels)
 
-def _remote_sdk(ctx, urls, strip_prefix, sha256):
+def _remote_sdk(ctx, urls, strip_prefix, sha256, auth):
+    # auth is only passed when it's needed, since older versions of Bazel
+    # don't accept it.
+    kwargs = {"auth": auth} if auth else {}
+
     # TODO(bazelbuild/bazel#7055): download_and_extract fails to extract
     # archives containing files with non-ASCII names. Go 1.12b1 has a test
     # file like this. Remove this workaround when the bug is fixed.
@@ -149,6 +299,7 @@ def _remote_sdk(ctx, urls, strip_prefix, sha256):
             url = urls,
             sha256 = sha256,
             output = "go_sdk.tar.gz",
+            **kwargs
         )
         res = ctx.execute(["tar", "-xf", "go_sdk.tar.gz", "--strip-components=1"])
         if res.return_code:
@@ -159,13 +310,163 @@ def _remote_sdk(ctx, urls, strip_prefix, sha256):
             url = urls,
             stripPrefix = strip_prefix,
             sha256 = sha256,
+            **kwargs
         )
 
+def _format_url(url, filename, version, goos, goarch):
+    """Expands the placeholders in a go_download_sdk URL template. {} and
+    {filename} are replaced with the archive name, like
+    go1.14.2.linux-amd64.tar.gz, and {version}, {goos}, and {goarch} with the
+    parts of it, so mirrors that lay out files differently can be used."""
+    if "{version}" in url and not version:
+        fail("URL template {} uses {{version}}, but the version of {} is not known; set version".format(url, filename))
+    for placeholder, value in [
+        ("{}", filename),
+        ("{filename}", filename),
+        ("{version}", version),
+        ("{goos}", goos),
+        ("{goarch}", goarch),
+    ]:
+        url = url.replace(placeholder, value)
+    return url
+
+def _version_from_filename(filename):
+    """Returns the version in an SDK archive name like
+    go1.14.2.linux-amd64.tar.gz, or "" if the name has a different form."""
+    if not filename.startswith("go"):
+        return ""
+    for suffix in (".tar.gz", ".zip"):
+        if filename.endswith(suffix):
+            name = filename[len("go"):-len(suffix)]
+            version, _, platform = name.rpartition(".")
+            return version if "-" in platform else ""
+    return ""
+
+def _read_checksums(ctx, label):
+    """Reads a checksum file in the format written by sha256sum, with a
+    SHA-256 sum and an archive name on each line. Returns a dict from archive
+    names to sums."""
+    checksums = {}
+    for line in ctx.read(ctx.path(label)).splitlines():
+        fields = line.split()
+        if len(fields) < 2 or line.lstrip().startswith("#"):
+            continue
+
+        # sha256sum marks files read in binary mode with "*".
+        checksums[fields[-1].lstrip("*")] = fields[0]
+    return checksums
+
+def _sdks_from_checksums(version, checksums):
+    """Returns a dict like those in SDK_REPOSITORIES for the archives of
+    version listed in a checksum file."""
+    sdks = {}
+    prefix = "go" + version + "."
+    for filename, sha256 in checksums.items():
+        if not filename.startswith(prefix):
+            continue
+        if filename.endswith(".tar.gz"):
+            platform = filename[len(prefix):-len(".tar.gz")]
+        elif filename.endswith(".zip"):
+            platform = filename[len(prefix):-len(".zip")]
+        else:
+            continue
+        if platform.count("-") != 1 or "." in platform:
+            # Source archives, or archives of a later patch release.
+            continue
+        goos, _, goarch = platform.partition("-")
+        if goarch == "armv6l":
+            goarch = "arm"
+        sdks[goos + "_" + goarch] = (filename, sha256)
+    if not sdks:
+        fail("no archives for Go {} found in checksums".format(version))
+    return sdks
+
+def _netrc_path(ctx):
+    if ctx.attr.netrc:
+        return ctx.attr.netrc
+    if ctx.os.environ.get("NETRC"):
+        return ctx.os.environ["NETRC"]
+    if ctx.os.name.startswith("windows"):
+        home = ctx.os.environ.get("USERPROFILE")
+        return home + "/_netrc" if home else ""
+    home = ctx.os.environ.get("HOME")
+    return home + "/.netrc" if home else ""
+
+def _parse_netrc(contents):
+    """Returns a dict from machine names to dicts of their login and password
+    in a .netrc file. Credentials for the default entry have the key "".
+    Macro definitions are not supported."""
+    machines = {}
+    creds = None
+    key = None
+    for token in contents.split():
+        if key == None:
+            if token == "default":
+                creds = machines.setdefault("", {})
+            elif token in ("machine", "login", "password", "account"):
+                key = token
+            continue
+        if key == "machine":
+            creds = machines.setdefault(token, {})
+        elif creds != None:
+            creds[key] = token
+        key = None
+    return machines
+
+def _url_host(url):
+    host = url.partition("://")[2].partition("/")[0]
+    host = host.rpartition("@")[2]
+    if host.startswith("["):
+        return host.partition("]")[0] + "]"
+    return host.partition(":")[0]
+
+def _download_auth(ctx, urls):
+    """Returns the auth argument for ctx.download with credentials from the
+    .netrc file for the hosts in urls.
+
+    Credentials are sent with HTTP basic authentication unless auth_patterns
+    has a template for the host, like "Bearer <password>", where <login> and
+    <password> are replaced with the credentials."""
+    path = _netrc_path(ctx)
+    if not path or not ctx.path(path).exists:
+        if ctx.attr.netrc:
+            fail("netrc file {} does not exist".format(ctx.attr.netrc))
+        return {}
+    machines = _parse_netrc(ctx.read(ctx.path(path)))
+    auth = {}
+    for url in urls:
+        if not url.startswith("https://") and not url.startswith("http://"):
+            continue
+        host = _url_host(url)
+        creds = machines.get(host, machines.get(""))
+        if not creds or "password" not in creds:
+            continue
+        pattern = ctx.attr.auth_patterns.get(host)
+        if pattern:
+            auth[url] = {
+                "type": "pattern",
+                "pattern": pattern,
+                "login": creds.get("login", ""),
+                "password": creds["password"],
+            }
+        elif "login" in creds:
+            auth[url] = {
+                "type": "basic",
+                "login": creds["login"],
+                "password": creds["password"],
+            }
+    return auth
+
 def _local_sdk(ctx, path):
     for entry in ["src", "pkg", "bin"]:
         ctx.symlink(path + "/" + entry, entry)
 
-def _sdk_build_file(ctx, platform):
+    # wasm_exec.js is in misc/wasm before Go 1.24 and lib/wasm after.
+    for entry in ["misc", "lib"]:
+        if ctx.path(path + "/" + entry).exists:
+            ctx.symlink(path + "/" + entry, entry)
+
+def _sdk_build_file(ctx, platform, version):
     ctx.file("ROOT")
     goos, _, goarch = platform.partition("_")
     ctx.template(
@@ -176,9 +477,42 @@ def _sdk_build_file(ctx, platform):
             "{goos}": goos,
             "{goarch}": goarch,
             "{exe}": ".exe" if goos == "windows" else "",
+            "{version}": version,
+            "{sdk_version_settings}": str(ctx.attr.sdk_version_settings),
+            "{validation_actions}": str(ctx.attr.validation_actions),
         },
     )
 
+def _supports_sdk_version_settings():
+    """Returns whether toolchains may be selected with the sdk_version flag.
+
+    This requires the target_settings attribute of toolchain, added in
+    Bazel 5.0. native.bazel_version is only available in WORKSPACE, so this
+    is checked by the SDK macros and passed to the repository rules.
+    """
+    bazel_version = versions.get()
+    return not bazel_version or versions.is_at_least("5.0.0", bazel_version)
+
+def _supports_validation_actions():
+    """Returns whether Bazel builds the _validation output group of top-level
+    targets by default. This was added in Bazel 4.0. Like
+    _supports_sdk_version_settings, this is checked by the SDK macros.
+    """
+    bazel_version = versions.get()
+    return not bazel_version or versions.is_at_least("4.0.0", bazel_version)
+
+def _detect_sdk_version(ctx, goroot):
+    """Returns the version of the SDK in goroot, like "1.14.2", read from its
+    VERSION file. Returns an empty string for development versions or if
+    there's no VERSION file."""
+    version_file = ctx.path(goroot + "/VERSION")
+    if not version_file.exists:
+        return ""
+    version = ctx.read(version_file).strip().split("\n")[0]
+    if not version.startswith("go"):
+        return ""
+    return version[len("go"):]
+
 def _detect_host_platform(ctx):
     if ctx.os.name == "linux":
         host = "linux_amd64"
@@ -240,7 +574,7 @@ def _detect_sdk_platform(ctx, goroot):
 
 def go_register_toolchains(go_version = None, nogo = None):
     """See /go/toolchains.rst#go-register-toolchains for full documentation."""
-    sdk_kinds = ("_go_download_sdk", "_go_host_sdk", "_go_local_sdk", "_go_wrap_sdk")
+    sdk_kinds = ("_go_download_sdk", "_go_host_sdk", "_go_local_sdk", "_go_source_sdk", "_go_wrap_sdk")
     existing_rules = native.existing_rules()
     sdk_rules = [r for r in existing_rules.values() if r["kind"] in sdk_kinds]
     if len(sdk_rules) == 0 and "go_sdk" in existing_rules:
@@ -48,11 +48,18 @@ def _go_path_impl(ctx):
             importpath, pkgpath = effective_importpath_pkgpath(archive)
             if importpath == "":
                 continue  # synthetic archive or inferred location
+            generated = [
+                struct(file = f, path = _package_relative_path(f, archive.label))
+                for f in archive.embedsrcs
+            ]
+            if archive.cgo_srcs:
+                generated.append(struct(file = archive.cgo_srcs, path = "_cgo"))
             pkg = struct(
                 importpath = importpath,
                 dir = "src/" + pkgpath,
                 srcs = as_list(archive.orig_srcs),
                 data = as_list(archive.data_files),
+                generated = generated,
                 pkgs = {mode: archive.file},
             )
             if pkgpath in pkg_map:
@@ -68,6 +75,14 @@ def _go_path_impl(ctx):
         for f in pkg.srcs:
             dst = pkg.dir + "/" + f.basename
             _add_manifest_entry(manifest_entries, manifest_entry_map, inputs, f, dst)
+    tree_dsts = {}
+    if ctx.attr.include_generated:
+        for pkg in pkg_map.values():
+            for g in pkg.generated:
+                dst = pkg.dir + "/" + g.path
+                _add_manifest_entry(manifest_entries, manifest_entry_map, inputs, g.file, dst)
+                if g.file.is_directory:
+                    tree_dsts[dst] = None
     if ctx.attr.include_pkg:
         for pkg in pkg_map.values():
             for mode, f in pkg.pkgs.items():
@@ -112,13 +127,16 @@ def _go_path_impl(ctx):
         out_short_path = out.short_path
         outputs = [out]
         out_file = out
-    else:  # link
+    else:  # link or materialize
         # Declare individual outputs in link mode. Symlinks can't point outside
-        # tree artifacts.
-        outputs = [
-            ctx.actions.declare_file(ctx.label.name + "/" + e.dst)
-            for e in manifest_entries
-        ]
+        # tree artifacts. Directories in the manifest are declared as tree
+        # artifacts, and their files are always copied.
+        outputs = []
+        for e in manifest_entries:
+            if e.dst in tree_dsts:
+                outputs.append(ctx.actions.declare_directory(ctx.label.name + "/" + e.dst))
+            else:
+                outputs.append(ctx.actions.declare_file(ctx.label.name + "/" + e.dst))
         tag = ctx.actions.declare_file(ctx.label.name + "/.tag")
         ctx.actions.write(tag, "")
         out_path = tag.dirname
@@ -159,9 +177,11 @@ go_path = rule(
                 "archive",
                 "copy",
                 "link",
+                "materialize",
             ],
         ),
         "include_data": attr.bool(default = True),
+        "include_generated": attr.bool(default = False),
         "include_pkg": attr.bool(default = False),
         "_go_path": attr.label(
             default = "@io_bazel_rules_go//go/tools/builders:go_path",
@@ -176,8 +196,20 @@ def _merge_pkg(x, y):
     x_data = {f.path: None for f in x.data}
     x.srcs.extend([f for f in y.srcs if f.path not in x_srcs])
     x.data.extend([f for f in y.data if f.path not in x_srcs])
+    x_generated = {g.path: None for g in x.generated}
+    x.generated.extend([g for g in y.generated if g.path not in x_generated])
     x.pkgs.update(y.pkgs)
 
+def _package_relative_path(f, label):
+    """Returns the path of f relative to the directory of the package that
+    label is in, or its base name if it's not in that directory."""
+    prefix = label.package + "/" if label.package else ""
+    if label.workspace_name:
+        prefix = "../" + label.workspace_name + "/" + prefix
+    if f.short_path.startswith(prefix):
+        return f.short_path[len(prefix):]
+    return f.basename
+
 def _add_manifest_entry(entries, entry_map, inputs, src, dst):
     if dst in entry_map:
         if entry_map[dst] != src.path:
@@ -41,6 +41,8 @@ func main() {
 	switch verb {
 	case "asm":
 		action = asm
+	case "checkconstraints":
+		action = checkConstraints
 	case "compile":
 		action = compile
 	case "compilepkg":
@@ -53,10 +55,24 @@ func main() {
 		action = genTestMain
 	case "link":
 		action = link
+	case "multiarch":
+		action = multiarch
 	case "gennogomain":
 		action = genNogoMain
+	case "nogo":
+		action = nogoPkg
+	case "nogovalidation":
+		action = nogoValidation
+	case "pkgmetadata":
+		action = pkgMetadata
 	case "pack":
 		action = pack
+	case "sbom":
+		action = sbom
+	case "sizereport":
+		action = sizeReportCmd
+	case "splitdebug":
+		action = splitDebug
 	case "stdlib":
 		action = stdlib
 	default:
@@ -0,0 +1,88 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Markers around the build information in runtime.modinfo. These must match
+// the markers written by cmd/go and read by runtime/debug.
+const (
+	modinfoStart = "\x30\x77\xaf\x0c\x92\x74\x08\x02\x41\xe1\xc1\x07\xe6\xd6\x18\xe6"
+	modinfoEnd   = "\xf9\x32\x43\x31\x86\x18\x20\x72\x00\x82\x42\x10\x41\x16\xd8\xf2"
+)
+
+// vcsStampKeys maps build settings reported by "go version -m" and
+// runtime/debug.ReadBuildInfo to the workspace status keys they are read
+// from. The first key present in the status files is used. Settings whose
+// keys aren't stamped are left out.
+var vcsStampKeys = []struct {
+	setting string
+	keys    []string
+}{
+	{"vcs", []string{"STABLE_VCS"}},
+	{"vcs.revision", []string{"STABLE_VCS_REVISION", "BUILD_SCM_REVISION"}},
+	{"vcs.time", []string{"STABLE_VCS_TIME"}},
+	{"vcs.modified", []string{"STABLE_VCS_MODIFIED"}},
+}
+
+// buildInfo returns the build information for a binary whose main package
+// has the import path mainPath, in the format written by cmd/go. VCS settings
+// are looked up in stampMap, the parsed workspace status files.
+func buildInfo(mainPath string, stampMap map[string]string) string {
+	var settings [][2]string
+	for _, s := range vcsStampKeys {
+		for _, key := range s.keys {
+			if value, ok := stampMap[key]; ok && value != "" {
+				settings = append(settings, [2]string{s.setting, value})
+				break
+			}
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "path\t%s\n", mainPath)
+	for _, s := range settings {
+		fmt.Fprintf(&b, "build\t%s=%s\n", s[0], quoteBuildValue(s[1]))
+	}
+	return b.String()
+}
+
+// quoteBuildValue quotes a build setting value if it contains characters
+// that runtime/debug.ParseBuildInfo would not read back unquoted.
+func quoteBuildValue(value string) string {
+	if strings.ContainsAny(value, " \t\r\n\"`") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// appendModinfo adds a modinfo directive with the given build information to
+// the importcfg file at importcfgPath. The linker stores it in
+// runtime.modinfo, where "go version -m" and runtime/debug find it.
+func appendModinfo(importcfgPath, info string) error {
+	f, err := os.OpenFile(importcfgPath, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(f, "modinfo %q\n", modinfoStart+info+modinfoEnd); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestBuildInfo(t *testing.T) {
+	for _, test := range []struct {
+		desc     string
+		stampMap map[string]string
+		want     string
+	}{
+		{
+			desc: "no_vcs",
+			want: "path\texample.com/cmd\n",
+		}, {
+			desc: "all_keys",
+			stampMap: map[string]string{
+				"STABLE_VCS":          "hg",
+				"STABLE_VCS_REVISION": "abc123",
+				"STABLE_VCS_TIME":     "2020-06-01T12:00:00Z",
+				"STABLE_VCS_MODIFIED": "true",
+				"BUILD_SCM_REVISION":  "ignored",
+			},
+			want: "path\texample.com/cmd\n" +
+				"build\tvcs=hg\n" +
+				"build\tvcs.revision=abc123\n" +
+				"build\tvcs.time=2020-06-01T12:00:00Z\n" +
+				"build\tvcs.modified=true\n",
+		}, {
+			desc:     "fallback_key",
+			stampMap: map[string]string{"BUILD_SCM_REVISION": "def456"},
+			want: "path\texample.com/cmd\n" +
+				"build\tvcs.revision=def456\n",
+		}, {
+			desc:     "quoted",
+			stampMap: map[string]string{"STABLE_VCS_REVISION": "with space"},
+			want: "path\texample.com/cmd\n" +
+				"build\tvcs.revision=\"with space\"\n",
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := buildInfo("example.com/cmd", test.stampMap); got != test.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAppendModinfo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "buildinfo_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	importcfgPath := filepath.Join(dir, "importcfg")
+	const packagefile = "packagefile fmt=fmt.a\n"
+	if err := ioutil.WriteFile(importcfgPath, []byte(packagefile), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	const info = "path\texample.com/cmd\n"
+	if err := appendModinfo(importcfgPath, info); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(importcfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 2 || lines[0]+"\n" != packagefile || !strings.HasPrefix(lines[1], "modinfo ") {
+		t.Fatalf("unexpected importcfg:\n%s", data)
+	}
+	modinfo, err := strconv.Unquote(strings.TrimPrefix(lines[1], "modinfo "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := modinfoStart + info + modinfoEnd; modinfo != want {
+		t.Errorf("got modinfo %q; want %q", modinfo, want)
+	}
+}
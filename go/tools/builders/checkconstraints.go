@@ -0,0 +1,221 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// checkconstraints reports Go source files that are excluded by build
+// constraints on every platform, and build tags that are neither known to the
+// Go toolchain nor set in the build configuration. Both usually mean a build
+// tag is misspelled; without this check, the file is silently left out.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// knownOS and knownArch list the GOOS and GOARCH values recognized by go/build,
+// including ones rules_go has no platforms for.
+var knownOS = []string{"aix", "android", "darwin", "dragonfly", "freebsd", "hurd", "illumos", "ios", "js", "linux", "nacl", "netbsd", "openbsd", "plan9", "solaris", "wasip1", "windows", "zos"}
+var knownArch = []string{"386", "amd64", "amd64p32", "arm", "armbe", "arm64", "arm64be", "loong64", "mips", "mipsle", "mips64", "mips64le", "mips64p32", "mips64p32le", "ppc", "ppc64", "ppc64le", "riscv", "riscv64", "s390", "s390x", "sparc", "sparc64", "wasm"}
+
+// knownTags lists other tags with a meaning to the go command or that are
+// conventionally used to exclude files.
+var knownTags = []string{"cgo", "gc", "gccgo", "ignore", "msan", "race", "unix"}
+
+func checkConstraints(args []string) error {
+	args, err := readParamsFiles(args)
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("GoCheckConstraints", flag.ExitOnError)
+	goenv := envFlags(fs)
+	var srcs, platforms multiFlag
+	var outPath string
+	var strict bool
+	fs.Var(&srcs, "src", "A .go file whose build constraints should be checked")
+	fs.Var(&platforms, "platform", "A GOOS_GOARCH pair the file may be built for")
+	fs.BoolVar(&strict, "strict", false, "Whether unknown build tags are errors instead of warnings")
+	fs.StringVar(&outPath, "o", "", "The file where diagnostics should be written")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := goenv.checkFlags(); err != nil {
+		return err
+	}
+
+	warnings, errs, err := checkSrcConstraints(srcs, platforms, build.Default.BuildTags, strict)
+	if err != nil {
+		return err
+	}
+	var report bytes.Buffer
+	for _, msg := range errs {
+		fmt.Fprintf(&report, "error: %s\n", msg)
+	}
+	for _, msg := range warnings {
+		fmt.Fprintf(&report, "warning: %s\n", msg)
+	}
+	if err := ioutil.WriteFile(outPath, report.Bytes(), 0666); err != nil {
+		return err
+	}
+	for _, msg := range warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", msg)
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// checkSrcConstraints checks the build constraints of srcs. A file is
+// reported if it is excluded on all platforms, with and without cgo, given
+// the tags set in the build configuration. Tags that aren't known are
+// reported as errors if strict is true, and as warnings otherwise.
+func checkSrcConstraints(srcs, platforms, tags []string, strict bool) (warnings, errs []string, err error) {
+	known := make(map[string]bool)
+	for _, list := range [][]string{knownOS, knownArch, knownTags, tags} {
+		for _, t := range list {
+			known[t] = true
+		}
+	}
+	for _, p := range platforms {
+		for _, t := range strings.Split(p, "_") {
+			known[t] = true
+		}
+	}
+
+	for _, src := range srcs {
+		if filepath.Ext(src) != ".go" {
+			continue
+		}
+		fileTags, err := readBuildTags(src)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, t := range fileTags {
+			if known[t] || isReleaseTag(t) {
+				continue
+			}
+			msg := fmt.Sprintf("%s: unknown build tag %q", src, t)
+			if strict {
+				errs = append(errs, msg)
+			} else {
+				warnings = append(warnings, msg)
+			}
+		}
+
+		matched, err := matchAnyPlatform(src, platforms, tags)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !matched {
+			warnings = append(warnings, fmt.Sprintf("%s: excluded by build constraints on all platforms", src))
+		}
+	}
+	return warnings, errs, nil
+}
+
+// matchAnyPlatform returns whether src would be compiled on any of platforms,
+// with or without cgo.
+func matchAnyPlatform(src string, platforms, tags []string) (bool, error) {
+	dir, base := filepath.Split(src)
+	if strings.HasPrefix(base, "_cgo") {
+		// Generated by cgo; see readFileInfo.
+		return true, nil
+	}
+	for _, p := range platforms {
+		i := strings.IndexByte(p, '_')
+		if i < 0 {
+			return false, fmt.Errorf("invalid platform %q; want GOOS_GOARCH", p)
+		}
+		for _, cgo := range []bool{true, false} {
+			bctx := build.Default
+			bctx.GOOS = p[:i]
+			bctx.GOARCH = p[i+1:]
+			bctx.CgoEnabled = cgo
+			bctx.BuildTags = tags
+			if match, err := bctx.MatchFile(dir, base); err != nil {
+				return false, err
+			} else if match {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// readBuildTags returns the tags named in the "// +build" and "//go:build"
+// lines at the top of a Go source file, sorted and without duplicates.
+func readBuildTags(src string) ([]string, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "//") {
+			// Build constraints may only be preceded by blank lines and line
+			// comments.
+			break
+		}
+		var expr string
+		if strings.HasPrefix(line, "// +build ") {
+			expr = line[len("// +build "):]
+		} else if strings.HasPrefix(line, "//go:build ") {
+			expr = line[len("//go:build "):]
+		} else {
+			continue
+		}
+		expr = strings.NewReplacer("(", " ", ")", " ", "!", " ", "&&", " ", "||", " ", ",", " ").Replace(expr)
+		for _, t := range strings.Fields(expr) {
+			seen[t] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	tags := make([]string, 0, len(seen))
+	for t := range seen {
+		tags = append(tags, t)
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// isReleaseTag returns whether tag is a release tag like "go1.14".
+func isReleaseTag(tag string) bool {
+	if !strings.HasPrefix(tag, "go1.") || len(tag) == len("go1.") {
+		return false
+	}
+	for _, r := range tag[len("go1."):] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
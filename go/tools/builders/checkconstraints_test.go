@@ -0,0 +1,135 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheckSrcConstraints(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkconstraints_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"plain.go":      "package p\n",
+		"linux.go":      "// +build linux,!cgo\n\npackage p\n",
+		"typo.go":       "// +build linxu\n\npackage p\n",
+		"gobuild.go":    "//go:build (darwin || windows) && go1.14\n\npackage p\n",
+		"custom.go":     "// Copyright\n\n// +build custom\n\npackage p\n",
+		"ignored.go":    "// +build ignore\n\npackage p\n",
+		"nope_plan9.go": "package p\n",
+		"asm.s":         "// +build whatever\n",
+	}
+	var srcs []string
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+		srcs = append(srcs, path)
+	}
+	platforms := []string{"linux_amd64", "darwin_amd64", "windows_amd64"}
+
+	for _, test := range []struct {
+		desc         string
+		tags         []string
+		strict       bool
+		wantWarnings []string
+		wantErrs     []string
+	}{
+		{
+			desc: "default",
+			wantWarnings: []string{
+				`custom.go: unknown build tag "custom"`,
+				"custom.go: excluded by build constraints on all platforms",
+				"ignored.go: excluded by build constraints on all platforms",
+				"nope_plan9.go: excluded by build constraints on all platforms",
+				`typo.go: unknown build tag "linxu"`,
+				"typo.go: excluded by build constraints on all platforms",
+			},
+		}, {
+			desc:   "strict_with_tag",
+			tags:   []string{"custom"},
+			strict: true,
+			wantWarnings: []string{
+				"ignored.go: excluded by build constraints on all platforms",
+				"nope_plan9.go: excluded by build constraints on all platforms",
+				"typo.go: excluded by build constraints on all platforms",
+			},
+			wantErrs: []string{`typo.go: unknown build tag "linxu"`},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			warnings, errs, err := checkSrcConstraints(srcs, platforms, test.tags, test.strict)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := relMessages(dir, warnings); !sameMessages(got, test.wantWarnings) {
+				t.Errorf("got warnings %q; want %q", got, test.wantWarnings)
+			}
+			if got := relMessages(dir, errs); !sameMessages(got, test.wantErrs) {
+				t.Errorf("got errors %q; want %q", got, test.wantErrs)
+			}
+		})
+	}
+}
+
+func TestIsReleaseTag(t *testing.T) {
+	for tag, want := range map[string]bool{
+		"go1.14":  true,
+		"go1.":    false,
+		"go1.x":   false,
+		"go2.0":   false,
+		"linux":   false,
+		"go1.100": true,
+	} {
+		if got := isReleaseTag(tag); got != want {
+			t.Errorf("isReleaseTag(%q) = %v; want %v", tag, got, want)
+		}
+	}
+}
+
+// relMessages trims dir from the beginning of each message.
+func relMessages(dir string, msgs []string) []string {
+	var rel []string
+	prefix := dir + string(filepath.Separator)
+	for _, msg := range msgs {
+		if len(msg) >= len(prefix) && msg[:len(prefix)] == prefix {
+			msg = msg[len(prefix):]
+		}
+		rel = append(rel, msg)
+	}
+	return rel
+}
+
+// sameMessages reports whether got and want contain the same messages,
+// ignoring order.
+func sameMessages(got, want []string) bool {
+	gotSet := make(map[string]int)
+	for _, msg := range got {
+		gotSet[msg]++
+	}
+	wantSet := make(map[string]int)
+	for _, msg := range want {
+		wantSet[msg]++
+	}
+	return reflect.DeepEqual(gotSet, wantSet)
+}
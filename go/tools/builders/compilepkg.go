@@ -40,14 +40,18 @@ func compilePkg(args []string) error {
 
 	fs := flag.NewFlagSet("GoCompilePkg", flag.ExitOnError)
 	goenv := envFlags(fs)
-	var unfilteredSrcs, coverSrcs multiFlag
+	var unfilteredSrcs, generatedSrcs, coverSrcs, embedSrcs, embedRoots, checkDeps multiFlag
 	var deps compileArchiveMultiFlag
-	var importPath, packagePath, nogoPath, packageListPath, coverMode string
-	var outPath, outFactsPath, cgoExportHPath string
-	var testFilter string
+	var importPath, packagePath, targetLabel, nogoPath, packageListPath, coverMode string
+	var outPath, outFactsPath, outNogoSARIFPath, outNogoFindingsPath, outExportDataPath, outEmbedcfgPath, cgoExportHPath, cgoSrcsDir string
+	var testFilter, unusedDepsMode, outUnusedDepsPath, coverFormat, coverMainMode string
+	var nogoTiming bool
 	var gcFlags, asmFlags, cppFlags, cFlags, cxxFlags, objcFlags, objcxxFlags, ldFlags quoteMultiFlag
 	fs.Var(&unfilteredSrcs, "src", ".go, .c, .cc, .m, .mm, .s, or .S file to be filtered and compiled")
+	fs.Var(&generatedSrcs, "generated_src", ".go file produced by another rule rather than checked in (must also be a -src)")
 	fs.Var(&coverSrcs, "cover", ".go file that should be instrumented for coverage (must also be a -src)")
+	fs.Var(&embedSrcs, "embedsrc", "file that may be embedded with //go:embed directives")
+	fs.Var(&embedRoots, "embedroot", "directory that sources and embedded files may be relative to, like bazel-out/.../bin")
 	fs.Var(&deps, "arc", "Import path, package path, and file name of a direct dependency, separated by '='")
 	fs.StringVar(&importPath, "importpath", "", "The import path of the package being compiled. Not passed to the compiler, but may be displayed in debug data.")
 	fs.StringVar(&packagePath, "p", "", "The package path (importmap) of the package being compiled")
@@ -61,11 +65,23 @@ func compilePkg(args []string) error {
 	fs.Var(&ldFlags, "ldflags", "C linker flags")
 	fs.StringVar(&nogoPath, "nogo", "", "The nogo binary. If unset, nogo will not be run.")
 	fs.StringVar(&packageListPath, "package_list", "", "The file containing the list of standard library packages")
+	fs.StringVar(&targetLabel, "label", "", "The label of the target the package is compiled for")
 	fs.StringVar(&coverMode, "cover_mode", "", "The coverage mode to use. Empty if coverage instrumentation should not be added.")
+	fs.StringVar(&coverFormat, "cover_format", "coverdata", "How coverage data is collected: coverdata or gocoverdir")
+	fs.StringVar(&coverMainMode, "cover_main_mode", "", "For gocoverdir coverage, the mode used to instrument a main package with no -cover sources: regonly or testmain")
 	fs.StringVar(&outPath, "o", "", "The output archive file to write")
 	fs.StringVar(&outFactsPath, "x", "", "The nogo facts file to write")
+	fs.StringVar(&outNogoSARIFPath, "nogo_sarif", "", "The file where nogo findings should be written in SARIF format")
+	fs.StringVar(&outNogoFindingsPath, "nogo_findings", "", "The file where nogo findings should be written in JSON format")
+	fs.StringVar(&outExportDataPath, "export_data", "", "The file where the export data of the compiled package should be written for nogo")
+	fs.StringVar(&outEmbedcfgPath, "embedcfg", "", "The file where the files matched by //go:embed patterns should be written")
+	fs.BoolVar(&nogoTiming, "nogo_timing", false, "Whether nogo should print how long each analyzer took to run")
 	fs.StringVar(&cgoExportHPath, "cgoexport", "", "The _cgo_exports.h file to write")
+	fs.StringVar(&cgoSrcsDir, "cgo_srcs", "", "The directory where Go files generated by cgo should be written")
 	fs.StringVar(&testFilter, "testfilter", "off", "Controls test package filtering")
+	fs.Var(&checkDeps, "check_dep", "Label and import paths of a dependency listed by the target, separated by '='; reported if no source imports it")
+	fs.StringVar(&unusedDepsMode, "unused_deps", "off", "Whether unused dependencies are reported: off, warn, or error")
+	fs.StringVar(&outUnusedDepsPath, "unused_deps_report", "", "The file where unused dependencies should be written in JSON format")
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
@@ -81,9 +97,29 @@ func compilePkg(args []string) error {
 	for i := range unfilteredSrcs {
 		unfilteredSrcs[i] = abs(unfilteredSrcs[i])
 	}
+	for i := range generatedSrcs {
+		generatedSrcs[i] = abs(generatedSrcs[i])
+	}
 	for i := range coverSrcs {
 		coverSrcs[i] = abs(coverSrcs[i])
 	}
+	for i := range embedSrcs {
+		embedSrcs[i] = abs(embedSrcs[i])
+	}
+	// Sources and embedded files in the source tree are relative to the
+	// execroot.
+	embedRoots = append(embedRoots, ".")
+	for i := range embedRoots {
+		embedRoots[i] = abs(embedRoots[i])
+	}
+
+	// Check for unused dependencies before filtering, so that deps imported
+	// only on other platforms aren't reported.
+	if outUnusedDepsPath != "" {
+		if err := checkUnusedDeps(targetLabel, unfilteredSrcs, checkDeps, unusedDepsMode, outUnusedDepsPath); err != nil {
+			return err
+		}
+	}
 
 	// Filter sources.
 	srcs, err := filterAndSplitFiles(unfilteredSrcs)
@@ -94,36 +130,23 @@ func compilePkg(args []string) error {
 	// TODO(jayconrod): remove -testfilter flag. The test action should compile
 	// the main, internal, and external packages by calling compileArchive
 	// with the correct sources for each.
-	switch testFilter {
-	case "off":
-	case "only":
-		testSrcs := make([]fileInfo, 0, len(srcs.goSrcs))
-		for _, f := range srcs.goSrcs {
-			if strings.HasSuffix(f.pkg, "_test") {
-				testSrcs = append(testSrcs, f)
-			}
-		}
-		srcs.goSrcs = testSrcs
-	case "exclude":
-		libSrcs := make([]fileInfo, 0, len(srcs.goSrcs))
-		for _, f := range srcs.goSrcs {
-			if !strings.HasSuffix(f.pkg, "_test") {
-				libSrcs = append(libSrcs, f)
-			}
-		}
-		srcs.goSrcs = libSrcs
-	default:
-		return fmt.Errorf("invalid test filter %q", testFilter)
+	if err := applyTestFilter(&srcs, testFilter); err != nil {
+		return err
 	}
 
 	return compileArchive(
 		goenv,
 		importPath,
 		packagePath,
+		targetLabel,
 		srcs,
 		deps,
 		coverMode,
+		coverFormat,
+		coverMainMode,
 		coverSrcs,
+		embedSrcs,
+		embedRoots,
 		cgoEnabled,
 		cc,
 		gcFlags,
@@ -135,20 +158,60 @@ func compilePkg(args []string) error {
 		objcxxFlags,
 		ldFlags,
 		nogoPath,
+		generatedSrcs,
+		nogoTiming,
 		packageListPath,
 		outPath,
 		outFactsPath,
-		cgoExportHPath)
+		outNogoSARIFPath,
+		outNogoFindingsPath,
+		outExportDataPath,
+		outEmbedcfgPath,
+		cgoExportHPath,
+		cgoSrcsDir)
+}
+
+// applyTestFilter removes Go sources from srcs according to the -testfilter
+// flag. "only" keeps files in external test packages, "exclude" drops them,
+// and "off" keeps all files.
+func applyTestFilter(srcs *archiveSrcs, testFilter string) error {
+	switch testFilter {
+	case "off":
+	case "only":
+		testSrcs := make([]fileInfo, 0, len(srcs.goSrcs))
+		for _, f := range srcs.goSrcs {
+			if strings.HasSuffix(f.pkg, "_test") {
+				testSrcs = append(testSrcs, f)
+			}
+		}
+		srcs.goSrcs = testSrcs
+	case "exclude":
+		libSrcs := make([]fileInfo, 0, len(srcs.goSrcs))
+		for _, f := range srcs.goSrcs {
+			if !strings.HasSuffix(f.pkg, "_test") {
+				libSrcs = append(libSrcs, f)
+			}
+		}
+		srcs.goSrcs = libSrcs
+	default:
+		return fmt.Errorf("invalid test filter %q", testFilter)
+	}
+	return nil
 }
 
 func compileArchive(
 	goenv *env,
 	importPath string,
 	packagePath string,
+	targetLabel string,
 	srcs archiveSrcs,
 	deps []archive,
 	coverMode string,
+	coverFormat string,
+	coverMainMode string,
 	coverSrcs []string,
+	embedSrcs []string,
+	embedRoots []string,
 	cgoEnabled bool,
 	cc string,
 	gcFlags []string,
@@ -160,10 +223,17 @@ func compileArchive(
 	objcxxFlags []string,
 	ldFlags []string,
 	nogoPath string,
+	generatedSrcs []string,
+	nogoTiming bool,
 	packageListPath string,
 	outPath string,
 	outFactsPath string,
-	cgoExportHPath string) error {
+	outNogoSARIFPath string,
+	outNogoFindingsPath string,
+	outExportDataPath string,
+	outEmbedcfgPath string,
+	cgoExportHPath string,
+	cgoSrcsDir string) error {
 
 	workDir, cleanup, err := goenv.workDir()
 	if err != nil {
@@ -220,7 +290,16 @@ func compileArchive(
 	haveCgo := len(cgoSrcs)+len(cSrcs)+len(cxxSrcs)+len(objcSrcs)+len(objcxxSrcs) > 0
 
 	// Instrument source files for coverage.
-	if coverMode != "" {
+	var coverCfgPath string
+	if coverMode != "" && coverFormat == "gocoverdir" {
+		goSrcs, cgoSrcs, coverCfgPath, err = instrumentPackageForCoverDir(goenv, workDir, importPath, packageName, coverMode, coverMainMode, coverSrcs, goSrcs, cgoSrcs, cgoEnabled)
+		if err != nil {
+			return err
+		}
+		if coverCfgPath != "" {
+			gcFlags = append(gcFlags, "-coveragecfg="+coverCfgPath)
+		}
+	} else if coverMode != "" {
 		shouldCover := make(map[string]bool)
 		for _, s := range coverSrcs {
 			shouldCover[s] = true
@@ -266,10 +345,17 @@ func compileArchive(
 		// If cgo is not enabled or we don't have other cgo sources, don't
 		// compile .S files.
 		var srcDir string
+		nGoSrcs := len(goSrcs)
 		srcDir, goSrcs, objFiles, err = cgo2(goenv, goSrcs, cgoSrcs, cSrcs, cxxSrcs, objcSrcs, objcxxSrcs, nil, hSrcs, packagePath, packageName, cc, cppFlags, cFlags, cxxFlags, objcFlags, objcxxFlags, ldFlags, cgoExportHPath)
 		if err != nil {
 			return err
 		}
+		if cgoSrcsDir != "" {
+			// cgo2 returns the files it generated after the regular Go files.
+			if err := writeCgoSrcs(cgoSrcsDir, goSrcs[nGoSrcs:]); err != nil {
+				return err
+			}
+		}
 
 		gcFlags = append(gcFlags, "-trimpath="+srcDir)
 	} else {
@@ -278,9 +364,41 @@ func compileArchive(
 				return err
 			}
 		}
+		if cgoSrcsDir != "" {
+			if err := writeCgoSrcs(cgoSrcsDir, nil); err != nil {
+				return err
+			}
+		}
 		gcFlags = append(gcFlags, "-trimpath=.")
 	}
 
+	// Resolve //go:embed patterns against embedsrcs.
+	embedPatterns, err := readEmbedPatterns(srcs.goSrcs, embedRoots)
+	if err != nil {
+		return err
+	}
+	if len(embedPatterns) > 0 {
+		if !goVersionAtLeast(16) {
+			return fmt.Errorf("%s: //go:embed requires go1.16 or later", embedPatterns[0].pos)
+		}
+		cfg, err := buildEmbedcfg(embedPatterns, embedSrcs, embedRoots)
+		if err != nil {
+			return err
+		}
+		embedcfgPath := outEmbedcfgPath
+		if embedcfgPath == "" {
+			embedcfgPath = filepath.Join(workDir, "embedcfg")
+		}
+		if err := writeEmbedcfg(cfg, abs(embedcfgPath)); err != nil {
+			return err
+		}
+		gcFlags = append(gcFlags, "-embedcfg", abs(embedcfgPath))
+	} else if outEmbedcfgPath != "" {
+		if err := writeEmbedcfg(&embedcfg{}, abs(outEmbedcfgPath)); err != nil {
+			return err
+		}
+	}
+
 	// Check that the filtered sources don't import anything outside of
 	// the standard library and the direct dependencies.
 	imports, err := checkImports(srcs.goSrcs, deps, packageListPath)
@@ -293,7 +411,13 @@ func compileArchive(
 		imports["syscall"] = nil
 		imports["unsafe"] = nil
 	}
-	if coverMode != "" {
+	if coverCfgPath != "" {
+		// Instrumented files import the packages that record coverage data.
+		imports["runtime/coverage"] = nil
+		if coverMode == "atomic" {
+			imports["sync/atomic"] = nil
+		}
+	} else if coverMode != "" && coverFormat != "gocoverdir" {
 		const coverdataPath = "github.com/bazelbuild/rules_go/go/tools/coverdata"
 		var coverdata *archive
 		for i := range deps {
@@ -321,7 +445,19 @@ func compileArchive(
 		ctx, cancel := context.WithCancel(context.Background())
 		nogoChan = make(chan error)
 		go func() {
-			nogoChan <- runNogo(ctx, workDir, nogoPath, goSrcs, deps, packagePath, importcfgPath, outFactsPath)
+			nogoChan <- runNogo(ctx, workDir, nogoArgs{
+				nogoPath:        nogoPath,
+				timing:          nogoTiming,
+				srcs:            goSrcs,
+				generatedSrcs:   generatedSrcs,
+				deps:            deps,
+				packagePath:     packagePath,
+				importcfgPath:   importcfgPath,
+				outFactsPath:    outFactsPath,
+				outSARIFPath:    outNogoSARIFPath,
+				outFindingsPath: outNogoFindingsPath,
+				targetLabel:     targetLabel,
+			})
 		}()
 		defer func() {
 			if nogoChan != nil {
@@ -349,6 +485,14 @@ func compileArchive(
 		return err
 	}
 
+	// Save the export data separately, so nogo actions for packages that
+	// import this one are not invalidated by changes that don't affect it.
+	if outExportDataPath != "" {
+		if err := extractExportData(outPath, abs(outExportDataPath)); err != nil {
+			return err
+		}
+	}
+
 	// Compile the .s files.
 	if len(srcs.sSrcs) > 0 {
 		includeSet := map[string]struct{}{
@@ -395,6 +539,21 @@ func compileArchive(
 	return nil
 }
 
+// writeCgoSrcs copies the Go files generated by cgo into dir, so tools that
+// can't run cgo themselves can read the package as plain Go. dir is created
+// even if there are no files, since it's a declared output.
+func writeCgoSrcs(dir string, srcs []string) error {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	for _, src := range srcs {
+		if err := copyFile(src, filepath.Join(dir, filepath.Base(src))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func compileGo(goenv *env, srcs []string, packagePath, importcfgPath, asmHdrPath, symabisPath string, gcFlags []string, outPath string) error {
 	args := goenv.goTool("compile")
 	args = append(args, "-p", packagePath, "-importcfg", importcfgPath, "-pack")
@@ -412,17 +571,47 @@ func compileGo(goenv *env, srcs []string, packagePath, importcfgPath, asmHdrPath
 	return goenv.runCommand(args)
 }
 
-func runNogo(ctx context.Context, workDir string, nogoPath string, srcs []string, deps []archive, packagePath, importcfgPath, outFactsPath string) error {
-	args := []string{nogoPath}
-	args = append(args, "-p", packagePath)
-	args = append(args, "-importcfg", importcfgPath)
-	for _, dep := range deps {
+// nogoArgs holds the inputs and outputs of a nogo run on one package.
+type nogoArgs struct {
+	nogoPath        string
+	timing          bool
+	srcs            []string
+	generatedSrcs   []string
+	deps            []archive
+	packagePath     string
+	importcfgPath   string
+	outFactsPath    string
+	outSARIFPath    string
+	outFindingsPath string
+	targetLabel     string
+}
+
+func runNogo(ctx context.Context, workDir string, n nogoArgs) error {
+	args := []string{n.nogoPath}
+	args = append(args, "-p", n.packagePath)
+	args = append(args, "-importcfg", n.importcfgPath)
+	if n.targetLabel != "" {
+		args = append(args, "-label", n.targetLabel)
+	}
+	for _, dep := range n.deps {
 		if dep.xFile != "" {
 			args = append(args, "-fact", fmt.Sprintf("%s=%s", dep.importPath, dep.xFile))
 		}
 	}
-	args = append(args, "-x", outFactsPath)
-	args = append(args, srcs...)
+	args = append(args, "-x", n.outFactsPath)
+	if n.outSARIFPath != "" {
+		args = append(args, "-sarif", n.outSARIFPath)
+	}
+	if n.outFindingsPath != "" {
+		args = append(args, "-json", n.outFindingsPath)
+	}
+	if n.timing {
+		args = append(args, "-timing")
+	}
+	for _, src := range n.generatedSrcs {
+		args = append(args, "-generated", src)
+	}
+	args = append(args, n.srcs...)
 
 	paramFile := filepath.Join(workDir, "nogo.param")
 	params := strings.Join(args[1:], "\n")
@@ -447,6 +636,10 @@ func runNogo(ctx context.Context, workDir string, nogoPath string, srcs []string
 			return fmt.Errorf("error running nogo: %v", err)
 		}
 	}
+	if out.Len() != 0 {
+		// Print analyzer timings.
+		os.Stderr.Write(out.Bytes())
+	}
 	return nil
 }
 
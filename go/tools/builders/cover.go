@@ -16,6 +16,7 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"go/ast"
@@ -23,7 +24,9 @@ import (
 	"go/parser"
 	"go/token"
 	"io/ioutil"
+	"path/filepath"
 	"strconv"
+	"strings"
 )
 
 // cover transforms a source file with "go tool cover". It is invoked by the
@@ -137,6 +140,107 @@ func init() {
 	return nil
 }
 
+// coverPkgConfig is the configuration passed to "go tool cover" with -pkgcfg.
+// It matches cmd/internal/cov/covcmd.CoverPkgConfig.
+type coverPkgConfig struct {
+	OutConfig   string
+	PkgPath     string
+	PkgName     string
+	Granularity string
+	ModulePath  string
+}
+
+// instrumentPackageForCoverDir runs "go tool cover" on the sources of a
+// package that should be instrumented for coverage, in the format supported by
+// Go 1.20 and later. Instrumented programs write coverage data to the
+// directory named by GOCOVERDIR when they exit, so servers and other programs
+// started by tests can report coverage too.
+//
+// Files in coverSrcs are instrumented together, since the cover tool writes
+// metadata for the whole package. If none of the package's files are in
+// coverSrcs and it's a main package, all of its files are instrumented with
+// mainMode instead, which only registers the hook that writes coverage data
+// for the other packages in the program.
+//
+// goSrcs and cgoSrcs are returned with instrumented files replacing the
+// originals, and an extra file declaring coverage variables added to goSrcs.
+// cfgPath is the file to pass to the compiler with -coveragecfg, or "" if
+// nothing was instrumented.
+func instrumentPackageForCoverDir(goenv *env, workDir, importPath, packageName, mode, mainMode string, coverSrcs, goSrcs, cgoSrcs []string, cgoEnabled bool) (newGoSrcs, newCgoSrcs []string, cfgPath string, err error) {
+	if !goVersionAtLeast(20) {
+		return nil, nil, "", fmt.Errorf("cover_format=gocoverdir requires go1.20 or later")
+	}
+	shouldCover := make(map[string]bool)
+	for _, s := range coverSrcs {
+		shouldCover[s] = true
+	}
+	combined := append([]string{}, goSrcs...)
+	if cgoEnabled {
+		combined = append(combined, cgoSrcs...)
+	}
+	var indices []int
+	for i, src := range combined {
+		if shouldCover[src] {
+			indices = append(indices, i)
+		}
+	}
+	if len(indices) == 0 {
+		if packageName != "main" || mainMode == "" {
+			return goSrcs, cgoSrcs, "", nil
+		}
+		mode = mainMode
+		for i := range combined {
+			indices = append(indices, i)
+		}
+	}
+
+	cfgPath = filepath.Join(workDir, "coveragecfg")
+	pkgcfg, err := json.Marshal(coverPkgConfig{
+		OutConfig:   cfgPath,
+		PkgPath:     importPath,
+		PkgName:     packageName,
+		Granularity: "perblock",
+	})
+	if err != nil {
+		return nil, nil, "", err
+	}
+	pkgcfgPath := filepath.Join(workDir, "coverpkgcfg")
+	if err := ioutil.WriteFile(pkgcfgPath, pkgcfg, 0666); err != nil {
+		return nil, nil, "", err
+	}
+
+	// The first output file declares the coverage variables. The others are
+	// the instrumented sources, in the same order as the inputs.
+	varsSrc := filepath.Join(workDir, "cover_vars.go")
+	outFiles := []string{varsSrc}
+	var inFiles []string
+	for _, i := range indices {
+		inFiles = append(inFiles, combined[i])
+		outFiles = append(outFiles, filepath.Join(workDir, fmt.Sprintf("cover_%d.go", i)))
+	}
+	outFileListPath := filepath.Join(workDir, "cover_outfiles")
+	if err := ioutil.WriteFile(outFileListPath, []byte(strings.Join(outFiles, "\n")+"\n"), 0666); err != nil {
+		return nil, nil, "", err
+	}
+	coverVar := "goCover_" + sanitizePathForIdentifier(importPath)
+	args := goenv.goTool("cover", "-pkgcfg", pkgcfgPath, "-mode", mode, "-var", coverVar, "-outfilelist", outFileListPath)
+	args = append(args, inFiles...)
+	if err := goenv.runCommand(args); err != nil {
+		return nil, nil, "", err
+	}
+
+	newGoSrcs = append([]string{varsSrc}, goSrcs...)
+	newCgoSrcs = append([]string{}, cgoSrcs...)
+	for j, i := range indices {
+		if i < len(goSrcs) {
+			newGoSrcs[i+1] = outFiles[j+1]
+		} else {
+			newCgoSrcs[i-len(goSrcs)] = outFiles[j+1]
+		}
+	}
+	return newGoSrcs, newCgoSrcs, cfgPath, nil
+}
+
 func addNamedImport(fset *token.FileSet, f *ast.File, name, path string) {
 	imp := &ast.ImportSpec{
 		Name: &ast.Ident{Name: name},
@@ -0,0 +1,274 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// embedPattern is a pattern from a //go:embed directive.
+type embedPattern struct {
+	pattern string
+	// pos is the position of the directive, used in error messages.
+	pos token.Position
+	// dir is the slash-separated directory of the file containing the
+	// directive, relative to its root. Patterns are relative to this
+	// directory.
+	dir string
+}
+
+// embedcfg is the file passed to the compiler with -embedcfg. It maps each
+// pattern to the files it matches, and each file to its location on disk.
+type embedcfg struct {
+	Patterns map[string][]string
+	Files    map[string]string
+}
+
+// readEmbedPatterns returns the patterns in //go:embed directives in srcs.
+// roots are the absolute paths of directories that sources and embedded files
+// may be relative to, like the execroot and bazel-out/.../bin.
+func readEmbedPatterns(srcs []fileInfo, roots []string) ([]embedPattern, error) {
+	var patterns []embedPattern
+	for _, src := range srcs {
+		data, err := ioutil.ReadFile(src.filename)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Contains(data, []byte("//go:embed")) {
+			continue
+		}
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, src.filename, data, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		dir := path.Dir(filepath.ToSlash(rootRelative(src.filename, roots)))
+		for _, group := range f.Comments {
+			for _, c := range group.List {
+				if !strings.HasPrefix(c.Text, "//go:embed") {
+					continue
+				}
+				args := strings.TrimPrefix(c.Text, "//go:embed")
+				pos := fset.Position(c.Pos())
+				if args != "" && !unicode.IsSpace(rune(args[0])) {
+					// Some other directive, like //go:embedded.
+					continue
+				}
+				words, err := splitEmbedArgs(args)
+				if err != nil {
+					return nil, fmt.Errorf("%s: invalid //go:embed directive: %v", pos, err)
+				}
+				if len(words) == 0 {
+					return nil, fmt.Errorf("%s: //go:embed directive has no patterns", pos)
+				}
+				for _, w := range words {
+					patterns = append(patterns, embedPattern{pattern: w, pos: pos, dir: dir})
+				}
+			}
+		}
+	}
+	return patterns, nil
+}
+
+// splitEmbedArgs splits the arguments of a //go:embed directive into
+// patterns. Patterns are separated by spaces and may be quoted with Go
+// string syntax.
+func splitEmbedArgs(args string) ([]string, error) {
+	var words []string
+	args = strings.TrimLeftFunc(args, unicode.IsSpace)
+	for args != "" {
+		var word string
+		switch args[0] {
+		case '"', '`':
+			quote := args[0]
+			i := 1
+			for ; i < len(args) && args[i] != quote; i++ {
+				if quote == '"' && args[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(args) {
+				return nil, fmt.Errorf("unterminated string %s", args)
+			}
+			var err error
+			word, err = strconv.Unquote(args[:i+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted string %s", args[:i+1])
+			}
+			args = args[i+1:]
+			if args != "" && !unicode.IsSpace(rune(args[0])) {
+				return nil, fmt.Errorf("invalid quoted string in %s", args)
+			}
+		default:
+			i := strings.IndexFunc(args, unicode.IsSpace)
+			if i < 0 {
+				i = len(args)
+			}
+			word, args = args[:i], args[i:]
+		}
+		words = append(words, word)
+		args = strings.TrimLeftFunc(args, unicode.IsSpace)
+	}
+	return words, nil
+}
+
+// buildEmbedcfg matches patterns against embedSrcs, the absolute paths of
+// files listed in embedsrcs, and returns the configuration for the compiler.
+// Every pattern must match at least one file.
+func buildEmbedcfg(patterns []embedPattern, embedSrcs, roots []string) (*embedcfg, error) {
+	// Index the embedded files by their slash-separated path relative to
+	// their root.
+	relSrcs := make(map[string]string)
+	for _, src := range embedSrcs {
+		relSrcs[filepath.ToSlash(rootRelative(src, roots))] = src
+	}
+	relNames := make([]string, 0, len(relSrcs))
+	for rel := range relSrcs {
+		relNames = append(relNames, rel)
+	}
+	sort.Strings(relNames)
+
+	cfg := &embedcfg{
+		Patterns: make(map[string][]string),
+		Files:    make(map[string]string),
+	}
+	var errs []string
+	for _, p := range patterns {
+		if _, ok := cfg.Patterns[p.pattern]; ok {
+			continue
+		}
+		files, err := matchEmbedPattern(p, relNames)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: pattern %s: %v", p.pos, p.pattern, err))
+			continue
+		}
+		for _, f := range files {
+			cfg.Files[f] = relSrcs[path.Join(p.dir, f)]
+		}
+		cfg.Patterns[p.pattern] = files
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+	return cfg, nil
+}
+
+// matchEmbedPattern returns the files among relNames matched by p, relative
+// to the directory containing the directive. A pattern that matches a
+// directory matches the files in it recursively, except files whose names
+// begin with "." or "_", unless the pattern starts with "all:".
+func matchEmbedPattern(p embedPattern, relNames []string) ([]string, error) {
+	pattern := p.pattern
+	all := strings.HasPrefix(pattern, "all:")
+	if all {
+		pattern = pattern[len("all:"):]
+	}
+	if _, err := path.Match(pattern, ""); err != nil || !validEmbedPattern(pattern) {
+		return nil, errors.New("invalid pattern syntax")
+	}
+
+	var files []string
+	var dirOnly []string
+	prefix := p.dir + "/"
+	if p.dir == "." {
+		prefix = ""
+	}
+	for _, rel := range relNames {
+		if !strings.HasPrefix(rel, prefix) {
+			continue
+		}
+		name := rel[len(prefix):]
+		if ok, _ := path.Match(pattern, name); ok {
+			files = append(files, name)
+			continue
+		}
+		// Check whether the pattern matches a directory containing the file.
+		elems := strings.Split(name, "/")
+		for i := 1; i < len(elems); i++ {
+			if ok, _ := path.Match(pattern, strings.Join(elems[:i], "/")); !ok {
+				continue
+			}
+			hidden := false
+			for _, e := range elems[i:] {
+				if strings.HasPrefix(e, ".") || strings.HasPrefix(e, "_") {
+					hidden = true
+				}
+			}
+			if hidden && !all {
+				dirOnly = append(dirOnly, name)
+			} else {
+				files = append(files, name)
+			}
+			break
+		}
+	}
+	if len(files) == 0 {
+		if len(dirOnly) > 0 {
+			return nil, fmt.Errorf("cannot embed directory containing only hidden files; use \"all:%s\" to include %s", pattern, dirOnly[0])
+		}
+		return nil, fmt.Errorf("no matching files found in embedsrcs (patterns are relative to %s)", p.dir)
+	}
+	return files, nil
+}
+
+// validEmbedPattern reports whether pattern is a valid embed pattern: an
+// unrooted, cleaned path that does not refer to the parent directory.
+func validEmbedPattern(pattern string) bool {
+	if pattern == "" || pattern == "." || strings.HasPrefix(pattern, "/") || strings.HasSuffix(pattern, "/") {
+		return false
+	}
+	for _, elem := range strings.Split(pattern, "/") {
+		if elem == "" || elem == "." || elem == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// writeEmbedcfg writes cfg in the JSON format read by the compiler.
+func writeEmbedcfg(cfg *embedcfg, outPath string) error {
+	data, err := json.MarshalIndent(cfg, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(outPath, data, 0666)
+}
+
+// rootRelative returns p relative to the longest of roots that contains it,
+// or p unchanged if no root contains it.
+func rootRelative(p string, roots []string) string {
+	best := ""
+	for _, root := range roots {
+		if len(root) > len(best) && strings.HasPrefix(p, root+string(filepath.Separator)) {
+			best = root
+		}
+	}
+	if best == "" {
+		return p
+	}
+	return p[len(best)+1:]
+}
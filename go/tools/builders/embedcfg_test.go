@@ -0,0 +1,143 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitEmbedArgs(t *testing.T) {
+	for _, test := range []struct {
+		args    string
+		want    []string
+		wantErr bool
+	}{
+		{args: " a.txt  b/*.txt", want: []string{"a.txt", "b/*.txt"}},
+		{args: ` "with space.txt" ` + "`raw.txt`", want: []string{"with space.txt", "raw.txt"}},
+		{args: ` "unterminated`, wantErr: true},
+		{args: ` "a"b`, wantErr: true},
+		{args: "", want: nil},
+	} {
+		got, err := splitEmbedArgs(test.args)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("splitEmbedArgs(%q) = %q; want error", test.args, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("splitEmbedArgs(%q): %v", test.args, err)
+		} else if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitEmbedArgs(%q) = %q; want %q", test.args, got, test.want)
+		}
+	}
+}
+
+func TestEmbedcfg(t *testing.T) {
+	dir, err := ioutil.TempDir("", "embedcfg_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	binDir := filepath.Join(dir, "bazel-out", "bin")
+	roots := []string{dir, binDir}
+
+	src := filepath.Join(dir, "pkg", "lib.go")
+	if err := os.MkdirAll(filepath.Dir(src), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(src, []byte(`package lib
+
+import "embed"
+
+//go:embed hello.txt
+var hello string
+
+//go:embed static gen.txt
+var files embed.FS
+
+//go:embed all:static
+var all embed.FS
+
+// Not a directive: //go:embed missing.txt
+var notEmbedded string
+`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	embedSrcs := []string{
+		filepath.Join(dir, "pkg", "hello.txt"),
+		filepath.Join(dir, "pkg", "static", "index.html"),
+		filepath.Join(dir, "pkg", "static", "_hidden.html"),
+		filepath.Join(dir, "other", "unused.txt"),
+		filepath.Join(binDir, "pkg", "gen.txt"),
+	}
+
+	patterns, err := readEmbedPatterns([]fileInfo{{filename: src}}, roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range patterns {
+		got = append(got, p.pattern)
+		if p.dir != "pkg" {
+			t.Errorf("pattern %s has dir %q; want %q", p.pattern, p.dir, "pkg")
+		}
+	}
+	if want := []string{"hello.txt", "static", "gen.txt", "all:static"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got patterns %q; want %q", got, want)
+	}
+
+	cfg, err := buildEmbedcfg(patterns, embedSrcs, roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &embedcfg{
+		Patterns: map[string][]string{
+			"hello.txt":  {"hello.txt"},
+			"static":     {"static/index.html"},
+			"gen.txt":    {"gen.txt"},
+			"all:static": {"static/_hidden.html", "static/index.html"},
+		},
+		Files: map[string]string{
+			"hello.txt":           embedSrcs[0],
+			"static/index.html":   embedSrcs[1],
+			"static/_hidden.html": embedSrcs[2],
+			"gen.txt":             embedSrcs[4],
+		},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("got %#v; want %#v", cfg, want)
+	}
+
+	// Patterns that match nothing are reported with their positions.
+	bad := []embedPattern{
+		{pattern: "missing.txt", dir: "pkg", pos: patterns[0].pos},
+		{pattern: "../other/unused.txt", dir: "pkg", pos: patterns[0].pos},
+	}
+	_, err = buildEmbedcfg(bad, embedSrcs, roots)
+	if err == nil {
+		t.Fatal("unmatched patterns did not cause an error")
+	}
+	for _, want := range []string{"lib.go:5:1: pattern missing.txt: no matching files", "pattern ../other/unused.txt: invalid pattern syntax"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not contain %q:\n%v", want, err)
+		}
+	}
+}
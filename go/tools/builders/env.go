@@ -233,6 +233,22 @@ func abs(path string) string {
 	}
 }
 
+// goVersionAtLeast returns whether the Go SDK is at least go1.minor. The
+// builder is compiled with the SDK it runs actions for, so this is the
+// builder's own version. Development versions are assumed to be new enough.
+func goVersionAtLeast(minor int) bool {
+	version := runtime.Version()
+	if !strings.HasPrefix(version, "go1.") {
+		return true
+	}
+	v := version[len("go1."):]
+	if i := strings.IndexFunc(v, func(r rune) bool { return r < '0' || r > '9' }); i >= 0 {
+		v = v[:i]
+	}
+	n, err := strconv.Atoi(v)
+	return err != nil || n >= minor
+}
+
 // absArgs applies abs to strings that appear in args. Only paths that are
 // part of options named by flags are modified.
 func absArgs(args []string, flags []string) {
@@ -22,11 +22,14 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"go/token"
 	"io/ioutil"
 	"math"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"text/template"
 )
 
@@ -46,9 +49,23 @@ import (
 
 var analyzers = []*analysis.Analyzer{
 {{- range $import := .Imports}}
+{{- if not $import.Bundle}}
 	{{$import.Name}}.Analyzer,
 {{- end}}
+{{- end}}
+}
+
+{{- if .HasBundles}}
+
+func init() {
+{{- range $import := .Imports}}
+{{- if $import.Bundle}}
+	analyzers = append(analyzers, analyzerBundle({{printf "%q" $import.Path}}, {{$import.Name}}.{{$import.Variable}}
+		{{- range $name := $import.Names}}, {{printf "%q" $name}}{{end}})...)
+{{- end}}
+{{- end}}
 }
+{{- end}}
 
 // configs maps analysis names to configurations.
 var configs = map[string]config{
@@ -74,9 +91,38 @@ var configs = map[string]config{
 			{{- end}}
 		},
 		{{- end}}
+		{{- if $config.Targets}}
+		targets: []targetRule{
+			{{- range $pattern, $action := $config.Targets}}
+			{pattern: mustParseTargetPattern({{printf "%q" $pattern}}), include: {{eq $action "include"}}},
+			{{- end}}
+		},
+		{{- end}}
+		{{- if $config.AnalyzerFlags}}
+		analyzerFlags: map[string]string{
+			{{- range $flag, $value := $config.AnalyzerFlags}}
+			{{printf "%q" $flag}}: {{printf "%q" $value}},
+			{{- end}}
+		},
+		{{- end}}
+		{{- if eq $config.Severity "warning"}}
+		warning: true,
+		{{- end}}
+		{{- if $config.GeneratedFiles}}
+		generatedFiles: {{printf "%q" $config.GeneratedFiles}},
+		{{- end}}
 	},
 {{- end}}
 }
+
+// baseline is the set of fingerprints of findings that existed before
+// analyzers were enabled. These findings are reported but do not fail the
+// build.
+var baseline = map[string]bool{
+{{- range $fingerprint := .Baseline}}
+	{{printf "%q" $fingerprint}}: true,
+{{- end}}
+}
 `
 
 func genNogoMain(args []string) error {
@@ -84,7 +130,10 @@ func genNogoMain(args []string) error {
 	flags := flag.NewFlagSet("generate_nogo_main", flag.ExitOnError)
 	out := flags.String("output", "", "output file to write (defaults to stdout)")
 	flags.Var(&analyzerImportPaths, "analyzer_importpath", "import path of an analyzer library")
+	analyzerBundles := multiFlag{}
+	flags.Var(&analyzerBundles, "analyzer_bundle", "import path of an analyzer bundle library, the name of the variable listing its analyzers, and a comma-separated list of analyzer names to include, separated by '='")
 	configFile := flags.String("config", "", "nogo config file")
+	baselineFile := flags.String("baseline", "", "nogo baseline file")
 	if err := flags.Parse(args); err != nil {
 		return err
 	}
@@ -108,13 +157,22 @@ func genNogoMain(args []string) error {
 	if err != nil {
 		return err
 	}
+	baseline, err := readBaseline(*baselineFile)
+	if err != nil {
+		return err
+	}
 
 	type Import struct {
 		Path, Name string
+		// Bundle is true if the package exports a variable listing several
+		// analyzers instead of a single Analyzer variable.
+		Bundle   bool
+		Variable string
+		Names    []string
 	}
 	// Create unique name for each imported analyzer.
 	suffix := 1
-	imports := make([]Import, 0, len(analyzerImportPaths))
+	imports := make([]Import, 0, len(analyzerImportPaths)+len(analyzerBundles))
 	for _, path := range analyzerImportPaths {
 		imports = append(imports, Import{
 			Path: path,
@@ -124,13 +182,40 @@ func genNogoMain(args []string) error {
 		}
 		suffix++
 	}
+	for _, bundle := range analyzerBundles {
+		parts := strings.Split(bundle, "=")
+		if len(parts) != 3 {
+			return fmt.Errorf("invalid analyzer bundle %q: must have import path, variable, and names separated by '='", bundle)
+		}
+		imp := Import{
+			Path:     parts[0],
+			Name:     "analyzer" + strconv.Itoa(suffix),
+			Bundle:   true,
+			Variable: parts[1],
+		}
+		if !token.IsExported(imp.Variable) {
+			return fmt.Errorf("invalid analyzer bundle %q: %q is not an exported identifier", bundle, imp.Variable)
+		}
+		if parts[2] != "" {
+			imp.Names = strings.Split(parts[2], ",")
+		}
+		imports = append(imports, imp)
+		if suffix == math.MaxInt32 {
+			return fmt.Errorf("cannot generate more than %d analyzers", suffix)
+		}
+		suffix++
+	}
 	data := struct {
 		Imports    []Import
 		Configs    Configs
+		Baseline   []string
 		NeedRegexp bool
+		HasBundles bool
 	}{
-		Imports: imports,
-		Configs: config,
+		Imports:    imports,
+		Configs:    config,
+		Baseline:   baseline,
+		HasBundles: len(analyzerBundles) > 0,
 	}
 	for _, c := range config {
 		if len(c.OnlyFiles) > 0 || len(c.ExcludeFiles) > 0 {
@@ -169,10 +254,43 @@ func buildConfig(path string) (Configs, error) {
 				return Configs{}, fmt.Errorf("invalid pattern for analysis %q: %v", name, err)
 			}
 		}
+		patterns := make(map[targetPattern]string)
+		for pattern, action := range config.Targets {
+			p, err := parseTargetPattern(pattern)
+			if err != nil {
+				return Configs{}, fmt.Errorf("invalid target pattern for analysis %q: %v", name, err)
+			}
+			if action != "include" && action != "exclude" {
+				return Configs{}, fmt.Errorf("invalid action for target pattern %q of analysis %q: %q (must be \"include\" or \"exclude\")", pattern, name, action)
+			}
+			if other, ok := patterns[p]; ok {
+				return Configs{}, fmt.Errorf("target patterns %q and %q of analysis %q are equivalent", other, pattern, name)
+			}
+			patterns[p] = pattern
+		}
+		for flag := range config.AnalyzerFlags {
+			if strings.HasPrefix(flag, "-") {
+				return Configs{}, fmt.Errorf("invalid flag for analysis %q: %q (flag names must not start with \"-\")", name, flag)
+			}
+		}
+		switch config.Severity {
+		case "", "error", "warning":
+		default:
+			return Configs{}, fmt.Errorf("invalid severity for analysis %q: %q (must be \"error\" or \"warning\")", name, config.Severity)
+		}
+		switch config.GeneratedFiles {
+		case "", "report", "warning", "exclude":
+		default:
+			return Configs{}, fmt.Errorf("invalid generated_files for analysis %q: %q (must be \"report\", \"warning\", or \"exclude\")", name, config.GeneratedFiles)
+		}
 		configs[name] = Config{
 			// Description is currently unused.
-			OnlyFiles:    config.OnlyFiles,
-			ExcludeFiles: config.ExcludeFiles,
+			OnlyFiles:      config.OnlyFiles,
+			ExcludeFiles:   config.ExcludeFiles,
+			Targets:        config.Targets,
+			Severity:       config.Severity,
+			GeneratedFiles: config.GeneratedFiles,
+			AnalyzerFlags:  config.AnalyzerFlags,
 		}
 	}
 	return configs, nil
@@ -181,7 +299,54 @@ func buildConfig(path string) (Configs, error) {
 type Configs map[string]Config
 
 type Config struct {
-	Description  string
-	OnlyFiles    map[string]string `json:"only_files"`
-	ExcludeFiles map[string]string `json:"exclude_files"`
+	Description    string
+	OnlyFiles      map[string]string `json:"only_files"`
+	ExcludeFiles   map[string]string `json:"exclude_files"`
+	Targets        map[string]string `json:"targets"`
+	Severity       string            `json:"severity"`
+	GeneratedFiles string            `json:"generated_files"`
+	AnalyzerFlags  map[string]string `json:"analyzer_flags"`
+}
+
+// readBaseline returns the sorted fingerprints of the findings listed in a
+// baseline file.
+func readBaseline(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline file: %v", err)
+	}
+	var baseline Baseline
+	if err := json.Unmarshal(b, &baseline); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal baseline file: %v", err)
+	}
+	seen := make(map[string]bool)
+	fingerprints := make([]string, 0, len(baseline.Findings))
+	for _, f := range baseline.Findings {
+		if f.Fingerprint == "" {
+			return nil, fmt.Errorf("baseline finding for %s in %s has no fingerprint", f.Analyzer, f.File)
+		}
+		if !seen[f.Fingerprint] {
+			seen[f.Fingerprint] = true
+			fingerprints = append(fingerprints, f.Fingerprint)
+		}
+	}
+	sort.Strings(fingerprints)
+	return fingerprints, nil
+}
+
+// Baseline lists findings that existed before analyzers were enabled. Only
+// fingerprints are used to match findings. The other fields are there to make
+// the file reviewable.
+type Baseline struct {
+	Findings []BaselineFinding `json:"findings"`
+}
+
+type BaselineFinding struct {
+	Analyzer    string `json:"analyzer"`
+	File        string `json:"file"`
+	Message     string `json:"message"`
+	Fingerprint string `json:"fingerprint"`
 }
@@ -50,20 +50,41 @@ type Example struct {
 
 // Cases holds template data.
 type Cases struct {
-	RunDir     string
-	Imports    []*Import
-	Tests      []TestCase
-	Benchmarks []TestCase
-	Examples   []Example
-	TestMain   string
-	Coverage   bool
-	Pkgname    string
+	RunDir      string
+	Imports     []*Import
+	Tests       []TestCase
+	Benchmarks  []TestCase
+	FuzzTargets []TestCase
+	Examples    []Example
+	TestMain    string
+	Coverage    bool
+	Pkgname     string
+
+	// CovdataTool is the runfiles path of the SDK's covdata tool, used to
+	// convert coverage data written to GOCOVERDIR into a coverage profile.
+	// It's set instead of Coverage for Go 1.20+ binary coverage.
+	CovdataTool string
+
+	// BenchmarkBaseline is the runfiles path of benchmark results to compare
+	// with, and BenchmarkThreshold is the largest slowdown allowed, in
+	// percent. Both are set by go_benchmark.
+	BenchmarkBaseline  string
+	BenchmarkThreshold float64
+
+	// Fuzzing is true if the testing package supports native fuzzing.
+	Fuzzing bool
+	// FuzzSrcDir is the workspace-relative directory the test changes to
+	// before fuzzing under "bazel run", and FuzzCorpus is the directory within
+	// it where generated inputs are stored. Both are set by go_fuzz_test.
+	FuzzSrcDir string
+	FuzzCorpus string
 }
 
 const testMainTpl = `
 package main
 import (
 	"flag"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
@@ -94,35 +115,124 @@ var benchmarks = []testing.InternalBenchmark{
 {{end}}
 }
 
-var examples = []testing.InternalExample{
+var allExamples = []testing.InternalExample{
 {{range .Examples}}
 	{Name: "{{.Name}}", F: {{.Package}}.{{.Name}}, Output: {{printf "%q" .Output}}, Unordered: {{.Unordered}} },
 {{end}}
 }
 
-func testsInShard() []testing.InternalTest {
-	totalShards, err := strconv.Atoi(os.Getenv("TEST_TOTAL_SHARDS"))
-	if err != nil || totalShards <= 1 {
-		return allTests
+// shardIndex returns the index of the shard to run and the total number of
+// shards when Bazel runs the test with shard_count set. ok is false if the
+// test is not sharded. Bazel expects the test to create
+// TEST_SHARD_STATUS_FILE to show that it supports sharding.
+func shardIndex() (index, total int, ok bool) {
+	total, err := strconv.Atoi(os.Getenv("TEST_TOTAL_SHARDS"))
+	if err != nil || total <= 1 {
+		return 0, 1, false
 	}
-	shardIndex, err := strconv.Atoi(os.Getenv("TEST_SHARD_INDEX"))
-	if err != nil || shardIndex < 0 {
-		return allTests
+	index, err = strconv.Atoi(os.Getenv("TEST_SHARD_INDEX"))
+	if err != nil || index < 0 || index >= total {
+		log.Fatalf("invalid TEST_SHARD_INDEX %q for %d shards", os.Getenv("TEST_SHARD_INDEX"), total)
+	}
+	if statusFile := os.Getenv("TEST_SHARD_STATUS_FILE"); statusFile != "" {
+		if err := ioutil.WriteFile(statusFile, nil, 0666); err != nil {
+			log.Fatalf("could not create shard status file: %v", err)
+		}
+	}
+	return index, total, true
+}
+
+// testsInShard returns the tests and examples run by this shard. Tests and
+// examples are assigned to shards round-robin in the order they appear in
+// the source files, so each one runs in exactly one shard, and the
+// assignment doesn't change between runs.
+func testsInShard() ([]testing.InternalTest, []testing.InternalExample) {
+	index, total, ok := shardIndex()
+	if !ok {
+		return allTests, allExamples
 	}
 	tests := []testing.InternalTest{}
 	for i, t := range allTests {
-		if i % totalShards == shardIndex {
+		if i%total == index {
 			tests = append(tests, t)
 		}
 	}
-	return tests
+	examples := []testing.InternalExample{}
+	for i, e := range allExamples {
+		if (len(allTests)+i)%total == index {
+			examples = append(examples, e)
+		}
+	}
+	return tests, examples
+}
+
+{{if .Fuzzing}}
+var allFuzzTargets = []testing.InternalFuzzTarget{
+{{range .FuzzTargets}}
+	{"{{.Name}}", {{.Package}}.{{.Name}} },
+{{end}}
+}
+
+// fuzzTargetsInShard returns the fuzz targets run by this shard. They are
+// assigned to shards after tests and examples.
+func fuzzTargetsInShard() []testing.InternalFuzzTarget {
+	index, total, ok := shardIndex()
+	if !ok {
+		return allFuzzTargets
+	}
+	targets := []testing.InternalFuzzTarget{}
+	for i, t := range allFuzzTargets {
+		if (len(allTests)+len(allExamples)+i)%total == index {
+			targets = append(targets, t)
+		}
+	}
+	return targets
 }
 
+// setUpFuzzing chooses where the fuzzing engine stores the inputs it
+// generates. Under "bazel test", they are kept in TEST_TMPDIR.
+{{- if .FuzzSrcDir}} Under
+// "bazel run", the test runs in its package's source directory, so failing
+// inputs are written to testdata/fuzz next to the seed corpus, and generated
+// inputs are stored in the corpus directory so fuzzing can resume where it
+// left off.
+{{- end}}
+func setUpFuzzing() {
+	cacheDir := ""
+	if tmpDir := os.Getenv("TEST_TMPDIR"); tmpDir != "" {
+		cacheDir = filepath.Join(tmpDir, "fuzzcache")
+	}
+{{if .FuzzSrcDir}}
+	if workspace := os.Getenv("BUILD_WORKSPACE_DIRECTORY"); workspace != "" {
+		srcDir := filepath.Join(workspace, {{printf "%q" .FuzzSrcDir}})
+		if err := os.Chdir(srcDir); err != nil {
+			log.Fatalf("could not change to source directory: %v", err)
+		}
+		os.Setenv("PWD", srcDir)
+		cacheDir = filepath.Join(srcDir, {{printf "%q" .FuzzCorpus}})
+	}
+{{end}}
+	if cacheDir != "" {
+		flag.Lookup("test.fuzzcachedir").Value.Set(cacheDir)
+	}
+}
+{{end}}
+
 func main() {
+	{{if .BenchmarkBaseline}}
+	benchmarkBaseline = {{printf "%q" .BenchmarkBaseline}}
+	benchmarkThreshold = {{.BenchmarkThreshold}}
+	{{end}}
+	{{if .CovdataTool}}
+	covdataTool = {{printf "%q" .CovdataTool}}
+	{{end}}
 	if shouldWrap() {
 		err := wrap("{{.Pkgname}}")
 		if xerr, ok := err.(*exec.ExitError); ok {
 			os.Exit(xerr.ExitCode())
+		} else if err == errBenchmarkRegression || err == errSandboxViolation {
+			log.Print(err)
+			os.Exit(1)
 		} else if err != nil {
 			log.Print(err)
 			os.Exit(testWrapperAbnormalExit)
@@ -130,6 +240,7 @@ func main() {
 			os.Exit(0)
 		}
 	}
+	catchTermSignal()
 
 	// Check if we're being run by Bazel and change directories if so.
 	// TEST_SRCDIR and TEST_WORKSPACE are set by the Bazel test runner, so that makes a decent proxy.
@@ -148,7 +259,13 @@ func main() {
 		}
 	}
 
-	m := testing.MainStart(testdeps.TestDeps{}, testsInShard(), benchmarks, examples)
+	tests, examples := testsInShard()
+	{{if .Fuzzing}}
+	m := testing.MainStart(testdeps.TestDeps{}, tests, benchmarks, fuzzTargetsInShard(), examples)
+	setUpFuzzing()
+	{{else}}
+	m := testing.MainStart(testdeps.TestDeps{}, tests, benchmarks, examples)
+	{{end}}
 
 	if filter := os.Getenv("TESTBRIDGE_TEST_ONLY"); filter != "" {
 		flag.Lookup("test.run").Value.Set(filter)
@@ -186,7 +303,12 @@ func genTestMain(args []string) error {
 	runDir := flags.String("rundir", ".", "Path to directory where tests should run.")
 	out := flags.String("output", "", "output file to write. Defaults to stdout.")
 	coverage := flags.Bool("coverage", false, "whether coverage is supported")
+	covdataTool := flags.String("covdata", "", "runfiles path of the covdata tool, if the test writes coverage data to GOCOVERDIR")
 	pkgname := flags.String("pkgname", "", "package name of test")
+	fuzzSrcDir := flags.String("fuzzsrcdir", "", "workspace-relative directory to fuzz in under bazel run")
+	fuzzCorpus := flags.String("fuzzcorpus", "", "directory within fuzzsrcdir where generated fuzz inputs are stored")
+	benchBaseline := flags.String("benchbaseline", "", "runfiles path of benchmark results to compare with")
+	benchThreshold := flags.Float64("benchthreshold", 10, "largest benchmark slowdown allowed, in percent")
 	flags.Var(&imports, "import", "Packages to import")
 	flags.Var(&sources, "src", "Sources to process for tests")
 	if err := flags.Parse(args); err != nil {
@@ -238,6 +360,16 @@ func genTestMain(args []string) error {
 		RunDir:   strings.Replace(filepath.FromSlash(*runDir), `\`, `\\`, -1),
 		Coverage: *coverage,
 		Pkgname:  *pkgname,
+		Fuzzing:  goVersionAtLeast(18), // native fuzzing was added in go1.18
+
+		CovdataTool: *covdataTool,
+
+		BenchmarkBaseline:  *benchBaseline,
+		BenchmarkThreshold: *benchThreshold,
+	}
+	if *fuzzSrcDir != "" {
+		cases.FuzzSrcDir = filepath.FromSlash(*fuzzSrcDir)
+		cases.FuzzCorpus = filepath.FromSlash(*fuzzCorpus)
 	}
 
 	testFileSet := token.NewFileSet()
@@ -327,6 +459,16 @@ func genTestMain(args []string) error {
 					Name:    fn.Name.Name,
 				})
 			}
+			if strings.HasPrefix(fn.Name.Name, "Fuzz") && cases.Fuzzing {
+				if selExpr.Sel.Name != "F" {
+					continue
+				}
+				pkgs[pkg] = true
+				cases.FuzzTargets = append(cases.FuzzTargets, TestCase{
+					Package: pkg,
+					Name:    fn.Name.Name,
+				})
+			}
 		}
 	}
 
@@ -34,6 +34,7 @@ const (
 	archiveMode
 	copyMode
 	linkMode
+	materializeMode
 )
 
 func modeFromString(s string) (mode, error) {
@@ -44,6 +45,8 @@ func modeFromString(s string) (mode, error) {
 		return copyMode, nil
 	case "link":
 		return linkMode, nil
+	case "materialize":
+		return materializeMode, nil
 	default:
 		return invalidMode, fmt.Errorf("invalid mode: %s", s)
 	}
@@ -51,6 +54,10 @@ func modeFromString(s string) (mode, error) {
 
 type manifestEntry struct {
 	Src, Dst string
+
+	// copy is set for files in directories listed in the manifest. They are
+	// copied even in link mode.
+	copy bool
 }
 
 func main() {
@@ -66,7 +73,7 @@ func run(args []string) error {
 	flags := flag.NewFlagSet("go_path", flag.ContinueOnError)
 	flags.StringVar(&manifest, "manifest", "", "name of json file listing files to include")
 	flags.StringVar(&out, "out", "", "output file or directory")
-	modeFlag := flags.String("mode", "", "copy, link, or archive")
+	modeFlag := flags.String("mode", "", "copy, link, materialize, or archive")
 	if err := flags.Parse(args); err != nil {
 		return err
 	}
@@ -92,7 +99,7 @@ func run(args []string) error {
 	switch mode {
 	case archiveMode:
 		err = archivePath(out, entries)
-	case copyMode:
+	case copyMode, materializeMode:
 		err = copyPath(out, entries)
 	case linkMode:
 		err = linkPath(out, entries)
@@ -109,7 +116,44 @@ func readManifest(path string) ([]manifestEntry, error) {
 	if err := json.Unmarshal(data, &entries); err != nil {
 		return nil, fmt.Errorf("error unmarshalling manifest %s: %v", path, err)
 	}
-	return entries, nil
+	return expandDirEntries(entries)
+}
+
+// expandDirEntries replaces entries whose sources are directories (tree
+// artifacts, like the Go files generated by cgo) with entries for the files
+// they contain. In link mode, these files are copied rather than linked,
+// since symlinks in a tree artifact can't point outside of it.
+func expandDirEntries(entries []manifestEntry) ([]manifestEntry, error) {
+	var expanded []manifestEntry
+	for _, entry := range entries {
+		st, err := os.Stat(entry.Src)
+		if err != nil {
+			return nil, err
+		}
+		if !st.IsDir() {
+			expanded = append(expanded, entry)
+			continue
+		}
+		err = filepath.Walk(entry.Src, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(entry.Src, path)
+			if err != nil {
+				return err
+			}
+			expanded = append(expanded, manifestEntry{
+				Src:  path,
+				Dst:  entry.Dst + "/" + filepath.ToSlash(rel),
+				copy: true,
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return expanded, nil
 }
 
 func archivePath(out string, manifest []manifestEntry) (err error) {
@@ -158,28 +202,30 @@ func copyPath(out string, manifest []manifestEntry) error {
 		if err := os.MkdirAll(filepath.Dir(dst), 0777); err != nil {
 			return err
 		}
-		srcFile, err := os.Open(entry.Src)
-		if err != nil {
-			return err
-		}
-		dstFile, err := os.Create(dst)
-		if err != nil {
-			srcFile.Close()
-			return err
-		}
-		if _, err := io.Copy(dstFile, srcFile); err != nil {
-			dstFile.Close()
-			srcFile.Close()
-			return err
-		}
-		srcFile.Close()
-		if err := dstFile.Close(); err != nil {
+		if err := copyFile(entry.Src, dst); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+func copyFile(src, dst string) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+	dstFile, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dstFile, srcFile); err != nil {
+		dstFile.Close()
+		return err
+	}
+	return dstFile.Close()
+}
+
 func linkPath(out string, manifest []manifestEntry) error {
 	// out directory may already exist and may contain old symlinks. Delete.
 	if err := os.RemoveAll(out); err != nil {
@@ -191,10 +237,16 @@ func linkPath(out string, manifest []manifestEntry) error {
 	for _, entry := range manifest {
 		dst := filepath.Join(out, filepath.FromSlash(entry.Dst))
 		dstDir := filepath.Dir(dst)
-		src, _ := filepath.Rel(dstDir, entry.Src)
 		if err := os.MkdirAll(dstDir, 0777); err != nil {
 			return err
 		}
+		if entry.copy {
+			if err := copyFile(entry.Src, dst); err != nil {
+				return err
+			}
+			continue
+		}
+		src, _ := filepath.Rel(dstDir, entry.Src)
 		if err := os.Symlink(src, dst); err != nil {
 			return err
 		}
@@ -65,10 +65,17 @@ func checkImports(files []fileInfo, archives []archive, stdPackageListPath strin
 	// Index the archives.
 	importToArchive := make(map[string]*archive)
 	importAliasToArchive := make(map[string]*archive)
+	ambiguousAliases := make(map[string][]*archive)
 	for i := range archives {
 		arc := &archives[i]
 		importToArchive[arc.importPath] = arc
 		for _, imp := range arc.importPathAliases {
+			if other := importAliasToArchive[imp]; other != nil && other.packagePath != arc.packagePath {
+				if len(ambiguousAliases[imp]) == 0 {
+					ambiguousAliases[imp] = append(ambiguousAliases[imp], other)
+				}
+				ambiguousAliases[imp] = append(ambiguousAliases[imp], arc)
+			}
 			importAliasToArchive[imp] = arc
 		}
 	}
@@ -87,6 +94,8 @@ func checkImports(files []fileInfo, archives []archive, stdPackageListPath strin
 				imports[path] = nil
 			} else if arc := importToArchive[path]; arc != nil {
 				imports[path] = arc
+			} else if arcs := ambiguousAliases[path]; len(arcs) > 0 {
+				derr.ambiguous = append(derr.ambiguous, ambiguousDep{f.filename, path, arcs})
 			} else if arc := importAliasToArchive[path]; arc != nil {
 				imports[path] = arc
 			} else {
@@ -94,7 +103,7 @@ func checkImports(files []fileInfo, archives []archive, stdPackageListPath strin
 			}
 		}
 	}
-	if len(derr.missing) > 0 {
+	if len(derr.missing) > 0 || len(derr.ambiguous) > 0 {
 		return nil, derr
 	}
 	return imports, nil
@@ -203,18 +212,39 @@ This will be an error in the future.`, arc.packagePath, arc.label, conflictLabel
 }
 
 type depsError struct {
-	missing []missingDep
-	known   []string
+	missing   []missingDep
+	ambiguous []ambiguousDep
+	known     []string
 }
 
 type missingDep struct {
 	filename, imp string
 }
 
+// ambiguousDep is an import of a path that more than one dependency lists in
+// importpath_aliases.
+type ambiguousDep struct {
+	filename, imp string
+	archives      []*archive
+}
+
 var _ error = depsError{}
 
 func (e depsError) Error() string {
 	buf := bytes.NewBuffer(nil)
+	if len(e.ambiguous) > 0 {
+		fmt.Fprintf(buf, "ambiguous imports:\n")
+		for _, dep := range e.ambiguous {
+			fmt.Fprintf(buf, "\t%s: import of %q, which is an alias of:\n", dep.filename, dep.imp)
+			for _, arc := range dep.archives {
+				fmt.Fprintf(buf, "\t\t%s (%s)\n", arc.importPath, arc.label)
+			}
+		}
+		if len(e.missing) == 0 {
+			fmt.Fprint(buf, "Remove the alias from importpath_aliases in all but one of these libraries, or import one of them by its importpath.")
+			return buf.String()
+		}
+	}
 	fmt.Fprintf(buf, "missing strict dependencies:\n")
 	for _, dep := range e.missing {
 		fmt.Fprintf(buf, "\t%s: import of %q\n", dep.filename, dep.imp)
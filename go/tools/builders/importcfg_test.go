@@ -0,0 +1,80 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckImportsAliases(t *testing.T) {
+	dir, err := ioutil.TempDir("", "importcfg_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	packageList := filepath.Join(dir, "packages.txt")
+	if err := ioutil.WriteFile(packageList, []byte("fmt\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	newLib := archive{label: "//new", importPath: "example.com/new", packagePath: "example.com/new", importPathAliases: []string{"example.com/old"}}
+	oldLib := archive{label: "//old", importPath: "example.com/old", packagePath: "example.com/old"}
+	otherLib := archive{label: "//other", importPath: "example.com/other", packagePath: "example.com/other", importPathAliases: []string{"example.com/old"}}
+
+	for _, test := range []struct {
+		desc     string
+		archives []archive
+		want     string
+		wantErr  string
+	}{
+		{
+			desc:     "alias",
+			archives: []archive{newLib},
+			want:     "example.com/new",
+		}, {
+			desc:     "importpath_wins",
+			archives: []archive{newLib, oldLib},
+			want:     "example.com/old",
+		}, {
+			desc:     "ambiguous",
+			archives: []archive{newLib, otherLib},
+			wantErr:  `import of "example.com/old", which is an alias of`,
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			files := []fileInfo{{filename: "a.go", imports: []string{"fmt", "example.com/old"}}}
+			imports, err := checkImports(files, test.archives, packageList)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("got error %v; want error containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if imports["fmt"] != nil {
+				t.Errorf("fmt resolved to %v; want the standard library", imports["fmt"])
+			}
+			if arc := imports["example.com/old"]; arc == nil || arc.packagePath != test.want {
+				t.Errorf("example.com/old resolved to %v; want %s", arc, test.want)
+			}
+		})
+	}
+}
@@ -50,6 +50,7 @@ func link(args []string) error {
 	flags.Var(&xdefs, "X", "A string variable to replace in the linked binary (repeated).")
 	flags.Var(&xstamps, "Xstamp", "Like -X but the values are looked up in the -stamp file.")
 	flags.Var(&stamps, "stamp", "The name of a file with stamping values.")
+	buildinfoPath := flags.String("buildinfo_path", "", "Import path of the main package to record in the binary's build information, along with VCS settings from the -stamp files.")
 	if err := flags.Parse(builderArgs); err != nil {
 		return err
 	}
@@ -97,6 +98,14 @@ func link(args []string) error {
 	}
 	defer os.Remove(importcfgName)
 
+	// Record build information, which "go version -m" prints. The linker only
+	// accepts it through the importcfg file in Go 1.18 and later.
+	if *buildinfoPath != "" && goVersionAtLeast(18) {
+		if err := appendModinfo(importcfgName, buildInfo(*buildinfoPath, stampMap)); err != nil {
+			return err
+		}
+	}
+
 	// generate any additional link options we need
 	goargs := goenv.goTool("link")
 	goargs = append(goargs, "-importcfg", importcfgName)
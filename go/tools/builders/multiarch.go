@@ -0,0 +1,193 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// multiarch combines binaries built for several platforms into one file,
+// either a macOS universal binary or a tar file with a directory for each
+// platform. It's used by go_multiarch_binary.
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"debug/macho"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+)
+
+// archBinary is a binary built for one platform, along with its path in the
+// combined output.
+type archBinary struct {
+	name string
+	data []byte
+}
+
+func multiarch(args []string) error {
+	args, err := readParamsFiles(args)
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("GoMultiarch", flag.ExitOnError)
+	goenv := envFlags(fs)
+	var binaryFlags multiFlag
+	var format, outPath string
+	fs.Var(&binaryFlags, "binary", "The path of a binary in the output and the file it's read from, separated by '='")
+	fs.StringVar(&format, "format", "", "The format of the output: universal or tar")
+	fs.StringVar(&outPath, "o", "", "The file where the combined binaries should be written")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := goenv.checkFlags(); err != nil {
+		return err
+	}
+
+	var binaries []archBinary
+	for _, f := range binaryFlags {
+		i := strings.IndexByte(f, '=')
+		if i < 0 {
+			return fmt.Errorf("invalid -binary %q; want name=file", f)
+		}
+		data, err := ioutil.ReadFile(f[i+1:])
+		if err != nil {
+			return err
+		}
+		binaries = append(binaries, archBinary{name: f[:i], data: data})
+	}
+
+	var out []byte
+	switch format {
+	case "universal":
+		out, err = universalBinary(binaries)
+	case "tar":
+		out, err = tarBinaries(binaries)
+	default:
+		return fmt.Errorf("invalid -format %q; want universal or tar", format)
+	}
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(outPath, out, 0777)
+}
+
+const (
+	// fatMagic is the magic number of a Mach-O universal binary. debug/macho
+	// can read these files but not write them.
+	fatMagic = 0xcafebabe
+
+	fatHeaderSize = 8
+	fatArchSize   = 20
+)
+
+// fatArch is an entry in the header of a universal binary describing the
+// binary for one architecture.
+type fatArch struct {
+	Cpu    macho.Cpu
+	SubCpu uint32
+	Offset uint32
+	Size   uint32
+	Align  uint32 // as a power of 2
+}
+
+// universalBinary returns a macOS universal binary with the Mach-O binaries
+// in binaries, like "lipo -create". Each binary must be built for a different
+// architecture.
+func universalBinary(binaries []archBinary) ([]byte, error) {
+	if len(binaries) == 0 {
+		return nil, fmt.Errorf("no binaries to combine")
+	}
+	archs := make([]fatArch, len(binaries))
+	seen := make(map[macho.Cpu]string)
+	offset := uint64(fatHeaderSize + fatArchSize*len(binaries))
+	for i, b := range binaries {
+		f, err := macho.NewFile(bytes.NewReader(b.data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: not a Mach-O binary: %v", b.name, err)
+		}
+		if other, ok := seen[f.Cpu]; ok {
+			return nil, fmt.Errorf("%s and %s are both built for %v", other, b.name, f.Cpu)
+		}
+		seen[f.Cpu] = b.name
+
+		// Binaries start on a page boundary, so they can be mapped directly.
+		align := uint32(12)
+		if f.Cpu == macho.CpuArm || f.Cpu == macho.CpuArm64 {
+			align = 14
+		}
+		offset = (offset + 1<<align - 1) &^ (1<<align - 1)
+		if offset+uint64(len(b.data)) > 1<<32 {
+			return nil, fmt.Errorf("%s: universal binary would be larger than 4GB", b.name)
+		}
+		archs[i] = fatArch{
+			Cpu:    f.Cpu,
+			SubCpu: f.SubCpu,
+			Offset: uint32(offset),
+			Size:   uint32(len(b.data)),
+			Align:  align,
+		}
+		offset += uint64(len(b.data))
+	}
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, [2]uint32{fatMagic, uint32(len(archs))})
+	binary.Write(&buf, binary.BigEndian, archs)
+	out := buf.Bytes()
+	for i, b := range binaries {
+		out = append(out, make([]byte, int(archs[i].Offset)-len(out))...)
+		out = append(out, b.data...)
+	}
+	return out, nil
+}
+
+// tarBinaries returns a tar file with each of the binaries, and a directory
+// entry for each directory they're in. Modification times are zero, so the
+// output only depends on the binaries.
+func tarBinaries(binaries []archBinary) ([]byte, error) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	mtime := time.Unix(0, 0)
+	dirs := make(map[string]bool)
+	for _, b := range binaries {
+		if dir := path.Dir(b.name); dir != "." && !dirs[dir] {
+			dirs[dir] = true
+			if err := tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     dir + "/",
+				Mode:     0755,
+				ModTime:  mtime,
+			}); err != nil {
+				return nil, err
+			}
+		}
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     b.name,
+			Mode:     0755,
+			Size:     int64(len(b.data)),
+			ModTime:  mtime,
+		}); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(b.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"debug/macho"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+// machOBinary returns a minimal 64-bit Mach-O executable for cpu.
+func machOBinary(t *testing.T, cpu macho.Cpu) []byte {
+	var buf bytes.Buffer
+	hdr := macho.FileHeader{Magic: macho.Magic64, Cpu: cpu, SubCpu: 3, Type: macho.TypeExec}
+	if err := binary.Write(&buf, binary.LittleEndian, &hdr); err != nil {
+		t.Fatal(err)
+	}
+	buf.Write(make([]byte, 4)) // reserved
+	buf.WriteString("contents for " + cpu.String())
+	return buf.Bytes()
+}
+
+func TestUniversalBinary(t *testing.T) {
+	binaries := []archBinary{
+		{name: "darwin_amd64", data: machOBinary(t, macho.CpuAmd64)},
+		{name: "darwin_arm64", data: machOBinary(t, macho.CpuArm64)},
+	}
+	data, err := universalBinary(binaries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := macho.NewFatFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Arches) != len(binaries) {
+		t.Fatalf("got %d architectures; want %d", len(f.Arches), len(binaries))
+	}
+	for i, arch := range f.Arches {
+		if want := []macho.Cpu{macho.CpuAmd64, macho.CpuArm64}[i]; arch.Cpu != want {
+			t.Errorf("architecture %d: got %v; want %v", i, arch.Cpu, want)
+		}
+		if arch.Offset%(1<<arch.Align) != 0 {
+			t.Errorf("%v: offset %d is not aligned to 2^%d", arch.Cpu, arch.Offset, arch.Align)
+		}
+		got := data[arch.Offset : arch.Offset+arch.Size]
+		if !bytes.Equal(got, binaries[i].data) {
+			t.Errorf("%v: contents differ from the input binary", arch.Cpu)
+		}
+	}
+
+	if _, err := universalBinary(append(binaries, binaries[0])); err == nil {
+		t.Error("got no error for two binaries with the same architecture")
+	}
+	if _, err := universalBinary([]archBinary{{name: "linux_amd64", data: []byte("\x7fELF")}}); err == nil {
+		t.Error("got no error for a binary that isn't Mach-O")
+	}
+}
+
+func TestTarBinaries(t *testing.T) {
+	data, err := tarBinaries([]archBinary{
+		{name: "linux_amd64/server", data: []byte("linux")},
+		{name: "windows_amd64/server.exe", data: []byte("windows")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(bytes.NewReader(data))
+	var names []string
+	contents := make(map[string]string)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+		if hdr.ModTime.Unix() != 0 {
+			t.Errorf("%s: got modification time %v; want 0", hdr.Name, hdr.ModTime)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			b, err := ioutil.ReadAll(tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			contents[hdr.Name] = string(b)
+		}
+	}
+	wantNames := []string{"linux_amd64/", "linux_amd64/server", "windows_amd64/", "windows_amd64/server.exe"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("got entries %q; want %q", names, wantNames)
+	}
+	if contents["windows_amd64/server.exe"] != "windows" {
+		t.Errorf("got contents %q for windows_amd64/server.exe; want %q", contents["windows_amd64/server.exe"], "windows")
+	}
+}
@@ -0,0 +1,83 @@
+/* Copyright 2020 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Identifies findings in a way that survives unrelated edits, so that
+// findings recorded in a baseline file can be suppressed.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// execRoot is the directory nogo was started in. Bazel runs actions in the
+// execution root, so source paths are reported relative to it.
+var execRoot, _ = os.Getwd()
+
+// workspaceRelative returns filename relative to the execution root, with
+// forward slashes, so that findings don't depend on where a sandbox happened
+// to be located. Files outside the execution root are returned unchanged.
+func workspaceRelative(filename string) string {
+	rel, err := filepath.Rel(execRoot, filename)
+	if err != nil {
+		return filepath.ToSlash(filename)
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return filepath.ToSlash(filename)
+	}
+	return rel
+}
+
+// fingerprint identifies a finding without using its line number, so that
+// findings can be matched across revisions that move code around. It hashes
+// the analyzer, the file, the message, and the text of the reported line with
+// surrounding whitespace removed.
+//
+// Baseline files are lists of fingerprints, so changing this function
+// invalidates existing baselines. If it must change, also change
+// sarifFingerprintKey.
+func fingerprint(analyzer, file, message, lineText string) string {
+	h := sha256.New()
+	for _, s := range []string{analyzer, file, message, strings.TrimSpace(lineText)} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// sourceLineCache holds the lines of source files read while computing
+// fingerprints. Files that can't be read are cached as having no lines.
+type sourceLineCache map[string][][]byte
+
+func (c sourceLineCache) line(filename string, line int) string {
+	lines, ok := c[filename]
+	if !ok {
+		if data, err := ioutil.ReadFile(filename); err == nil {
+			lines = bytes.Split(data, []byte("\n"))
+		}
+		c[filename] = lines
+	}
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return string(lines[line-1])
+}
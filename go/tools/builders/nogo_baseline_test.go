@@ -0,0 +1,40 @@
+/* Copyright 2020 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestWorkspaceRelative(t *testing.T) {
+	outside, err := filepath.Abs(filepath.Join(execRoot, "..", "other", "a.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		filename, want string
+	}{
+		{filepath.Join(execRoot, "foo", "a.go"), "foo/a.go"},
+		{filepath.Join(execRoot, "..foo", "a.go"), "..foo/a.go"},
+		{outside, filepath.ToSlash(outside)},
+		{filepath.Dir(execRoot), filepath.ToSlash(filepath.Dir(execRoot))},
+	} {
+		if got := workspaceRelative(test.filename); got != test.want {
+			t.Errorf("workspaceRelative(%q): got %q; want %q", test.filename, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+/* Copyright 2020 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Reads the lines changed by a patch, so that only nogo findings on those
+// lines fail the build.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// changedLines maps workspace-relative file paths to the lines changed in
+// each file. A nil slice means every line in the file changed.
+type changedLines map[string][]lineRange
+
+// lineRange is an inclusive range of 1-based line numbers.
+type lineRange struct {
+	start, end int
+}
+
+// readChangedLines reads the file at path, which is either a unified diff
+// (like the output of "git diff") or a list of changed lines. See
+// parseUnifiedDiff and parseLineList.
+func readChangedLines(path string) (changedLines, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if isUnifiedDiff(data) {
+		return parseUnifiedDiff(data)
+	}
+	return parseLineList(data)
+}
+
+// includes returns whether any line from start to end in file changed. end
+// may be zero if the end is not known.
+func (c changedLines) includes(file string, start, end int) bool {
+	ranges, ok := c[file]
+	if !ok {
+		return false
+	}
+	if ranges == nil {
+		return true
+	}
+	if end < start {
+		end = start
+	}
+	for _, r := range ranges {
+		if r.start <= end && start <= r.end {
+			return true
+		}
+	}
+	return false
+}
+
+func (c changedLines) add(file string, r lineRange) {
+	ranges, ok := c[file]
+	if ok && ranges == nil {
+		// The whole file already changed.
+		return
+	}
+	c[file] = append(ranges, r)
+}
+
+func isUnifiedDiff(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("--- ")) ||
+		bytes.Contains(data, []byte("\n--- ")) ||
+		bytes.HasPrefix(data, []byte("diff ")) ||
+		bytes.Contains(data, []byte("\n+++ "))
+}
+
+var hunkHeaderRe = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@`)
+
+// parseUnifiedDiff returns the lines added or modified by a unified diff,
+// numbered as in the new version of each file. Paths in "+++" lines have
+// their "b/" prefix removed, as written by "git diff". Deleted files are
+// ignored.
+func parseUnifiedDiff(data []byte) (changedLines, error) {
+	changed := make(changedLines)
+	file := ""
+	line, remaining := 0, 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		text := scanner.Text()
+		if remaining > 0 {
+			// Inside a hunk.
+			switch {
+			case strings.HasPrefix(text, "+"):
+				if file != "" {
+					changed.add(file, lineRange{line, line})
+				}
+				line++
+				remaining--
+			case strings.HasPrefix(text, "-"):
+			case strings.HasPrefix(text, `\`):
+				// "\ No newline at end of file"
+			default:
+				line++
+				remaining--
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(text, "+++ "):
+			file = strings.TrimPrefix(text, "+++ ")
+			if i := strings.IndexByte(file, '\t'); i >= 0 {
+				// Some tools write a timestamp after the path.
+				file = file[:i]
+			}
+			if file == "/dev/null" {
+				file = ""
+			} else {
+				file = strings.TrimPrefix(file, "b/")
+			}
+		case strings.HasPrefix(text, "@@ "):
+			m := hunkHeaderRe.FindStringSubmatch(text)
+			if m == nil {
+				return nil, fmt.Errorf("invalid hunk header: %q", text)
+			}
+			line, _ = strconv.Atoi(m[1])
+			remaining = 1
+			if m[2] != "" {
+				remaining, _ = strconv.Atoi(m[2])
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// parseLineList returns the changed lines listed in data. Each non-empty line
+// names a workspace-relative file, optionally followed by a colon and a line
+// number or an inclusive range of line numbers:
+//
+//     foo/foo.go         every line in foo/foo.go changed
+//     foo/bar.go:12      line 12 changed
+//     foo/bar.go:20-25   lines 20 through 25 changed
+//
+// Lines starting with "#" are comments.
+func parseLineList(data []byte) (changedLines, error) {
+	changed := make(changedLines)
+	for i, text := range strings.Split(string(data), "\n") {
+		text = strings.TrimSpace(text)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		colon := strings.LastIndexByte(text, ':')
+		if colon < 0 {
+			changed[text] = nil
+			continue
+		}
+		file, lines := text[:colon], text[colon+1:]
+		var r lineRange
+		var err error
+		if dash := strings.IndexByte(lines, '-'); dash >= 0 {
+			if r.start, err = strconv.Atoi(lines[:dash]); err == nil {
+				r.end, err = strconv.Atoi(lines[dash+1:])
+			}
+		} else {
+			r.start, err = strconv.Atoi(lines)
+			r.end = r.start
+		}
+		if err != nil || r.start < 1 || r.end < r.start {
+			return nil, fmt.Errorf("line %d: invalid line range %q", i+1, lines)
+		}
+		changed.add(file, r)
+	}
+	return changed, nil
+}
@@ -0,0 +1,120 @@
+/* Copyright 2020 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseUnifiedDiff(t *testing.T) {
+	diff := `diff --git a/foo/foo.go b/foo/foo.go
+index 1111111..2222222 100644
+--- a/foo/foo.go
++++ b/foo/foo.go
+@@ -3,4 +3,5 @@ package foo
+ func A() {
+-	old()
++	new()
++	newer()
+ }
+
+@@ -20,2 +21,2 @@ func B() {
+-	x := 1
++	x := 2
+ 	_ = x
+diff --git a/gone.go b/gone.go
+deleted file mode 100644
+--- a/gone.go
++++ /dev/null
+@@ -1,1 +0,0 @@
+-package gone
+--- /dev/null
++++ b/new.go	2020-01-01 00:00:00
+@@ -0,0 +1,2 @@
++package new
++
+\ No newline at end of file
+`
+	got, err := parseUnifiedDiff([]byte(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := changedLines{
+		"foo/foo.go": {{4, 4}, {5, 5}, {21, 21}},
+		"new.go":     {{1, 1}, {2, 2}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+	if !isUnifiedDiff([]byte(diff)) {
+		t.Error("diff was not recognized as a unified diff")
+	}
+}
+
+func TestParseLineList(t *testing.T) {
+	list := `# changed lines
+foo/foo.go:12
+foo/foo.go:20-25
+
+bar/bar.go
+bar/bar.go:3
+`
+	if isUnifiedDiff([]byte(list)) {
+		t.Fatal("line list was recognized as a unified diff")
+	}
+	got, err := parseLineList([]byte(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := changedLines{
+		"foo/foo.go": {{12, 12}, {20, 25}},
+		"bar/bar.go": nil,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+
+	for _, bad := range []string{"foo.go:x", "foo.go:0", "foo.go:5-3", "foo.go:1-"} {
+		if _, err := parseLineList([]byte(bad)); err == nil {
+			t.Errorf("parseLineList(%q) succeeded; want error", bad)
+		}
+	}
+}
+
+func TestChangedLinesIncludes(t *testing.T) {
+	changed := changedLines{
+		"foo.go": {{10, 12}},
+		"bar.go": nil,
+	}
+	for _, test := range []struct {
+		file       string
+		start, end int
+		want       bool
+	}{
+		{"foo.go", 10, 0, true},
+		{"foo.go", 12, 12, true},
+		{"foo.go", 9, 0, false},
+		{"foo.go", 5, 10, true},
+		{"foo.go", 13, 20, false},
+		{"bar.go", 100, 0, true},
+		{"baz.go", 1, 0, false},
+	} {
+		if got := changed.includes(test.file, test.start, test.end); got != test.want {
+			t.Errorf("includes(%q, %d, %d) = %v; want %v", test.file, test.start, test.end, got, test.want)
+		}
+	}
+}
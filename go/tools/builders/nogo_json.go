@@ -0,0 +1,89 @@
+/* Copyright 2020 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Writes nogo findings in a simple JSON format, so they can be aggregated by
+// tools that don't need the full SARIF schema.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+)
+
+// jsonFindings is the top-level object of a findings file.
+type jsonFindings struct {
+	// Package is the package path (importmap) of the analyzed package.
+	Package  string        `json:"package"`
+	Findings []jsonFinding `json:"findings"`
+}
+
+type jsonFinding struct {
+	Analyzer string `json:"analyzer"`
+	Category string `json:"category,omitempty"`
+	// Severity is "error" or "warning".
+	Severity string `json:"severity"`
+	// Baselined is true if the finding is listed in the baseline and did not
+	// fail the build.
+	Baselined bool `json:"baselined,omitempty"`
+	// Generated is true if the file is produced by another rule rather than
+	// checked in.
+	Generated bool   `json:"generated,omitempty"`
+	Message   string `json:"message"`
+	// File is the path of the source file, relative to the workspace root if
+	// the file is in the main workspace.
+	File        string `json:"file"`
+	Line        int    `json:"line"`
+	Column      int    `json:"column"`
+	EndLine     int    `json:"end_line,omitempty"`
+	EndColumn   int    `json:"end_column,omitempty"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// writeJSONFindings writes the given findings to path. The file is written
+// even if there are no findings, so that every analyzed package has one.
+func writeJSONFindings(path, packagePath string, findings []finding) error {
+	out := jsonFindings{
+		Package:  packagePath,
+		Findings: make([]jsonFinding, 0, len(findings)),
+	}
+	for _, f := range findings {
+		severity := "error"
+		if f.warning {
+			severity = "warning"
+		}
+		jf := jsonFinding{
+			Analyzer:    f.analyzer.Name,
+			Category:    f.diagnostic.Category,
+			Severity:    severity,
+			Baselined:   f.baselined,
+			Generated:   f.generated,
+			Message:     f.diagnostic.Message,
+			File:        workspaceRelative(f.pos.Filename),
+			Line:        f.pos.Line,
+			Column:      f.pos.Column,
+			Fingerprint: f.fingerprint,
+		}
+		if f.end.IsValid() {
+			jf.EndLine, jf.EndColumn = f.end.Line, f.end.Column
+		}
+		out.Findings = append(out.Findings, jf)
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0666)
+}
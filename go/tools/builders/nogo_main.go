@@ -29,6 +29,7 @@ import (
 	"go/parser"
 	"go/token"
 	"go/types"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
@@ -37,18 +38,13 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/analysis/internal/facts"
 	"golang.org/x/tools/go/gcexportdata"
 )
 
-func init() {
-	if err := analysis.Validate(analyzers); err != nil {
-		log.Fatal(err)
-	}
-}
-
 var typesSizes = types.SizesFor("gc", os.Getenv("GOARCH"))
 
 func main() {
@@ -60,7 +56,9 @@ func main() {
 }
 
 // run returns an error if there is a problem loading the package or if any
-// analysis fails.
+// analysis fails. Findings don't cause an error: they're written to the
+// reports, which are checked by a separate validation action, so that the
+// reports are kept when there are findings that fail the build.
 func run(args []string) error {
 	args, err := readParamsFiles(args)
 	if err != nil {
@@ -68,11 +66,17 @@ func run(args []string) error {
 	}
 
 	factMap := factMultiFlag{}
+	var generatedSrcs multiFlag
 	flags := flag.NewFlagSet("nogo", flag.ExitOnError)
 	flags.Var(&factMap, "fact", "Import path and file containing facts for that library, separated by '=' (may be repeated)'")
 	importcfg := flags.String("importcfg", "", "The import configuration file")
 	packagePath := flags.String("p", "", "The package path (importmap) of the package being compiled")
+	targetLabel := flags.String("label", "", "The label of the target the package is compiled for")
 	xPath := flags.String("x", "", "The file where serialized facts should be written")
+	sarifPath := flags.String("sarif", "", "The file where findings should be written in SARIF format")
+	jsonPath := flags.String("json", "", "The file where findings should be written in JSON format")
+	timing := flags.Bool("timing", false, "Whether to print how long each analyzer took to run")
+	flags.Var(&generatedSrcs, "generated", "A source file produced by another rule rather than checked in (may be repeated)")
 	flags.Parse(args)
 	srcs := flags.Args()
 
@@ -81,12 +85,43 @@ func run(args []string) error {
 		return fmt.Errorf("error parsing importcfg: %v", err)
 	}
 
-	diagnostics, facts, err := checkPackage(analyzers, *packagePath, packageFile, importMap, factMap, srcs)
+	for _, src := range generatedSrcs {
+		generated[workspaceRelative(abs(src))] = true
+	}
+
+	var target label
+	if *targetLabel != "" {
+		if target, err = parseLabel(*targetLabel); err != nil {
+			return err
+		}
+	}
+
+	// Analyzers are validated here rather than in an init function, since
+	// generated init functions add analyzers from bundles, and they may run
+	// after one in this file.
+	if err := analysis.Validate(analyzers); err != nil {
+		return err
+	}
+	if err := setAnalyzerFlags(analyzers); err != nil {
+		return err
+	}
+	var timingOut io.Writer
+	if *timing {
+		timingOut = os.Stderr
+	}
+	findings, facts, err := checkPackage(analyzers, *packagePath, target, packageFile, importMap, factMap, srcs, timingOut)
 	if err != nil {
 		return fmt.Errorf("error running analyzers: %v", err)
 	}
-	if diagnostics != "" {
-		return fmt.Errorf("errors found by nogo during build-time code analysis:\n%s\n", diagnostics)
+	if *sarifPath != "" {
+		if err := writeSARIF(abs(*sarifPath), analyzers, findings); err != nil {
+			return fmt.Errorf("error writing SARIF report: %v", err)
+		}
+	}
+	if *jsonPath != "" {
+		if err := writeJSONFindings(abs(*jsonPath), *packagePath, findings); err != nil {
+			return fmt.Errorf("error writing JSON findings: %v", err)
+		}
 	}
 	if *xPath != "" {
 		if err := ioutil.WriteFile(abs(*xPath), facts, 0666); err != nil {
@@ -97,6 +132,120 @@ func run(args []string) error {
 	return nil
 }
 
+// analyzerBundle returns the analyzers listed in v, a variable exported by
+// the package at importPath. This lets nogo run suites of analyzers, like
+// staticcheck, without a library for each analyzer. v may be a slice or map
+// whose elements are *analysis.Analyzer values or structs (or pointers to
+// structs) with an Analyzer field of that type. Map elements are ordered by
+// key. If names are given, only the analyzers with those names are returned.
+//
+// analyzerBundle is called by generated code when the nogo binary starts, so
+// it exits if v can't be used.
+func analyzerBundle(importPath string, v interface{}, names ...string) []*analysis.Analyzer {
+	rv := reflect.ValueOf(v)
+	var elems []reflect.Value
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			elems = append(elems, rv.Index(i))
+		}
+	case reflect.Map:
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, k := range keys {
+			elems = append(elems, rv.MapIndex(k))
+		}
+	default:
+		log.Fatalf("analyzer bundle %s: unsupported type %T; must be a slice or map", importPath, v)
+	}
+
+	all := make([]*analysis.Analyzer, 0, len(elems))
+	byName := make(map[string]*analysis.Analyzer)
+	for _, e := range elems {
+		a := bundleAnalyzer(e)
+		if a == nil {
+			log.Fatalf("analyzer bundle %s: unsupported element type %s", importPath, e.Type())
+		}
+		all = append(all, a)
+		byName[a.Name] = a
+	}
+	if len(names) == 0 {
+		return all
+	}
+	selected := make([]*analysis.Analyzer, 0, len(names))
+	for _, name := range names {
+		a, ok := byName[name]
+		if !ok {
+			log.Fatalf("analyzer bundle %s has no analyzer named %q", importPath, name)
+		}
+		selected = append(selected, a)
+	}
+	return selected
+}
+
+// bundleAnalyzer returns the analyzer in an element of an analyzer bundle,
+// or nil if the element is not supported.
+func bundleAnalyzer(v reflect.Value) *analysis.Analyzer {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if a, ok := v.Interface().(*analysis.Analyzer); ok {
+			return a
+		}
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	f := v.FieldByName("Analyzer")
+	if !f.IsValid() || !f.CanInterface() {
+		return nil
+	}
+	a, _ := f.Interface().(*analysis.Analyzer)
+	return a
+}
+
+// setAnalyzerFlags sets the flags of the given analyzers and the analyzers
+// they require to the values in their configurations.
+func setAnalyzerFlags(analyzers []*analysis.Analyzer) error {
+	seen := make(map[*analysis.Analyzer]bool)
+	var visit func(a *analysis.Analyzer) error
+	visit = func(a *analysis.Analyzer) error {
+		if seen[a] {
+			return nil
+		}
+		seen[a] = true
+		names := make([]string, 0, len(configs[a.Name].analyzerFlags))
+		for name := range configs[a.Name].analyzerFlags {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if a.Flags.Lookup(name) == nil {
+				return fmt.Errorf("analyzer %q has no flag %q", a.Name, name)
+			}
+			if err := a.Flags.Set(name, configs[a.Name].analyzerFlags[name]); err != nil {
+				return fmt.Errorf("setting flag %q of analyzer %q: %v", name, a.Name, err)
+			}
+		}
+		for _, req := range a.Requires {
+			if err := visit(req); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, a := range analyzers {
+		if err := visit(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Adapted from go/src/cmd/compile/internal/gc/main.go. Keep in sync.
 func readImportCfg(file string) (packageFile map[string]string, importMap map[string]string, err error) {
 	packageFile, importMap = make(map[string]string), make(map[string]string)
@@ -141,11 +290,17 @@ func readImportCfg(file string) (packageFile map[string]string, importMap map[st
 }
 
 // checkPackage runs all the given analyzers on the specified package and
-// returns the source code diagnostics that the must be printed in the build log.
-// It returns an empty string if no source code diagnostics need to be printed.
+// returns the findings that were not discarded by analyzer configuration,
+// along with the facts exported for importers of the package. An error is
+// returned if the package can't be loaded or if any analyzer fails. target is
+// the label of the target the package is compiled for; it may be empty. If
+// timing is not nil, the time taken by each analyzer is printed to it.
+//
+// Each analyzer is run at most once, even if it is required by several
+// others, so expensive prerequisites like buildssa are shared.
 //
 // This implementation was adapted from that of golang.org/x/tools/go/checker/internal/checker.
-func checkPackage(analyzers []*analysis.Analyzer, packagePath string, packageFile, importMap map[string]string, factMap map[string]string, filenames []string) (string, []byte, error) {
+func checkPackage(analyzers []*analysis.Analyzer, packagePath string, target label, packageFile, importMap map[string]string, factMap map[string]string, filenames []string, timing io.Writer) ([]finding, []byte, error) {
 	// Register fact types and establish dependencies between analyzers.
 	actions := make(map[*analysis.Analyzer]*action)
 	var visit func(a *analysis.Analyzer) *action
@@ -179,7 +334,7 @@ func checkPackage(analyzers []*analysis.Analyzer, packagePath string, packageFil
 	imp := newImporter(importMap, packageFile, factMap)
 	pkg, err := load(packagePath, imp, filenames)
 	if err != nil {
-		return "", nil, fmt.Errorf("error loading package: %v", err)
+		return nil, nil, fmt.Errorf("error loading package: %v", err)
 	}
 	for _, act := range actions {
 		act.pkg = pkg
@@ -187,11 +342,17 @@ func checkPackage(analyzers []*analysis.Analyzer, packagePath string, packageFil
 
 	// Execute the analyzers.
 	execAll(roots)
+	if timing != nil {
+		printTimings(timing, packagePath, actions, analyzers)
+	}
 
 	// Process diagnostics and encode facts for importers of this package.
-	diagnostics := checkAnalysisResults(roots, pkg)
+	findings, err := checkAnalysisResults(roots, pkg, target)
+	if err != nil {
+		return nil, nil, err
+	}
 	facts := pkg.facts.Encode()
-	return diagnostics, facts, nil
+	return findings, facts, nil
 }
 
 // An action represents one unit of analysis work: the application of
@@ -209,6 +370,9 @@ type action struct {
 	diagnostics []analysis.Diagnostic
 	usesFacts   bool
 	err         error
+	// duration is the time taken by the analyzer's Run function, not
+	// including its prerequisites.
+	duration time.Duration
 }
 
 func (act *action) String() string {
@@ -283,7 +447,9 @@ func (act *action) execOnce() {
 	if act.pkg.illTyped && !pass.Analyzer.RunDespiteErrors {
 		err = fmt.Errorf("analysis skipped due to type-checking error: %v", act.pkg.typeCheckError)
 	} else {
+		start := time.Now()
 		act.result, err = pass.Analyzer.Run(pass)
+		act.duration = time.Since(start)
 		if err == nil {
 			if got, want := reflect.TypeOf(act.result), pass.Analyzer.ResultType; got != want {
 				err = fmt.Errorf(
@@ -295,6 +461,34 @@ func (act *action) execOnce() {
 	act.err = err
 }
 
+// printTimings prints the time taken by each analyzer that ran, slowest
+// first. Analyzers that only ran because another analyzer required them are
+// marked as prerequisites.
+func printTimings(w io.Writer, packagePath string, actions map[*analysis.Analyzer]*action, analyzers []*analysis.Analyzer) {
+	isRoot := make(map[*analysis.Analyzer]bool)
+	for _, a := range analyzers {
+		isRoot[a] = true
+	}
+	sorted := make([]*action, 0, len(actions))
+	for _, act := range actions {
+		sorted = append(sorted, act)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].duration != sorted[j].duration {
+			return sorted[i].duration > sorted[j].duration
+		}
+		return sorted[i].a.Name < sorted[j].a.Name
+	})
+	fmt.Fprintf(w, "nogo analyzer timings for %s:\n", packagePath)
+	for _, act := range sorted {
+		suffix := ""
+		if !isRoot[act.a] {
+			suffix = " (prerequisite)"
+		}
+		fmt.Fprintf(w, "%10.3fms  %s%s\n", float64(act.duration)/float64(time.Millisecond), act.a.Name, suffix)
+	}
+}
+
 // load parses and type checks the source code in each file in filenames.
 // load also deserializes facts stored for imported packages.
 func load(packagePath string, imp *importer, filenames []string) (*goPackage, error) {
@@ -362,12 +556,95 @@ func (g *goPackage) String() string {
 	return g.types.Path()
 }
 
+// A finding is a diagnostic reported by an analyzer that was not discarded
+// by that analyzer's configuration.
+type finding struct {
+	analyzer   *analysis.Analyzer
+	diagnostic analysis.Diagnostic
+	// pos and end are the resolved positions of diagnostic.Pos and
+	// diagnostic.End. end is invalid if the analyzer did not report an end.
+	pos, end token.Position
+	// fingerprint identifies the finding independently of its line number.
+	// See fingerprint.
+	fingerprint string
+	// baselined is true if the finding is listed in the baseline, which means
+	// it was present before the analyzer was enabled and must not fail the
+	// build.
+	baselined bool
+	// warning is true if the analyzer is configured with the "warning"
+	// severity, or the finding is in a generated file and the analyzer is
+	// configured to report those as warnings. Warnings are printed by the
+	// validation action but do not fail the build.
+	warning bool
+	// generated is true if the finding is in a file produced by another rule
+	// rather than checked in.
+	generated bool
+	// fixes are the diagnostic's suggested fixes, with positions resolved to
+	// byte offsets so they can be applied outside of nogo.
+	fixes []fix
+}
+
+// A fix is a suggested fix for a finding. Applying all of its edits resolves
+// the finding.
+type fix struct {
+	message string
+	edits   []edit
+}
+
+// An edit replaces length bytes at offset in file with newText. file is
+// relative to the execution root.
+type edit struct {
+	file           string
+	offset, length int
+	newText        string
+}
+
+// resolveFixes converts the suggested fixes of a diagnostic reported in file
+// into fixes with byte offsets. Offsets must be in the file that is edited, so
+// positions are not adjusted by //line directives. A fix is dropped if any of
+// its edits is outside of file, as in files generated by cgo, whose positions
+// refer to the user's source while offsets are in generated code.
+func resolveFixes(fset *token.FileSet, file string, fixes []analysis.SuggestedFix) []fix {
+	var resolved []fix
+	for _, sf := range fixes {
+		f := fix{message: sf.Message}
+		for _, te := range sf.TextEdits {
+			start := fset.PositionFor(te.Pos, false)
+			end := start
+			if te.End.IsValid() {
+				end = fset.PositionFor(te.End, false)
+			}
+			if !start.IsValid() || start.Filename != file || end.Filename != start.Filename {
+				f.edits = nil
+				break
+			}
+			f.edits = append(f.edits, edit{
+				file:    workspaceRelative(start.Filename),
+				offset:  start.Offset,
+				length:  end.Offset - start.Offset,
+				newText: string(te.NewText),
+			})
+		}
+		if len(f.edits) > 0 {
+			resolved = append(resolved, f)
+		}
+	}
+	return resolved
+}
+
+// generated is the set of source files produced by other rules rather than
+// checked in, relative to the execution root. How findings in them are
+// handled depends on each analyzer's configuration. It is set by run.
+var generated = make(map[string]bool)
+
 // checkAnalysisResults checks the analysis diagnostics in the given actions
-// and returns a string containing all the diagnostics that should be printed
-// to the build log.
-func checkAnalysisResults(actions []*action, pkg *goPackage) string {
-	var diagnostics []analysis.Diagnostic
+// and returns the findings that were not discarded by analyzer configuration,
+// sorted by position. An error listing the analyzers that failed is returned
+// if there are any.
+func checkAnalysisResults(actions []*action, pkg *goPackage, target label) ([]finding, error) {
+	var findings []finding
 	var errs []error
+	lines := make(sourceLineCache)
 	for _, act := range actions {
 		if act.err != nil {
 			// Analyzer failed.
@@ -378,59 +655,46 @@ func checkAnalysisResults(actions []*action, pkg *goPackage) string {
 			continue
 		}
 		config, ok := configs[act.a.Name]
-		if !ok {
-			// If the analyzer is not explicitly configured, it emits diagnostics for
-			// all files.
-			diagnostics = append(diagnostics, act.diagnostics...)
+		if ok && !config.includesTarget(target) {
 			continue
 		}
-		// Discard diagnostics based on the analyzer configuration.
 		for _, d := range act.diagnostics {
-			filename := pkg.fset.File(d.Pos).Name()
-			include := true
-			if len(config.onlyFiles) > 0 {
-				// This analyzer emits diagnostics for only a set of files.
-				include = false
-				for _, pattern := range config.onlyFiles {
-					if pattern.MatchString(filename) {
-						include = true
-						break
-					}
-				}
+			// If the analyzer is not explicitly configured, it emits diagnostics for
+			// all files. Otherwise, discard diagnostics based on the analyzer
+			// configuration.
+			if ok && !config.includes(pkg.fset.File(d.Pos).Name()) {
+				continue
 			}
-			if include {
-				for _, pattern := range config.excludeFiles {
-					if pattern.MatchString(filename) {
-						include = false
-						break
-					}
-				}
+			f := finding{analyzer: act.a, diagnostic: d, pos: pkg.fset.Position(d.Pos)}
+			if d.End.IsValid() {
+				f.end = pkg.fset.Position(d.End)
 			}
-			if include {
-				diagnostics = append(diagnostics, d)
+			f.generated = generated[workspaceRelative(f.pos.Filename)]
+			if f.generated && ok && config.generatedFiles == "exclude" {
+				continue
 			}
+			f.fingerprint = fingerprint(act.a.Name, workspaceRelative(f.pos.Filename), d.Message, lines.line(f.pos.Filename, f.pos.Line))
+			f.baselined = baseline[f.fingerprint]
+			f.warning = ok && (config.warning || f.generated && config.generatedFiles == "warning")
+			f.fixes = resolveFixes(pkg.fset, f.pos.Filename, d.SuggestedFixes)
+			findings = append(findings, f)
 		}
 	}
-	if len(diagnostics) == 0 && len(errs) == 0 {
-		return ""
+	if len(errs) > 0 {
+		errMsg := &bytes.Buffer{}
+		for i, err := range errs {
+			if i > 0 {
+				errMsg.WriteString("\n")
+			}
+			errMsg.WriteString(err.Error())
+		}
+		return nil, errors.New(errMsg.String())
 	}
 
-	sort.Slice(diagnostics, func(i, j int) bool {
-		return diagnostics[i].Pos < diagnostics[j].Pos
+	sort.Slice(findings, func(i, j int) bool {
+		return findings[i].diagnostic.Pos < findings[j].diagnostic.Pos
 	})
-	errMsg := &bytes.Buffer{}
-	sep := ""
-	for _, err := range errs {
-		errMsg.WriteString(sep)
-		sep = "\n"
-		errMsg.WriteString(err.Error())
-	}
-	for _, d := range diagnostics {
-		errMsg.WriteString(sep)
-		sep = "\n"
-		fmt.Fprintf(errMsg, "%s: %s", pkg.fset.Position(d.Pos), d.Message)
-	}
-	return errMsg.String()
+	return findings, nil
 }
 
 // config determines which source files an analyzer will emit diagnostics for.
@@ -445,6 +709,71 @@ type config struct {
 	// excludeFiles is a list of regular expressions that match files that an
 	// analyzer will not emit diagnostics for.
 	excludeFiles []*regexp.Regexp
+
+	// targets is a list of target patterns that determine which targets an
+	// analyzer will emit diagnostics for. The most specific pattern that
+	// matches a target applies. When no pattern matches, the analyzer emits
+	// diagnostics for the target.
+	targets []targetRule
+
+	// warning is true if the analyzer's diagnostics should be printed in the
+	// build log without failing the build.
+	warning bool
+
+	// generatedFiles determines how diagnostics in files produced by other
+	// rules are handled: "report" or "" like other files, "warning" as
+	// warnings, and "exclude" not at all.
+	generatedFiles string
+
+	// analyzerFlags maps the names of flags in the analyzer's Flags to the
+	// values they should be set to before the analyzer runs.
+	analyzerFlags map[string]string
+}
+
+// includes returns whether an analyzer with this configuration should emit
+// diagnostics for the named file.
+func (c config) includes(filename string) bool {
+	if len(c.onlyFiles) > 0 {
+		// This analyzer emits diagnostics for only a set of files.
+		matched := false
+		for _, pattern := range c.onlyFiles {
+			if pattern.MatchString(filename) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for _, pattern := range c.excludeFiles {
+		if pattern.MatchString(filename) {
+			return false
+		}
+	}
+	return true
+}
+
+// targetRule includes or excludes targets matched by a pattern.
+type targetRule struct {
+	pattern targetPattern
+	include bool
+}
+
+// includesTarget returns whether an analyzer with this configuration should
+// emit diagnostics for packages compiled for the target. All targets are
+// included if the target is not known.
+func (c config) includesTarget(target label) bool {
+	if target == (label{}) {
+		return true
+	}
+	include, specificity := true, -1
+	for _, t := range c.targets {
+		if s := t.pattern.specificity(); s > specificity && t.pattern.match(target) {
+			include, specificity = t.include, s
+		}
+	}
+	return include
 }
 
 // importer is an implementation of go/types.Importer that imports type
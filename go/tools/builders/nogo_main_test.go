@@ -0,0 +1,88 @@
+/* Copyright 2020 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// analyzers, configs, and baseline are normally generated with the nogo
+// binary's main file.
+var (
+	analyzers []*analysis.Analyzer
+	configs   = map[string]config{}
+	baseline  = map[string]bool{}
+)
+
+func TestResolveFixes(t *testing.T) {
+	src := filepath.Join(execRoot, "p", "a.go")
+	// This is how cgo rewrites a.go: positions refer to a.go through //line
+	// directives, but offsets are in the generated file.
+	cgo1 := filepath.Join(execRoot, "_cgo", "a.cgo1.go")
+	fset := token.NewFileSet()
+	srcFile, err := parser.ParseFile(fset, src, "package p\n\nvar x = 1\n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cgo1File, err := parser.ParseFile(fset, cgo1, "// Code generated by cmd/cgo; DO NOT EDIT.\n\n//line "+src+":1:1\npackage p\n\nvar x = 1\n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// valueEdit replaces the 1 in "var x = 1" in file.
+	valueEdit := func(file *ast.File) analysis.TextEdit {
+		value := file.Decls[0].(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Values[0]
+		return analysis.TextEdit{Pos: value.Pos(), End: value.End(), NewText: []byte("2")}
+	}
+
+	got := resolveFixes(fset, src, []analysis.SuggestedFix{{
+		Message:   "use 2",
+		TextEdits: []analysis.TextEdit{valueEdit(srcFile)},
+	}})
+	want := edit{file: "p/a.go", offset: len("package p\n\nvar x = "), length: 1, newText: "2"}
+	if len(got) != 1 || got[0].message != "use 2" || len(got[0].edits) != 1 || got[0].edits[0] != want {
+		t.Errorf("got fixes %+v; want one fix with edit %+v", got, want)
+	}
+
+	// The finding is reported in a.go, since fset follows //line directives,
+	// but the edit's offset is in a.cgo1.go.
+	cgoPos := fset.Position(valueEdit(cgo1File).Pos)
+	if cgoPos.Filename != src {
+		t.Fatalf("position in a.cgo1.go is reported in %s; want %s", cgoPos.Filename, src)
+	}
+	got = resolveFixes(fset, cgoPos.Filename, []analysis.SuggestedFix{{
+		Message:   "use 2",
+		TextEdits: []analysis.TextEdit{valueEdit(cgo1File)},
+	}})
+	if len(got) != 0 {
+		t.Errorf("got fixes %+v for a file generated by cgo; want none", got)
+	}
+
+	// A fix is dropped if any of its edits can't be applied.
+	got = resolveFixes(fset, src, []analysis.SuggestedFix{{
+		Message:   "use 2",
+		TextEdits: []analysis.TextEdit{valueEdit(srcFile), valueEdit(cgo1File)},
+	}})
+	if len(got) != 0 {
+		t.Errorf("got fixes %+v with an edit in a file generated by cgo; want none", got)
+	}
+}
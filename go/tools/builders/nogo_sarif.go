@@ -0,0 +1,223 @@
+/* Copyright 2020 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Writes nogo findings as a SARIF 2.1.0 log, so they can be ingested by code
+// scanning and code review systems.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	nogoInfoURI  = "https://github.com/bazelbuild/rules_go/blob/master/go/nogo.rst"
+
+	// sarifFingerprintKey names the partial fingerprint nogo computes for each
+	// result. The version suffix must change if the fingerprint algorithm does.
+	// See fingerprint.
+	sarifFingerprintKey = "nogo/v1"
+)
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	FullDescription  sarifMessage `json:"fullDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+	BaselineState       string            `json:"baselineState,omitempty"`
+	Fixes               []sarifFix        `json:"fixes,omitempty"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifByteRegion       `json:"deletedRegion"`
+	InsertedContent *sarifArtifactContent `json:"insertedContent,omitempty"`
+}
+
+type sarifArtifactContent struct {
+	Text string `json:"text"`
+}
+
+type sarifByteRegion struct {
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+// writeSARIF writes the given findings to path as a SARIF log with a single
+// run. Every analyzer is listed as a rule, even if it reported nothing, so
+// that consumers can tell which checks were enabled.
+func writeSARIF(path string, analyzers []*analysis.Analyzer, findings []finding) error {
+	rules := make([]sarifRule, 0, len(analyzers))
+	ruleIndex := make(map[*analysis.Analyzer]int)
+	for _, a := range analyzers {
+		ruleIndex[a] = len(rules)
+		short := a.Doc
+		if i := strings.Index(short, "\n\n"); i >= 0 {
+			short = short[:i]
+		}
+		rules = append(rules, sarifRule{
+			ID:               a.Name,
+			ShortDescription: sarifMessage{Text: short},
+			FullDescription:  sarifMessage{Text: a.Doc},
+		})
+	}
+
+	results := make([]sarifResult, 0, len(findings))
+	for _, f := range findings {
+		region := sarifRegion{StartLine: f.pos.Line, StartColumn: f.pos.Column}
+		if f.end.IsValid() {
+			region.EndLine, region.EndColumn = f.end.Line, f.end.Column
+		}
+		level := "error"
+		if f.warning {
+			level = "warning"
+		}
+		baselineState := ""
+		if len(baseline) > 0 {
+			baselineState = "new"
+			if f.baselined {
+				baselineState = "unchanged"
+			}
+		}
+		results = append(results, sarifResult{
+			RuleID:    f.analyzer.Name,
+			RuleIndex: ruleIndex[f.analyzer],
+			Level:     level,
+			Message:   sarifMessage{Text: f.diagnostic.Message},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: workspaceRelative(f.pos.Filename)},
+					Region:           region,
+				},
+			}},
+			PartialFingerprints: map[string]string{sarifFingerprintKey: f.fingerprint},
+			BaselineState:       baselineState,
+			Fixes:               sarifFixes(f.fixes),
+		})
+	}
+
+	log := sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "nogo",
+				InformationURI: nogoInfoURI,
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+	data, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0666)
+}
+
+// sarifFixes converts suggested fixes to SARIF fixes. Edits are grouped into
+// one artifact change per file, in the order files first appear.
+func sarifFixes(fixes []fix) []sarifFix {
+	var sfs []sarifFix
+	for _, f := range fixes {
+		sf := sarifFix{Description: sarifMessage{Text: f.message}}
+		changeIndex := make(map[string]int)
+		for _, e := range f.edits {
+			i, ok := changeIndex[e.file]
+			if !ok {
+				i = len(sf.ArtifactChanges)
+				changeIndex[e.file] = i
+				sf.ArtifactChanges = append(sf.ArtifactChanges, sarifArtifactChange{
+					ArtifactLocation: sarifArtifactLocation{URI: e.file},
+				})
+			}
+			r := sarifReplacement{DeletedRegion: sarifByteRegion{ByteOffset: e.offset, ByteLength: e.length}}
+			if e.newText != "" {
+				r.InsertedContent = &sarifArtifactContent{Text: e.newText}
+			}
+			sf.ArtifactChanges[i].Replacements = append(sf.ArtifactChanges[i].Replacements, r)
+		}
+		sfs = append(sfs, sf)
+	}
+	return sfs
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// nogoFindings is the part of the JSON findings file written by nogo that is
+// needed to check it. The format is documented in go/nogo.rst#json-findings.
+type nogoFindings struct {
+	Findings []struct {
+		Analyzer  string `json:"analyzer"`
+		Severity  string `json:"severity"`
+		Baselined bool   `json:"baselined"`
+		Message   string `json:"message"`
+		File      string `json:"file"`
+		Line      int    `json:"line"`
+		Column    int    `json:"column"`
+		EndLine   int    `json:"end_line"`
+	} `json:"findings"`
+}
+
+// nogoValidation checks the findings nogo reported for a package. Warnings
+// are printed, and errors fail the action. Findings listed in the baseline,
+// and findings outside the changed lines if a diff is given, are skipped.
+//
+// nogo itself only fails if analysis can't be completed, so its reports are
+// kept even when they contain findings that fail the build. This action runs
+// separately, as a validation action, so that compiling the package doesn't
+// wait for analysis. It is invoked by the Go rules as an action.
+func nogoValidation(args []string) error {
+	args, err := readParamsFiles(args)
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("GoNogoValidation", flag.ExitOnError)
+	goenv := envFlags(fs)
+	var findingsPath, diffPath, outPath string
+	fs.StringVar(&findingsPath, "findings", "", "The JSON findings file written by nogo")
+	fs.StringVar(&diffPath, "nogo_diff", "", "A unified diff or list of changed lines; only findings on changed lines are checked")
+	fs.StringVar(&outPath, "o", "", "The file to write if there are no findings that fail the build")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := goenv.checkFlags(); err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(findingsPath)
+	if err != nil {
+		return err
+	}
+	var findings nogoFindings
+	if err := json.Unmarshal(data, &findings); err != nil {
+		return fmt.Errorf("%s: %v", findingsPath, err)
+	}
+	var changed changedLines
+	if diffPath != "" {
+		if changed, err = readChangedLines(diffPath); err != nil {
+			return fmt.Errorf("error reading changed lines: %v", err)
+		}
+	}
+	warnings, errs := checkNogoFindings(findings, changed)
+	if len(warnings) > 0 {
+		fmt.Fprintf(os.Stderr, "warnings found by nogo during build-time code analysis:\n%s\n", strings.Join(warnings, "\n"))
+	}
+	if len(errs) > 0 {
+		return errors.New("errors found by nogo during build-time code analysis:\n" + strings.Join(errs, "\n") + "\n")
+	}
+	return ioutil.WriteFile(outPath, nil, 0666)
+}
+
+// checkNogoFindings returns messages for the findings that should be printed
+// as warnings and for those that should fail the build. If changed is not
+// nil, findings on lines it doesn't include are skipped.
+func checkNogoFindings(findings nogoFindings, changed changedLines) (warnings, errs []string) {
+	for _, f := range findings.Findings {
+		if f.Baselined {
+			continue
+		}
+		if changed != nil && !changed.includes(f.File, f.Line, f.EndLine) {
+			continue
+		}
+		msg := fmt.Sprintf("%s:%d:%d: %s", f.File, f.Line, f.Column, f.Message)
+		if f.Severity == "warning" {
+			warnings = append(warnings, fmt.Sprintf("%s (%s)", msg, f.Analyzer))
+		} else {
+			errs = append(errs, msg)
+		}
+	}
+	return warnings, errs
+}
@@ -0,0 +1,52 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCheckNogoFindings(t *testing.T) {
+	var findings nogoFindings
+	data := `{
+  "package": "example.com/foo",
+  "findings": [
+    {"analyzer": "a", "severity": "error", "message": "bad", "file": "foo/a.go", "line": 3, "column": 2},
+    {"analyzer": "b", "severity": "warning", "message": "meh", "file": "foo/a.go", "line": 4, "column": 1},
+    {"analyzer": "a", "severity": "error", "baselined": true, "message": "old", "file": "foo/b.go", "line": 1, "column": 1}
+  ]
+}`
+	if err := json.Unmarshal([]byte(data), &findings); err != nil {
+		t.Fatal(err)
+	}
+	warnings, errs := checkNogoFindings(findings, nil)
+	if want := []string{"foo/a.go:4:1: meh (b)"}; !reflect.DeepEqual(warnings, want) {
+		t.Errorf("got warnings %q; want %q", warnings, want)
+	}
+	if want := []string{"foo/a.go:3:2: bad"}; !reflect.DeepEqual(errs, want) {
+		t.Errorf("got errors %q; want %q", errs, want)
+	}
+
+	changed := changedLines{"foo/a.go": {{start: 4, end: 4}}}
+	warnings, errs = checkNogoFindings(findings, changed)
+	if want := []string{"foo/a.go:4:1: meh (b)"}; !reflect.DeepEqual(warnings, want) {
+		t.Errorf("with changed lines, got warnings %q; want %q", warnings, want)
+	}
+	if len(errs) != 0 {
+		t.Errorf("with changed lines, got errors %q; want none", errs)
+	}
+}
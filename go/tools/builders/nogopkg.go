@@ -0,0 +1,138 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// nogoPkg runs nogo on a Go package in an action separate from compilation.
+// Dependencies are loaded from their export data instead of their archives,
+// so Bazel only reruns the action when the sources of the package, or the
+// export data or facts of its dependencies change. It is invoked by the Go
+// rules as an action.
+//
+// Packages that use cgo are analyzed by compilepkg instead, since their
+// generated sources are only available there.
+func nogoPkg(args []string) error {
+	// Parse arguments.
+	args, err := readParamsFiles(args)
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("GoNogo", flag.ExitOnError)
+	goenv := envFlags(fs)
+	var unfilteredSrcs, generatedSrcs multiFlag
+	var deps compileArchiveMultiFlag
+	var importPath, packagePath, targetLabel, nogoPath, packageListPath string
+	var outFactsPath, outNogoSARIFPath, outNogoFindingsPath string
+	var testFilter string
+	var nogoTiming bool
+	fs.Var(&unfilteredSrcs, "src", ".go file to be filtered and analyzed")
+	fs.Var(&generatedSrcs, "generated_src", ".go file produced by another rule rather than checked in (must also be a -src)")
+	fs.Var(&deps, "arc", "Import path, package path, export data file, and facts file of a direct dependency, separated by '='")
+	fs.StringVar(&importPath, "importpath", "", "The import path of the package being analyzed.")
+	fs.StringVar(&packagePath, "p", "", "The package path (importmap) of the package being analyzed")
+	fs.StringVar(&nogoPath, "nogo", "", "The nogo binary")
+	fs.StringVar(&packageListPath, "package_list", "", "The file containing the list of standard library packages")
+	fs.StringVar(&targetLabel, "label", "", "The label of the target the package is compiled for")
+	fs.StringVar(&outFactsPath, "x", "", "The nogo facts file to write")
+	fs.StringVar(&outNogoSARIFPath, "nogo_sarif", "", "The file where nogo findings should be written in SARIF format")
+	fs.StringVar(&outNogoFindingsPath, "nogo_findings", "", "The file where nogo findings should be written in JSON format")
+	fs.BoolVar(&nogoTiming, "nogo_timing", false, "Whether nogo should print how long each analyzer took to run")
+	fs.StringVar(&testFilter, "testfilter", "off", "Controls test package filtering")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := goenv.checkFlags(); err != nil {
+		return err
+	}
+	if importPath == "" {
+		importPath = packagePath
+	}
+	outFactsPath = abs(outFactsPath)
+	for i := range unfilteredSrcs {
+		unfilteredSrcs[i] = abs(unfilteredSrcs[i])
+	}
+	for i := range generatedSrcs {
+		generatedSrcs[i] = abs(generatedSrcs[i])
+	}
+
+	// Filter sources.
+	srcs, err := filterAndSplitFiles(unfilteredSrcs)
+	if err != nil {
+		return err
+	}
+	if err := applyTestFilter(&srcs, testFilter); err != nil {
+		return err
+	}
+
+	workDir, cleanup, err := goenv.workDir()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if len(srcs.goSrcs) == 0 {
+		// compilepkg compiles an empty package in this case. Analyze the same
+		// package so that facts are still written.
+		emptyPath := filepath.Join(workDir, "_empty.go")
+		if err := ioutil.WriteFile(emptyPath, []byte("package empty\n"), 0666); err != nil {
+			return err
+		}
+		srcs.goSrcs = append(srcs.goSrcs, fileInfo{
+			filename: emptyPath,
+			ext:      goExt,
+			matched:  true,
+			pkg:      "empty",
+		})
+		defer os.Remove(emptyPath)
+	}
+	goSrcs := make([]string, len(srcs.goSrcs))
+	for i, src := range srcs.goSrcs {
+		goSrcs[i] = src.filename
+	}
+
+	// Build an importcfg file that points to the export data of direct
+	// dependencies.
+	imports, err := checkImports(srcs.goSrcs, deps, packageListPath)
+	if err != nil {
+		return err
+	}
+	importcfgPath, err := buildImportcfgFileForCompile(imports, goenv.installSuffix, filepath.Dir(outFactsPath))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(importcfgPath)
+
+	return runNogo(context.Background(), workDir, nogoArgs{
+		nogoPath:        nogoPath,
+		timing:          nogoTiming,
+		srcs:            goSrcs,
+		generatedSrcs:   generatedSrcs,
+		deps:            deps,
+		packagePath:     packagePath,
+		importcfgPath:   importcfgPath,
+		outFactsPath:    outFactsPath,
+		outSARIFPath:    outNogoSARIFPath,
+		outFindingsPath: outNogoFindingsPath,
+		targetLabel:     targetLabel,
+	})
+}
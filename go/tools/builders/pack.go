@@ -162,6 +162,63 @@ func extractFiles(archive, dir string, names map[string]struct{}) (files []strin
 	}
 }
 
+// extractExportData copies the __.PKGDEF entry of a Go archive, which contains
+// the package's export data, into a new archive at outPath. The new archive
+// may be read by go/types importers in place of the original archive. Since
+// it doesn't include compiled code, it only changes when the package's
+// exported API changes.
+func extractExportData(archive, outPath string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+
+	header := make([]byte, len(arHeader))
+	if _, err := io.ReadFull(r, header); err != nil || string(header) != arHeader {
+		return fmt.Errorf("%s: bad header", archive)
+	}
+
+	var nameData []byte
+	for {
+		name, size, err := readMetadata(r, &nameData)
+		if err == io.EOF {
+			return fmt.Errorf("%s: no export data", archive)
+		}
+		if err != nil {
+			return err
+		}
+		if name != "__.PKGDEF" {
+			if err := skipFile(r, size); err != nil {
+				return err
+			}
+			continue
+		}
+
+		w, err := os.Create(outPath)
+		if err != nil {
+			return err
+		}
+		bw := bufio.NewWriter(w)
+		// The entry is written with zero timestamps and IDs, like the entries
+		// written by stripArMetadata.
+		fmt.Fprintf(bw, "%s%-16s%-12d%-6d%-6d%-8o%-10d`\n", arHeader, name, 0, 0, 0, 0644, size)
+		if _, err := io.CopyN(bw, r, size); err != nil {
+			w.Close()
+			return err
+		}
+		if size%2 != 0 {
+			bw.WriteByte('\n')
+		}
+		if err := bw.Flush(); err != nil {
+			w.Close()
+			return err
+		}
+		return w.Close()
+	}
+}
+
 // readMetadata reads the relevant fields of an entry. Before calling,
 // r must be positioned at the beginning of an entry. Afterward, r will
 // be positioned at the beginning of the file data. io.EOF is returned if
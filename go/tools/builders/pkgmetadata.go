@@ -0,0 +1,160 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// pkgmetadata writes a JSON description of the Go sources of a package: the
+// package clause, imports, and build tags of each file, and the platforms it
+// is built on. Rules read it through the GoPackageMetadata provider, so they
+// don't need to parse the sources themselves.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// packageMetadata is the format of the file written by pkgmetadata. It's
+// documented in go/providers.rst#GoPackageMetadata.
+type packageMetadata struct {
+	ImportPath string
+	Name       string
+	Files      []fileMetadata
+}
+
+type fileMetadata struct {
+	Path         string
+	Package      string
+	Imports      []string
+	Tags         []string
+	Platforms    []string
+	CgoPlatforms []string
+	Generated    bool
+}
+
+func pkgMetadata(args []string) error {
+	args, err := readParamsFiles(args)
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("GoPackageMetadata", flag.ExitOnError)
+	goenv := envFlags(fs)
+	var srcs, generatedSrcs, platforms multiFlag
+	var importPath, outPath string
+	fs.Var(&srcs, "src", "A .go file to describe")
+	fs.Var(&generatedSrcs, "generated_src", "A .go file produced by another rule rather than checked in (must also be a -src)")
+	fs.Var(&platforms, "platform", "A GOOS_GOARCH pair the file may be built for")
+	fs.StringVar(&importPath, "importpath", "", "The import path of the package")
+	fs.StringVar(&outPath, "o", "", "The file where the metadata should be written")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := goenv.checkFlags(); err != nil {
+		return err
+	}
+
+	md, err := readPackageMetadata(importPath, srcs, generatedSrcs, platforms, build.Default.BuildTags)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(md, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(outPath, append(data, '\n'), 0666)
+}
+
+// readPackageMetadata parses the package clause and imports of each .go file
+// in srcs, and matches it against platforms, with and without cgo, given the
+// tags set in the build configuration. Files in generatedSrcs are marked as
+// generated. Other files are skipped.
+func readPackageMetadata(importPath string, srcs, generatedSrcs, platforms, tags []string) (*packageMetadata, error) {
+	md := &packageMetadata{ImportPath: importPath, Files: []fileMetadata{}}
+	generated := make(map[string]bool)
+	for _, src := range generatedSrcs {
+		generated[src] = true
+	}
+	for _, src := range srcs {
+		if filepath.Ext(src) != ".go" {
+			continue
+		}
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, src, nil, parser.ImportsOnly)
+		if err != nil {
+			return nil, err
+		}
+		fm := fileMetadata{
+			Path:         src,
+			Package:      f.Name.Name,
+			Imports:      []string{},
+			Platforms:    []string{},
+			CgoPlatforms: []string{},
+			Generated:    generated[src],
+		}
+		isCgo := false
+		for _, imp := range f.Imports {
+			path, err := strconv.Unquote(imp.Path.Value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid import %s", src, imp.Path.Value)
+			}
+			fm.Imports = append(fm.Imports, path)
+			isCgo = isCgo || path == "C"
+		}
+		if fm.Tags, err = readBuildTags(src); err != nil {
+			return nil, err
+		}
+		dir, base := filepath.Split(src)
+		for _, p := range platforms {
+			i := strings.IndexByte(p, '_')
+			if i < 0 {
+				return nil, fmt.Errorf("invalid platform %q; want GOOS_GOARCH", p)
+			}
+			for _, cgo := range []bool{false, true} {
+				if isCgo && !cgo {
+					// MatchFile doesn't check imports, so cgo files match
+					// even when cgo is disabled.
+					continue
+				}
+				bctx := build.Default
+				bctx.GOOS = p[:i]
+				bctx.GOARCH = p[i+1:]
+				bctx.CgoEnabled = cgo
+				bctx.BuildTags = tags
+				match, err := bctx.MatchFile(dir, base)
+				if err != nil {
+					return nil, err
+				}
+				if !match {
+					continue
+				}
+				if cgo {
+					fm.CgoPlatforms = append(fm.CgoPlatforms, p)
+				} else {
+					fm.Platforms = append(fm.Platforms, p)
+				}
+			}
+		}
+		if md.Name == "" && !strings.HasSuffix(fm.Package, "_test") {
+			md.Name = fm.Package
+		}
+		md.Files = append(md.Files, fm)
+	}
+	return md, nil
+}
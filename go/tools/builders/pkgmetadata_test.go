@@ -0,0 +1,99 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadPackageMetadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pkgmetadata_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := []struct{ name, content string }{
+		{"p_test.go", "package p_test\n\nimport \"testing\"\n"},
+		{"p.go", "package p\n\nimport (\n\t\"fmt\"\n\tx \"example.com/x\"\n)\n"},
+		{"linux.go", "// +build linux,custom\n\npackage p\n"},
+		{"cgo.go", "// +build darwin\n\npackage p\n\nimport \"C\"\n"},
+		{"asm.s", "TEXT ·f(SB),0,$0\n"},
+	}
+	var srcs []string
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		if err := ioutil.WriteFile(path, []byte(f.content), 0666); err != nil {
+			t.Fatal(err)
+		}
+		srcs = append(srcs, path)
+	}
+	platforms := []string{"darwin_amd64", "linux_amd64"}
+
+	got, err := readPackageMetadata("example.com/p", srcs, srcs[1:2], platforms, []string{"custom"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &packageMetadata{
+		ImportPath: "example.com/p",
+		Name:       "p",
+		Files: []fileMetadata{
+			{
+				Path:         srcs[0],
+				Package:      "p_test",
+				Imports:      []string{"testing"},
+				Tags:         []string{},
+				Platforms:    platforms,
+				CgoPlatforms: platforms,
+			}, {
+				Path:         srcs[1],
+				Package:      "p",
+				Imports:      []string{"fmt", "example.com/x"},
+				Tags:         []string{},
+				Platforms:    platforms,
+				CgoPlatforms: platforms,
+				Generated:    true,
+			}, {
+				Path:         srcs[2],
+				Package:      "p",
+				Imports:      []string{},
+				Tags:         []string{"custom", "linux"},
+				Platforms:    []string{"linux_amd64"},
+				CgoPlatforms: []string{"linux_amd64"},
+			}, {
+				Path:         srcs[3],
+				Package:      "p",
+				Imports:      []string{"C"},
+				Tags:         []string{"darwin"},
+				Platforms:    []string{},
+				CgoPlatforms: []string{"darwin_amd64"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%#v\nwant:\n%#v", got, want)
+	}
+
+	got, err = readPackageMetadata("example.com/p", srcs[2:3], nil, platforms, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Files) != 1 || len(got.Files[0].Platforms) != 0 || len(got.Files[0].CgoPlatforms) != 0 {
+		t.Errorf("linux.go matched without the custom tag: %#v", got.Files)
+	}
+}
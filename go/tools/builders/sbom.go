@@ -0,0 +1,511 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// sbom writes a software bill of materials for a linked binary, listing the
+// modules its packages come from and the version of the standard library.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+const (
+	sbomFormatCycloneDX = "cyclonedx"
+	sbomFormatSPDX      = "spdx"
+)
+
+// sbomComponent is a module or repository a binary's packages come from.
+type sbomComponent struct {
+	// Name is the module path. Packages from an external repository that
+	// matches no module are listed under the repository name, like "@repo".
+	Name string
+
+	// Version is the module version, or "" if it's not known.
+	Version string
+
+	// Sum is the module's "h1:" checksum from go.sum, or "" if it's not known.
+	Sum string
+}
+
+// purl returns the package URL of the component, which tools use to look up
+// known vulnerabilities.
+func (c sbomComponent) purl() string {
+	if strings.HasPrefix(c.Name, "@") {
+		return ""
+	}
+	if c.Version == "" {
+		return "pkg:golang/" + c.Name
+	}
+	return "pkg:golang/" + c.Name + "@" + c.Version
+}
+
+// properties returns CycloneDX properties for the component. The "h1:"
+// checksum is a property rather than a hash: it's computed over a summary of
+// the module's files, not over any artifact it could be verified against.
+func (c sbomComponent) properties() []cycloneDXProperty {
+	if !strings.HasPrefix(c.Sum, "h1:") {
+		return nil
+	}
+	return []cycloneDXProperty{{Name: "go.sum:h1", Value: c.Sum}}
+}
+
+// modFileInfo is what sbom needs from go.mod and go.sum files: the main
+// module path, the versions of required modules, and their checksums.
+type modFileInfo struct {
+	mainModule string
+
+	// versions maps the paths of required modules to their versions.
+	versions map[string]string
+
+	// replacements maps the paths of required modules that are replaced by
+	// other modules to the paths of the replacements. Their versions are the
+	// versions of the replacements.
+	replacements map[string]string
+
+	// sums maps module paths and versions, separated by a space, to the
+	// "h1:" checksums of the modules' files.
+	sums map[string]string
+}
+
+func sbom(args []string) error {
+	args, err := readParamsFiles(args)
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("GoSBOM", flag.ExitOnError)
+	goenv := envFlags(fs)
+	var packages, modFiles multiFlag
+	var format, name, mainPath, goVersion, outPath string
+	fs.StringVar(&format, "format", sbomFormatCycloneDX, "The format of the SBOM: cyclonedx or spdx")
+	fs.StringVar(&name, "name", "", "The name of the binary")
+	fs.StringVar(&mainPath, "main", "", "The import path of the binary's main package")
+	fs.StringVar(&goVersion, "go_version", "", "The version of the Go SDK the binary was built with")
+	fs.Var(&packages, "package", "The import path of a package linked into the binary and the name of its repository, separated by '='")
+	fs.Var(&modFiles, "modfile", "A go.mod or go.sum file with the versions and checksums of modules")
+	fs.StringVar(&outPath, "o", "", "The file where the SBOM should be written")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := goenv.checkFlags(); err != nil {
+		return err
+	}
+
+	info := modFileInfo{
+		versions:     map[string]string{},
+		replacements: map[string]string{},
+		sums:         map[string]string{},
+	}
+	for _, path := range modFiles {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if strings.HasSuffix(path, ".sum") {
+			err = parseGoSum(bytes.NewReader(data), info.sums)
+		} else {
+			err = parseGoMod(bytes.NewReader(data), &info)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	var pkgs [][2]string
+	for _, p := range packages {
+		i := strings.LastIndex(p, "=")
+		if i < 0 {
+			return fmt.Errorf("invalid -package argument %q: want importpath=repository", p)
+		}
+		pkgs = append(pkgs, [2]string{p[:i], p[i+1:]})
+	}
+	components := sbomComponents(pkgs, &info)
+
+	var doc interface{}
+	switch format {
+	case sbomFormatCycloneDX:
+		doc = cycloneDXDocument(name, mainPath, goVersion, components)
+	case sbomFormatSPDX:
+		doc = spdxDocument(name, mainPath, goVersion, components)
+	default:
+		return fmt.Errorf("unknown SBOM format %q; want %s or %s", format, sbomFormatCycloneDX, sbomFormatSPDX)
+	}
+	var data bytes.Buffer
+	enc := json.NewEncoder(&data)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(outPath, data.Bytes(), 0666)
+}
+
+// sbomComponents returns the components the packages come from, sorted by
+// name. Each package is given with the name of its Bazel repository, which is
+// empty for the main repository. A package belongs to the module with the
+// longest path that prefixes its import path. Packages in the main module, or
+// in the main repository and no required module, are part of the binary
+// itself and aren't listed.
+func sbomComponents(pkgs [][2]string, info *modFileInfo) []sbomComponent {
+	seen := map[string]bool{}
+	var components []sbomComponent
+	for _, p := range pkgs {
+		importPath, repo := p[0], p[1]
+		modPath := ""
+		for m := range info.versions {
+			if pathHasPrefix(importPath, m) && len(m) > len(modPath) {
+				modPath = m
+			}
+		}
+		var c sbomComponent
+		switch {
+		case modPath != "":
+			name, version := modPath, info.versions[modPath]
+			if newPath, ok := info.replacements[modPath]; ok {
+				name = newPath
+			}
+			c = sbomComponent{Name: name, Version: version, Sum: info.sums[name+" "+version]}
+		case repo == "" || (info.mainModule != "" && pathHasPrefix(importPath, info.mainModule)):
+			continue
+		default:
+			c = sbomComponent{Name: "@" + repo}
+		}
+		if !seen[c.Name] {
+			seen[c.Name] = true
+			components = append(components, c)
+		}
+	}
+	sort.Slice(components, func(i, j int) bool { return components[i].Name < components[j].Name })
+	return components
+}
+
+// pathHasPrefix reports whether path is prefix or a path below it.
+func pathHasPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// parseGoMod reads the module path and the required module versions from a
+// go.mod file into info. Replacements with a version replace the required
+// version of a module, or of one version of it. Modules replaced with a local
+// directory are dropped, since their files are then part of the workspace.
+func parseGoMod(r io.Reader, info *modFileInfo) error {
+	type replacement struct{ oldVersion, newPath, newVersion string }
+	replacements := map[string][]replacement{}
+	block := ""
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		verb := block
+		if block == "" {
+			verb, fields = fields[0], fields[1:]
+			if len(fields) == 1 && fields[0] == "(" {
+				block = verb
+				continue
+			}
+		} else if len(fields) == 1 && fields[0] == ")" {
+			block = ""
+			continue
+		}
+		for i := range fields {
+			fields[i] = strings.Trim(fields[i], "\"`")
+		}
+		switch verb {
+		case "module":
+			if len(fields) != 1 {
+				return fmt.Errorf("line %d: malformed module directive", lineNum)
+			}
+			if info.mainModule == "" {
+				info.mainModule = fields[0]
+			}
+		case "require":
+			if len(fields) != 2 {
+				return fmt.Errorf("line %d: malformed require directive", lineNum)
+			}
+			if _, ok := info.versions[fields[0]]; !ok {
+				info.versions[fields[0]] = fields[1]
+			}
+		case "replace":
+			arrow := -1
+			for i, f := range fields {
+				if f == "=>" {
+					arrow = i
+				}
+			}
+			if arrow < 1 || arrow > 2 || len(fields)-arrow-1 < 1 || len(fields)-arrow-1 > 2 {
+				return fmt.Errorf("line %d: malformed replace directive", lineNum)
+			}
+			var rep replacement
+			if arrow == 2 {
+				rep.oldVersion = fields[1]
+			}
+			if len(fields)-arrow-1 == 2 {
+				rep.newPath, rep.newVersion = fields[arrow+1], fields[arrow+2]
+			}
+			replacements[fields[0]] = append(replacements[fields[0]], rep)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if block != "" {
+		return errors.New("unterminated block")
+	}
+
+	for oldPath, reps := range replacements {
+		version, ok := info.versions[oldPath]
+		if !ok {
+			continue
+		}
+		for _, rep := range reps {
+			if rep.oldVersion != "" && rep.oldVersion != version {
+				continue
+			}
+			if rep.newPath == "" {
+				delete(info.versions, oldPath)
+			} else {
+				// The replacement provides the packages under the old path,
+				// but its source and checksum are found under its own path.
+				info.versions[oldPath] = rep.newVersion
+				info.replacements[oldPath] = rep.newPath
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// parseGoSum reads the "h1:" checksums of module contents from a go.sum
+// file into sums, keyed by module path and version separated by a space.
+// Checksums of go.mod files are ignored.
+func parseGoSum(r io.Reader, sums map[string]string) error {
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return fmt.Errorf("line %d: malformed go.sum entry", lineNum)
+		}
+		if strings.HasSuffix(fields[1], "/go.mod") {
+			continue
+		}
+		sums[fields[0]+" "+fields[1]] = fields[2]
+	}
+	return scanner.Err()
+}
+
+// sbomTool is the tool reported as the author of SBOMs.
+const sbomTool = "rules_go"
+
+// SBOMs don't have a creation time, or have a fixed one where the format
+// requires it, so they're reproducible like other build outputs.
+const sbomCreated = "1970-01-01T00:00:00Z"
+
+// stdlibComponent returns the standard library as a component, in the form
+// used by "go version -m" and vulnerability databases.
+func stdlibComponent(goVersion string) sbomComponent {
+	c := sbomComponent{Name: "stdlib"}
+	if goVersion != "" {
+		c.Version = "v" + strings.TrimPrefix(goVersion, "go")
+	}
+	return c
+}
+
+type cycloneDXBOM struct {
+	BOMFormat    string                `json:"bomFormat"`
+	SpecVersion  string                `json:"specVersion"`
+	Version      int                   `json:"version"`
+	Metadata     cycloneDXMetadata     `json:"metadata"`
+	Components   []cycloneDXComponent  `json:"components"`
+	Dependencies []cycloneDXDependency `json:"dependencies"`
+}
+
+type cycloneDXMetadata struct {
+	Tools     []cycloneDXTool    `json:"tools"`
+	Component cycloneDXComponent `json:"component"`
+}
+
+type cycloneDXTool struct {
+	Name string `json:"name"`
+}
+
+type cycloneDXComponent struct {
+	BOMRef     string              `json:"bom-ref"`
+	Type       string              `json:"type"`
+	Name       string              `json:"name"`
+	Version    string              `json:"version,omitempty"`
+	PURL       string              `json:"purl,omitempty"`
+	Properties []cycloneDXProperty `json:"properties,omitempty"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cycloneDXDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// cycloneDXDocument returns a CycloneDX 1.4 SBOM for the binary. The binary
+// depends directly on every component, since the module graph isn't known.
+func cycloneDXDocument(name, mainPath, goVersion string, components []sbomComponent) *cycloneDXBOM {
+	bom := &cycloneDXBOM{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.4",
+		Version:     1,
+		Metadata: cycloneDXMetadata{
+			Tools: []cycloneDXTool{{Name: sbomTool}},
+			Component: cycloneDXComponent{
+				BOMRef: mainPath,
+				Type:   "application",
+				Name:   name,
+				PURL:   "pkg:golang/" + mainPath,
+			},
+		},
+		Components: []cycloneDXComponent{},
+	}
+	root := cycloneDXDependency{Ref: mainPath}
+	for _, c := range append(components, stdlibComponent(goVersion)) {
+		ref := c.purl()
+		if ref == "" {
+			ref = c.Name
+		}
+		bom.Components = append(bom.Components, cycloneDXComponent{
+			BOMRef:     ref,
+			Type:       "library",
+			Name:       c.Name,
+			Version:    c.Version,
+			PURL:       c.purl(),
+			Properties: c.properties(),
+		})
+		bom.Dependencies = append(bom.Dependencies, cycloneDXDependency{Ref: ref})
+		root.DependsOn = append(root.DependsOn, ref)
+	}
+	bom.Dependencies = append([]cycloneDXDependency{root}, bom.Dependencies...)
+	return bom
+}
+
+type spdxDoc struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Creators []string `json:"creators"`
+	Created  string   `json:"created"`
+}
+
+type spdxPackage struct {
+	SPDXID           string            `json:"SPDXID"`
+	Name             string            `json:"name"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// spdxDocument returns an SPDX 2.3 SBOM for the binary. The document
+// namespace, which must be unique, is derived from the contents, so it's
+// the same whenever the binary is built from the same modules.
+func spdxDocument(name, mainPath, goVersion string, components []sbomComponent) *spdxDoc {
+	const mainID = "SPDXRef-Package-main"
+	doc := &spdxDoc{
+		SPDXVersion: "SPDX-2.3",
+		DataLicense: "CC0-1.0",
+		SPDXID:      "SPDXRef-DOCUMENT",
+		Name:        name,
+		CreationInfo: spdxCreationInfo{
+			Creators: []string{"Tool: " + sbomTool},
+			Created:  sbomCreated,
+		},
+		Packages: []spdxPackage{{
+			SPDXID:           mainID,
+			Name:             name,
+			DownloadLocation: "NOASSERTION",
+			ExternalRefs:     spdxPURL("pkg:golang/" + mainPath),
+		}},
+		Relationships: []spdxRelationship{{
+			SPDXElementID:      "SPDXRef-DOCUMENT",
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: mainID,
+		}},
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", name, mainPath)
+	for i, c := range append(components, stdlibComponent(goVersion)) {
+		fmt.Fprintf(h, "%s %s %s\n", c.Name, c.Version, c.Sum)
+		id := fmt.Sprintf("SPDXRef-Package-%d", i)
+		p := spdxPackage{
+			SPDXID:           id,
+			Name:             c.Name,
+			VersionInfo:      c.Version,
+			DownloadLocation: "NOASSERTION",
+		}
+		if purl := c.purl(); purl != "" {
+			p.ExternalRefs = spdxPURL(purl)
+		}
+		doc.Packages = append(doc.Packages, p)
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			SPDXElementID:      mainID,
+			RelationshipType:   "DEPENDS_ON",
+			RelatedSPDXElement: id,
+		})
+	}
+	doc.DocumentNamespace = fmt.Sprintf("https://github.com/bazelbuild/rules_go/spdx/%s-%x", name, h.Sum(nil))
+	return doc
+}
+
+func spdxPURL(purl string) []spdxExternalRef {
+	return []spdxExternalRef{{
+		ReferenceCategory: "PACKAGE-MANAGER",
+		ReferenceType:     "purl",
+		ReferenceLocator:  purl,
+	}}
+}
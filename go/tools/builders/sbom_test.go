@@ -0,0 +1,162 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testGoMod = `module example.com/app
+
+go 1.14
+
+require (
+	example.com/a v1.2.3
+	example.com/a/nested v0.1.0 // indirect
+	example.com/old v1.0.0
+	example.com/local v0.0.1
+)
+
+require example.com/b v0.5.0
+
+replace example.com/old => example.com/new v1.1.0
+
+replace example.com/local => ../local
+`
+
+const testGoSum = `example.com/a v1.2.3 h1:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=
+example.com/a v1.2.3/go.mod h1:n+bLVUXeKLc4LMgD0Tqd2Vu+ZWyZ/bBzQMyq3NbdVZQ=
+example.com/new v1.1.0 h1:ungZ0gwzi/7K6tfXvEFoGFjiCxCU0ku9/w+p3xpZcaw=
+`
+
+func parseTestModFiles(t *testing.T) *modFileInfo {
+	info := &modFileInfo{
+		versions:     map[string]string{},
+		replacements: map[string]string{},
+		sums:         map[string]string{},
+	}
+	if err := parseGoMod(strings.NewReader(testGoMod), info); err != nil {
+		t.Fatal(err)
+	}
+	if err := parseGoSum(strings.NewReader(testGoSum), info.sums); err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+func TestParseModFiles(t *testing.T) {
+	info := parseTestModFiles(t)
+	if info.mainModule != "example.com/app" {
+		t.Errorf("got main module %q; want %q", info.mainModule, "example.com/app")
+	}
+	wantVersions := map[string]string{
+		"example.com/a":        "v1.2.3",
+		"example.com/a/nested": "v0.1.0",
+		"example.com/old":      "v1.1.0",
+		"example.com/b":        "v0.5.0",
+	}
+	if !reflect.DeepEqual(info.versions, wantVersions) {
+		t.Errorf("got versions %v; want %v", info.versions, wantVersions)
+	}
+	wantReplacements := map[string]string{"example.com/old": "example.com/new"}
+	if !reflect.DeepEqual(info.replacements, wantReplacements) {
+		t.Errorf("got replacements %v; want %v", info.replacements, wantReplacements)
+	}
+	if len(info.sums) != 2 {
+		t.Errorf("got sums %v; want checksums of module files only", info.sums)
+	}
+}
+
+func TestSBOMComponents(t *testing.T) {
+	info := parseTestModFiles(t)
+	pkgs := [][2]string{
+		{"example.com/app/cmd/server", ""},
+		{"example.com/app/internal/x", ""},
+		{"example.com/a/pkg", "com_example_a"},
+		{"example.com/a", "com_example_a"},
+		{"example.com/a/nested/sub", "com_example_a_nested"},
+		{"example.com/old/pkg", "com_example_old"},
+		{"example.com/local", "com_example_local"},
+		{"example.com/generated", ""},
+	}
+	got := sbomComponents(pkgs, info)
+	want := []sbomComponent{
+		{Name: "@com_example_local"},
+		{Name: "example.com/a", Version: "v1.2.3", Sum: "h1:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="},
+		{Name: "example.com/a/nested", Version: "v0.1.0"},
+		{Name: "example.com/new", Version: "v1.1.0", Sum: "h1:ungZ0gwzi/7K6tfXvEFoGFjiCxCU0ku9/w+p3xpZcaw="},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got components %#v; want %#v", got, want)
+	}
+}
+
+func TestSBOMComponentProperties(t *testing.T) {
+	c := sbomComponent{Sum: "h1:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="}
+	want := []cycloneDXProperty{{Name: "go.sum:h1", Value: c.Sum}}
+	if got := c.properties(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+	if got := (sbomComponent{Sum: "h2:abc"}).properties(); got != nil {
+		t.Errorf("got properties %v for an unknown checksum kind; want none", got)
+	}
+}
+
+func TestCycloneDXDocument(t *testing.T) {
+	components := []sbomComponent{
+		{Name: "@repo"},
+		{Name: "example.com/a", Version: "v1.2.3", Sum: "h1:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="},
+	}
+	bom := cycloneDXDocument("server", "example.com/app/cmd/server", "1.14.2", components)
+	var names, purls []string
+	for _, c := range bom.Components {
+		names = append(names, c.Name)
+		purls = append(purls, c.PURL)
+	}
+	if want := []string{"@repo", "example.com/a", "stdlib"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got components %v; want %v", names, want)
+	}
+	if want := []string{"", "pkg:golang/example.com/a@v1.2.3", "pkg:golang/stdlib@v1.14.2"}; !reflect.DeepEqual(purls, want) {
+		t.Errorf("got purls %v; want %v", purls, want)
+	}
+	if props := bom.Components[1].Properties; len(props) != 1 || props[0].Name != "go.sum:h1" {
+		t.Errorf("got properties %v for example.com/a; want its go.sum:h1 sum", props)
+	}
+	root := bom.Dependencies[0]
+	if want := []string{"@repo", "pkg:golang/example.com/a@v1.2.3", "pkg:golang/stdlib@v1.14.2"}; root.Ref != "example.com/app/cmd/server" || !reflect.DeepEqual(root.DependsOn, want) {
+		t.Errorf("got root dependency %v; want the binary depending on %v", root, want)
+	}
+}
+
+func TestSPDXDocument(t *testing.T) {
+	components := []sbomComponent{{Name: "example.com/a", Version: "v1.2.3"}}
+	doc := spdxDocument("server", "example.com/app/cmd/server", "1.14.2", components)
+	if len(doc.Packages) != 3 || len(doc.Relationships) != 3 {
+		t.Fatalf("got %d packages and %d relationships; want 3 of each", len(doc.Packages), len(doc.Relationships))
+	}
+	if p := doc.Packages[2]; p.Name != "stdlib" || p.VersionInfo != "v1.14.2" {
+		t.Errorf("got package %s@%s; want stdlib@v1.14.2", p.Name, p.VersionInfo)
+	}
+	other := spdxDocument("server", "example.com/app/cmd/server", "1.15", components)
+	if doc.DocumentNamespace == other.DocumentNamespace {
+		t.Errorf("documents for different components have the same namespace %s", doc.DocumentNamespace)
+	}
+	again := spdxDocument("server", "example.com/app/cmd/server", "1.14.2", components)
+	if !reflect.DeepEqual(doc, again) {
+		t.Errorf("documents for the same components differ")
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// sizereport summarizes how much each package contributes to the size of a
+// linked binary, using the symbol sizes printed by "go tool nm -size".
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sizeReport is the format of the file written by sizereport. It's
+// documented in go/core.rst#binary-size-reports.
+type sizeReport struct {
+	Binary   string
+	FileSize int64
+	sectionSizes
+	Packages []packageSize
+}
+
+type packageSize struct {
+	Package string
+	sectionSizes
+	Symbols int
+}
+
+// sectionSizes are the sizes of symbols in code, data (including read-only
+// data), and BSS. BSS doesn't take space in the file.
+type sectionSizes struct {
+	Text, Data, BSS int64
+}
+
+// Symbol names that start with these prefixes belong to no package. They're
+// type descriptors, itabs, strings, and other data generated by the compiler
+// and linker.
+var metadataSymbolPrefixes = []string{
+	"go:", "type:", "type.",
+	"go.buildid", "go.cuinfo.", "go.func.", "go.importpath.", "go.info.",
+	"go.interface", "go.itab.", "go.map.", "go.shape.", "go.string.",
+}
+
+const (
+	metadataPackage = "<metadata>"
+	otherPackage    = "<other>"
+)
+
+func sizeReportCmd(args []string) error {
+	args, err := readParamsFiles(args)
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("GoSizeReport", flag.ExitOnError)
+	goenv := envFlags(fs)
+	var binaryPath, outPath string
+	fs.StringVar(&binaryPath, "binary", "", "The linked binary to report on")
+	fs.StringVar(&outPath, "o", "", "The file where the report should be written")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := goenv.checkFlags(); err != nil {
+		return err
+	}
+
+	fi, err := os.Stat(binaryPath)
+	if err != nil {
+		return err
+	}
+	nmArgs := goenv.goTool("nm", "-size", binaryPath)
+	if _, err := os.Stat(nmArgs[0]); os.IsNotExist(err) {
+		// Newer SDKs don't include every tool prebuilt.
+		nmArgs = goenv.goCmd("tool", "nm", "-size", binaryPath)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(nmArgs[0], nmArgs[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// Binaries linked with -s have no symbol table. Their report only
+		// has the file size.
+		if !strings.Contains(stderr.String(), "no symbols") {
+			return fmt.Errorf("error running nm: %v\n%s", err, stderr.Bytes())
+		}
+		stdout.Reset()
+	}
+	report, err := summarizeSymbolSizes(&stdout)
+	if err != nil {
+		return err
+	}
+	report.Binary = binaryPath
+	report.FileSize = fi.Size()
+	var data bytes.Buffer
+	enc := json.NewEncoder(&data)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(outPath, data.Bytes(), 0666)
+}
+
+// summarizeSymbolSizes reads the output of "go tool nm -size" and adds up the
+// sizes of the defined symbols of each package. Packages are sorted by the
+// space they take in the file, largest first.
+func summarizeSymbolSizes(r io.Reader) (*sizeReport, error) {
+	report := &sizeReport{Packages: []packageSize{}}
+	byPackage := make(map[string]*packageSize)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// Each line has an address, a size, a type, and a name, which may
+		// contain spaces. Undefined symbols have no address.
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid nm output %q: %v", scanner.Text(), err)
+		}
+		var total, pkgTotal *int64
+		pkgPath := symbolPackage(strings.Join(fields[3:], " "))
+		pkg := byPackage[pkgPath]
+		if pkg == nil {
+			pkg = &packageSize{Package: pkgPath}
+			byPackage[pkgPath] = pkg
+		}
+		switch fields[2] {
+		case "T", "t":
+			total, pkgTotal = &report.Text, &pkg.Text
+		case "D", "d", "R", "r":
+			total, pkgTotal = &report.Data, &pkg.Data
+		case "B", "b":
+			total, pkgTotal = &report.BSS, &pkg.BSS
+		default:
+			continue
+		}
+		*total += size
+		*pkgTotal += size
+		pkg.Symbols++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, pkg := range byPackage {
+		if pkg.Symbols > 0 {
+			report.Packages = append(report.Packages, *pkg)
+		}
+	}
+	sort.Slice(report.Packages, func(i, j int) bool {
+		pi, pj := report.Packages[i], report.Packages[j]
+		if si, sj := pi.Text+pi.Data, pj.Text+pj.Data; si != sj {
+			return si > sj
+		}
+		return pi.Package < pj.Package
+	})
+	return report, nil
+}
+
+// symbolPackage returns the path of the package a symbol belongs to, or
+// metadataPackage or otherPackage for symbols that don't belong to a package,
+// like type descriptors and C functions linked with cgo.
+func symbolPackage(name string) string {
+	for _, prefix := range metadataSymbolPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return metadataPackage
+		}
+	}
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		// Instantiations of generic functions and types name their type
+		// arguments in brackets.
+		name = name[:i]
+	}
+	slash := strings.LastIndexByte(name, '/')
+	dot := strings.IndexByte(name[slash+1:], '.')
+	if dot <= 0 {
+		return otherPackage
+	}
+	return name[:slash+1+dot]
+}
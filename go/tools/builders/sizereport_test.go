@@ -0,0 +1,72 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSymbolPackage(t *testing.T) {
+	for _, test := range []struct {
+		name, want string
+	}{
+		{"main.main", "main"},
+		{"fmt.(*pp).doPrintf", "fmt"},
+		{"example.com/a/b.(*T).M", "example.com/a/b"},
+		{"example.com/a/b.F.func1", "example.com/a/b"},
+		{"go.uber.org/zap.New", "go.uber.org/zap"},
+		{"vendor/golang.org/x/net/dns/dnsmessage.(*Parser).Start", "vendor/golang.org/x/net/dns/dnsmessage"},
+		{"example.com/a.Map[go.shape.string,example.com/b.T]", "example.com/a"},
+		{"type:*example.com/a.T", metadataPackage},
+		{"type.*example.com/a.T", metadataPackage},
+		{"go.itab.*os.File,io.Writer", metadataPackage},
+		{"go:string.*", metadataPackage},
+		{"x_cgo_init", otherPackage},
+		{".note.go.buildid", otherPackage},
+	} {
+		if got := symbolPackage(test.name); got != test.want {
+			t.Errorf("symbolPackage(%q) = %q; want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSummarizeSymbolSizes(t *testing.T) {
+	const nm = `  401000        100 T main.main
+  401100        300 T fmt.Println
+  401400         50 t fmt.(*pp).free
+  501000         40 R fmt.ppFree
+  601000       1000 B fmt.ppBuf
+  502000         60 R type:*fmt.pp
+                      U _cgo_panic
+  402000         30 T main.init.0
+`
+	got, err := summarizeSymbolSizes(strings.NewReader(nm))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &sizeReport{
+		sectionSizes: sectionSizes{Text: 480, Data: 100, BSS: 1000},
+		Packages: []packageSize{
+			{Package: "fmt", sectionSizes: sectionSizes{Text: 350, Data: 40, BSS: 1000}, Symbols: 4},
+			{Package: "main", sectionSizes: sectionSizes{Text: 130}, Symbols: 2},
+			{Package: metadataPackage, sectionSizes: sectionSizes{Data: 60}, Symbols: 1},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v; want %+v", got, want)
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// splitdebug writes a copy of an ELF binary without its debug information and
+// symbol table. The copy has a .gnu_debuglink section naming the original
+// binary, so debuggers that find the original next to the copy or in a debug
+// directory load symbols from it.
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+func splitDebug(args []string) error {
+	args, err := readParamsFiles(args)
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("GoSplitDebug", flag.ExitOnError)
+	goenv := envFlags(fs)
+	var binaryPath, outPath string
+	fs.StringVar(&binaryPath, "binary", "", "The linked binary with debug information, which becomes the debug file")
+	fs.StringVar(&outPath, "o", "", "The file where the stripped binary should be written")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := goenv.checkFlags(); err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(binaryPath)
+	if err != nil {
+		return err
+	}
+	stripped, err := stripDebugInfo(data, filepath.Base(binaryPath))
+	if err != nil {
+		return fmt.Errorf("%s: %v", binaryPath, err)
+	}
+	return ioutil.WriteFile(outPath, stripped, 0777)
+}
+
+// isDebugSection returns whether a section holds DWARF data.
+func isDebugSection(name string) bool {
+	return strings.HasPrefix(name, ".debug_") || strings.HasPrefix(name, ".zdebug_")
+}
+
+// stripDebugInfo returns a copy of the ELF file in data without DWARF
+// sections or a symbol table, with a .gnu_debuglink section naming debugLink
+// and holding the CRC of data.
+//
+// Loaded segments are copied unchanged. The removed sections aren't part of
+// any segment, so their contents are dropped, and other sections after the
+// segments are moved up. Removed sections are left in the section header
+// table as empty SHT_NULL entries, so the section indices in symbols and
+// other headers don't change.
+func stripDebugInfo(data []byte, debugLink string) ([]byte, error) {
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	bo := f.ByteOrder
+	is64 := f.Class == elf.ELFCLASS64
+
+	// Read the raw file header and section headers. debug/elf reports the
+	// uncompressed size of compressed sections, not their size in the file.
+	var hdr64 elf.Header64
+	var hdr32 elf.Header32
+	var shoff, phend uint64
+	var shnum, shstrndx int
+	r := bytes.NewReader(data)
+	if is64 {
+		if err := binary.Read(r, bo, &hdr64); err != nil {
+			return nil, err
+		}
+		shoff, shnum, shstrndx = hdr64.Shoff, int(hdr64.Shnum), int(hdr64.Shstrndx)
+		phend = hdr64.Phoff + uint64(hdr64.Phnum)*uint64(hdr64.Phentsize)
+	} else {
+		if err := binary.Read(r, bo, &hdr32); err != nil {
+			return nil, err
+		}
+		shoff, shnum, shstrndx = uint64(hdr32.Shoff), int(hdr32.Shnum), int(hdr32.Shstrndx)
+		phend = uint64(hdr32.Phoff) + uint64(hdr32.Phnum)*uint64(hdr32.Phentsize)
+	}
+	if len(f.Progs) == 0 {
+		return nil, errors.New("not an executable or shared library")
+	}
+	if shnum == 0 || shnum >= int(elf.SHN_LORESERVE) || shstrndx == 0 || shstrndx >= shnum || shnum != len(f.Sections) {
+		return nil, errors.New("unsupported section header table")
+	}
+	sections := make([]elf.Section64, shnum)
+	r = bytes.NewReader(data)
+	if _, err := r.Seek(int64(shoff), 0); err != nil {
+		return nil, err
+	}
+	for i := range sections {
+		if is64 {
+			err = binary.Read(r, bo, &sections[i])
+		} else {
+			var s elf.Section32
+			err = binary.Read(r, bo, &s)
+			sections[i] = elf.Section64{
+				Name: s.Name, Type: s.Type, Flags: uint64(s.Flags), Addr: uint64(s.Addr),
+				Off: uint64(s.Off), Size: uint64(s.Size), Link: s.Link, Info: s.Info,
+				Addralign: uint64(s.Addralign), Entsize: uint64(s.Entsize),
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	remove := make([]bool, shnum)
+	hasDebug := false
+	for i, s := range f.Sections {
+		if isDebugSection(s.Name) {
+			remove[i] = true
+			hasDebug = true
+		} else if s.Type == elf.SHT_SYMTAB {
+			remove[i] = true
+			if link := int(s.Link); link != shstrndx && link < shnum && f.Sections[link].Type == elf.SHT_STRTAB {
+				remove[link] = true
+			}
+		}
+	}
+	if !hasDebug {
+		return nil, errors.New("no debug information; was the binary linked with -w?")
+	}
+
+	// Everything up to the end of the last loaded section stays where it is.
+	fixedEnd := phend
+	for _, p := range f.Progs {
+		if end := p.Off + p.Filesz; end > fixedEnd {
+			fixedEnd = end
+		}
+	}
+	for i, s := range sections {
+		if s.Type == uint32(elf.SHT_NOBITS) {
+			continue
+		}
+		end := s.Off + s.Size
+		if remove[i] {
+			if elf.SectionFlag(s.Flags)&elf.SHF_ALLOC != 0 || s.Off < fixedEnd {
+				return nil, fmt.Errorf("section %s is in a loaded segment and can't be removed", f.Sections[i].Name)
+			}
+		} else if elf.SectionFlag(s.Flags)&elf.SHF_ALLOC != 0 && end > fixedEnd {
+			fixedEnd = end
+		}
+	}
+
+	out := append([]byte(nil), data[:fixedEnd]...)
+	appendSection := func(s *elf.Section64, content []byte) {
+		if align := s.Addralign; align > 1 {
+			for uint64(len(out))%align != 0 {
+				out = append(out, 0)
+			}
+		}
+		s.Off = uint64(len(out))
+		s.Size = uint64(len(content))
+		out = append(out, content...)
+	}
+
+	// The section name table always moves to the end, since it grows.
+	shstrtab := append([]byte(nil), data[sections[shstrndx].Off:sections[shstrndx].Off+sections[shstrndx].Size]...)
+	debugLinkName := uint32(len(shstrtab))
+	shstrtab = append(shstrtab, ".gnu_debuglink\x00"...)
+	for i := 1; i < shnum; i++ {
+		s := &sections[i]
+		switch {
+		case remove[i]:
+			*s = elf.Section64{}
+		case i == shstrndx:
+			appendSection(s, shstrtab)
+		case s.Type != uint32(elf.SHT_NOBITS) && s.Off >= fixedEnd:
+			appendSection(s, data[s.Off:s.Off+s.Size])
+		}
+	}
+
+	// The debug link is the file name of the debug file, padded to four bytes,
+	// followed by its CRC.
+	link := append([]byte(debugLink), 0)
+	for len(link)%4 != 0 {
+		link = append(link, 0)
+	}
+	var crc [4]byte
+	bo.PutUint32(crc[:], crc32.ChecksumIEEE(data))
+	link = append(link, crc[:]...)
+	linkSection := elf.Section64{Name: debugLinkName, Type: uint32(elf.SHT_PROGBITS), Addralign: 4}
+	appendSection(&linkSection, link)
+	sections = append(sections, linkSection)
+
+	// Write the section header table and point the file header at it.
+	var buf bytes.Buffer
+	if is64 {
+		for len(out)%8 != 0 {
+			out = append(out, 0)
+		}
+		hdr64.Shoff = uint64(len(out))
+		hdr64.Shnum = uint16(len(sections))
+		if err := binary.Write(&buf, bo, sections); err != nil {
+			return nil, err
+		}
+		out = append(out, buf.Bytes()...)
+		buf.Reset()
+		if err := binary.Write(&buf, bo, &hdr64); err != nil {
+			return nil, err
+		}
+	} else {
+		for len(out)%4 != 0 {
+			out = append(out, 0)
+		}
+		hdr32.Shoff = uint32(len(out))
+		hdr32.Shnum = uint16(len(sections))
+		for _, s := range sections {
+			s32 := elf.Section32{
+				Name: s.Name, Type: s.Type, Flags: uint32(s.Flags), Addr: uint32(s.Addr),
+				Off: uint32(s.Off), Size: uint32(s.Size), Link: s.Link, Info: s.Info,
+				Addralign: uint32(s.Addralign), Entsize: uint32(s.Entsize),
+			}
+			if err := binary.Write(&buf, bo, &s32); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, buf.Bytes()...)
+		buf.Reset()
+		if err := binary.Write(&buf, bo, &hdr32); err != nil {
+			return nil, err
+		}
+	}
+	copy(out, buf.Bytes())
+	return out, nil
+}
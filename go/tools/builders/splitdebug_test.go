@@ -0,0 +1,136 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestStripDebugInfo strips the test binary itself, which has debug
+// information unless it was linked with -w.
+func TestStripDebugInfo(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		t.Skipf("test binary is not an ELF file: %v", err)
+	}
+	if orig.Section(".debug_info") == nil && orig.Section(".zdebug_info") == nil {
+		t.Skip("test binary has no debug information")
+	}
+
+	stripped, err := stripDebugInfo(data, "splitdebug_test.debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stripped) >= len(data) {
+		t.Errorf("stripped binary has %d bytes; original has %d", len(stripped), len(data))
+	}
+	f, err := elf.NewFile(bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Sections) != len(orig.Sections)+1 {
+		t.Fatalf("got %d sections; want %d", len(f.Sections), len(orig.Sections)+1)
+	}
+	for i, s := range orig.Sections {
+		got := f.Sections[i]
+		if isDebugSection(s.Name) || s.Type == elf.SHT_SYMTAB || s.Name == ".strtab" {
+			if got.Type != elf.SHT_NULL || got.Name != "" {
+				t.Errorf("section %d (%s) was not removed: %+v", i, s.Name, got.SectionHeader)
+			}
+			continue
+		}
+		if s.Name == ".shstrtab" {
+			// The section name table grows to name .gnu_debuglink.
+			continue
+		}
+		if got.Name != s.Name || got.Addr != s.Addr || got.Size != s.Size {
+			t.Errorf("section %d: got %+v; want %+v", i, got.SectionHeader, s.SectionHeader)
+			continue
+		}
+		if s.Type == elf.SHT_NOBITS {
+			continue
+		}
+		want, _ := s.Data()
+		if data, _ := got.Data(); !bytes.Equal(data, want) {
+			t.Errorf("section %s has different contents", s.Name)
+		}
+	}
+	if !reflect.DeepEqual(f.Progs[0].ProgHeader, orig.Progs[0].ProgHeader) || len(f.Progs) != len(orig.Progs) {
+		t.Errorf("program headers changed")
+	}
+
+	link := f.Section(".gnu_debuglink")
+	if link == nil {
+		t.Fatal("no .gnu_debuglink section")
+	}
+	linkData, err := link.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantName := "splitdebug_test.debug\x00\x00\x00"
+	if len(linkData) != len(wantName)+4 || string(linkData[:len(wantName)]) != wantName {
+		t.Fatalf("got debug link %q; want %q and a CRC", linkData, wantName)
+	}
+	if got, want := f.ByteOrder.Uint32(linkData[len(wantName):]), crc32.ChecksumIEEE(data); got != want {
+		t.Errorf("got CRC %x; want %x", got, want)
+	}
+
+	// The stripped binary still runs.
+	dir, err := ioutil.TempDir("", "splitdebug_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "stripped")
+	if err := ioutil.WriteFile(path, stripped, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command(path, "-test.run=^$").CombinedOutput(); err != nil {
+		t.Errorf("running stripped binary: %v\n%s", err, out)
+	}
+}
+
+func TestStripDebugInfoWithoutDebugInfo(t *testing.T) {
+	// An ELF header with no program or section headers.
+	var buf bytes.Buffer
+	hdr := elf.Header64{Type: uint16(elf.ET_EXEC), Machine: uint16(elf.EM_X86_64), Version: uint32(elf.EV_CURRENT), Ehsize: 64}
+	copy(hdr.Ident[:], elf.ELFMAG)
+	hdr.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	hdr.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	hdr.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	if err := binary.Write(&buf, binary.LittleEndian, &hdr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stripDebugInfo(buf.Bytes(), "x.debug"); err == nil {
+		t.Error("got no error for a file without program headers")
+	}
+}
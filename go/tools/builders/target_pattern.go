@@ -0,0 +1,162 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// label is a parsed Bazel label. repo is empty for labels in the main
+// workspace.
+type label struct {
+	repo, pkg, name string
+}
+
+// parseLabel parses an absolute label like "//pkg:name" or "@repo//pkg:name".
+// If the name is omitted, it is the last component of the package.
+func parseLabel(s string) (label, error) {
+	repo, pkg, name, err := splitLabel(s)
+	if err != nil {
+		return label{}, err
+	}
+	if name == "" {
+		if pkg == "" {
+			return label{}, fmt.Errorf("invalid label %q: no target name", s)
+		}
+		name = pkg[strings.LastIndex(pkg, "/")+1:]
+	}
+	return label{repo: repo, pkg: pkg, name: name}, nil
+}
+
+// targetPattern is a parsed Bazel target pattern. The following forms are
+// supported:
+//
+//     //pkg:name     the target name in pkg
+//     //pkg          the target in pkg with the same name as the last component
+//     //pkg:all      all targets in pkg (":*" is also accepted)
+//     //pkg/...      all targets in pkg and its subpackages
+//     //...          all targets in the workspace
+//
+// Patterns may start with "@repo" to match targets in an external repository.
+// Other patterns only match targets in the main workspace.
+type targetPattern struct {
+	repo, pkg string
+	// name is the target name, or empty if the pattern matches all targets in
+	// pkg.
+	name string
+	// recursive is true if the pattern also matches targets in subpackages.
+	recursive bool
+}
+
+func parseTargetPattern(s string) (targetPattern, error) {
+	repo, pkg, name, err := splitLabel(s)
+	if err != nil {
+		return targetPattern{}, err
+	}
+	p := targetPattern{repo: repo}
+	if pkg == "..." || strings.HasSuffix(pkg, "/...") {
+		p.recursive = true
+		pkg = strings.TrimSuffix(strings.TrimSuffix(pkg, "..."), "/")
+		if name != "" && name != "all" && name != "*" {
+			return targetPattern{}, fmt.Errorf("invalid target pattern %q: recursive patterns may not name a target", s)
+		}
+		name = ""
+	} else if name == "all" || name == "*" {
+		name = ""
+	} else if name == "" {
+		if pkg == "" {
+			return targetPattern{}, fmt.Errorf("invalid target pattern %q: no target name", s)
+		}
+		name = pkg[strings.LastIndex(pkg, "/")+1:]
+	}
+	if pkg != "" {
+		for _, c := range strings.Split(pkg, "/") {
+			if c == "" || c == "." || c == ".." || c == "..." {
+				return targetPattern{}, fmt.Errorf("invalid target pattern %q: invalid package name", s)
+			}
+		}
+	}
+	p.pkg, p.name = pkg, name
+	return p, nil
+}
+
+// mustParseTargetPattern is like parseTargetPattern but panics if the
+// pattern is invalid. It is used by generated code for patterns that were
+// already validated.
+func mustParseTargetPattern(s string) targetPattern {
+	p, err := parseTargetPattern(s)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// match returns whether l is matched by the pattern.
+func (p targetPattern) match(l label) bool {
+	if p.repo != l.repo {
+		return false
+	}
+	if p.recursive {
+		return p.pkg == "" || l.pkg == p.pkg || strings.HasPrefix(l.pkg, p.pkg+"/")
+	}
+	return l.pkg == p.pkg && (p.name == "" || l.name == p.name)
+}
+
+// specificity orders patterns that may match the same target. Patterns
+// for deeper packages are more specific. Within a package, a recursive
+// pattern is less specific than one for all targets in the package, which is
+// less specific than a pattern naming a target.
+func (p targetPattern) specificity() int {
+	depth := 0
+	if p.pkg != "" {
+		depth = strings.Count(p.pkg, "/") + 1
+	}
+	s := 3 * depth
+	if !p.recursive {
+		s++
+		if p.name != "" {
+			s++
+		}
+	}
+	return s
+}
+
+// splitLabel splits a label or target pattern into its repository, package
+// and name. The "@" prefix is removed from the repository, and "@//" is
+// treated as the main workspace.
+func splitLabel(s string) (repo, pkg, name string, err error) {
+	rest := s
+	if strings.HasPrefix(rest, "@") {
+		i := strings.Index(rest, "//")
+		if i < 0 {
+			return "", "", "", fmt.Errorf("invalid label %q: missing \"//\"", s)
+		}
+		repo, rest = rest[1:i], rest[i:]
+	}
+	if !strings.HasPrefix(rest, "//") {
+		return "", "", "", fmt.Errorf("invalid label %q: must start with \"//\" or \"@\"", s)
+	}
+	rest = rest[len("//"):]
+	if i := strings.Index(rest, ":"); i >= 0 {
+		pkg, name = rest[:i], rest[i+1:]
+		if name == "" {
+			return "", "", "", fmt.Errorf("invalid label %q: empty target name", s)
+		}
+	} else {
+		pkg = rest
+	}
+	return repo, pkg, name, nil
+}
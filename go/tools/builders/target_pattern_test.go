@@ -0,0 +1,110 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+func TestParseTargetPattern(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		want    targetPattern
+		wantErr bool
+	}{
+		{pattern: "//...", want: targetPattern{recursive: true}},
+		{pattern: "//foo/...", want: targetPattern{pkg: "foo", recursive: true}},
+		{pattern: "//foo/...:all", want: targetPattern{pkg: "foo", recursive: true}},
+		{pattern: "@org_golang_x_tools//...", want: targetPattern{repo: "org_golang_x_tools", recursive: true}},
+		{pattern: "@//foo:all", want: targetPattern{pkg: "foo"}},
+		{pattern: "//foo:*", want: targetPattern{pkg: "foo"}},
+		{pattern: "//foo/bar:baz", want: targetPattern{pkg: "foo/bar", name: "baz"}},
+		{pattern: "//foo/bar", want: targetPattern{pkg: "foo/bar", name: "bar"}},
+		{pattern: ":foo", wantErr: true},
+		{pattern: "foo/...", wantErr: true},
+		{pattern: "//foo:", wantErr: true},
+		{pattern: "//foo/...:bar", wantErr: true},
+		{pattern: "//foo//bar", wantErr: true},
+		{pattern: "//foo/../bar:all", wantErr: true},
+		{pattern: "@foo", wantErr: true},
+	} {
+		t.Run(test.pattern, func(t *testing.T) {
+			got, err := parseTargetPattern(test.pattern)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("got %#v; want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %#v; want %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestTargetPatternMatch(t *testing.T) {
+	for _, test := range []struct {
+		pattern, label string
+		want           bool
+	}{
+		{"//...", "//foo:bar", true},
+		{"//...", "@repo//foo:bar", false},
+		{"@repo//...", "@repo//foo:bar", true},
+		{"//foo/...", "//foo:foo", true},
+		{"//foo/...", "//foo/bar:baz", true},
+		{"//foo/...", "//foobar:baz", false},
+		{"//foo:all", "//foo:bar", true},
+		{"//foo:all", "//foo/bar:bar", false},
+		{"//foo:bar", "//foo:bar", true},
+		{"//foo:bar", "//foo:baz", false},
+		{"//foo", "//foo", true},
+		{"//foo", "@//foo:foo", true},
+	} {
+		t.Run(test.pattern+" "+test.label, func(t *testing.T) {
+			p, err := parseTargetPattern(test.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			l, err := parseLabel(test.label)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.match(l); got != test.want {
+				t.Errorf("got %v; want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestTargetPatternSpecificity(t *testing.T) {
+	// Each pattern is more specific than the one before it.
+	patterns := []string{
+		"//...",
+		"//foo/...",
+		"//foo:all",
+		"//foo:bar",
+		"//foo/bar/...",
+	}
+	prev := -1
+	for _, pattern := range patterns {
+		s := mustParseTargetPattern(pattern).specificity()
+		if s <= prev {
+			t.Errorf("%s has specificity %d; want more than %d", pattern, s, prev)
+		}
+		prev = s
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// unusedDepsReport is the format of the file written for -unused_deps_report.
+// It's documented in go/core.rst#unused-dependencies.
+type unusedDepsReport struct {
+	Label      string
+	UnusedDeps []string
+}
+
+// checkUnusedDeps reports the deps in checkDeps that no file in srcs imports.
+// The report is written to outPath. If there are unused deps, they're printed
+// as a warning in "warn" mode, and returned as an error in "error" mode.
+func checkUnusedDeps(label string, srcs, checkDeps []string, mode, outPath string) error {
+	if mode != "warn" && mode != "error" {
+		return fmt.Errorf("invalid -unused_deps mode %q; want warn or error", mode)
+	}
+	unused, err := findUnusedDeps(srcs, checkDeps)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(unusedDepsReport{Label: label, UnusedDeps: unused}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(outPath, append(data, '\n'), 0666); err != nil {
+		return err
+	}
+	if len(unused) == 0 {
+		return nil
+	}
+	msg := formatUnusedDeps(label, unused)
+	if mode == "error" {
+		return errors.New(msg)
+	}
+	fmt.Fprint(os.Stderr, msg)
+	return nil
+}
+
+// findUnusedDeps returns the labels of the deps in checkDeps that aren't
+// imported by any .go file in srcs. Each element of checkDeps is a label and a
+// colon-separated list of import paths, separated by '='. Build constraints
+// are ignored: a dep imported on any platform is used.
+func findUnusedDeps(srcs, checkDeps []string) ([]string, error) {
+	imports := make(map[string]bool)
+	for _, src := range srcs {
+		if filepath.Ext(src) != ".go" {
+			continue
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), src, nil, parser.ImportsOnly)
+		if err != nil {
+			return nil, err
+		}
+		for _, imp := range f.Imports {
+			path, err := strconv.Unquote(imp.Path.Value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid import %s", src, imp.Path.Value)
+			}
+			imports[path] = true
+		}
+	}
+
+	unused := []string{}
+	for _, dep := range checkDeps {
+		i := strings.LastIndexByte(dep, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("invalid -check_dep %q; want label=importpath", dep)
+		}
+		used := false
+		for _, importPath := range strings.Split(dep[i+1:], ":") {
+			if imports[importPath] {
+				used = true
+				break
+			}
+		}
+		if !used {
+			unused = append(unused, dep[:i])
+		}
+	}
+	return unused, nil
+}
+
+// formatUnusedDeps describes unused deps along with the buildozer commands
+// that remove them.
+func formatUnusedDeps(label string, unused []string) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s: dependencies not imported by any source:\n", label)
+	for _, dep := range unused {
+		fmt.Fprintf(&buf, "\t%s\n", dep)
+	}
+	fmt.Fprintf(&buf, "To remove them, run:\n")
+	for _, dep := range unused {
+		fmt.Fprintf(&buf, "\tbuildozer 'remove deps %s' %s\n", dep, label)
+	}
+	return buf.String()
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindUnusedDeps(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unused_deps_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := []struct{ name, content string }{
+		{"a.go", "package a\n\nimport \"example.com/used\"\n"},
+		{"a_windows.go", "package a\n\nimport w \"example.com/windows\"\n"},
+		{"a.s", "TEXT ·f(SB),0,$0\n"},
+	}
+	var srcs []string
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		if err := ioutil.WriteFile(path, []byte(f.content), 0666); err != nil {
+			t.Fatal(err)
+		}
+		srcs = append(srcs, path)
+	}
+	checkDeps := []string{
+		"//used:go_default_library=example.com/used",
+		"//windows=example.com/windows",
+		"//alias=example.com/new:example.com/used",
+		"//unused=example.com/unused",
+		"@other//:lib=example.com/other:example.com/other/v2",
+	}
+
+	got, err := findUnusedDeps(srcs, checkDeps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"//unused", "@other//:lib"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	if _, err := findUnusedDeps(srcs, []string{"//bad"}); err == nil {
+		t.Error("got no error for -check_dep without import paths")
+	}
+}
+
+func TestFormatUnusedDeps(t *testing.T) {
+	got := formatUnusedDeps("//a:a", []string{"//b"})
+	if !strings.Contains(got, "buildozer 'remove deps //b' //a:a\n") {
+		t.Errorf("got %q; want a buildozer command", got)
+	}
+}
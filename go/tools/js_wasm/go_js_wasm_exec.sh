@@ -0,0 +1,36 @@
+#!/bin/sh
+# Copyright 2020 The Bazel Authors. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#    http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# go_js_wasm_exec runs a WebAssembly module built for GOOS=js with its
+# arguments, like misc/wasm/go_js_wasm_exec in the Go distribution.
+#
+# GO_JS_WASM_EXEC is the path of wasm_exec_node.js or wasm_exec.js from the Go
+# SDK the module was built with; the launchers of go_binary and go_test set it.
+# Node.js is found in PATH, or GOJSWASMNODE may name its executable.
+
+set -eu
+
+if [ $# -lt 1 ]; then
+  echo "usage: $0 module.wasm [args...]" >&2
+  exit 2
+fi
+if [ -z "${GO_JS_WASM_EXEC:-}" ]; then
+  echo "$0: GO_JS_WASM_EXEC is not set" >&2
+  exit 2
+fi
+
+# The Go runtime for js/wasm needs a larger stack than Node.js allows by
+# default; Go's script sets the same limit.
+exec "${GOJSWASMNODE:-node}" --stack-size=8192 "$GO_JS_WASM_EXEC" "$@"
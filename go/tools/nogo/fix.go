@@ -0,0 +1,404 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command fix applies the fixes suggested by nogo analyzers to source files
+// in the workspace, similar to "go vet -fix". It must be invoked with
+// bazel run:
+//
+//     bazel run @io_bazel_rules_go//go/tools/nogo:fix -- //...
+//
+// fix builds the given targets with nogo findings reported in the nogo_sarif
+// output group instead of failing the build, then applies the first fix
+// suggested for each finding. Fixes that would overlap with a fix that was
+// already applied are skipped; running fix again may apply them. Files
+// outside the workspace, such as generated files and files in external
+// repositories, are never changed.
+//
+// With -diff, fix prints the changes it would make as a unified diff instead
+// of writing files.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("fix: ")
+	if err := run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("fix", flag.ExitOnError)
+	bazel := fs.String("bazel", "bazel", "The bazel command used to build targets")
+	diff := fs.Bool("diff", false, "Print a diff of the fixes instead of applying them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("no targets given")
+	}
+	workspace := os.Getenv("BUILD_WORKSPACE_DIRECTORY")
+	if workspace == "" {
+		return errors.New("fix must be invoked with bazel run")
+	}
+
+	reports, err := buildReports(*bazel, workspace, fs.Args())
+	if err != nil {
+		return err
+	}
+	fixes, err := readFixes(reports)
+	if err != nil {
+		return err
+	}
+	files, skipped := planEdits(workspace, fixes)
+	for _, f := range skipped {
+		log.Printf("skipped fix %q: it overlaps with another fix", f.description)
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := filepath.Join(workspace, filepath.FromSlash(name))
+		old, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		fixed, err := applyEdits(old, files[name])
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		if *diff {
+			writeDiff(os.Stdout, name, old, files[name])
+			continue
+		}
+		if err := ioutil.WriteFile(path, fixed, 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A fix is a set of replacements that resolves one finding.
+type fix struct {
+	description string
+	edits       []edit
+}
+
+// An edit replaces length bytes at offset in file with newText. file is
+// relative to the execution root, which is also relative to the workspace for
+// source files in the main repository.
+type edit struct {
+	file           string
+	offset, length int
+	newText        string
+}
+
+func (e edit) end() int { return e.offset + e.length }
+
+// sarifFixLog contains the subset of a SARIF log written by nogo that's
+// needed to apply fixes.
+type sarifFixLog struct {
+	Runs []struct {
+		Results []struct {
+			Message struct {
+				Text string `json:"text"`
+			} `json:"message"`
+			Fixes []struct {
+				Description struct {
+					Text string `json:"text"`
+				} `json:"description"`
+				ArtifactChanges []struct {
+					ArtifactLocation struct {
+						URI string `json:"uri"`
+					} `json:"artifactLocation"`
+					Replacements []struct {
+						DeletedRegion struct {
+							ByteOffset int `json:"byteOffset"`
+							ByteLength int `json:"byteLength"`
+						} `json:"deletedRegion"`
+						InsertedContent struct {
+							Text string `json:"text"`
+						} `json:"insertedContent"`
+					} `json:"replacements"`
+				} `json:"artifactChanges"`
+			} `json:"fixes"`
+		} `json:"results"`
+	} `json:"runs"`
+}
+
+// readFixes returns the first fix suggested for each finding in the given
+// reports.
+func readFixes(reports []string) ([]fix, error) {
+	var fixes []fix
+	for _, report := range reports {
+		data, err := ioutil.ReadFile(report)
+		if err != nil {
+			return nil, err
+		}
+		var l sarifFixLog
+		if err := json.Unmarshal(data, &l); err != nil {
+			return nil, fmt.Errorf("%s: %v", report, err)
+		}
+		for _, run := range l.Runs {
+			for _, r := range run.Results {
+				if len(r.Fixes) == 0 {
+					continue
+				}
+				sf := r.Fixes[0]
+				f := fix{description: sf.Description.Text}
+				if f.description == "" {
+					f.description = r.Message.Text
+				}
+				for _, c := range sf.ArtifactChanges {
+					for _, rep := range c.Replacements {
+						f.edits = append(f.edits, edit{
+							file:    c.ArtifactLocation.URI,
+							offset:  rep.DeletedRegion.ByteOffset,
+							length:  rep.DeletedRegion.ByteLength,
+							newText: rep.InsertedContent.Text,
+						})
+					}
+				}
+				fixes = append(fixes, f)
+			}
+		}
+	}
+	return fixes, nil
+}
+
+// planEdits chooses the edits to apply to each workspace file. Fixes are
+// considered in order. A fix is skipped if any of its edits overlaps with an
+// edit of a fix that was already chosen. Edits identical to chosen edits are
+// ignored, since the same finding may be reported more than once (for
+// example, for both a library and its test). Fixes that change files outside
+// the workspace are dropped.
+func planEdits(workspace string, fixes []fix) (files map[string][]edit, skipped []fix) {
+	files = make(map[string][]edit)
+	for _, f := range fixes {
+		if !inWorkspace(workspace, f) {
+			continue
+		}
+		var added []edit
+		conflict := false
+		for _, e := range f.edits {
+			dup, overlap := false, false
+			for _, prev := range files[e.file] {
+				if prev == e {
+					dup = true
+					break
+				}
+				if e.offset < prev.end() && prev.offset < e.end() ||
+					e.offset == prev.offset && (e.length == 0 || prev.length == 0) {
+					overlap = true
+					break
+				}
+			}
+			if overlap {
+				conflict = true
+				break
+			}
+			if !dup {
+				added = append(added, e)
+			}
+		}
+		if conflict {
+			skipped = append(skipped, f)
+			continue
+		}
+		for _, e := range added {
+			files[e.file] = append(files[e.file], e)
+		}
+	}
+	for _, edits := range files {
+		sort.Slice(edits, func(i, j int) bool { return edits[i].offset < edits[j].offset })
+	}
+	return files, skipped
+}
+
+// inWorkspace returns whether every file changed by f is a source file in the
+// workspace.
+func inWorkspace(workspace string, f fix) bool {
+	for _, e := range f.edits {
+		if filepath.IsAbs(e.file) || strings.HasPrefix(e.file, "external/") || strings.HasPrefix(e.file, "bazel-out/") {
+			return false
+		}
+		if _, err := os.Stat(filepath.Join(workspace, filepath.FromSlash(e.file))); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// applyEdits returns content with the given edits applied. Edits must be
+// sorted by offset and must not overlap.
+func applyEdits(content []byte, edits []edit) ([]byte, error) {
+	var buf bytes.Buffer
+	last := 0
+	for _, e := range edits {
+		if e.offset < last || e.end() > len(content) {
+			return nil, fmt.Errorf("invalid edit at offset %d", e.offset)
+		}
+		buf.Write(content[last:e.offset])
+		buf.WriteString(e.newText)
+		last = e.end()
+	}
+	buf.Write(content[last:])
+	return buf.Bytes(), nil
+}
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// writeDiff writes the changes made by edits to content as a unified diff.
+// Edits must be sorted by offset and must not overlap.
+func writeDiff(w io.Writer, name string, content []byte, edits []edit) {
+	lineStarts := []int{0}
+	for i, b := range content {
+		if b == '\n' && i+1 < len(content) {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	lineOf := func(offset int) int {
+		return sort.Search(len(lineStarts), func(i int) bool { return lineStarts[i] > offset }) - 1
+	}
+	lineEnd := func(line int) int {
+		if line+1 < len(lineStarts) {
+			return lineStarts[line+1]
+		}
+		return len(content)
+	}
+
+	// Combine edits that touch the same lines into changes, then group
+	// changes close enough that their context would overlap into hunks.
+	type change struct {
+		first, last int // changed lines, inclusive
+		edits       []edit
+	}
+	var changes []change
+	for _, e := range edits {
+		first := lineOf(e.offset)
+		last := first
+		if e.length > 0 {
+			last = lineOf(e.end() - 1)
+		}
+		if n := len(changes); n > 0 && first <= changes[n-1].last {
+			if last > changes[n-1].last {
+				changes[n-1].last = last
+			}
+			changes[n-1].edits = append(changes[n-1].edits, e)
+			continue
+		}
+		changes = append(changes, change{first: first, last: last, edits: []edit{e}})
+	}
+	var hunks [][]change
+	for _, c := range changes {
+		if n := len(hunks); n > 0 {
+			h := hunks[n-1]
+			if c.first-h[len(h)-1].last-1 <= 2*diffContext {
+				hunks[n-1] = append(h, c)
+				continue
+			}
+		}
+		hunks = append(hunks, []change{c})
+	}
+
+	fmt.Fprintf(w, "--- a/%s\n+++ b/%s\n", name, name)
+	delta := 0 // lines added minus lines removed by previous hunks
+	for _, h := range hunks {
+		start := h[0].first - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := h[len(h)-1].last + diffContext
+		if end >= len(lineStarts) {
+			end = len(lineStarts) - 1
+		}
+
+		var body bytes.Buffer
+		oldCount, newCount := 0, 0
+		unchanged := func(from, to int) {
+			if from > to {
+				return
+			}
+			lines := splitLines(content[lineStarts[from]:lineEnd(to)])
+			writeLines(&body, " ", lines)
+			oldCount += len(lines)
+			newCount += len(lines)
+		}
+		next := start
+		for _, c := range h {
+			unchanged(next, c.first-1)
+			// Apply the edits to the changed lines only.
+			base := lineStarts[c.first]
+			shifted := make([]edit, len(c.edits))
+			for i, e := range c.edits {
+				e.offset -= base
+				shifted[i] = e
+			}
+			oldText := content[base:lineEnd(c.last)]
+			newText, _ := applyEdits(oldText, shifted)
+			oldLines, newLines := splitLines(oldText), splitLines(newText)
+			writeLines(&body, "-", oldLines)
+			writeLines(&body, "+", newLines)
+			oldCount += len(oldLines)
+			newCount += len(newLines)
+			next = c.last + 1
+		}
+		unchanged(next, end)
+
+		fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@\n", start+1, oldCount, start+1+delta, newCount)
+		w.Write(body.Bytes())
+		delta += newCount - oldCount
+	}
+}
+
+// splitLines splits text into lines, keeping line endings.
+func splitLines(text []byte) []string {
+	var lines []string
+	for len(text) > 0 {
+		i := bytes.IndexByte(text, '\n') + 1
+		if i == 0 {
+			i = len(text)
+		}
+		lines = append(lines, string(text[:i]))
+		text = text[i:]
+	}
+	return lines
+}
+
+func writeLines(w io.Writer, prefix string, lines []string) {
+	for _, line := range lines {
+		io.WriteString(w, prefix+line)
+		if !strings.HasSuffix(line, "\n") {
+			io.WriteString(w, "\n\\ No newline at end of file\n")
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPlanEdits(t *testing.T) {
+	workspace, err := ioutil.TempDir("", "fix_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(workspace)
+	if err := ioutil.WriteFile(filepath.Join(workspace, "a.go"), []byte("package a\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	first := fix{description: "first", edits: []edit{{file: "a.go", offset: 0, length: 7, newText: "PACKAGE"}}}
+	dup := fix{description: "dup", edits: first.edits}
+	overlap := fix{description: "overlap", edits: []edit{{file: "a.go", offset: 5, length: 4, newText: "x"}}}
+	insert := fix{description: "insert", edits: []edit{{file: "a.go", offset: 10, newText: "// end\n"}}}
+	missing := fix{description: "missing", edits: []edit{{file: "b.go", offset: 0, newText: "x"}}}
+	external := fix{description: "external", edits: []edit{{file: "external/c/c.go", offset: 0, newText: "x"}}}
+
+	files, skipped := planEdits(workspace, []fix{insert, first, dup, overlap, missing, external})
+	wantFiles := map[string][]edit{"a.go": {first.edits[0], insert.edits[0]}}
+	if !reflect.DeepEqual(files, wantFiles) {
+		t.Errorf("got files %v; want %v", files, wantFiles)
+	}
+	if len(skipped) != 1 || skipped[0].description != "overlap" {
+		t.Errorf("got skipped %v; want [overlap]", skipped)
+	}
+}
+
+func TestApplyEditsAndDiff(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 20; i++ {
+		lines = append(lines, "line"+string(rune('a'+i-1)))
+	}
+	content := []byte(strings.Join(lines, "\n") + "\n")
+	offset := func(line int) int { return bytes.Index(content, []byte(lines[line-1])) }
+	edits := []edit{
+		{file: "f.txt", offset: offset(2), length: len("lineb"), newText: "B"},
+		{file: "f.txt", offset: offset(4), length: len("lined\n"), newText: ""},
+		{file: "f.txt", offset: offset(18), newText: "new\n"},
+	}
+
+	got, err := applyEdits(content, edits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(string(content), "lineb", "B", 1)
+	want = strings.Replace(want, "lined\n", "", 1)
+	want = strings.Replace(want, "liner", "new\nliner", 1)
+	if string(got) != want {
+		t.Errorf("applyEdits: got %q; want %q", got, want)
+	}
+
+	var buf bytes.Buffer
+	writeDiff(&buf, "f.txt", content, edits)
+	wantDiff := `--- a/f.txt
++++ b/f.txt
+@@ -1,7 +1,6 @@
+ linea
+-lineb
++B
+ linec
+-lined
+ linee
+ linef
+ lineg
+@@ -15,6 +14,7 @@
+ lineo
+ linep
+ lineq
+-liner
++new
++liner
+ lines
+ linet
+`
+	if buf.String() != wantDiff {
+		t.Errorf("writeDiff: got\n%s\nwant\n%s", buf.String(), wantDiff)
+	}
+}
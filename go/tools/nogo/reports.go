@@ -0,0 +1,105 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// buildReports builds targets in workspace so that nogo writes SARIF reports
+// without failing the build, and returns the paths of the reports that were
+// built.
+func buildReports(bazel, workspace string, targets []string) ([]string, error) {
+	bepFile, err := ioutil.TempFile("", "nogo_bep")
+	if err != nil {
+		return nil, err
+	}
+	bepPath := bepFile.Name()
+	bepFile.Close()
+	defer os.Remove(bepPath)
+
+	args := []string{
+		"build",
+		"--@io_bazel_rules_go//go/config:nogo_write_baseline",
+		"--output_groups=nogo_sarif",
+		"--build_event_json_file=" + bepPath,
+		"--",
+	}
+	args = append(args, targets...)
+	cmd := exec.Command(bazel, args...)
+	cmd.Dir = workspace
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("building nogo reports: %v", err)
+	}
+
+	f, err := os.Open(bepPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return reportsFromBuildEvents(f)
+}
+
+// reportsFromBuildEvents returns the paths of the nogo SARIF reports listed
+// in a stream of build events in JSON format.
+func reportsFromBuildEvents(r io.Reader) ([]string, error) {
+	var event struct {
+		NamedSetOfFiles struct {
+			Files []struct {
+				URI string `json:"uri"`
+			} `json:"files"`
+		} `json:"namedSetOfFiles"`
+	}
+	seen := make(map[string]bool)
+	var reports []string
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		event.NamedSetOfFiles.Files = nil
+		if err := dec.Decode(&event); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("reading build events: %v", err)
+		}
+		for _, f := range event.NamedSetOfFiles.Files {
+			if !strings.HasSuffix(f.URI, ".nogo.sarif") {
+				continue
+			}
+			u, err := url.Parse(f.URI)
+			if err != nil || u.Scheme != "file" {
+				continue
+			}
+			path := filepath.FromSlash(u.Path)
+			if len(path) >= 3 && path[0] == filepath.Separator && path[2] == ':' {
+				// On Windows, file URIs look like file:///C:/foo.
+				path = path[1:]
+			}
+			if !seen[path] {
+				seen[path] = true
+				reports = append(reports, path)
+			}
+		}
+	}
+	return reports, nil
+}
@@ -0,0 +1,40 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReportsFromBuildEvents(t *testing.T) {
+	events := `{"id":{"started":{}},"started":{"uuid":"x"}}
+{"id":{"namedSet":{"id":"0"}},"namedSetOfFiles":{"files":[{"name":"a/a.nogo.sarif","uri":"file:///out/a/a.nogo.sarif"},{"name":"a/a.a","uri":"file:///out/a/a.a"}]}}
+{"id":{"namedSet":{"id":"1"}},"namedSetOfFiles":{"files":[{"name":"b/b%20c.nogo.sarif","uri":"file:///out/b/b%20c.nogo.sarif"},{"name":"a/a.nogo.sarif","uri":"file:///out/a/a.nogo.sarif"}]}}
+`
+	got, err := reportsFromBuildEvents(strings.NewReader(events))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.FromSlash("/out/a/a.nogo.sarif"),
+		filepath.FromSlash("/out/b/b c.nogo.sarif"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command write_baseline collects the findings nogo reports for the given
+// targets into a baseline file, which can be passed to the baseline
+// attribute of a nogo rule to suppress those findings. It must be invoked
+// with bazel run:
+//
+//     bazel run @io_bazel_rules_go//go/tools/nogo:write_baseline -- \
+//       -o nogo_baseline.json //...
+//
+// write_baseline builds the targets with nogo findings reported in the
+// nogo_sarif output group instead of failing the build, then collects the
+// reports listed in the build's events. Reports left in the output tree by
+// other builds are not included.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// fingerprintKey is the name of the partial fingerprint nogo writes for each
+// SARIF result. It must match sarifFingerprintKey in
+// go/tools/builders/nogo_sarif.go.
+const fingerprintKey = "nogo/v1"
+
+// baseline is the format of baseline files. It must match Baseline in
+// go/tools/builders/generate_nogo_main.go.
+type baseline struct {
+	Findings []baselineFinding `json:"findings"`
+}
+
+type baselineFinding struct {
+	Analyzer    string `json:"analyzer"`
+	File        string `json:"file"`
+	Message     string `json:"message"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// sarifLog contains the subset of a SARIF log written by nogo that's needed
+// to write a baseline.
+type sarifLog struct {
+	Runs []struct {
+		Results []struct {
+			RuleID  string `json:"ruleId"`
+			Message struct {
+				Text string `json:"text"`
+			} `json:"message"`
+			Locations []struct {
+				PhysicalLocation struct {
+					ArtifactLocation struct {
+						URI string `json:"uri"`
+					} `json:"artifactLocation"`
+				} `json:"physicalLocation"`
+			} `json:"locations"`
+			PartialFingerprints map[string]string `json:"partialFingerprints"`
+		} `json:"results"`
+	} `json:"runs"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("write_baseline: ")
+	if err := run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("write_baseline", flag.ExitOnError)
+	bazel := fs.String("bazel", "bazel", "The bazel command used to build targets")
+	out := fs.String("o", "", "The baseline file to write")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return errors.New("-o must be set")
+	}
+	if fs.NArg() == 0 {
+		return errors.New("no targets given")
+	}
+	workspace := os.Getenv("BUILD_WORKSPACE_DIRECTORY")
+	if workspace == "" {
+		return errors.New("write_baseline must be invoked with bazel run")
+	}
+
+	reports, err := buildReports(*bazel, workspace, fs.Args())
+	if err != nil {
+		return err
+	}
+	b, err := collectFindings(reports)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	return ioutil.WriteFile(workingDirPath(*out), data, 0666)
+}
+
+// workingDirPath resolves a relative path against the directory bazel run was
+// invoked from, since the tool itself runs in its runfiles directory.
+func workingDirPath(path string) string {
+	if wd := os.Getenv("BUILD_WORKING_DIRECTORY"); wd != "" && !filepath.IsAbs(path) {
+		return filepath.Join(wd, path)
+	}
+	return path
+}
+
+// collectFindings reads the given SARIF reports and returns a baseline
+// containing each distinct finding, sorted by file, analyzer, and message.
+func collectFindings(reports []string) (*baseline, error) {
+	seen := make(map[string]bool)
+	b := &baseline{Findings: []baselineFinding{}}
+	for _, report := range reports {
+		data, err := ioutil.ReadFile(report)
+		if err != nil {
+			return nil, err
+		}
+		var l sarifLog
+		if err := json.Unmarshal(data, &l); err != nil {
+			return nil, fmt.Errorf("%s: %v", report, err)
+		}
+		for _, run := range l.Runs {
+			for _, r := range run.Results {
+				fp := r.PartialFingerprints[fingerprintKey]
+				if fp == "" {
+					return nil, fmt.Errorf("%s: result for %s has no %s fingerprint", report, r.RuleID, fingerprintKey)
+				}
+				if seen[fp] {
+					continue
+				}
+				seen[fp] = true
+				f := baselineFinding{
+					Analyzer:    r.RuleID,
+					Message:     r.Message.Text,
+					Fingerprint: fp,
+				}
+				if len(r.Locations) > 0 {
+					f.File = r.Locations[0].PhysicalLocation.ArtifactLocation.URI
+				}
+				b.Findings = append(b.Findings, f)
+			}
+		}
+	}
+	sort.Slice(b.Findings, func(i, j int) bool {
+		fi, fj := b.Findings[i], b.Findings[j]
+		if fi.File != fj.File {
+			return fi.File < fj.File
+		}
+		if fi.Analyzer != fj.Analyzer {
+			return fi.Analyzer < fj.Analyzer
+		}
+		if fi.Message != fj.Message {
+			return fi.Message < fj.Message
+		}
+		return fi.Fingerprint < fj.Fingerprint
+	})
+	return b, nil
+}
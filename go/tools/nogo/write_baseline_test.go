@@ -0,0 +1,94 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const reportTmpl = `{
+  "version": "2.1.0",
+  "runs": [{
+    "tool": {"driver": {"name": "nogo"}},
+    "results": [%s]
+  }]
+}`
+
+func result(analyzer, file, message, fingerprint string) string {
+	return `{
+  "ruleId": "` + analyzer + `",
+  "message": {"text": "` + message + `"},
+  "locations": [{"physicalLocation": {"artifactLocation": {"uri": "` + file + `"}, "region": {"startLine": 1}}}],
+  "partialFingerprints": {"nogo/v1": "` + fingerprint + `"}
+}`
+}
+
+func TestCollectFindings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "write_baseline_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	reports := map[string]string{
+		"a.nogo.sarif": result("printf", "a/a.go", "bad format", "fa") + "," +
+			result("bools", "a/a.go", "redundant or", "fb"),
+		"b.nogo.sarif":          result("printf", "b/b.go", "bad format", "fc"),
+		"b.internal.nogo.sarif": result("printf", "b/b.go", "bad format", "fc"),
+	}
+	var paths []string
+	for name, results := range reports {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(fmt.Sprintf(reportTmpl, results)), 0666); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	got, err := collectFindings(paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &baseline{Findings: []baselineFinding{
+		{Analyzer: "bools", File: "a/a.go", Message: "redundant or", Fingerprint: "fb"},
+		{Analyzer: "printf", File: "a/a.go", Message: "bad format", Fingerprint: "fa"},
+		{Analyzer: "printf", File: "b/b.go", Message: "bad format", Fingerprint: "fc"},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v; want %#v", got, want)
+	}
+}
+
+func TestCollectFindingsMissingFingerprint(t *testing.T) {
+	f, err := ioutil.TempFile("", "write_baseline_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(fmt.Sprintf(reportTmpl, `{"ruleId": "printf"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := collectFindings([]string{f.Name()}); err == nil {
+		t.Error("unexpected success")
+	}
+}
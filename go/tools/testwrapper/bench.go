@@ -0,0 +1,208 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// benchmarkBaseline is the runfiles path of a file with benchmark results
+// to compare with. It is set by the generated test main for go_benchmark
+// targets with a baseline.
+var benchmarkBaseline string
+
+// benchmarkThreshold is the largest slowdown in time per operation, in
+// percent, that is not reported as a regression.
+var benchmarkThreshold = 10.0
+
+// errBenchmarkRegression is returned by checkBenchmarks when a benchmark is
+// slower than its baseline by more than benchmarkThreshold.
+var errBenchmarkRegression = errors.New("benchmarks regressed compared to baseline")
+
+// checkBenchmarks extracts benchmark results from the output of a test and
+// writes them to benchmark.txt in TEST_UNDECLARED_OUTPUTS_DIR in the
+// standard benchmark format, so they can be compared with benchstat. If
+// benchmarkBaseline is set, the results are compared with it, and a report
+// is written to w.
+func checkBenchmarks(output []byte, w io.Writer) error {
+	results := extractBenchmarks(output)
+	if len(results) == 0 {
+		return nil
+	}
+	if dir, ok := os.LookupEnv("TEST_UNDECLARED_OUTPUTS_DIR"); ok {
+		if err := ioutil.WriteFile(filepath.Join(dir, "benchmark.txt"), results, 0666); err != nil {
+			return fmt.Errorf("error writing benchmark results: %v", err)
+		}
+	}
+	if benchmarkBaseline == "" {
+		return nil
+	}
+
+	path := filepath.Join(os.Getenv("TEST_SRCDIR"), os.Getenv("TEST_WORKSPACE"), filepath.FromSlash(benchmarkBaseline))
+	baseline, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading benchmark baseline: %v", err)
+	}
+	report, regressed := compareBenchmarks(parseBenchmarks(baseline), parseBenchmarks(results), benchmarkThreshold)
+	fmt.Fprintf(w, "\nComparison with %s (threshold %g%%):\n%s", benchmarkBaseline, benchmarkThreshold, report)
+	if regressed {
+		return errBenchmarkRegression
+	}
+	return nil
+}
+
+// extractBenchmarks returns the benchmark results and configuration lines
+// (like "goos: linux") from the output of a test, leaving out test output.
+func extractBenchmarks(output []byte) []byte {
+	var buf bytes.Buffer
+	hasResults := false
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if _, _, ok := parseBenchmarkLine(line); ok {
+			hasResults = true
+		} else if !isBenchmarkConfigLine(line) {
+			continue
+		}
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	if !hasResults {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// parseBenchmarks returns the time per operation of each benchmark in data,
+// in nanoseconds. Benchmarks run more than once have several samples. The
+// GOMAXPROCS suffix is removed from names, so results from machines with
+// different numbers of CPUs can be compared.
+func parseBenchmarks(data []byte) map[string][]float64 {
+	results := make(map[string][]float64)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if name, nsPerOp, ok := parseBenchmarkLine(scanner.Text()); ok {
+			if i := strings.LastIndexByte(name, '-'); i >= 0 {
+				if _, err := strconv.Atoi(name[i+1:]); err == nil {
+					name = name[:i]
+				}
+			}
+			results[name] = append(results[name], nsPerOp)
+		}
+	}
+	return results
+}
+
+// parseBenchmarkLine parses a result line like
+// "BenchmarkFoo-8   1000   1234 ns/op   16 B/op".
+func parseBenchmarkLine(line string) (name string, nsPerOp float64, ok bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+		return "", 0, false
+	}
+	if _, err := strconv.Atoi(fields[1]); err != nil {
+		return "", 0, false
+	}
+	for i := 2; i+1 < len(fields); i += 2 {
+		if fields[i+1] == "ns/op" {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return "", 0, false
+			}
+			return fields[0], v, true
+		}
+	}
+	return "", 0, false
+}
+
+// isBenchmarkConfigLine returns whether line is a configuration line like
+// "goos: linux" or "pkg: example.com/foo", which benchstat uses to group
+// results. Only keys printed by the testing package are recognized, so test
+// output that happens to look like "key: value" is not mistaken for one.
+func isBenchmarkConfigLine(line string) bool {
+	i := strings.Index(line, ": ")
+	if i <= 0 {
+		return false
+	}
+	switch line[:i] {
+	case "goos", "goarch", "pkg", "cpu":
+		return true
+	}
+	return false
+}
+
+// compareBenchmarks compares the mean time per operation of benchmarks found
+// in both baseline and results. regressed is true if any benchmark is slower
+// by more than threshold percent.
+func compareBenchmarks(baseline, results map[string][]float64, threshold float64) (report string, regressed bool) {
+	var names []string
+	for name := range results {
+		if _, ok := baseline[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "name\told time/op\tnew time/op\tdelta\t")
+	for _, name := range names {
+		oldMean, newMean := mean(baseline[name]), mean(results[name])
+		delta := 0.0
+		if oldMean > 0 {
+			delta = (newMean - oldMean) / oldMean * 100
+		}
+		note := ""
+		if delta > threshold {
+			note = "regression"
+			regressed = true
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%+.2f%%\t%s\n", name, formatNs(oldMean), formatNs(newMean), delta, note)
+	}
+	tw.Flush()
+	return buf.String(), regressed
+}
+
+func mean(samples []float64) float64 {
+	sum := 0.0
+	for _, s := range samples {
+		sum += s
+	}
+	return sum / float64(len(samples))
+}
+
+func formatNs(ns float64) string {
+	switch {
+	case ns >= 1e9:
+		return fmt.Sprintf("%.2fs", ns/1e9)
+	case ns >= 1e6:
+		return fmt.Sprintf("%.2fms", ns/1e6)
+	case ns >= 1e3:
+		return fmt.Sprintf("%.2fµs", ns/1e3)
+	default:
+		return fmt.Sprintf("%.2fns", ns)
+	}
+}
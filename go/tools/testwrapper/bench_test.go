@@ -0,0 +1,112 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const benchmarkOutput = `=== RUN   TestIgnored
+--- PASS: TestIgnored (0.00s)
+goos: linux
+goarch: amd64
+pkg: example.com/fast
+BenchmarkFast
+BenchmarkFast-8   	 1000000	      1000 ns/op	      16 B/op	       1 allocs/op
+BenchmarkFast-8   	 1000000	      1200 ns/op	      16 B/op	       1 allocs/op
+BenchmarkSlow-8   	     100	  20000000 ns/op
+PASS
+ok  	example.com/fast	3.000s
+`
+
+func TestExtractBenchmarks(t *testing.T) {
+	got := string(extractBenchmarks([]byte(benchmarkOutput)))
+	want := `goos: linux
+goarch: amd64
+pkg: example.com/fast
+BenchmarkFast-8   	 1000000	      1000 ns/op	      16 B/op	       1 allocs/op
+BenchmarkFast-8   	 1000000	      1200 ns/op	      16 B/op	       1 allocs/op
+BenchmarkSlow-8   	     100	  20000000 ns/op
+`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if got := extractBenchmarks([]byte("goos: linux\nPASS\n")); got != nil {
+		t.Errorf("got %q for output without benchmarks; want nil", got)
+	}
+}
+
+func TestParseBenchmarks(t *testing.T) {
+	got := parseBenchmarks([]byte(benchmarkOutput))
+	want := map[string][]float64{
+		"BenchmarkFast": {1000, 1200},
+		"BenchmarkSlow": {20000000},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
+func TestCompareBenchmarks(t *testing.T) {
+	baseline := map[string][]float64{
+		"BenchmarkFast-8": {1000},
+		"BenchmarkSlow-8": {20000000},
+		"BenchmarkGone-8": {10},
+	}
+	results := map[string][]float64{
+		"BenchmarkFast-8": {1000, 1200},
+		"BenchmarkSlow-8": {20000000},
+		"BenchmarkNew-8":  {10},
+	}
+	report, regressed := compareBenchmarks(baseline, results, 10)
+	if regressed {
+		t.Errorf("regression reported for a 10%% slowdown with a 10%% threshold:\n%s", report)
+	}
+	for _, want := range []string{"BenchmarkFast-8", "+10.00%", "BenchmarkSlow-8", "20.00ms"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report does not contain %q:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, "BenchmarkGone") || strings.Contains(report, "BenchmarkNew") {
+		t.Errorf("report contains benchmarks missing from the baseline or results:\n%s", report)
+	}
+
+	report, regressed = compareBenchmarks(baseline, results, 5)
+	if !regressed || !strings.Contains(report, "regression") {
+		t.Errorf("no regression reported for a 10%% slowdown with a 5%% threshold:\n%s", report)
+	}
+}
+
+func TestIsBenchmarkConfigLine(t *testing.T) {
+	for _, tc := range []struct {
+		line string
+		want bool
+	}{
+		{"goos: linux", true},
+		{"goarch: amd64", true},
+		{"pkg: example.com/fast", true},
+		{"cpu: Intel(R) Xeon(R) CPU @ 2.20GHz", true},
+		{"status: ok", false},
+		{"retries: 3", false},
+		{"goos:linux", false},
+	} {
+		if got := isBenchmarkConfigLine(tc.line); got != tc.want {
+			t.Errorf("isBenchmarkConfigLine(%q) = %v; want %v", tc.line, got, tc.want)
+		}
+	}
+}
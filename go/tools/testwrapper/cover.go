@@ -0,0 +1,86 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// coverDirName is the name of the directory in TEST_UNDECLARED_OUTPUTS_DIR
+// where coverage data is written when GOCOVERDIR isn't set.
+const coverDirName = "gocoverdir"
+
+// covdataTool is the runfiles path of the SDK's covdata tool. It is set by
+// the generated test main when the test is built for coverage with
+// --@io_bazel_rules_go//go/config:cover_format=gocoverdir. Instrumented
+// programs then write coverage data to GOCOVERDIR when they exit.
+var covdataTool string
+
+// setUpCoverDir sets GOCOVERDIR for the test and the programs it starts, if
+// the test writes coverage data there and the variable isn't set already.
+// The data is kept with the test's undeclared outputs, so it can be merged
+// with data from other tests using "go tool covdata". It returns the
+// directory, or "" if coverage data isn't collected.
+func setUpCoverDir() (string, error) {
+	if covdataTool == "" {
+		return "", nil
+	}
+	if dir := os.Getenv("GOCOVERDIR"); dir != "" {
+		return dir, nil
+	}
+	parent := os.Getenv("TEST_UNDECLARED_OUTPUTS_DIR")
+	if parent == "" {
+		parent = os.Getenv("TEST_TMPDIR")
+	}
+	if parent == "" {
+		return "", nil
+	}
+	dir := filepath.Join(parent, coverDirName)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return "", fmt.Errorf("error creating coverage directory: %v", err)
+	}
+	// The wrapper is instrumented like the test binary, since they're the same
+	// program, so it also needs the variable to write its own data on exit.
+	if err := os.Setenv("GOCOVERDIR", dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// writeCoverageProfile converts the coverage data in dir to a profile in the
+// format written by "go test -coverprofile", and writes it to
+// COVERAGE_OUTPUT_FILE, where "bazel coverage" expects it. Data written by
+// every instrumented program the test ran is merged.
+func writeCoverageProfile(dir string) error {
+	out, ok := os.LookupEnv("COVERAGE_OUTPUT_FILE")
+	if !ok || dir == "" {
+		return nil
+	}
+	if meta, err := filepath.Glob(filepath.Join(dir, "covmeta.*")); err != nil || len(meta) == 0 {
+		// No instrumented program ran to completion.
+		return err
+	}
+	tool := filepath.Join(os.Getenv("TEST_SRCDIR"), os.Getenv("TEST_WORKSPACE"), filepath.FromSlash(covdataTool))
+	cmd := exec.Command(tool, "textfmt", "-i", dir, "-o", out)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error converting coverage data: %v", err)
+	}
+	return nil
+}
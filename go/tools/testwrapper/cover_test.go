@@ -0,0 +1,77 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setEnv sets environment variables for the duration of a test. An empty
+// value unsets the variable.
+func setEnv(t *testing.T, vars map[string]string) {
+	for k, v := range vars {
+		old, ok := os.LookupEnv(k)
+		if v == "" {
+			os.Unsetenv(k)
+		} else {
+			os.Setenv(k, v)
+		}
+		k := k
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(k, old)
+			} else {
+				os.Unsetenv(k)
+			}
+		})
+	}
+}
+
+func TestSetUpCoverDir(t *testing.T) {
+	outputs, err := ioutil.TempDir("", "TestSetUpCoverDir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outputs)
+	defer func(old string) { covdataTool = old }(covdataTool)
+
+	covdataTool = ""
+	setEnv(t, map[string]string{"GOCOVERDIR": "", "TEST_UNDECLARED_OUTPUTS_DIR": outputs})
+	if dir, err := setUpCoverDir(); err != nil || dir != "" {
+		t.Errorf("without covdata: got %q, %v; want no directory", dir, err)
+	}
+
+	covdataTool = "../go_sdk/pkg/tool/linux_amd64/covdata"
+	want := filepath.Join(outputs, coverDirName)
+	if dir, err := setUpCoverDir(); err != nil {
+		t.Fatal(err)
+	} else if dir != want {
+		t.Errorf("got %q; want %q", dir, want)
+	}
+	if fi, err := os.Stat(want); err != nil || !fi.IsDir() {
+		t.Errorf("%s was not created: %v", want, err)
+	}
+	if got := os.Getenv("GOCOVERDIR"); got != want {
+		t.Errorf("got GOCOVERDIR=%q; want %q", got, want)
+	}
+
+	setEnv(t, map[string]string{"GOCOVERDIR": "/custom/dir"})
+	if dir, err := setUpCoverDir(); err != nil || dir != "/custom/dir" {
+		t.Errorf("with GOCOVERDIR set: got %q, %v; want %q", dir, err, "/custom/dir")
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// raceStress returns the number of times each test should run under each
+// GOMAXPROCS value when stressing a race-enabled test, as set by the
+// race_stress attribute of go_test. 0 means tests run normally.
+func raceStress() int {
+	stressEnv, ok := os.LookupEnv("GO_TEST_RACE_STRESS")
+	if !ok {
+		return 0
+	}
+	stress, err := strconv.Atoi(stressEnv)
+	if err != nil || stress < 0 {
+		log.Fatalf("invalid value for GO_TEST_RACE_STRESS: %q", stressEnv)
+	}
+	return stress
+}
+
+// raceStressArgs returns the flags that make the test binary run each test
+// count times with each GOMAXPROCS value in cpus.
+func raceStressArgs(count int, cpus []int) []string {
+	return []string{
+		"-test.count=" + strconv.Itoa(count),
+		"-test.cpu=" + cpuList(cpus),
+	}
+}
+
+// cpuList formats GOMAXPROCS values as a comma-separated list, as accepted
+// by -test.cpu.
+func cpuList(cpus []int) string {
+	list := make([]string, len(cpus))
+	for i, n := range cpus {
+		list[i] = strconv.Itoa(n)
+	}
+	return strings.Join(list, ",")
+}
+
+// raceStressCPUs returns the GOMAXPROCS values tests are run with: 1, 2, 4,
+// and the number of CPUs available, so that goroutines are scheduled both
+// sequentially and in parallel.
+func raceStressCPUs(numCPU int) []int {
+	seen := make(map[int]bool)
+	var cpus []int
+	for _, n := range []int{1, 2, 4, numCPU} {
+		if !seen[n] {
+			seen[n] = true
+			cpus = append(cpus, n)
+		}
+	}
+	sort.Ints(cpus)
+	return cpus
+}
+
+const (
+	raceReportDelimiter = "=================="
+
+	// maxRaceOutputSize is how much of the error output of a stressed test
+	// is kept to find race reports in. Races are usually reported in the
+	// first iterations, and later output is dropped instead of holding all
+	// of it in memory.
+	maxRaceOutputSize = 16 << 20
+)
+
+// Parts of race reports that differ between occurrences of the same race.
+var raceReportNoise = regexp.MustCompile(`0x[0-9a-f]+|[Gg]oroutine \d+`)
+
+// raceReport is a data race reported by the race detector, along with the
+// number of times it was reported.
+type raceReport struct {
+	text  string
+	count int
+}
+
+// parseRaceReports returns the distinct data races reported in output, in the
+// order they were first reported. Reports of the same race in different
+// iterations differ in addresses and goroutine numbers, which are ignored.
+func parseRaceReports(output string) []*raceReport {
+	var reports []*raceReport
+	byKey := make(map[string]*raceReport)
+	lines := strings.Split(output, "\n")
+	for i := 0; i < len(lines); i++ {
+		if lines[i] != raceReportDelimiter || i+1 >= len(lines) || lines[i+1] != "WARNING: DATA RACE" {
+			continue
+		}
+		end := i + 1
+		for end < len(lines) && lines[end] != raceReportDelimiter {
+			end++
+		}
+		if end == len(lines) {
+			// The output was truncated in the middle of a report.
+			break
+		}
+		text := strings.Join(lines[i+1:end], "\n")
+		i = end
+		key := raceReportNoise.ReplaceAllString(text, "")
+		if r, ok := byKey[key]; ok {
+			r.count++
+			continue
+		}
+		r := &raceReport{text: text, count: 1}
+		byKey[key] = r
+		reports = append(reports, r)
+	}
+	return reports
+}
+
+// printRaceSummary writes the distinct data races in output to w, so they can
+// be found at the end of the test log instead of among the output of every
+// iteration. truncated is true if the end of the output was dropped.
+func printRaceSummary(w io.Writer, output string, truncated bool, count int, cpus []int) {
+	if truncated {
+		fmt.Fprintf(w, "testwrapper: race stress: error output is longer than %d MB; only races reported in the first %d MB are summarized\n", maxRaceOutputSize>>20, maxRaceOutputSize>>20)
+	}
+	reports := parseRaceReports(output)
+	if len(reports) == 0 {
+		fmt.Fprintf(w, "testwrapper: race stress: no data races found running each test %d times with GOMAXPROCS=%s\n", count, cpuList(cpus))
+		return
+	}
+	fmt.Fprintf(w, "testwrapper: race stress: found %d distinct data races running each test %d times with GOMAXPROCS=%s\n", len(reports), count, cpuList(cpus))
+	for _, r := range reports {
+		fmt.Fprintf(w, "%s\n%s\n(reported %d times)\n", raceReportDelimiter, r.text, r.count)
+	}
+	fmt.Fprintln(w, raceReportDelimiter)
+}
+
+// headBuffer is a writer that keeps the first max bytes written to it.
+type headBuffer struct {
+	buf       []byte
+	max       int
+	truncated bool
+}
+
+func (b *headBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if room := b.max - len(b.buf); n > room {
+		p = p[:room]
+		b.truncated = true
+	}
+	b.buf = append(b.buf, p...)
+	return n, nil
+}
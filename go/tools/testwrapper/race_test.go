@@ -0,0 +1,116 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRaceStressCPUs(t *testing.T) {
+	for _, test := range []struct {
+		numCPU int
+		want   []int
+	}{
+		{1, []int{1, 2, 4}},
+		{4, []int{1, 2, 4}},
+		{16, []int{1, 2, 4, 16}},
+	} {
+		if got := raceStressCPUs(test.numCPU); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("raceStressCPUs(%d) = %v; want %v", test.numCPU, got, test.want)
+		}
+	}
+	if got, want := raceStressArgs(10, []int{1, 2, 4}), []string{"-test.count=10", "-test.cpu=1,2,4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestParseRaceReports(t *testing.T) {
+	const output = `=== RUN   TestRace
+==================
+WARNING: DATA RACE
+Write at 0x00c0000a4010 by goroutine 8:
+  example.com/p.TestRace.func1()
+      /src/p_test.go:12 +0x3a
+
+Previous write at 0x00c0000a4010 by goroutine 7:
+  example.com/p.TestRace()
+      /src/p_test.go:14 +0x8e
+==================
+    testing.go:1093: race detected during execution of test
+==================
+WARNING: DATA RACE
+Write at 0x00c0000b6020 by goroutine 12:
+  example.com/p.TestRace.func1()
+      /src/p_test.go:12 +0x3a
+
+Previous write at 0x00c0000b6020 by goroutine 11:
+  example.com/p.TestRace()
+      /src/p_test.go:14 +0x8e
+==================
+==================
+WARNING: DATA RACE
+Read at 0x00c0000b6030 by goroutine 13:
+  example.com/p.TestOther()
+      /src/p_test.go:30 +0x3a
+==================
+`
+	reports := parseRaceReports(output + "==================\nWARNING: DATA RACE\nRead at")
+	if len(reports) != 2 {
+		t.Fatalf("got %d reports; want 2", len(reports))
+	}
+	if reports[0].count != 2 || !strings.Contains(reports[0].text, "by goroutine 8") {
+		t.Errorf("got first report %+v; want the first occurrence, reported twice", reports[0])
+	}
+	if reports[1].count != 1 || !strings.Contains(reports[1].text, "TestOther") {
+		t.Errorf("got second report %+v; want the race in TestOther", reports[1])
+	}
+}
+
+func TestRepeatedRuns(t *testing.T) {
+	const events = `{"Action":"run","Test":"TestRace"}
+{"Action":"fail","Test":"TestRace"}
+{"Action":"run","Test":"TestRace"}
+{"Action":"pass","Test":"TestRace"}
+{"Action":"run","Test":"TestOK"}
+{"Action":"pass","Test":"TestOK"}
+{"Action":"run","Test":"TestOK"}
+{"Action":"pass","Test":"TestOK"}
+{"Action":"fail"}
+`
+	_, testcases, err := parseTestEvents(strings.NewReader(events))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := testcases["TestRace"].state; got != "fail" {
+		t.Errorf("TestRace: got state %q; want fail", got)
+	}
+	if got := testcases["TestOK"].state; got != "pass" {
+		t.Errorf("TestOK: got state %q; want pass", got)
+	}
+}
+
+func TestHeadBuffer(t *testing.T) {
+	b := &headBuffer{max: 4}
+	for _, s := range []string{"abc", "def", "ghi"} {
+		if n, err := b.Write([]byte(s)); n != len(s) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v; want %d, nil", s, n, err, len(s))
+		}
+	}
+	if got := string(b.buf); got != "abcd" || !b.truncated {
+		t.Errorf("got %q, truncated %v; want \"abcd\", truncated", got, b.truncated)
+	}
+}
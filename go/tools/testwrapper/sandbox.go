@@ -0,0 +1,227 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// sandboxChecksEnv lists the hermeticity checks enabled by the
+	// sandbox_checks attribute of go_test, separated by commas.
+	sandboxChecksEnv = "GO_TEST_SANDBOX_CHECKS"
+
+	// sandboxViolationsEnv is the file where the test process reports
+	// blocked accesses, one per line, so the wrapper can fail the test even
+	// if the test handled the error.
+	sandboxViolationsEnv = "GO_TEST_SANDBOX_VIOLATIONS"
+)
+
+// errSandboxViolation is returned by sandboxChecks.check when the test
+// accessed the network or wrote files into its runfiles tree.
+var errSandboxViolation = errors.New("test is not hermetic")
+
+// sandboxCheckEnabled reports whether the named check is listed in
+// GO_TEST_SANDBOX_CHECKS.
+func sandboxCheckEnabled(name string) bool {
+	for _, check := range strings.Split(os.Getenv(sandboxChecksEnv), ",") {
+		if check == name {
+			return true
+		}
+	}
+	return false
+}
+
+// reportSandboxViolation prints a blocked access and records it in the file
+// named by GO_TEST_SANDBOX_VIOLATIONS, if it's set.
+func reportSandboxViolation(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	fmt.Fprintf(os.Stderr, "testwrapper: %s\n", msg)
+	path := os.Getenv(sandboxViolationsEnv)
+	if path == "" {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, msg)
+}
+
+// sandboxChecks holds what the wrapper needs to check a test's hermeticity
+// after it runs.
+type sandboxChecks struct {
+	// violations is the file where the test reports blocked accesses.
+	violations string
+
+	// root is the directory checked for writes, and files is what it
+	// contained before the test ran. root is "" if writes aren't checked.
+	root  string
+	files map[string]fileState
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// startSandboxChecks prepares the checks enabled with GO_TEST_SANDBOX_CHECKS
+// before the test runs. It returns nil if no check is enabled.
+//
+// Network accesses are blocked in the test process and reported through a
+// file in TEST_TMPDIR. Writes are found by comparing the runfiles tree before
+// and after the test, since it's where tests most often write by accident,
+// for example to update golden files in testdata. Writes anywhere else, like
+// /tmp or source files that aren't runfiles, aren't detected.
+func startSandboxChecks() (*sandboxChecks, error) {
+	network, filesystem := sandboxCheckEnabled("network"), sandboxCheckEnabled("filesystem")
+	if !network && !filesystem {
+		return nil, nil
+	}
+	s := &sandboxChecks{}
+	if network {
+		f, err := ioutil.TempFile("", "sandbox_violations")
+		if err != nil {
+			return nil, err
+		}
+		f.Close()
+		s.violations = f.Name()
+		if err := os.Setenv(sandboxViolationsEnv, s.violations); err != nil {
+			return nil, err
+		}
+	}
+	if root := os.Getenv("TEST_SRCDIR"); filesystem && root != "" {
+		files, err := snapshotFiles(root, sandboxWritableDirs())
+		if err != nil {
+			return nil, fmt.Errorf("error listing runfiles: %v", err)
+		}
+		s.root, s.files = root, files
+	}
+	return s, nil
+}
+
+// check prints the accesses blocked while the test ran and the files it
+// wrote in the runfiles tree to w, and returns errSandboxViolation if there
+// were any.
+func (s *sandboxChecks) check(w io.Writer) error {
+	if s == nil {
+		return nil
+	}
+	var violations []string
+	if s.violations != "" {
+		data, err := ioutil.ReadFile(s.violations)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		// The resolver retries lookups, so the same access may be reported
+		// several times.
+		seen := map[string]bool{}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line != "" && !seen[line] {
+				seen[line] = true
+				violations = append(violations, line)
+			}
+		}
+		os.Remove(s.violations)
+	}
+	if s.root != "" {
+		after, err := snapshotFiles(s.root, sandboxWritableDirs())
+		if err != nil {
+			return fmt.Errorf("error listing runfiles: %v", err)
+		}
+		for _, path := range changedFiles(s.files, after) {
+			violations = append(violations, "modified "+path+" in the runfiles tree")
+		}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	fmt.Fprintf(w, "testwrapper: %s:\n", errSandboxViolation)
+	for _, v := range violations {
+		fmt.Fprintf(w, "\t%s\n", v)
+	}
+	return errSandboxViolation
+}
+
+// sandboxWritableDirs returns the directories tests may write to, which are
+// skipped when they're in the runfiles tree.
+func sandboxWritableDirs() []string {
+	var dirs []string
+	for _, key := range []string{"TEST_TMPDIR", "TEST_UNDECLARED_OUTPUTS_DIR"} {
+		if dir := os.Getenv(key); dir != "" {
+			dirs = append(dirs, filepath.Clean(dir))
+		}
+	}
+	return dirs
+}
+
+// snapshotFiles returns the size and modification time of each file under
+// root, except in the skipped directories. Runfiles are usually symbolic
+// links, so the files they point to are checked, but linked directories
+// aren't walked.
+func snapshotFiles(root string, skip []string) (map[string]fileState, error) {
+	files := map[string]fileState{}
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if fi.IsDir() {
+			for _, dir := range skip {
+				if path == dir {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if target, err := os.Stat(path); err == nil {
+				fi = target
+			}
+		}
+		files[path] = fileState{size: fi.Size(), modTime: fi.ModTime()}
+		return nil
+	})
+	return files, err
+}
+
+// changedFiles returns the sorted paths of files that were created, modified,
+// or removed between the two snapshots.
+func changedFiles(before, after map[string]fileState) []string {
+	var changed []string
+	for path, state := range after {
+		if old, ok := before[path]; !ok || old.size != state.size || !old.modTime.Equal(state.modTime) {
+			changed = append(changed, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
@@ -0,0 +1,79 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// This file is only compiled into tests with the "network" sandbox check,
+// so other tests don't link net/http.
+
+func init() {
+	if sandboxCheckEnabled("network") {
+		blockNetwork()
+	}
+}
+
+// blockNetwork makes DNS lookups and connections from the default HTTP
+// transport fail, except to loopback addresses, so servers started with
+// net/http/httptest still work. Connections to IP addresses with net.Dial
+// aren't intercepted; they're blocked by Bazel's sandbox where it supports
+// the block-network execution requirement.
+func blockNetwork() {
+	net.DefaultResolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			// Lookups of localhost are answered from the hosts file, so
+			// the resolver only dials to reach a DNS server.
+			return nil, blockedAccess("DNS lookup through %s", address)
+		},
+	}
+	if t, ok := http.DefaultTransport.(*http.Transport); ok {
+		dial := t.DialContext
+		if dial == nil {
+			dial = (&net.Dialer{}).DialContext
+		}
+		t.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+			if !isLoopback(address) {
+				return nil, blockedAccess("%s connection to %s", network, address)
+			}
+			return dial(ctx, network, address)
+		}
+	}
+}
+
+// isLoopback reports whether address, a host and port, refers to this
+// machine.
+func isLoopback(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func blockedAccess(format string, args ...interface{}) error {
+	msg := "blocked " + fmt.Sprintf(format, args...)
+	reportSandboxViolation("%s", msg)
+	return fmt.Errorf("%s: tests must not access the network (sandbox_checks includes \"network\")", msg)
+}
@@ -0,0 +1,180 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSandboxCheckEnabled(t *testing.T) {
+	setEnv(t, map[string]string{sandboxChecksEnv: "network,filesystem"})
+	if !sandboxCheckEnabled("network") || !sandboxCheckEnabled("filesystem") || sandboxCheckEnabled("net") {
+		t.Errorf("checks not parsed from %s=%q", sandboxChecksEnv, os.Getenv(sandboxChecksEnv))
+	}
+}
+
+func TestChangedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestChangedFiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, "tmp")
+	for _, name := range []string{"same", "modified", "removed", "tmp/scratch"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(name), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(dir, "modified"), filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	before, err := snapshotFiles(dir, []string{tmp})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "modified"), []byte("golden file update"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "removed")); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"created", "tmp/scratch", "tmp/new"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("new"), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	after, err := snapshotFiles(dir, []string{tmp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, path := range changedFiles(before, after) {
+		got = append(got, filepath.Base(path))
+	}
+	// The link's target changed, so the link is reported along with it.
+	want := []string{"created", "link", "modified", "removed"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got changed files %v; want %v", got, want)
+	}
+}
+
+func TestSandboxChecks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestSandboxChecks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	srcdir := filepath.Join(dir, "runfiles")
+	tmp := filepath.Join(srcdir, "tmp")
+	if err := os.MkdirAll(tmp, 0777); err != nil {
+		t.Fatal(err)
+	}
+	setEnv(t, map[string]string{
+		sandboxChecksEnv:              "network,filesystem",
+		sandboxViolationsEnv:          "",
+		"TEST_SRCDIR":                 srcdir,
+		"TEST_TMPDIR":                 tmp,
+		"TEST_UNDECLARED_OUTPUTS_DIR": "",
+		"TMPDIR":                      tmp,
+	})
+
+	s, err := startSandboxChecks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := s.check(&out); err != nil {
+		t.Fatalf("got %v before the test did anything; want no error\n%s", err, &out)
+	}
+
+	s, err = startSandboxChecks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reportSandboxViolation("blocked DNS lookup through 8.8.8.8:53")
+	if err := ioutil.WriteFile(filepath.Join(srcdir, "golden.txt"), nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := s.check(&out); err != errSandboxViolation {
+		t.Errorf("got %v; want %v", err, errSandboxViolation)
+	}
+	for _, want := range []string{"blocked DNS lookup through 8.8.8.8:53", "modified " + filepath.Join(srcdir, "golden.txt")} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, &out)
+		}
+	}
+
+	setEnv(t, map[string]string{sandboxChecksEnv: ""})
+	if s, err := startSandboxChecks(); s != nil || err != nil {
+		t.Errorf("got %v, %v without checks; want nil", s, err)
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	for address, want := range map[string]bool{
+		"localhost:80":      true,
+		"127.0.0.1:8080":    true,
+		"[::1]:443":         true,
+		"example.com:443":   false,
+		"93.184.216.34:80":  false,
+		"[2001:db8::1]:443": false,
+	} {
+		if got := isLoopback(address); got != want {
+			t.Errorf("isLoopback(%q) = %v; want %v", address, got, want)
+		}
+	}
+}
+
+func TestBlockNetwork(t *testing.T) {
+	transport := http.DefaultTransport.(*http.Transport)
+	oldResolver, oldDial := net.DefaultResolver, transport.DialContext
+	defer func() {
+		net.DefaultResolver, transport.DialContext = oldResolver, oldDial
+	}()
+	setEnv(t, map[string]string{sandboxViolationsEnv: ""})
+	blockNetwork()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request to a loopback server failed: %v", err)
+	}
+	resp.Body.Close()
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	if _, err := client.Get("http://93.184.216.34/"); err == nil || !strings.Contains(err.Error(), "blocked tcp connection to 93.184.216.34:80") {
+		t.Errorf("got %v for a request to a remote address; want it blocked", err)
+	}
+	if _, err := net.LookupHost("example.com"); err == nil || !strings.Contains(err.Error(), "blocked DNS lookup") {
+		t.Errorf("got %v for a DNS lookup; want it blocked", err)
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// goroutineDumpFile is the name of the file in TEST_UNDECLARED_OUTPUTS_DIR
+	// where goroutine stacks are written when a test times out.
+	goroutineDumpFile = "goroutines.txt"
+
+	// maxGoroutineDumpSize is how much of the end of a test's error output is
+	// kept to find goroutine stacks in.
+	maxGoroutineDumpSize = 16 << 20
+)
+
+// terminated is set when the wrapper receives SIGTERM. Bazel kills the test
+// soon after, so failed tests aren't rerun.
+var terminated bool
+
+// Lines the Go runtime and the testing package print before the stacks of all
+// goroutines when a test binary receives SIGQUIT or -test.timeout expires.
+var goroutineDumpMarkers = [][]byte{
+	[]byte("SIGQUIT: quit"),
+	[]byte("panic: test timed out after"),
+}
+
+// testTimeout returns the value of -test.timeout for the test binary, as set
+// by the test_timeout attribute of go_test, or "" if the flag shouldn't be
+// passed. "auto" derives the timeout from the one Bazel enforces, so that the
+// testing package reports the timeout with goroutine stacks before Bazel kills
+// the test.
+func testTimeout() string {
+	timeoutEnv := os.Getenv("GO_TEST_TIMEOUT")
+	switch timeoutEnv {
+	case "":
+		return ""
+	case "auto":
+		secs, err := strconv.Atoi(os.Getenv("TEST_TIMEOUT"))
+		if err != nil || secs <= 0 {
+			return ""
+		}
+		return deriveTestTimeout(time.Duration(secs) * time.Second).String()
+	default:
+		if d, err := time.ParseDuration(timeoutEnv); err != nil || d <= 0 {
+			log.Fatalf("invalid value for GO_TEST_TIMEOUT: %q", timeoutEnv)
+		}
+		return timeoutEnv
+	}
+}
+
+// deriveTestTimeout returns a timeout for the test binary that leaves time to
+// print goroutine stacks and write test reports before bazelTimeout expires:
+// 5% of it, but at least 5 seconds, and at most half of it.
+func deriveTestTimeout(bazelTimeout time.Duration) time.Duration {
+	margin := bazelTimeout / 20
+	if margin < 5*time.Second {
+		margin = 5 * time.Second
+	}
+	if margin > bazelTimeout/2 {
+		margin = bazelTimeout / 2
+	}
+	return bazelTimeout - margin
+}
+
+// hasTestFlag returns whether args sets the test flag with the given name,
+// like "test.timeout", with one or two dashes.
+func hasTestFlag(args []string, name string) bool {
+	for _, arg := range args {
+		flagName := strings.TrimLeft(arg, "-")
+		if n := len(arg) - len(flagName); n == 0 || n > 2 {
+			continue
+		}
+		if flagName == name || strings.HasPrefix(flagName, name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// catchTermSignal makes a test binary run by the wrapper survive SIGTERM.
+// Bazel sends SIGTERM to the wrapper and the test binary when a test times
+// out; the wrapper handles it by sending SIGQUIT to the test binary, which
+// makes the runtime print the stacks of all goroutines before exiting.
+//
+// The signal is caught and dropped rather than ignored. An ignored signal
+// stays ignored in processes the test starts, which could then no longer be
+// stopped with SIGTERM; a caught one is reset to the default on exec.
+func catchTermSignal() {
+	if os.Getenv("GO_TEST_CATCH_SIGTERM") == "1" {
+		signal.Notify(make(chan os.Signal, 1), syscall.SIGTERM)
+	}
+}
+
+// tailBuffer is a writer that keeps about the last max bytes written to it.
+type tailBuffer struct {
+	buf []byte
+	max int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > 2*b.max {
+		b.buf = append(b.buf[:0], b.buf[len(b.buf)-b.max:]...)
+	}
+	return len(p), nil
+}
+
+// goroutineDump returns the goroutine stacks at the end of a test binary's
+// error output, or nil if there are none.
+func goroutineDump(stderr []byte) []byte {
+	start := -1
+	for _, marker := range goroutineDumpMarkers {
+		if i := bytes.LastIndex(stderr, marker); i > start {
+			start = i
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+	return stderr[start:]
+}
+
+// saveGoroutineDump writes the goroutine stacks at the end of a test binary's
+// error output to goroutineDumpFile in TEST_UNDECLARED_OUTPUTS_DIR, so that
+// they can be found without reading the whole test log.
+func saveGoroutineDump(stderr []byte) {
+	dir, ok := os.LookupEnv("TEST_UNDECLARED_OUTPUTS_DIR")
+	if !ok {
+		return
+	}
+	stacks := goroutineDump(stderr)
+	if stacks == nil {
+		return
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, goroutineDumpFile), stacks, 0666); err != nil {
+		fmt.Fprintf(os.Stderr, "testwrapper: error writing goroutine stacks: %v\n", err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "testwrapper: the test timed out; goroutine stacks were written to %s in the test's undeclared outputs\n", goroutineDumpFile)
+}
@@ -0,0 +1,32 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build plan9 || windows
+// +build plan9 windows
+
+package main
+
+import "os/exec"
+
+// canQuitOnTerm returns whether the wrapper forwards SIGTERM to the test
+// binary as SIGQUIT. Windows can't send SIGQUIT, and Plan 9 has no such
+// signal.
+func canQuitOnTerm() bool {
+	return false
+}
+
+// runQuittingOnTerm runs cmd. SIGTERM isn't forwarded on this platform.
+func runQuittingOnTerm(cmd *exec.Cmd) error {
+	return cmd.Run()
+}
@@ -0,0 +1,56 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !plan9 && !windows
+// +build !plan9,!windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+)
+
+// canQuitOnTerm returns whether the wrapper forwards SIGTERM to the test
+// binary as SIGQUIT.
+func canQuitOnTerm() bool {
+	return true
+}
+
+// runQuittingOnTerm runs cmd. If the wrapper receives SIGTERM while cmd is
+// running, cmd is sent SIGQUIT, and cmd's result is returned once it exits.
+func runQuittingOnTerm(cmd *exec.Cmd) error {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-sigs:
+		terminated = true
+		fmt.Fprintln(os.Stderr, "testwrapper: received SIGTERM; sending SIGQUIT to the test to print goroutine stacks")
+		if err := cmd.Process.Signal(syscall.SIGQUIT); err != nil {
+			cmd.Process.Kill()
+		}
+		return <-done
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeriveTestTimeout(t *testing.T) {
+	for _, test := range []struct {
+		bazel, want time.Duration
+	}{
+		{60 * time.Second, 55 * time.Second},
+		{300 * time.Second, 285 * time.Second},
+		{3600 * time.Second, 3420 * time.Second},
+		{6 * time.Second, 3 * time.Second},
+	} {
+		if got := deriveTestTimeout(test.bazel); got != test.want {
+			t.Errorf("deriveTestTimeout(%v) = %v; want %v", test.bazel, got, test.want)
+		}
+	}
+}
+
+func TestHasTestFlag(t *testing.T) {
+	for _, test := range []struct {
+		args []string
+		want bool
+	}{
+		{nil, false},
+		{[]string{"-test.v"}, false},
+		{[]string{"-test.timeout=1m"}, true},
+		{[]string{"--test.timeout", "1m"}, true},
+		{[]string{"-test.timeoutx=1"}, false},
+		{[]string{"---test.timeout=1m"}, false},
+	} {
+		if got := hasTestFlag(test.args, "test.timeout"); got != test.want {
+			t.Errorf("hasTestFlag(%q) = %v; want %v", test.args, got, test.want)
+		}
+	}
+}
+
+func TestGoroutineDump(t *testing.T) {
+	const stacks = `panic: test timed out after 2s
+
+goroutine 7 [running]:
+testing.(*M).startAlarm.func1()
+`
+	if got := string(goroutineDump([]byte("=== RUN   TestHang\n" + stacks))); got != stacks {
+		t.Errorf("got %q; want %q", got, stacks)
+	}
+	if got := goroutineDump([]byte("--- FAIL: TestX\nFAIL\n")); got != nil {
+		t.Errorf("got %q for output without stacks; want nil", got)
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	b := &tailBuffer{max: 4}
+	for _, s := range []string{"abc", "def", "ghi"} {
+		b.Write([]byte(s))
+	}
+	if got := string(b.buf); !strings.HasSuffix(got, "ghi") || len(got) > 8 {
+		t.Errorf("got %q; want at most 8 bytes ending with ghi", got)
+	}
+}
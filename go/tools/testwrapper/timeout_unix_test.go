@@ -0,0 +1,81 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunQuittingOnTerm(t *testing.T) {
+	if !canQuitOnTerm() {
+		t.Skip("SIGQUIT is not supported")
+	}
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep not found")
+	}
+	defer func() { terminated = false }()
+	cmd := exec.Command(sleep, "60")
+	go func() {
+		for cmd.Process == nil {
+			time.Sleep(10 * time.Millisecond)
+		}
+		syscall.Kill(os.Getpid(), syscall.SIGTERM)
+	}()
+	start := time.Now()
+	err = runQuittingOnTerm(cmd)
+	if err == nil {
+		t.Fatal("command succeeded; want it to be stopped")
+	}
+	if !terminated {
+		t.Error("terminated was not set")
+	}
+	if d := time.Since(start); d > 30*time.Second {
+		t.Errorf("command ran for %v after SIGTERM", d)
+	}
+}
+
+func TestCatchTermSignalNotInherited(t *testing.T) {
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep not found")
+	}
+	os.Setenv("GO_TEST_CATCH_SIGTERM", "1")
+	defer os.Unsetenv("GO_TEST_CATCH_SIGTERM")
+	catchTermSignal()
+	defer signal.Reset(syscall.SIGTERM)
+
+	cmd := exec.Command(sleep, "60")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Wait(); err == nil {
+		t.Fatal("command succeeded; want it to be stopped by SIGTERM")
+	}
+	if d := time.Since(start); d > 30*time.Second {
+		t.Errorf("command ran for %v after SIGTERM", d)
+	}
+}
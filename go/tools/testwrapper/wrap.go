@@ -16,13 +16,19 @@ package main
 
 import (
 	"bytes"
+	"encoding/xml"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 // testWrapperAbnormalExit is used by the testwrapper to indicate the child
@@ -31,6 +37,11 @@ import (
 const testWrapperAbnormalExit = 6
 
 func shouldWrap() bool {
+	if runtime.GOOS == "js" || runtime.GOOS == "wasip1" {
+		// WebAssembly programs can't start processes, so the test can't be
+		// run as a child of the wrapper.
+		return false
+	}
 	if wrapEnv, ok := os.LookupEnv("GO_TEST_WRAP"); ok {
 		wrap, err := strconv.ParseBool(wrapEnv)
 		if err != nil {
@@ -56,22 +67,99 @@ func shouldAddTestV() bool {
 	return false
 }
 
-func wrap(pkg string) error {
-	var jsonBuffer bytes.Buffer
-	jsonConverter := NewConverter(&jsonBuffer, pkg, Timestamp)
+// shouldWriteJSON indicates if the test wrapper should write the test's
+// events, in the format of "go test -json", to test_events.json in
+// TEST_UNDECLARED_OUTPUTS_DIR. This implies -test.v, since events for
+// passing tests are only reported in verbose mode.
+func shouldWriteJSON() bool {
+	if jsonEnv, ok := os.LookupEnv("GO_TEST_JSON"); ok {
+		json, err := strconv.ParseBool(jsonEnv)
+		if err != nil {
+			log.Fatalf("invalid value for GO_TEST_JSON: %q", jsonEnv)
+		}
+		return json
+	}
+	return false
+}
 
+// testReruns returns the number of times failed tests should be rerun, as
+// set by the reruns attribute of go_test or --define=gotest_reruns.
+func testReruns() int {
+	rerunsEnv, ok := os.LookupEnv("GO_TEST_RERUNS")
+	if !ok {
+		return 0
+	}
+	reruns, err := strconv.Atoi(rerunsEnv)
+	if err != nil || reruns < 0 {
+		log.Fatalf("invalid value for GO_TEST_RERUNS: %q", rerunsEnv)
+	}
+	return reruns
+}
+
+func wrap(pkg string) error {
+	coverDir, err := setUpCoverDir()
+	if err != nil {
+		return err
+	}
+	sandbox, err := startSandboxChecks()
+	if err != nil {
+		return err
+	}
 	args := os.Args[1:]
-	if shouldAddTestV() {
+	writeJSON := shouldWriteJSON()
+	if shouldAddTestV() || writeJSON {
 		args = append([]string{"-test.v"}, args...)
 	}
-	cmd := exec.Command(os.Args[0], args...)
-	cmd.Env = append(os.Environ(), "GO_TEST_WRAP=0")
-	cmd.Stderr = os.Stderr
-	cmd.Stdout = io.MultiWriter(os.Stdout, jsonConverter)
-	err := cmd.Run()
-	jsonConverter.Close()
+	stress := raceStress()
+	var cpus []int
+	var stderr io.Writer
+	raceOutput := &headBuffer{max: maxRaceOutputSize}
+	if stress > 0 {
+		cpus = raceStressCPUs(runtime.NumCPU())
+		args = append(raceStressArgs(stress, cpus), args...)
+		stderr = raceOutput
+	}
+	if timeout := testTimeout(); timeout != "" && !hasTestFlag(args, "test.timeout") {
+		args = append([]string{"-test.timeout=" + timeout}, args...)
+	}
+	// Output is only kept for benchmarks, so that ordinary tests with a lot
+	// of output don't hold all of it in memory.
+	benchmarks := benchmarkBaseline != "" || hasTestFlag(args, "test.bench")
+	var stdout io.Writer
+	var benchOutput bytes.Buffer
+	if benchmarks {
+		stdout = &benchOutput
+	}
+	jsonBuffer, err := runTest(pkg, args, nil, stdout, stderr)
+	if err == nil && benchmarks {
+		err = checkBenchmarks(benchOutput.Bytes(), os.Stdout)
+	}
+	if stress > 0 {
+		printRaceSummary(os.Stderr, string(raceOutput.buf), raceOutput.truncated, stress, cpus)
+	}
+	events := bytes.NewBuffer(jsonBuffer)
+	pkgDuration, testcases, perr := parseTestEvents(bytes.NewReader(jsonBuffer))
+	if perr == nil && err != nil && stress == 0 && !terminated {
+		// Failures found by stressing a test aren't flaky in the sense that
+		// reruns are meant to paper over, so they aren't rerun.
+		err = rerunFailedTests(pkg, args, testReruns(), testcases, events, err)
+	}
+	if serr := sandbox.check(os.Stderr); serr != nil && err == nil {
+		err = serr
+	}
+	if cerr := writeCoverageProfile(coverDir); cerr != nil {
+		if err != nil {
+			return fmt.Errorf("%s, (error wrapping test execution: %s)", cerr, err)
+		}
+		return cerr
+	}
 	if out, ok := os.LookupEnv("XML_OUTPUT_FILE"); ok {
-		werr := writeReport(jsonBuffer, pkg, out)
+		werr := perr
+		if werr == nil {
+			werr = writeReport(toXML(pkg, pkgDuration, testcases), out)
+		} else {
+			werr = fmt.Errorf("error converting test output to xml: %s", werr)
+		}
 		if werr != nil {
 			if err != nil {
 				return fmt.Errorf("error while generating testreport: %s, (error wrapping test execution: %s)", werr, err)
@@ -79,15 +167,158 @@ func wrap(pkg string) error {
 			return fmt.Errorf("error while generating testreport: %s", werr)
 		}
 	}
+	if dir, ok := os.LookupEnv("TEST_UNDECLARED_OUTPUTS_DIR"); ok && writeJSON {
+		if werr := ioutil.WriteFile(filepath.Join(dir, "test_events.json"), events.Bytes(), 0666); werr != nil {
+			if err != nil {
+				return fmt.Errorf("error writing test events: %s, (error wrapping test execution: %s)", werr, err)
+			}
+			return fmt.Errorf("error writing test events: %s", werr)
+		}
+	}
 	return err
 }
 
-func writeReport(jsonBuffer bytes.Buffer, pkg string, path string) error {
-	xml, cerr := json2xml(&jsonBuffer, pkg)
-	if cerr != nil {
-		return fmt.Errorf("error converting test output to xml: %s", cerr)
+// runTest runs the test binary with args and returns its output converted to
+// JSON by test2json. The output is also copied to os.Stdout and stdout, if
+// stdout is not nil, and errors are copied to os.Stderr and stderr, if stderr
+// is not nil. env is added to the environment of the test. If the test fails
+// after printing goroutine stacks, because it timed out, the stacks are saved
+// with the test's undeclared outputs.
+func runTest(pkg string, args, env []string, stdout, stderr io.Writer) ([]byte, error) {
+	var jsonBuffer bytes.Buffer
+	jsonConverter := NewConverter(&jsonBuffer, pkg, Timestamp)
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "GO_TEST_WRAP=0")
+	if canQuitOnTerm() {
+		cmd.Env = append(cmd.Env, "GO_TEST_CATCH_SIGTERM=1")
+	}
+	cmd.Env = append(cmd.Env, env...)
+	stderrTail := &tailBuffer{max: maxGoroutineDumpSize}
+	if stderr != nil {
+		cmd.Stderr = io.MultiWriter(os.Stderr, stderrTail, stderr)
+	} else {
+		cmd.Stderr = io.MultiWriter(os.Stderr, stderrTail)
+	}
+	if stdout != nil {
+		cmd.Stdout = io.MultiWriter(os.Stdout, jsonConverter, stdout)
+	} else {
+		cmd.Stdout = io.MultiWriter(os.Stdout, jsonConverter)
+	}
+	err := runQuittingOnTerm(cmd)
+	jsonConverter.Close()
+	if err != nil {
+		saveGoroutineDump(stderrTail.buf)
+	}
+	return jsonBuffer.Bytes(), err
+}
+
+// rerunFailedTests reruns the top-level tests that failed in testcases, up to
+// reruns times, until they pass. Results are merged into testcases, and the
+// events of each rerun are appended to events. If every
+// failed test passes on a rerun, the failures are reported as flaky and nil
+// is returned; otherwise, err is returned.
+//
+// Only failures attributed to a test are retried. If the test binary failed
+// for any other reason (for example, TestMain returned a non-zero code
+// without any test failing), err is returned as is.
+func rerunFailedTests(pkg string, args []string, reruns int, testcases map[string]*testCase, events io.Writer, err error) error {
+	if reruns == 0 {
+		return err
+	}
+	if _, ok := err.(*exec.ExitError); !ok {
+		return err
+	}
+	failed := failedTests(testcases)
+	if len(failed) == 0 {
+		return err
+	}
+	args = stripRunFlags(args)
+	// Coverage from the first run is kept. Reruns would overwrite it with the
+	// coverage of the rerun tests only.
+	env := []string{"COVERAGE_OUTPUT_FILE="}
+	for attempt := 1; attempt <= reruns && len(failed) > 0; attempt++ {
+		fmt.Fprintf(os.Stderr, "testwrapper: rerunning failed tests (attempt %d of %d): %s\n", attempt, reruns, strings.Join(failed, " "))
+		rerunArgs := append([]string{"-test.run=" + runFilter(failed)}, args...)
+		jsonBuffer, rerr := runTest(pkg, rerunArgs, env, nil, nil)
+		events.Write(jsonBuffer)
+		_, rerun, perr := parseTestEvents(bytes.NewReader(jsonBuffer))
+		if perr != nil {
+			return perr
+		}
+		mergeRerun(testcases, rerun)
+		failed = failedTests(testcases)
+		if rerr != nil && len(failed) == 0 {
+			// The tests passed, but the binary failed anyway.
+			return rerr
+		}
+	}
+	if len(failed) > 0 {
+		return err
+	}
+	var flaky []string
+	for name, c := range testcases {
+		if !strings.Contains(name, "/") && c.state == "pass" && len(c.failedAttempts) > 0 {
+			flaky = append(flaky, name)
+		}
+	}
+	sort.Strings(flaky)
+	fmt.Fprintf(os.Stderr, "testwrapper: flaky tests passed on a rerun: %s\n", strings.Join(flaky, " "))
+	return nil
+}
+
+// failedTests returns the sorted names of top-level tests that failed.
+// Subtests can't be rerun on their own reliably, since their names may not be
+// unique or stable, so their parents are rerun instead.
+func failedTests(testcases map[string]*testCase) []string {
+	var failed []string
+	for name, c := range testcases {
+		if c.state == "fail" && !strings.Contains(name, "/") {
+			failed = append(failed, name)
+		}
+	}
+	sort.Strings(failed)
+	return failed
+}
+
+// runFilter returns a -test.run pattern matching exactly the named top-level
+// tests.
+func runFilter(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = regexp.QuoteMeta(name)
+	}
+	return "^(" + strings.Join(quoted, "|") + ")$"
+}
+
+// stripRunFlags removes -test.run flags from args, since reruns select tests
+// with their own filter.
+func stripRunFlags(args []string) []string {
+	var stripped []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name := strings.TrimLeft(arg, "-")
+		if len(arg)-len(name) == 0 || len(arg)-len(name) > 2 {
+			stripped = append(stripped, arg)
+			continue
+		}
+		if name == "test.run" {
+			i++ // The value is in the next argument.
+			continue
+		}
+		if strings.HasPrefix(name, "test.run=") {
+			continue
+		}
+		stripped = append(stripped, arg)
+	}
+	return stripped
+}
+
+func writeReport(suites *xmlTestSuites, path string) error {
+	data, err := xml.MarshalIndent(suites, "", "\t")
+	if err != nil {
+		return fmt.Errorf("error converting test output to xml: %s", err)
 	}
-	if err := ioutil.WriteFile(path, xml, 0664); err != nil {
+	if err := ioutil.WriteFile(path, data, 0664); err != nil {
 		return fmt.Errorf("error writing test xml: %s", err)
 	}
 	return nil
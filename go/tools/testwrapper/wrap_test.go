@@ -61,3 +61,34 @@ func TestShouldWrap(t *testing.T) {
 		})
 	}
 }
+
+func TestStripRunFlags(t *testing.T) {
+	for _, tt := range []struct {
+		args, want []string
+	}{
+		{
+			args: []string{"-test.v", "-test.run=TestA", "arg"},
+			want: []string{"-test.v", "arg"},
+		}, {
+			args: []string{"--test.run", "TestA", "-test.count=2"},
+			want: []string{"-test.count=2"},
+		}, {
+			args: []string{"-test.runner", "---test.run=TestA"},
+			want: []string{"-test.runner", "---test.run=TestA"},
+		},
+	} {
+		t.Run(fmt.Sprintf("%v", tt.args), func(t *testing.T) {
+			got := stripRunFlags(tt.args)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunFilter(t *testing.T) {
+	got := runFilter([]string{"TestA", "TestB.x"})
+	if want := `^(TestA|TestB\.x)$`; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
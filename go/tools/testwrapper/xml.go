@@ -49,6 +49,12 @@ type xmlTestCase struct {
 	Failure   *xmlMessage `xml:"failure,omitempty"`
 	Error     *xmlMessage `xml:"error,omitempty"`
 	Skipped   *xmlMessage `xml:"skipped,omitempty"`
+
+	// FlakyFailures and RerunFailures report earlier failed attempts of a test
+	// that was rerun. They use the element names of the Maven Surefire
+	// extension to the JUnit format, which many report viewers understand.
+	FlakyFailures []xmlMessage `xml:"flakyFailure,omitempty"`
+	RerunFailures []xmlMessage `xml:"rerunFailure,omitempty"`
 }
 
 type xmlMessage struct {
@@ -71,11 +77,25 @@ type testCase struct {
 	state    string
 	output   strings.Builder
 	duration *float64
+
+	// failedAttempts holds the output of earlier runs of the test that failed
+	// before it was rerun.
+	failedAttempts []string
 }
 
 // json2xml converts test2json's output into an xml output readable by Bazel.
 // http://windyroad.com.au/dl/Open%20Source/JUnit.xsd
 func json2xml(r io.Reader, pkgName string) ([]byte, error) {
+	pkgDuration, testcases, err := parseTestEvents(r)
+	if err != nil {
+		return nil, err
+	}
+	return xml.MarshalIndent(toXML(pkgName, pkgDuration, testcases), "", "\t")
+}
+
+// parseTestEvents reads test2json's output and returns the duration of the
+// package and the test cases it ran, by name.
+func parseTestEvents(r io.Reader) (*float64, map[string]*testCase, error) {
 	var pkgDuration *float64
 	testcases := make(map[string]*testCase)
 	testCaseByName := func(name string) *testCase {
@@ -94,11 +114,13 @@ func json2xml(r io.Reader, pkgName string) ([]byte, error) {
 		if err := dec.Decode(&e); err == io.EOF {
 			break
 		} else if err != nil {
-			return nil, fmt.Errorf("error decoding test2json output: %s", err)
+			return nil, nil, fmt.Errorf("error decoding test2json output: %s", err)
 		}
 		switch s := e.Action; s {
 		case "run":
-			if c := testCaseByName(e.Test); c != nil {
+			// A test run more than once, with -test.count or -test.cpu, fails
+			// if any of its runs fails.
+			if c := testCaseByName(e.Test); c != nil && c.state != "fail" {
 				c.state = s
 			}
 		case "output":
@@ -120,15 +142,42 @@ func json2xml(r io.Reader, pkgName string) ([]byte, error) {
 			}
 		case "pass":
 			if c := testCaseByName(e.Test); c != nil {
-				c.duration = e.Elapsed
-				c.state = s
+				if c.state != "fail" {
+					c.duration = e.Elapsed
+					c.state = s
+				}
 			} else {
 				pkgDuration = e.Elapsed
 			}
 		}
 	}
+	return pkgDuration, testcases, nil
+}
 
-	return xml.MarshalIndent(toXML(pkgName, pkgDuration, testcases), "", "\t")
+// mergeRerun updates testcases with the results of rerunning some of them.
+// Each rerun top-level test replaces the earlier results of the test and all
+// of its subtests, so subtests that failed before don't outlive a passing
+// rerun. The output of a test's failed attempts is kept, so it can be
+// reported along with the final result.
+func mergeRerun(testcases, rerun map[string]*testCase) {
+	prev := make(map[string]*testCase)
+	for name := range rerun {
+		if strings.Contains(name, "/") {
+			continue
+		}
+		for n, c := range testcases {
+			if n == name || strings.HasPrefix(n, name+"/") {
+				prev[n] = c
+				delete(testcases, n)
+			}
+		}
+	}
+	for name, c := range rerun {
+		if p, ok := prev[name]; ok && p.state == "fail" {
+			c.failedAttempts = append(p.failedAttempts, p.output.String())
+		}
+		testcases[name] = c
+	}
 }
 
 func toXML(pkgName string, pkgDuration *float64, testcases map[string]*testCase) *xmlTestSuites {
@@ -166,8 +215,19 @@ func toXML(pkgName string, pkgDuration *float64, testcases map[string]*testCase)
 				Message:  "Failed",
 				Contents: c.output.String(),
 			}
+			for _, output := range c.failedAttempts {
+				newCase.RerunFailures = append(newCase.RerunFailures, xmlMessage{
+					Message:  "Failed",
+					Contents: output,
+				})
+			}
 		case "pass":
-			break
+			for _, output := range c.failedAttempts {
+				newCase.FlakyFailures = append(newCase.FlakyFailures, xmlMessage{
+					Message:  "Flaky",
+					Contents: output,
+				})
+			}
 		default:
 			suite.Errors++
 			newCase.Error = &xmlMessage{
@@ -53,3 +53,70 @@ func TestJSON2XML(t *testing.T) {
 		})
 	}
 }
+
+func TestMergeRerun(t *testing.T) {
+	const first = `{"Action":"run","Test":"TestFlaky"}
+{"Action":"output","Test":"TestFlaky","Output":"flaky failure\n"}
+{"Action":"fail","Test":"TestFlaky"}
+{"Action":"run","Test":"TestBroken"}
+{"Action":"output","Test":"TestBroken","Output":"broken 1\n"}
+{"Action":"fail","Test":"TestBroken"}
+{"Action":"run","Test":"TestOK"}
+{"Action":"pass","Test":"TestOK"}
+{"Action":"run","Test":"TestSub"}
+{"Action":"run","Test":"TestSub/a"}
+{"Action":"output","Test":"TestSub/a","Output":"sub failure\n"}
+{"Action":"fail","Test":"TestSub/a"}
+{"Action":"run","Test":"TestSub/b"}
+{"Action":"fail","Test":"TestSub/b"}
+{"Action":"fail","Test":"TestSub"}
+{"Action":"fail"}
+`
+	const rerun = `{"Action":"run","Test":"TestFlaky"}
+{"Action":"pass","Test":"TestFlaky"}
+{"Action":"run","Test":"TestSub"}
+{"Action":"run","Test":"TestSub/a"}
+{"Action":"pass","Test":"TestSub/a"}
+{"Action":"pass","Test":"TestSub"}
+{"Action":"run","Test":"TestBroken"}
+{"Action":"output","Test":"TestBroken","Output":"broken 2\n"}
+{"Action":"fail","Test":"TestBroken"}
+{"Action":"fail"}
+`
+	_, testcases, err := parseTestEvents(strings.NewReader(first))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, rerunCases, err := parseTestEvents(strings.NewReader(rerun))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mergeRerun(testcases, rerunCases)
+	if got := failedTests(testcases); len(got) != 1 || got[0] != "TestBroken" {
+		t.Errorf("got failed tests %q; want [TestBroken]", got)
+	}
+
+	suites := toXML("pkg/testing", nil, testcases)
+	got := make(map[string]xmlTestCase)
+	for _, c := range suites.Suites[0].TestCases {
+		got[c.Name] = c
+	}
+	if c := got["TestFlaky"]; c.Failure != nil || len(c.FlakyFailures) != 1 || c.FlakyFailures[0].Contents != "flaky failure\n" {
+		t.Errorf("TestFlaky: got %+v; want a single flaky failure", c)
+	}
+	if c := got["TestBroken"]; c.Failure == nil || c.Failure.Contents != "broken 2\n" || len(c.RerunFailures) != 1 || c.RerunFailures[0].Contents != "broken 1\n" {
+		t.Errorf("TestBroken: got %+v; want a failure and a rerun failure", c)
+	}
+	if c := got["TestOK"]; c.Failure != nil || len(c.FlakyFailures) != 0 {
+		t.Errorf("TestOK: got %+v; want a pass", c)
+	}
+	if c := got["TestSub/a"]; c.Failure != nil || len(c.FlakyFailures) != 1 || c.FlakyFailures[0].Contents != "sub failure\n" {
+		t.Errorf("TestSub/a: got %+v; want a single flaky failure", c)
+	}
+	if c, ok := got["TestSub/b"]; ok {
+		t.Errorf("TestSub/b: got %+v; want it replaced by the rerun of TestSub", c)
+	}
+	if n := suites.Suites[0].Failures; n != 1 {
+		t.Errorf("got %d failures; want 1", n)
+	}
+}
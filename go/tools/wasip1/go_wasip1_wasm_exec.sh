@@ -0,0 +1,75 @@
+#!/bin/sh
+# Copyright 2020 The Bazel Authors. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#    http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# go_wasip1_wasm_exec runs a WebAssembly module built for GOOS=wasip1 with its
+# arguments, like misc/wasm/go_wasip1_wasm_exec in the Go distribution.
+#
+# The runtime is chosen with GOWASIRUNTIME: wasmtime (the default), wazero,
+# wasmedge, or wasmer. It's found in PATH, or GOWASIRUNTIMEPATH may name the
+# runtime's executable. GOWASIRUNTIMEARGS adds flags for the runtime.
+#
+# The host file system is mounted at /, so paths in the runfiles tree work,
+# and the environment is passed to the module, since runtimes don't pass it by
+# default. The Go runtime for wasip1 sets its working directory from PWD.
+
+set -eu
+
+if [ $# -lt 1 ]; then
+  echo "usage: $0 module.wasm [args...]" >&2
+  exit 2
+fi
+module="$1"
+shift
+
+runtime="${GOWASIRUNTIME:-wasmtime}"
+exe="${GOWASIRUNTIMEPATH:-$runtime}"
+
+# The module's arguments are moved after the runtime's flags and the module
+# below, since "set --" is the only way to build a list of arguments.
+nargs=$#
+case "$runtime" in
+  wazero)
+    set -- "$@" run -mount /:/ -env-inherit -cachedir "${TMPDIR:-/tmp}/wazero"
+    ;;
+  wasmtime | wasmedge | wasmer)
+    if [ "$runtime" = wasmtime ]; then
+      set -- "$@" run -W max-wasm-stack=1048576
+    elif [ "$runtime" = wasmer ]; then
+      set -- "$@" run
+    fi
+    set -- "$@" --dir=/
+    for name in $(env | sed -n 's/^\([A-Za-z_][A-Za-z0-9_]*\)=.*/\1/p'); do
+      eval "value=\${$name}"
+      set -- "$@" --env "$name=$value"
+    done
+    ;;
+  *)
+    echo "$0: unknown GOWASIRUNTIME $runtime; want wasmtime, wazero, wasmedge, or wasmer" >&2
+    exit 2
+    ;;
+esac
+# shellcheck disable=SC2086
+set -- "$@" ${GOWASIRUNTIMEARGS:-} "$module"
+if [ "$runtime" = wasmer ]; then
+  set -- "$@" --
+fi
+
+i=0
+while [ "$i" -lt "$nargs" ]; do
+  set -- "$@" "$1"
+  shift
+  i=$((i + 1))
+done
+exec "$exe" "$@"
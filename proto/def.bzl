@@ -26,6 +26,10 @@ load(
     "@io_bazel_rules_go//go/private:rules/rule.bzl",
     "go_rule",
 )
+load(
+    "@io_bazel_rules_go//go/private:common.bzl",
+    "nogo_validation_outputs",
+)
 load(
     "@io_bazel_rules_go//go/private:providers.bzl",
     "INFERRED_PATH",
@@ -126,10 +130,12 @@ def _go_proto_library_impl(ctx):
     if valid_archive:
         archive = go.archive(go, source)
         output_groups["compilation_outputs"] = [archive.data.file]
+        if archive.data.nogo_validation:
+            output_groups["_validation"] = [archive.data.nogo_validation]
         providers.extend([
             archive,
             DefaultInfo(
-                files = depset([archive.data.file]),
+                files = depset([archive.data.file] + nogo_validation_outputs(go, archive)),
                 runfiles = archive.runfiles,
             ),
         ])
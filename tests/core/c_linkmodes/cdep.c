@@ -0,0 +1,5 @@
+#include "tests/core/c_linkmodes/cdep.h"
+
+int cdep_value(void) {
+    return 42;
+}
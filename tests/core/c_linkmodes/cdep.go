@@ -0,0 +1,11 @@
+package main
+
+// #include "tests/core/c_linkmodes/cdep.h"
+import "C"
+
+//export GoCdepValue
+func GoCdepValue() int {
+	return int(C.cdep_value())
+}
+
+func main() {}
@@ -0,0 +1 @@
+int cdep_value(void);
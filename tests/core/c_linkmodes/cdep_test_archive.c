@@ -0,0 +1,7 @@
+#include <assert.h>
+#include "tests/core/c_linkmodes/cdep_archive.h"
+
+int main(int argc, char** argv) {
+    assert(GoCdepValue() == 42);
+    return 0;
+}
@@ -0,0 +1,201 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gocoverdir_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_binary", "go_library", "go_test")
+
+go_library(
+    name = "a",
+    srcs = ["a.go"],
+    importpath = "example.com/gocoverdir/a",
+)
+
+go_binary(
+    name = "server",
+    srcs = ["server.go"],
+    deps = [":a"],
+)
+
+go_test(
+    name = "a_test",
+    srcs = ["a_test.go"],
+    data = [":server"],
+    env = {"SERVER": "$(rootpath :server)"},
+    deps = [":a"],
+)
+
+-- a.go --
+package a
+
+func FromTest() int {
+	return 1
+}
+
+func FromServer() int {
+	return 2
+}
+
+func Dead() int {
+	return 3
+}
+
+-- server.go --
+package main
+
+import (
+	"fmt"
+
+	"example.com/gocoverdir/a"
+)
+
+func main() {
+	fmt.Println(a.FromServer())
+}
+
+-- a_test.go --
+package a_test
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+
+	"example.com/gocoverdir/a"
+)
+
+func TestA(t *testing.T) {
+	if a.FromTest() != 1 {
+		t.Error("FromTest() != 1")
+	}
+	out, err := exec.Command(os.Getenv("SERVER")).CombinedOutput()
+	if err != nil {
+		t.Fatalf("server failed: %v\n%s", err, out)
+	}
+	if string(out) != "2\n" {
+		t.Errorf("got server output %q; want %q", out, "2\n")
+	}
+}
+`,
+	})
+}
+
+// Binary coverage needs go1.20 or later, which is newer than the SDK the rest
+// of the tests use.
+const go120 = `
+load("@io_bazel_rules_go//go:deps.bzl", "go_download_sdk")
+
+go_download_sdk(
+    name = "go_sdk",
+    # Checksums are omitted, so Bazel only warns about them.
+    sdks = {
+        "darwin_amd64": ("go1.20.darwin-amd64.tar.gz", ""),
+        "linux_amd64": ("go1.20.linux-amd64.tar.gz", ""),
+    },
+)
+
+go_rules_dependencies()
+
+go_register_toolchains()
+`
+
+const coverFormat = "--@io_bazel_rules_go//go/config:cover_format=gocoverdir"
+
+func Test(t *testing.T) {
+	if runtime.GOARCH != "amd64" || (runtime.GOOS != "linux" && runtime.GOOS != "darwin") {
+		t.Skipf("no go1.20 SDK configured for %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	origWorkspaceData, err := ioutil.ReadFile("WORKSPACE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := bytes.Index(origWorkspaceData, []byte("go_rules_dependencies()"))
+	if i < 0 {
+		t.Fatal("could not find call to go_rules_dependencies()")
+	}
+	workspaceData := append(origWorkspaceData[:i:i], go120...)
+	if err := ioutil.WriteFile("WORKSPACE", workspaceData, 0666); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := ioutil.WriteFile("WORKSPACE", origWorkspaceData, 0666); err != nil {
+			t.Errorf("error restoring WORKSPACE: %v", err)
+		}
+	}()
+
+	t.Run("test", func(t *testing.T) {
+		if err := bazel_testing.RunBazel("coverage", coverFormat, "--instrumentation_filter=//:a", "//:a_test"); err != nil {
+			t.Fatal(err)
+		}
+		coveragePath := filepath.FromSlash("bazel-testlogs/a_test/coverage.dat")
+		coverageData, err := ioutil.ReadFile(coveragePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Each function body is one block, reported with the number of
+		// statements in it and whether it ran. FromServer only runs in the
+		// server, so its coverage shows that data from the server was merged.
+		for _, want := range []string{
+			`example.com/gocoverdir/a/a.go:3\.\d+,5\.\d+ 1 1`,
+			`example.com/gocoverdir/a/a.go:7\.\d+,9\.\d+ 1 1`,
+			`example.com/gocoverdir/a/a.go:11\.\d+,13\.\d+ 1 0`,
+		} {
+			if !regexp.MustCompile("(?m)^" + want + "$").Match(coverageData) {
+				t.Errorf("%s: no line matching %q in:\n%s", coveragePath, want, coverageData)
+			}
+		}
+		if strings.Contains(string(coverageData), "server.go") {
+			t.Errorf("%s: the server's main package was instrumented, but it's not in --instrumentation_filter:\n%s", coveragePath, coverageData)
+		}
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		if err := bazel_testing.RunBazel("build", "--collect_code_coverage", "--instrumentation_filter=//:a", coverFormat, "//:server"); err != nil {
+			t.Fatal(err)
+		}
+		coverDir, err := ioutil.TempDir("", "gocoverdir")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(coverDir)
+		cmd := exec.Command(filepath.FromSlash("bazel-bin/server_/server"))
+		cmd.Env = append(os.Environ(), "GOCOVERDIR="+coverDir)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("server failed: %v\n%s", err, out)
+		}
+		for _, pattern := range []string{"covmeta.*", "covcounters.*"} {
+			if matches, _ := filepath.Glob(filepath.Join(coverDir, pattern)); len(matches) == 0 {
+				t.Errorf("the server did not write %s to GOCOVERDIR", pattern)
+			}
+		}
+	})
+}
@@ -34,6 +34,8 @@ type check struct {
 var darwin = flag.String("darwin", "", "The darwin binary")
 var linux = flag.String("linux", "", "The linux binary")
 var windows = flag.String("windows", "", "The windows binary")
+var linuxArm64Cross = flag.String("linux_arm64_cross", "", "The linux arm64 binary built by go_cross_binary")
+var windowsPlatformCross = flag.String("windows_platform_cross", "", "The windows binary built by go_cross_binary with a platform")
 
 var checks = []check{
 	{darwin, []string{
@@ -55,6 +57,19 @@ var checks = []check{
 		"console",
 		"x86-64",
 	}},
+	{linuxArm64Cross, []string{
+		"ELF",
+		"64-bit",
+		"executable",
+		"ARM aarch64",
+	}},
+	{windowsPlatformCross, []string{
+		"PE32+",
+		"Windows",
+		"executable",
+		"console",
+		"x86-64",
+	}},
 }
 
 func TestCross(t *testing.T) {
@@ -0,0 +1,162 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package multiarch_test
+
+import (
+	"archive/tar"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_binary", "go_multiarch_binary")
+
+go_binary(
+    name = "bin",
+    srcs = ["main.go"],
+    pure = "on",
+)
+
+go_multiarch_binary(
+    name = "dir",
+    target = ":bin",
+    platforms = [
+        "linux_amd64",
+        "windows_amd64",
+    ],
+)
+
+go_multiarch_binary(
+    name = "archive",
+    target = ":bin",
+    platforms = [
+        "linux_arm64",
+        "windows_amd64",
+    ],
+    format = "tar",
+)
+
+go_multiarch_binary(
+    name = "universal",
+    target = ":bin",
+    platforms = [
+        "darwin_386",
+        "darwin_amd64",
+    ],
+    format = "universal",
+)
+
+go_multiarch_binary(
+    name = "universal_linux",
+    target = ":bin",
+    platforms = [
+        "darwin_amd64",
+        "linux_amd64",
+    ],
+    format = "universal",
+    tags = ["manual"],
+)
+
+-- main.go --
+package main
+
+func main() {}
+`,
+	})
+}
+
+func TestDirectory(t *testing.T) {
+	if err := bazel_testing.RunBazel("build", "//:dir"); err != nil {
+		t.Fatal(err)
+	}
+	f, err := elf.Open("bazel-bin/dir/linux_amd64/bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if f.Machine != elf.EM_X86_64 {
+		t.Errorf("linux_amd64/bin: got machine %v; want %v", f.Machine, elf.EM_X86_64)
+	}
+	p, err := pe.Open("bazel-bin/dir/windows_amd64/bin.exe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+}
+
+func TestTar(t *testing.T) {
+	if err := bazel_testing.RunBazel("build", "//:archive"); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open("bazel-bin/archive.tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var names []string
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+	want := []string{"linux_arm64/", "linux_arm64/bin", "windows_amd64/", "windows_amd64/bin.exe"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got entries %q; want %q", names, want)
+	}
+}
+
+func TestUniversal(t *testing.T) {
+	if err := bazel_testing.RunBazel("build", "//:universal"); err != nil {
+		t.Fatal(err)
+	}
+	f, err := macho.OpenFat("bazel-bin/universal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var cpus []macho.Cpu
+	for _, arch := range f.Arches {
+		cpus = append(cpus, arch.Cpu)
+	}
+	if want := []macho.Cpu{macho.Cpu386, macho.CpuAmd64}; !reflect.DeepEqual(cpus, want) {
+		t.Errorf("got architectures %v; want %v", cpus, want)
+	}
+}
+
+func TestUniversalNotDarwin(t *testing.T) {
+	err := bazel_testing.RunBazel("build", "//:universal_linux")
+	if err == nil {
+		t.Fatal("unexpected success")
+	}
+	if !strings.Contains(err.Error(), "universal binaries can only be built for darwin") {
+		t.Errorf("got error %v; want an error about the linux platform", err)
+	}
+}
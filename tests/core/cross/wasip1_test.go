@@ -0,0 +1,75 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasip1_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_binary", "go_test")
+
+go_binary(
+    name = "hello",
+    srcs = ["hello.go"],
+)
+
+go_test(
+    name = "hello_test",
+    srcs = ["hello_test.go"],
+)
+
+-- hello.go --
+package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("hello")
+}
+
+-- hello_test.go --
+package hello_test
+
+import "testing"
+
+func Test(t *testing.T) {}
+`,
+	})
+}
+
+// The SDK used by tests is older than Go 1.21, which added wasip1.
+func TestOldSDK(t *testing.T) {
+	for _, target := range []string{"//:hello", "//:hello_test"} {
+		t.Run(strings.TrimPrefix(target, "//:"), func(t *testing.T) {
+			cmd := bazel_testing.BazelCmd("build", "--platforms=@io_bazel_rules_go//go/toolchain:wasip1_wasm", target)
+			var stderr bytes.Buffer
+			cmd.Stderr = &stderr
+			if err := cmd.Run(); err == nil {
+				t.Fatal("build succeeded; want an error for the old SDK")
+			}
+			if want := "GOOS=wasip1 requires Go 1.21 or later"; !strings.Contains(stderr.String(), want) {
+				t.Errorf("output does not contain %q:\n%s", want, &stderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm_exec_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_binary", "go_test")
+
+go_test(
+    name = "pass_test",
+    srcs = ["pass_test.go"],
+    data = ["data.txt"],
+    env = {"GREETING": "hello"},
+)
+
+go_test(
+    name = "fail_test",
+    srcs = ["fail_test.go"],
+)
+
+go_binary(
+    name = "hello",
+    srcs = ["hello.go"],
+)
+
+sh_test(
+    name = "hello_data_test",
+    srcs = ["hello_data_test.sh"],
+    data = [":hello"],
+)
+
+-- hello_data_test.sh --
+#!/bin/sh
+# Runs hello's launcher from this test's runfiles, where it has no runfiles
+# tree of its own.
+set -eu
+got="$("$TEST_SRCDIR/__main__/hello.run" arg)"
+if [ "$got" != "hello from js arg" ]; then
+  echo "got \"$got\"; want \"hello from js arg\"" >&2
+  exit 1
+fi
+-- data.txt --
+data
+-- pass_test.go --
+package pass_test
+
+import (
+	"io/ioutil"
+	"os"
+	"runtime"
+	"testing"
+)
+
+func Test(t *testing.T) {
+	if runtime.GOOS != "js" {
+		t.Errorf("got GOOS %s; want js", runtime.GOOS)
+	}
+	if got := os.Getenv("GREETING"); got != "hello" {
+		t.Errorf("got GREETING %q; want %q", got, "hello")
+	}
+	if _, err := ioutil.ReadFile("data.txt"); err != nil {
+		t.Error(err)
+	}
+}
+
+-- fail_test.go --
+package fail_test
+
+import "testing"
+
+func Test(t *testing.T) {
+	t.Fatal("failed on purpose")
+}
+
+-- hello.go --
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+func main() {
+	fmt.Println("hello from", runtime.GOOS, os.Args[1])
+}
+`,
+	})
+}
+
+const platform = "--platforms=@io_bazel_rules_go//go/toolchain:js_wasm"
+
+func Test(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("Node.js is not installed")
+	}
+	// Tests run with a minimal PATH, which may not include Node.js.
+	path := "--test_env=PATH=" + filepath.Dir(node)
+
+	t.Run("pass", func(t *testing.T) {
+		if err := bazel_testing.RunBazel("test", platform, path, "//:pass_test"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		if err := bazel_testing.RunBazel("test", platform, path, "//:fail_test"); err == nil {
+			t.Fatal("test passed; want it to fail")
+		}
+	})
+
+	t.Run("data", func(t *testing.T) {
+		if err := os.Chmod("hello_data_test.sh", 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := bazel_testing.RunBazel("test", platform, path, "//:hello_data_test"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("run", func(t *testing.T) {
+		out, err := bazel_testing.BazelOutput("run", platform, "//:hello", "--", "arg")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := strings.TrimSpace(string(out)), "hello from js arg"; got != want {
+			t.Errorf("got %q; want %q", got, want)
+		}
+	})
+}
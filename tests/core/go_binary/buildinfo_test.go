@@ -0,0 +1,149 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildinfo_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_binary")
+
+go_binary(
+    name = "cmd",
+    srcs = ["cmd.go"],
+    importpath = "example.com/cmd",
+)
+
+-- cmd.go --
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+func main() {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		fmt.Println("no build info")
+		return
+	}
+	fmt.Println("path", bi.Path)
+	for _, s := range bi.Settings {
+		fmt.Println(s.Key, s.Value)
+	}
+}
+
+-- status.sh --
+#!/bin/sh
+echo BUILD_SCM_REVISION 0123456789abcdef
+echo STABLE_VCS_TIME 2020-06-01T12:00:00Z
+echo STABLE_VCS_MODIFIED true
+`,
+	})
+}
+
+// Build information needs go1.18 or later, which is newer than the SDK the
+// rest of the tests use.
+const go118 = `
+load("@io_bazel_rules_go//go:deps.bzl", "go_download_sdk")
+
+go_download_sdk(
+    name = "go_sdk",
+    # Checksums are omitted, so Bazel only warns about them.
+    sdks = {
+        "darwin_amd64": ("go1.18.darwin-amd64.tar.gz", ""),
+        "linux_amd64": ("go1.18.linux-amd64.tar.gz", ""),
+    },
+)
+
+go_rules_dependencies()
+
+go_register_toolchains()
+`
+
+const buildVCS = "--@io_bazel_rules_go//go/config:buildvcs"
+
+func Test(t *testing.T) {
+	if runtime.GOARCH != "amd64" || (runtime.GOOS != "linux" && runtime.GOOS != "darwin") {
+		t.Skipf("no go1.18 SDK configured for %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	origWorkspaceData, err := ioutil.ReadFile("WORKSPACE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := bytes.Index(origWorkspaceData, []byte("go_rules_dependencies()"))
+	if i < 0 {
+		t.Fatal("could not find call to go_rules_dependencies()")
+	}
+	workspaceData := append(origWorkspaceData[:i:i], go118...)
+	if err := ioutil.WriteFile("WORKSPACE", workspaceData, 0666); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := ioutil.WriteFile("WORKSPACE", origWorkspaceData, 0666); err != nil {
+			t.Errorf("error restoring WORKSPACE: %v", err)
+		}
+	}()
+	if err := os.Chmod("status.sh", 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("stamp", func(t *testing.T) {
+		out, err := bazel_testing.BazelOutput("run", "--stamp", "--workspace_status_command=./status.sh", buildVCS, "//:cmd")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := strings.TrimSpace(string(out))
+		want := `path example.com/cmd
+vcs.revision 0123456789abcdef
+vcs.time 2020-06-01T12:00:00Z
+vcs.modified true`
+		if got != want {
+			t.Errorf("got:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("stamp_without_buildvcs", func(t *testing.T) {
+		out, err := bazel_testing.BazelOutput("run", "--stamp", "--workspace_status_command=./status.sh", "//:cmd")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := strings.TrimSpace(string(out)), "no build info"; got != want {
+			t.Errorf("got %q; want %q", got, want)
+		}
+	})
+
+	t.Run("nostamp", func(t *testing.T) {
+		out, err := bazel_testing.BazelOutput("run", "--nostamp", "--workspace_status_command=./status.sh", buildVCS, "//:cmd")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := strings.TrimSpace(string(out)), "no build info"; got != want {
+			t.Errorf("got %q; want %q", got, want)
+		}
+	})
+}
@@ -0,0 +1,110 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package run_env_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_binary", "go_cross_binary")
+
+go_binary(
+    name = "server",
+    srcs = ["server.go"],
+    args = ["-config=$(rootpath config.txt)"],
+    data = [
+        "config.txt",
+        "templates.txt",
+    ],
+    env = {
+        "SERVER_TEMPLATES": "$(rootpath templates.txt)",
+        "SERVER_NAME": "it's $(TARGET_CPU)",
+    },
+)
+
+go_cross_binary(
+    name = "server_cross",
+    target = ":server",
+)
+
+-- config.txt --
+config contents
+-- templates.txt --
+templates contents
+-- server.go --
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+func main() {
+	configPath := flag.String("config", "", "")
+	flag.Parse()
+	for _, path := range []string{*configPath, os.Getenv("SERVER_TEMPLATES")} {
+		if path == "" {
+			continue
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(string(data))
+	}
+	fmt.Println("name:", os.Getenv("SERVER_NAME"))
+	fmt.Println("args:", flag.Args())
+}
+`,
+	})
+}
+
+func TestRun(t *testing.T) {
+	out, err := bazel_testing.BazelOutput("run", "//:server", "--", "extra arg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(out)
+	for _, want := range []string{
+		"config contents\n",
+		"templates contents\n",
+		"name: it's ",
+		"args: [extra arg]\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestCross(t *testing.T) {
+	out, err := bazel_testing.BazelOutput("run", "//:server_cross")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(out); !strings.Contains(got, "templates contents\n") {
+		t.Errorf("environment not set when running go_cross_binary:\n%s", got)
+	}
+}
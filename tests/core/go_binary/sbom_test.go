@@ -0,0 +1,221 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sbom_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_binary", "go_library")
+
+filegroup(
+    name = "sbom_modules",
+    srcs = [
+        "go.mod",
+        "go.sum",
+    ],
+)
+
+go_library(
+    name = "internal",
+    srcs = ["internal.go"],
+    importpath = "example.com/app/internal",
+)
+
+go_binary(
+    name = "server",
+    srcs = ["server.go"],
+    deps = [
+        ":internal",
+        "@com_example_tool//:tool",
+        "@com_github_pkg_errors//:errors",
+    ],
+)
+
+-- go.mod --
+module example.com/app
+
+go 1.14
+
+require github.com/pkg/errors v0.9.1
+
+-- go.sum --
+github.com/pkg/errors v0.9.1 h1:FEBLx1zS214owpjy7qsBeixbURkuhQAwrK5UwLGTwt4=
+github.com/pkg/errors v0.9.1/go.mod h1:bwawxfHBFNV+L2hUp1rHADufV3IMtnDRdf1r5NINEl0=
+
+-- internal.go --
+package internal
+
+func Name() string { return "internal" }
+
+-- server.go --
+package main
+
+import (
+	"fmt"
+
+	"example.com/app/internal"
+	"example.com/tool"
+	"github.com/pkg/errors"
+)
+
+func main() {
+	fmt.Println(errors.New(internal.Name()), tool.Name())
+}
+
+-- third_party/errors/WORKSPACE --
+-- third_party/errors/BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "errors",
+    srcs = ["errors.go"],
+    importpath = "github.com/pkg/errors",
+    visibility = ["//visibility:public"],
+)
+
+-- third_party/errors/errors.go --
+package errors
+
+import "errors"
+
+func New(s string) error { return errors.New(s) }
+
+-- third_party/tool/WORKSPACE --
+-- third_party/tool/BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "tool",
+    srcs = ["tool.go"],
+    importpath = "example.com/tool",
+    visibility = ["//visibility:public"],
+)
+
+-- third_party/tool/tool.go --
+package tool
+
+func Name() string { return "tool" }
+`,
+		WorkspaceSuffix: `
+local_repository(
+    name = "com_github_pkg_errors",
+    path = "third_party/errors",
+)
+
+local_repository(
+    name = "com_example_tool",
+    path = "third_party/tool",
+)
+`,
+	})
+}
+
+const sbomModules = "--@io_bazel_rules_go//go/config:sbom_modules=//:sbom_modules"
+
+const errorsSum = "h1:FEBLx1zS214owpjy7qsBeixbURkuhQAwrK5UwLGTwt4="
+
+func TestCycloneDX(t *testing.T) {
+	if err := bazel_testing.RunBazel("build", "--output_groups=sbom", sbomModules, "//:server"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.FromSlash("bazel-bin/server.cdx.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bom struct {
+		BOMFormat string
+		Metadata  struct {
+			Component struct{ Name string }
+		}
+		Components []struct {
+			Name, Version, PURL string
+			Hashes              []struct{ Alg, Content string }
+			Properties          []struct{ Name, Value string }
+		}
+	}
+	if err := json.Unmarshal(data, &bom); err != nil {
+		t.Fatal(err)
+	}
+	if bom.BOMFormat != "CycloneDX" || bom.Metadata.Component.Name != "server" {
+		t.Errorf("got format %q for component %q; want CycloneDX for server", bom.BOMFormat, bom.Metadata.Component.Name)
+	}
+	var names []string
+	for _, c := range bom.Components {
+		names = append(names, c.Name)
+		switch c.Name {
+		case "github.com/pkg/errors":
+			if c.Version != "v0.9.1" || c.PURL != "pkg:golang/github.com/pkg/errors@v0.9.1" {
+				t.Errorf("got %s version %q with purl %q; want v0.9.1", c.Name, c.Version, c.PURL)
+			}
+			if len(c.Hashes) != 0 {
+				t.Errorf("got %s hashes %v; want none, since h1 sums don't hash an artifact", c.Name, c.Hashes)
+			}
+			if len(c.Properties) != 1 || c.Properties[0].Name != "go.sum:h1" || c.Properties[0].Value != errorsSum {
+				t.Errorf("got %s properties %v; want go.sum:h1 %s", c.Name, c.Properties, errorsSum)
+			}
+		case "stdlib":
+			if c.Version == "" {
+				t.Errorf("got no version for the standard library")
+			}
+		}
+	}
+	if want := []string{"@com_example_tool", "github.com/pkg/errors", "stdlib"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got components %v; want %v", names, want)
+	}
+}
+
+func TestSPDX(t *testing.T) {
+	if err := bazel_testing.RunBazel("build", "--output_groups=sbom", sbomModules, "--@io_bazel_rules_go//go/config:sbom_format=spdx", "//:server"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.FromSlash("bazel-bin/server.spdx.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		SPDXVersion string
+		Packages    []struct {
+			Name, VersionInfo string
+			Checksums         []struct{ Algorithm, ChecksumValue string }
+		}
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.SPDXVersion != "SPDX-2.3" {
+		t.Errorf("got spdxVersion %q; want SPDX-2.3", doc.SPDXVersion)
+	}
+	var names []string
+	for _, p := range doc.Packages {
+		names = append(names, p.Name)
+		if p.Name == "github.com/pkg/errors" && (p.VersionInfo != "v0.9.1" || len(p.Checksums) != 0) {
+			t.Errorf("got %s version %q with checksums %v; want v0.9.1 with no checksums", p.Name, p.VersionInfo, p.Checksums)
+		}
+	}
+	if want := []string{"server", "@com_example_tool", "github.com/pkg/errors", "stdlib"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got packages %v; want %v", names, want)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package size_report_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_binary", "go_library")
+
+go_library(
+    name = "big",
+    srcs = ["big.go"],
+    importpath = "example.com/big",
+)
+
+go_binary(
+    name = "hello",
+    srcs = ["hello.go"],
+    deps = [":big"],
+)
+
+go_binary(
+    name = "stripped",
+    srcs = ["hello.go"],
+    gc_linkopts = ["-s"],
+    deps = [":big"],
+)
+
+-- big.go --
+package big
+
+var Table = [4096]int64{1, 2, 3}
+
+func Sum() int64 {
+	var s int64
+	for _, v := range Table {
+		s += v
+	}
+	return s
+}
+
+-- hello.go --
+package main
+
+import (
+	"fmt"
+
+	"example.com/big"
+)
+
+func main() {
+	fmt.Println(big.Sum())
+}
+`,
+	})
+}
+
+type report struct {
+	FileSize        int64
+	Text, Data, BSS int64
+	Packages        []struct {
+		Package         string
+		Text, Data, BSS int64
+		Symbols         int
+	}
+}
+
+func TestSizeReport(t *testing.T) {
+	r := buildReport(t, "hello")
+	if r.FileSize == 0 || r.Text == 0 || r.Data == 0 {
+		t.Errorf("missing totals: %+v", r)
+	}
+	sizes := make(map[string]int64)
+	for _, p := range r.Packages {
+		sizes[p.Package] = p.Text + p.Data
+	}
+	if sizes["example.com/big"] < 4096*8 {
+		t.Errorf("example.com/big has size %d; want at least %d", sizes["example.com/big"], 4096*8)
+	}
+	for _, pkg := range []string{"main", "fmt", "runtime"} {
+		if sizes[pkg] == 0 {
+			t.Errorf("package %s not found in report", pkg)
+		}
+	}
+	for i := 1; i < len(r.Packages); i++ {
+		prev, p := r.Packages[i-1], r.Packages[i]
+		if prev.Text+prev.Data < p.Text+p.Data {
+			t.Errorf("packages not sorted by size: %s before %s", prev.Package, p.Package)
+		}
+	}
+}
+
+func TestStripped(t *testing.T) {
+	r := buildReport(t, "stripped")
+	if r.FileSize == 0 || len(r.Packages) != 0 {
+		t.Errorf("got %+v; want only a file size", r)
+	}
+}
+
+func buildReport(t *testing.T, target string) report {
+	if err := bazel_testing.RunBazel("build", "--output_groups=size_report", "//:"+target); err != nil {
+		t.Fatal(err)
+	}
+	out, err := bazel_testing.BazelOutput("info", "bazel-bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(strings.TrimSpace(string(out)), target+".size_report.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r report
+	if err := json.Unmarshal(data, &r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
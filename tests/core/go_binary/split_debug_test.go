@@ -0,0 +1,129 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package split_debug_test
+
+import (
+	"bytes"
+	"debug/elf"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_binary")
+
+go_binary(
+    name = "hello",
+    srcs = ["hello.go"],
+    split_debug = True,
+)
+
+go_binary(
+    name = "no_dwarf",
+    srcs = ["hello.go"],
+    gc_linkopts = ["-w"],
+    split_debug = True,
+)
+
+-- hello.go --
+package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("hello")
+}
+`,
+	})
+}
+
+func TestSplitDebug(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("split_debug is only supported on ELF platforms")
+	}
+	if err := bazel_testing.RunBazel("build", "--output_groups=+debug_info", "//:hello"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := bazel_testing.BazelOutput("info", "bazel-bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin := strings.TrimSpace(string(out))
+
+	stripped, err := elf.Open(filepath.Join(bin, "hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stripped.Close()
+	for _, s := range stripped.Sections {
+		if strings.HasPrefix(s.Name, ".debug_") || strings.HasPrefix(s.Name, ".zdebug_") || s.Type == elf.SHT_SYMTAB {
+			t.Errorf("stripped binary has section %s", s.Name)
+		}
+	}
+	link := stripped.Section(".gnu_debuglink")
+	if link == nil {
+		t.Fatal("stripped binary has no .gnu_debuglink section")
+	}
+	if data, err := link.Data(); err != nil {
+		t.Fatal(err)
+	} else if !bytes.HasPrefix(data, []byte("hello.debug\x00")) {
+		t.Errorf("got debug link %q; want hello.debug", data)
+	}
+
+	debug, err := elf.Open(filepath.Join(bin, "hello.debug"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer debug.Close()
+	if _, err := debug.DWARF(); err != nil {
+		t.Errorf("debug file has no DWARF data: %v", err)
+	}
+	if fi, err := os.Stat(filepath.Join(bin, "hello")); err != nil {
+		t.Fatal(err)
+	} else if dfi, err := os.Stat(filepath.Join(bin, "hello.debug")); err != nil {
+		t.Fatal(err)
+	} else if fi.Size() >= dfi.Size() {
+		t.Errorf("stripped binary has %d bytes; debug file has %d", fi.Size(), dfi.Size())
+	}
+
+	runOut, err := bazel_testing.BazelOutput("run", "//:hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(runOut)); got != "hello" {
+		t.Errorf("got output %q; want hello", got)
+	}
+}
+
+func TestNoDebugInfo(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("split_debug is only supported on ELF platforms")
+	}
+	err := bazel_testing.RunBazel("build", "//:no_dwarf")
+	if err == nil {
+		t.Fatal("build succeeded; want failure")
+	}
+	if !strings.Contains(err.Error(), "no debug information") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
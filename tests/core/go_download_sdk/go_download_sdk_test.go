@@ -17,6 +17,7 @@ package go_download_sdk_test
 import (
 	"bytes"
 	"io/ioutil"
+	"strings"
 	"testing"
 
 	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
@@ -33,6 +34,14 @@ go_test(
     srcs = ["version_test.go"],
 )
 
+-- go1.12.13.sha256 --
+6d3de6f7d7c0e8162aaa009128839fa5afcba578dcbd6ff034a82419d82480e9  go1.12.13.darwin-amd64.tar.gz
+da036454cb3353f9f507f0ceed4048feac611065e4e1818b434365eb32ac9bdc  go1.12.13.linux-amd64.tar.gz
+c441a298e8b510d3cdabfd361885cd6762e33eaceb27cbb0eabe6757f9d1f07d *go1.12.13.windows-amd64.zip
+
+-- src.sha256 --
+0000000000000000000000000000000000000000000000000000000000000000  go1.12.13.src.tar.gz
+
 -- version_test.go --
 package version_test
 
@@ -55,7 +64,7 @@ func Test(t *testing.T) {
 
 func Test(t *testing.T) {
 	for _, test := range []struct {
-		desc, rule, wantVersion string
+		desc, rule, wantVersion, wantErr string
 	}{
 		{
 			desc: "version",
@@ -84,6 +93,39 @@ go_download_sdk(
 )
 `,
 			wantVersion: "go1.12.13",
+		}, {
+			desc: "checksums",
+			rule: `
+load("@io_bazel_rules_go//go:deps.bzl", "go_download_sdk")
+
+go_download_sdk(
+    name = "go_sdk",
+    sdks = {
+        "darwin_amd64":      ("go1.12.13.darwin-amd64.tar.gz", ""),
+        "linux_amd64":       ("go1.12.13.linux-amd64.tar.gz", ""),
+        "windows_amd64":     ("go1.12.13.windows-amd64.zip", ""),
+    },
+    urls = ["https://dl.google.com/go/{filename}"],
+    checksums = "//:go1.12.13.sha256",
+)
+`,
+			wantVersion: "go1.12.13",
+		}, {
+			desc: "missing_checksum",
+			rule: `
+load("@io_bazel_rules_go//go:deps.bzl", "go_download_sdk")
+
+go_download_sdk(
+    name = "go_sdk",
+    sdks = {
+        "darwin_amd64":      ("go1.12.13.darwin-amd64.tar.gz", ""),
+        "linux_amd64":       ("go1.12.13.linux-amd64.tar.gz", ""),
+        "windows_amd64":     ("go1.12.13.windows-amd64.zip", ""),
+    },
+    checksums = "//:src.sha256",
+)
+`,
+			wantErr: "no SHA-256 sum for go1.12.13.",
 		},
 	} {
 		t.Run(test.desc, func(t *testing.T) {
@@ -114,6 +156,17 @@ go_register_toolchains()
 				}
 			}()
 
+			if test.wantErr != "" {
+				cmd := bazel_testing.BazelCmd("test", "//:version_test")
+				var stderr bytes.Buffer
+				cmd.Stderr = &stderr
+				if err := cmd.Run(); err == nil {
+					t.Fatal("unexpected success")
+				} else if !strings.Contains(stderr.String(), test.wantErr) {
+					t.Fatalf("got error:\n%s\nwant error containing %q", &stderr, test.wantErr)
+				}
+				return
+			}
 			if err := bazel_testing.RunBazel("test", "//:version_test", "--test_arg=-version="+test.wantVersion); err != nil {
 				t.Fatal(err)
 			}
@@ -0,0 +1,171 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sdk_version_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")
+
+go_library(
+    name = "version",
+    srcs = ["version.go"],
+    importpath = "example.com/version",
+)
+
+go_test(
+    name = "default_test",
+    srcs = ["version_test.go"],
+    args = ["-version=go1.14.2"],
+    deps = [":version"],
+)
+
+go_test(
+    name = "old_test",
+    srcs = ["version_test.go"],
+    args = ["-version=go1.13.10"],
+    sdk_version = "1.13",
+    deps = [":version"],
+)
+
+go_test(
+    name = "exact_test",
+    srcs = ["version_test.go"],
+    args = ["-version=go1.13.10"],
+    sdk_version = "1.13.10",
+    deps = [":version"],
+)
+
+-- version.go --
+package version
+
+import "runtime"
+
+// Runtime returns the version of the SDK the binary was built with.
+func Runtime() string { return runtime.Version() }
+
+-- version_test.go --
+package version_test
+
+import (
+	"flag"
+	"testing"
+
+	"example.com/version"
+)
+
+var want = flag.String("version", "", "")
+
+func Test(t *testing.T) {
+	if v := version.Runtime(); v != *want {
+		t.Errorf("got version %q; want %q", v, *want)
+	}
+}
+`,
+	})
+}
+
+// The default SDK is registered first, so targets without sdk_version use it.
+const sdks = `
+load("@io_bazel_rules_go//go:deps.bzl", "go_download_sdk")
+
+go_download_sdk(
+    name = "go_sdk",
+    version = "1.14.2",
+)
+
+go_download_sdk(
+    name = "go_sdk_1_13",
+    version = "1.13.10",
+)
+
+go_rules_dependencies()
+
+go_register_toolchains()
+`
+
+func Test(t *testing.T) {
+	origWorkspaceData, err := ioutil.ReadFile("WORKSPACE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := bytes.Index(origWorkspaceData, []byte("go_rules_dependencies()"))
+	if i < 0 {
+		t.Fatal("could not find call to go_rules_dependencies()")
+	}
+	workspaceData := append(origWorkspaceData[:i:i], sdks...)
+	if err := ioutil.WriteFile("WORKSPACE", workspaceData, 0666); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := ioutil.WriteFile("WORKSPACE", origWorkspaceData, 0666); err != nil {
+			t.Errorf("error restoring WORKSPACE: %v", err)
+		}
+	}()
+
+	selectable, err := canSelectSDKVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range []string{"//:default_test", "//:old_test", "//:exact_test"} {
+		t.Run(strings.TrimPrefix(target, "//:"), func(t *testing.T) {
+			cmd := bazel_testing.BazelCmd("test", target)
+			var stderr bytes.Buffer
+			cmd.Stderr = &stderr
+			err := cmd.Run()
+			if selectable || target == "//:default_test" {
+				if err != nil {
+					t.Fatalf("%v\n%s", err, &stderr)
+				}
+				return
+			}
+			// Older versions of Bazel can't select toolchains by version, so
+			// the attribute must be rejected, not ignored.
+			if err == nil {
+				t.Fatal("unexpected success with a version of Bazel that can't select SDKs by version")
+			}
+			if want := "selecting Go SDKs by version requires Bazel 5.0 or newer"; !strings.Contains(stderr.String(), want) {
+				t.Fatalf("got error:\n%s\nwant error containing %q", &stderr, want)
+			}
+		})
+	}
+}
+
+// canSelectSDKVersion returns whether the version of Bazel running the test
+// can select toolchains with target_settings, which was added in Bazel 5.0.
+// Development versions are assumed to be new enough.
+func canSelectSDKVersion() (bool, error) {
+	out, err := bazel_testing.BazelOutput("info", "release")
+	if err != nil {
+		return false, err
+	}
+	release := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(out)), "release"))
+	major, err := strconv.Atoi(strings.SplitN(release, ".", 2)[0])
+	if err != nil {
+		return true, nil
+	}
+	return major >= 5, nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build_constraints_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "lax",
+    srcs = [
+        "ok.go",
+        "sys_linux.go",
+    ],
+    importpath = "example.com/lax",
+)
+
+go_library(
+    name = "strict",
+    srcs = [
+        "ok.go",
+        "sys_linux.go",
+    ],
+    importpath = "example.com/strict",
+    strict_build_tags = True,
+)
+
+-- ok.go --
+// +build linux darwin,cgo go1.9
+
+package p
+
+-- sys_linux.go --
+// +build linxu
+
+package p
+`,
+	})
+}
+
+func TestReport(t *testing.T) {
+	if err := bazel_testing.RunBazel("build", "--output_groups=build_constraints", "//:lax"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := bazel_testing.BazelOutput("info", "bazel-bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reportPath string
+	filepath.Walk(strings.TrimSpace(string(out)), func(path string, info os.FileInfo, err error) error {
+		if err == nil && filepath.Base(path) == "lax.build_constraints" {
+			reportPath = path
+		}
+		return nil
+	})
+	if reportPath == "" {
+		t.Fatal("lax.build_constraints not found")
+	}
+	data, err := ioutil.ReadFile(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := string(data)
+	for _, want := range []string{
+		`warning: sys_linux.go: unknown build tag "linxu"`,
+		"warning: sys_linux.go: excluded by build constraints on all platforms",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report does not contain %q:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, "ok.go") {
+		t.Errorf("report mentions ok.go:\n%s", report)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	err := bazel_testing.RunBazel("build", "//:strict")
+	if err == nil {
+		t.Fatal("//:strict built successfully; want error")
+	}
+	if want := `sys_linux.go: unknown build tag "linxu"`; !strings.Contains(err.Error(), want) {
+		t.Errorf("error does not contain %q:\n%v", want, err)
+	}
+
+	// Tags set in the configuration are known.
+	if err := bazel_testing.RunBazel("build", "--define", "gotags=linxu", "//:strict"); err != nil {
+		t.Fatal(err)
+	}
+}
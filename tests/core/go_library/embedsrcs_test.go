@@ -0,0 +1,216 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedsrcs_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")
+
+go_library(
+    name = "embed_lib",
+    srcs = ["embed.go"],
+    embedsrcs = glob(["static/**"]) + [
+        "unused.txt",
+        ":gen",
+    ],
+    importpath = "example.com/embed",
+)
+
+genrule(
+    name = "gen",
+    outs = ["gen.txt"],
+    cmd = "echo generated >$@",
+)
+
+go_test(
+    name = "embed_test",
+    srcs = ["embed_test.go"],
+    embed = [":embed_lib"],
+)
+
+go_library(
+    name = "missing",
+    srcs = ["missing.go"],
+    embedsrcs = ["unused.txt"],
+    importpath = "example.com/missing",
+)
+
+-- embed.go --
+package embed
+
+import "embed"
+
+//go:embed static
+var Static embed.FS
+
+//go:embed gen.txt
+var Gen string
+
+-- embed_test.go --
+package embed
+
+import (
+	_ "embed"
+	"strings"
+	"testing"
+)
+
+//go:embed static/hello.txt
+var hello string
+
+func TestEmbed(t *testing.T) {
+	if strings.TrimSpace(hello) != "hello" {
+		t.Errorf("hello = %q", hello)
+	}
+	if strings.TrimSpace(Gen) != "generated" {
+		t.Errorf("Gen = %q", Gen)
+	}
+	if data, err := Static.ReadFile("static/sub/nested.txt"); err != nil || strings.TrimSpace(string(data)) != "nested" {
+		t.Errorf("static/sub/nested.txt: got %q, %v", data, err)
+	}
+	if _, err := Static.ReadFile("static/_hidden.txt"); err == nil {
+		t.Error("static/_hidden.txt was embedded without all:")
+	}
+}
+
+-- missing.go --
+package missing
+
+import _ "embed"
+
+//go:embed missing.txt
+var missing string
+
+-- static/hello.txt --
+hello
+-- static/sub/nested.txt --
+nested
+-- static/_hidden.txt --
+hidden
+-- unused.txt --
+unused
+`,
+	})
+}
+
+// //go:embed needs go1.16 or later, which is newer than the SDK the rest of
+// the tests use.
+const go116 = `
+load("@io_bazel_rules_go//go:deps.bzl", "go_download_sdk")
+
+go_download_sdk(
+    name = "go_sdk",
+    # Checksums are omitted, so Bazel only warns about them.
+    sdks = {
+        "darwin_amd64": ("go1.16.darwin-amd64.tar.gz", ""),
+        "linux_amd64": ("go1.16.linux-amd64.tar.gz", ""),
+    },
+)
+
+go_rules_dependencies()
+
+go_register_toolchains()
+`
+
+func Test(t *testing.T) {
+	if runtime.GOARCH != "amd64" || (runtime.GOOS != "linux" && runtime.GOOS != "darwin") {
+		t.Skipf("no go1.16 SDK configured for %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	origWorkspaceData, err := ioutil.ReadFile("WORKSPACE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := bytes.Index(origWorkspaceData, []byte("go_rules_dependencies()"))
+	if i < 0 {
+		t.Fatal("could not find call to go_rules_dependencies()")
+	}
+	workspaceData := append(origWorkspaceData[:i:i], go116...)
+	if err := ioutil.WriteFile("WORKSPACE", workspaceData, 0666); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := ioutil.WriteFile("WORKSPACE", origWorkspaceData, 0666); err != nil {
+			t.Errorf("error restoring WORKSPACE: %v", err)
+		}
+	}()
+
+	t.Run("embed", func(t *testing.T) {
+		if err := bazel_testing.RunBazel("test", "//:embed_test"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("embedcfg", func(t *testing.T) {
+		if err := bazel_testing.RunBazel("build", "//:embed_lib", "--output_groups=embedcfg"); err != nil {
+			t.Fatal(err)
+		}
+		out, err := bazel_testing.BazelOutput("info", "bazel-bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var cfgPath string
+		filepath.Walk(strings.TrimSpace(string(out)), func(path string, info os.FileInfo, err error) error {
+			if err == nil && filepath.Base(path) == "embed_lib.embedcfg" {
+				cfgPath = path
+			}
+			return nil
+		})
+		if cfgPath == "" {
+			t.Fatal("embed_lib.embedcfg not found")
+		}
+		data, err := ioutil.ReadFile(cfgPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var cfg struct{ Patterns map[string][]string }
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string][]string{
+			"static":  {"static/hello.txt", "static/sub/nested.txt"},
+			"gen.txt": {"gen.txt"},
+		}
+		if !reflect.DeepEqual(cfg.Patterns, want) {
+			t.Errorf("got patterns %v; want %v", cfg.Patterns, want)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		err := bazel_testing.RunBazel("build", "//:missing")
+		if err == nil {
+			t.Fatal("//:missing built successfully; want error")
+		}
+		const want = "missing.go:5:1: pattern missing.txt: no matching files found in embedsrcs"
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not contain %q:\n%v", want, err)
+		}
+	})
+}
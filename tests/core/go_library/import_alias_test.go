@@ -17,6 +17,8 @@ package import_alias
 import (
 	"import_alias/a"
 	"import_alias/b"
+	"import_alias/c"
+	cv2 "import_alias/c/v2"
 	"testing"
 )
 
@@ -31,3 +33,12 @@ func TestB(t *testing.T) {
 		t.Errorf("got %q; want %q", b.B, "import_alias/b")
 	}
 }
+
+func TestSamePackage(t *testing.T) {
+	if &c.C != &cv2.C {
+		t.Error("import_alias/c and import_alias/c/v2 are different packages")
+	}
+	if c.C != "import_alias/c/v2" {
+		t.Errorf("got %q; want %q", c.C, "import_alias/c/v2")
+	}
+}
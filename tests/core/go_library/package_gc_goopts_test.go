@@ -0,0 +1,199 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package package_gc_goopts_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_binary", "go_package_gc_goopts")
+
+go_package_gc_goopts(
+    name = "lib_flags",
+    packages = [
+        "//lib/...",
+        "-//lib/vendored/...",
+    ],
+    gc_goopts = ["-bogus_lib_flag"],
+)
+
+go_package_gc_goopts(
+    name = "other_flags",
+    packages = ["//other"],
+    gc_goopts = ["-bogus_other_flag"],
+    deps = [":lib_flags"],
+)
+
+go_binary(
+    name = "plain_bin",
+    srcs = ["main.go"],
+    deps = ["//lib"],
+)
+
+go_binary(
+    name = "flags_bin",
+    srcs = ["main.go"],
+    package_gc_goopts = ":lib_flags",
+    deps = ["//lib"],
+)
+
+-- main.go --
+package main
+
+import "example.com/lib"
+
+func main() {
+	lib.Hello()
+}
+
+-- lib/BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "lib",
+    srcs = ["lib.go"],
+    importpath = "example.com/lib",
+    visibility = ["//visibility:public"],
+)
+
+-- lib/lib.go --
+package lib
+
+func Hello() {}
+
+-- lib/sub/BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "sub",
+    srcs = ["sub.go"],
+    importpath = "example.com/lib/sub",
+)
+
+-- lib/sub/sub.go --
+package sub
+
+-- lib/vendored/BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "vendored",
+    srcs = ["vendored.go"],
+    importpath = "example.com/lib/vendored",
+)
+
+-- lib/vendored/vendored.go --
+package vendored
+
+-- other/BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "other",
+    srcs = ["other.go"],
+    importpath = "example.com/other",
+)
+
+-- other/other.go --
+package other
+`,
+	})
+}
+
+func Test(t *testing.T) {
+	const (
+		libFlags   = "--@io_bazel_rules_go//go/config:package_gc_goopts=//:lib_flags"
+		otherFlags = "--@io_bazel_rules_go//go/config:package_gc_goopts=//:other_flags"
+		globalFlag = "--@io_bazel_rules_go//go/config:gc_goopts=-bogus_global_flag"
+	)
+	for _, test := range []struct {
+		desc, target string
+		args         []string
+		wantFlag     string
+	}{
+		{
+			desc:   "no_flags",
+			target: "//...",
+		}, {
+			desc:     "global",
+			target:   "//other",
+			args:     []string{globalFlag},
+			wantFlag: "-bogus_global_flag",
+		}, {
+			desc:     "package",
+			target:   "//lib",
+			args:     []string{libFlags},
+			wantFlag: "-bogus_lib_flag",
+		}, {
+			desc:     "subpackage",
+			target:   "//lib/sub",
+			args:     []string{libFlags},
+			wantFlag: "-bogus_lib_flag",
+		}, {
+			desc:   "excluded",
+			target: "//lib/vendored",
+			args:   []string{libFlags},
+		}, {
+			desc:   "unmatched",
+			target: "//other",
+			args:   []string{libFlags},
+		}, {
+			desc:     "deps",
+			target:   "//lib",
+			args:     []string{otherFlags},
+			wantFlag: "-bogus_lib_flag",
+		}, {
+			desc:     "deps_own",
+			target:   "//other",
+			args:     []string{otherFlags},
+			wantFlag: "-bogus_other_flag",
+		}, {
+			desc:     "attribute",
+			target:   "//:flags_bin",
+			wantFlag: "-bogus_lib_flag",
+		}, {
+			desc:   "attribute_unset",
+			target: "//:plain_bin",
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			args := append([]string{"build"}, test.args...)
+			args = append(args, test.target)
+			cmd := bazel_testing.BazelCmd(args...)
+			stderr := &bytes.Buffer{}
+			cmd.Stderr = stderr
+			err := cmd.Run()
+			if test.wantFlag == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v\n%s", err, stderr)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("build succeeded; want the compiler to reject %s", test.wantFlag)
+			}
+			if !bytes.Contains(stderr.Bytes(), []byte(test.wantFlag)) {
+				t.Errorf("output did not mention %s:\n%s", test.wantFlag, stderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package package_metadata_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_source")
+load(":defs.bzl", "imports_of")
+
+go_library(
+    name = "lib",
+    srcs = [
+        "lib.go",
+        "lib_linux.go",
+    ],
+    importpath = "example.com/lib",
+)
+
+go_source(
+    name = "src",
+    srcs = [
+        "src.go",
+        ":gen",
+    ],
+)
+
+genrule(
+    name = "gen",
+    outs = ["gen.go"],
+    cmd = "echo 'package src' >$@",
+)
+
+imports_of(
+    name = "lib_imports",
+    lib = ":lib",
+)
+
+-- defs.bzl --
+load("@io_bazel_rules_go//go:def.bzl", "GoPackageMetadata")
+
+def _imports_of_impl(ctx):
+    md = ctx.attr.lib[GoPackageMetadata]
+    out = ctx.actions.declare_file(ctx.label.name + ".json")
+    ctx.actions.run_shell(
+        inputs = [md.metadata],
+        outputs = [out],
+        command = "cp \"$1\" \"$2\"",
+        arguments = [md.metadata.path, out.path],
+    )
+    return [DefaultInfo(files = depset([out]))]
+
+imports_of = rule(
+    implementation = _imports_of_impl,
+    attrs = {"lib": attr.label(providers = [GoPackageMetadata])},
+)
+
+-- lib.go --
+package lib
+
+import (
+	"fmt"
+	"os"
+)
+
+var _ = fmt.Sprint
+var _ = os.Args
+
+-- lib_linux.go --
+// +build linux
+
+package lib
+
+import "strings"
+
+var _ = strings.Fields
+
+-- src.go --
+package src
+`,
+	})
+}
+
+type fileMetadata struct {
+	Path         string
+	Package      string
+	Imports      []string
+	Tags         []string
+	Platforms    []string
+	CgoPlatforms []string
+	Generated    bool
+}
+
+type packageMetadata struct {
+	ImportPath string
+	Name       string
+	Files      []fileMetadata
+}
+
+func TestLibrary(t *testing.T) {
+	if err := bazel_testing.RunBazel("build", "//:lib_imports"); err != nil {
+		t.Fatal(err)
+	}
+	md := readMetadata(t, "lib_imports.json")
+	if md.ImportPath != "example.com/lib" || md.Name != "lib" {
+		t.Errorf("got import path %q and name %q; want example.com/lib and lib", md.ImportPath, md.Name)
+	}
+	if len(md.Files) != 2 {
+		t.Fatalf("got %d files; want 2", len(md.Files))
+	}
+	if got, want := md.Files[0].Imports, []string{"fmt", "os"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("lib.go: got imports %q; want %q", got, want)
+	}
+	linux := md.Files[1]
+	if !strings.HasSuffix(linux.Path, "lib_linux.go") {
+		t.Fatalf("got %s; want lib_linux.go", linux.Path)
+	}
+	if got, want := linux.Tags, []string{"linux"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("lib_linux.go: got tags %q; want %q", got, want)
+	}
+	if len(linux.Platforms) == 0 {
+		t.Error("lib_linux.go: no platforms")
+	}
+	for _, p := range linux.Platforms {
+		if !strings.HasPrefix(p, "linux_") {
+			t.Errorf("lib_linux.go: got platform %s", p)
+		}
+	}
+}
+
+func TestSource(t *testing.T) {
+	if err := bazel_testing.RunBazel("build", "--output_groups=package_metadata", "//:src"); err != nil {
+		t.Fatal(err)
+	}
+	md := readMetadata(t, "src.pkgmetadata.json")
+	if md.Name != "src" || len(md.Files) != 2 || md.Files[0].Package != "src" {
+		t.Fatalf("unexpected metadata: %#v", md)
+	}
+	if md.Files[0].Generated {
+		t.Errorf("src.go: marked as generated")
+	}
+	if gen := md.Files[1]; !strings.HasSuffix(gen.Path, "gen.go") || !gen.Generated {
+		t.Errorf("got %s with Generated %v; want gen.go marked as generated", gen.Path, gen.Generated)
+	}
+}
+
+func readMetadata(t *testing.T, name string) packageMetadata {
+	out, err := bazel_testing.BazelOutput("info", "bazel-bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(strings.TrimSpace(string(out)), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var md packageMetadata
+	if err := json.Unmarshal(data, &md); err != nil {
+		t.Fatal(err)
+	}
+	return md
+}
@@ -0,0 +1,155 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unused_deps_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "lib",
+    srcs = [
+        "lib.go",
+        "lib_windows.go",
+    ],
+    importpath = "example.com/lib",
+    deps = [
+        ":used",
+        ":unused",
+        ":windows",
+    ],
+)
+
+go_library(
+    name = "clean",
+    srcs = ["clean.go"],
+    importpath = "example.com/clean",
+    deps = [":used"],
+)
+
+go_library(
+    name = "used",
+    srcs = ["dep.go"],
+    importpath = "example.com/used",
+)
+
+go_library(
+    name = "unused",
+    srcs = ["dep.go"],
+    importpath = "example.com/unused",
+)
+
+go_library(
+    name = "windows",
+    srcs = ["dep.go"],
+    importpath = "example.com/windows",
+)
+
+-- lib.go --
+package lib
+
+import _ "example.com/used"
+
+-- lib_windows.go --
+package lib
+
+import _ "example.com/windows"
+
+-- clean.go --
+package clean
+
+import _ "example.com/used"
+
+-- dep.go --
+package dep
+`,
+	})
+}
+
+type unusedDepsReport struct {
+	Label      string
+	UnusedDeps []string
+}
+
+func TestOff(t *testing.T) {
+	if err := bazel_testing.RunBazel("build", "//:lib"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWarn(t *testing.T) {
+	if err := bazel_testing.RunBazel("build", "--@io_bazel_rules_go//go/config:unused_deps=warn", "--output_groups=unused_deps", "//:lib", "//:clean"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := readReport(t, "lib.unused_deps.json").UnusedDeps, []string{"//:unused"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("lib: got unused deps %q; want %q", got, want)
+	}
+	if got := readReport(t, "clean.unused_deps.json").UnusedDeps; len(got) != 0 {
+		t.Errorf("clean: got unused deps %q; want none", got)
+	}
+}
+
+func TestError(t *testing.T) {
+	err := bazel_testing.RunBazel("build", "--@io_bazel_rules_go//go/config:unused_deps=error", "//:lib")
+	if err == nil {
+		t.Fatal("build succeeded; want failure")
+	}
+	if want := "buildozer 'remove deps //:unused' //:lib"; !strings.Contains(err.Error(), want) {
+		t.Errorf("error does not contain %q:\n%v", want, err)
+	}
+	if err := bazel_testing.RunBazel("build", "--@io_bazel_rules_go//go/config:unused_deps=error", "//:clean"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readReport(t *testing.T, name string) unusedDepsReport {
+	out, err := bazel_testing.BazelOutput("info", "bazel-bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reportPath string
+	filepath.Walk(strings.TrimSpace(string(out)), func(path string, info os.FileInfo, err error) error {
+		if err == nil && filepath.Base(path) == name {
+			reportPath = path
+		}
+		return nil
+	})
+	if reportPath == "" {
+		t.Fatalf("%s not found", name)
+	}
+	data, err := ioutil.ReadFile(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report unusedDepsReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
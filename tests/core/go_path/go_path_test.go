@@ -29,7 +29,8 @@ import (
 	"github.com/bazelbuild/rules_go/go/tools/bazel"
 )
 
-var copyPath, linkPath, archivePath, nodataPath string
+var copyPath, linkPath, materializePath, archivePath, nodataPath string
+var generatedCopyPath, generatedLinkPath string
 
 var defaultMode = runtime.GOOS + "_" + runtime.GOARCH
 
@@ -51,13 +52,25 @@ var files = []string{
 	"pkg/" + defaultMode + "/example.com/repo/pkg/lib.a",
 	"pkg/" + defaultMode + "/example.com/repo/vendor/example.com/repo2.a",
 	"pkg/plan9_arm/example.com/repo/cmd/bin.a",
+	"-src/example.com/repo/pkg/lib/_cgo/",
+}
+
+var generatedFiles = []string{
+	"src/example.com/repo/pkg/gen/gen.go",
+	"src/example.com/repo/pkg/gen/static/hello.txt",
+	"src/example.com/repo/pkg/lib/lib.go",
+	"src/example.com/repo/pkg/lib/_cgo/_cgo_gotypes.go",
+	"src/example.com/repo/pkg/lib/_cgo/lib.cgo1.go",
 }
 
 func TestMain(m *testing.M) {
 	flag.StringVar(&copyPath, "copy_path", "", "path to copied go_path")
 	flag.StringVar(&linkPath, "link_path", "", "path to symlinked go_path")
+	flag.StringVar(&materializePath, "materialize_path", "", "path to materialized go_path")
 	flag.StringVar(&archivePath, "archive_path", "", "path to archive go_path")
 	flag.StringVar(&nodataPath, "nodata_path", "", "path to go_path without data")
+	flag.StringVar(&generatedCopyPath, "generated_copy_path", "", "path to copied go_path with generated files")
+	flag.StringVar(&generatedLinkPath, "generated_link_path", "", "path to symlinked go_path with generated files")
 	flag.Parse()
 	os.Exit(m.Run())
 }
@@ -76,6 +89,14 @@ func TestLinkPath(t *testing.T) {
 	checkPath(t, linkPath, files)
 }
 
+func TestMaterializePath(t *testing.T) {
+	if materializePath == "" {
+		t.Fatal("-materialize_path not set")
+	}
+	checkPath(t, materializePath, files)
+	checkMaterialized(t, materializePath)
+}
+
 func TestArchivePath(t *testing.T) {
 	if archivePath == "" {
 		t.Fatal("-archive_path not set")
@@ -133,6 +154,17 @@ func TestNoDataPath(t *testing.T) {
 	checkPath(t, nodataPath, files)
 }
 
+func TestGeneratedPath(t *testing.T) {
+	if generatedCopyPath == "" {
+		t.Fatal("-generated_copy_path not set")
+	}
+	if generatedLinkPath == "" {
+		t.Fatal("-generated_link_path not set")
+	}
+	checkPath(t, generatedCopyPath, generatedFiles)
+	checkPath(t, generatedLinkPath, generatedFiles)
+}
+
 // checkPath checks that dir contains a list of files. files is a list of
 // slash-separated paths relative to dir. Files that start with "-" should be
 // absent. Files that end with "/" should be directories.
@@ -173,3 +205,29 @@ func checkPath(t *testing.T, dir string, files []string) {
 		}
 	}
 }
+
+// checkMaterialized checks that the files in dir are not links to files
+// outside of dir. Files in runfiles are always links, so each file is
+// resolved and checked to be in a directory named like dir.
+func checkMaterialized(t *testing.T, dir string) {
+	if strings.HasPrefix(dir, "external") {
+		dir = filepath.Join(os.Getenv("TEST_SRCDIR"), strings.TrimPrefix(dir, "external/"))
+	}
+	want := string(filepath.Separator) + filepath.Base(dir) + string(filepath.Separator)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		resolved, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(resolved, want) {
+			t.Errorf("%s is a link to %s; wanted a regular file", path, resolved)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build go1.16
+// +build go1.16
+
+package gen
+
+import _ "embed"
+
+//go:embed static/hello.txt
+var Hello string
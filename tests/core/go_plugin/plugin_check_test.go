@@ -0,0 +1,135 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin_check_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_binary", "go_library")
+
+go_binary(
+    name = "host",
+    srcs = ["host.go"],
+    plugins = [":plugin"],
+    deps = [":lib"],
+)
+
+go_binary(
+    name = "plugin",
+    srcs = ["plugin.go"],
+    linkmode = "plugin",
+    deps = [":lib"],
+)
+
+go_library(
+    name = "lib",
+    srcs = ["lib.go"],
+    importpath = "example.com/lib",
+)
+
+go_binary(
+    name = "host_other_lib",
+    srcs = ["host.go"],
+    plugins = [":plugin"],
+    deps = [":other_lib"],
+)
+
+go_library(
+    name = "other_lib",
+    srcs = ["other_lib.go"],
+    importpath = "example.com/lib",
+)
+
+go_binary(
+    name = "host_tagged_plugin",
+    srcs = ["host.go"],
+    plugins = [":tagged_plugin"],
+    deps = [":lib"],
+)
+
+go_binary(
+    name = "tagged_plugin",
+    srcs = ["plugin.go"],
+    gotags = ["extra"],
+    linkmode = "plugin",
+    deps = [":lib"],
+)
+
+-- host.go --
+package main
+
+import "example.com/lib"
+
+func main() { println(lib.Name) }
+
+-- plugin.go --
+package main
+
+import "example.com/lib"
+
+func Name() string { return lib.Name }
+
+func main() {}
+
+-- lib.go --
+package lib
+
+const Name = "lib"
+
+-- other_lib.go --
+package lib
+
+const Name = "other_lib"
+`,
+	})
+}
+
+func TestCompatible(t *testing.T) {
+	if err := bazel_testing.RunBazel("build", "//:host"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMismatch(t *testing.T) {
+	for _, test := range []struct {
+		target, want string
+	}{
+		{
+			target: "//:host_other_lib",
+			want:   "plugin //:plugin is built with a different version of package example.com/lib",
+		}, {
+			target: "//:host_tagged_plugin",
+			want:   "plugin //:tagged_plugin is built in a different configuration",
+		},
+	} {
+		t.Run(strings.TrimPrefix(test.target, "//:"), func(t *testing.T) {
+			err := bazel_testing.RunBazel("build", "--nobuild", test.target)
+			if err == nil {
+				t.Fatalf("%s: analysis succeeded; want error", test.target)
+			}
+			if !strings.Contains(err.Error(), test.want) {
+				t.Errorf("%s: error does not contain %q:\n%v", test.target, test.want, err)
+			}
+		})
+	}
+}
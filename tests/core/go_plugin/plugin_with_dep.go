@@ -0,0 +1,7 @@
+package main
+
+import "github.com/bazelbuild/rules_go/tests/core/go_plugin/shared_dep"
+
+func Incr() int { return shared_dep.Incr() }
+
+func main() {}
@@ -0,0 +1,10 @@
+package shared_dep
+
+var count int
+
+// Incr increments a counter and returns its new value. The plugin and the
+// binary loading it share this package, so they share the counter.
+func Incr() int {
+	count++
+	return count
+}
@@ -0,0 +1,27 @@
+package shared_dep_test
+
+import (
+	"plugin"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/tests/core/go_plugin/shared_dep"
+)
+
+func TestSharedDep(t *testing.T) {
+	p, err := plugin.Open("plugin_with_dep.so")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := p.Lookup("Incr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	incr := f.(func() int)
+
+	if got := shared_dep.Incr(); got != 1 {
+		t.Errorf("shared_dep.Incr() from the test: got %d; want 1", got)
+	}
+	if got := incr(); got != 2 {
+		t.Errorf("Incr() from the plugin: got %d; want 2", got)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package go_source_sdk_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_test")
+
+go_test(
+    name = "version_test",
+    srcs = ["version_test.go"],
+)
+
+-- version_test.go --
+package version_test
+
+import (
+	"flag"
+	"runtime"
+	"testing"
+)
+
+var want = flag.String("version", "", "")
+
+func Test(t *testing.T) {
+	if v := runtime.Version(); v != *want {
+		t.Errorf("got version %q; want %q", v, *want)
+	}
+}
+`,
+	})
+}
+
+// A built archive is used so the test doesn't spend minutes in make.bash.
+const sdk = `
+load("@io_bazel_rules_go//go:deps.bzl", "go_source_sdk")
+
+go_source_sdk(
+    name = "go_sdk",
+    urls = ["https://dl.google.com/go/go1.14.2.linux-amd64.tar.gz"],
+    sha256 = "6272d6e940ecb71ea5636ddb5fab3933e087c1356173c61f4a803895e947ebb3",
+    version = "1.14.2",
+)
+
+go_rules_dependencies()
+
+go_register_toolchains()
+`
+
+func TestBuiltArchive(t *testing.T) {
+	if runtime.GOOS != "linux" || runtime.GOARCH != "amd64" {
+		t.Skipf("the archive is for linux_amd64, not %s_%s", runtime.GOOS, runtime.GOARCH)
+	}
+	restore := replaceSDK(t, sdk)
+	defer restore()
+
+	if err := bazel_testing.RunBazel("test", "//:version_test", "--test_arg=-version=go1.14.2"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// The source archive has a VERSION file, which is deleted so the rule has to
+// write one before running make.bash.
+const sourceArchive = `
+load("@bazel_tools//tools/build_defs/repo:http.bzl", "http_archive")
+
+http_archive(
+    name = "go_src",
+    urls = ["https://dl.google.com/go/go1.14.2.src.tar.gz"],
+    strip_prefix = "go",
+    patch_cmds = ["rm VERSION"],
+    build_file_content = 'filegroup(name = "files", srcs = glob(["**"]))',
+)
+`
+
+const sourceSDK = `
+load("@io_bazel_rules_go//go:deps.bzl", "go_source_sdk")
+
+go_source_sdk(
+    name = "go_sdk",
+    path = "%s",
+    version = "1.14.2",
+    goroot_bootstrap = "%s",
+)
+
+go_rules_dependencies()
+
+go_register_toolchains()
+`
+
+func TestMakeBash(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("go_source_sdk doesn't build Go from source on Windows")
+	}
+	bootstrap, err := bootstrapGOROOT()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fetch the source first, so go_source_sdk can copy it from a path.
+	restore := replaceSDK(t, sourceArchive+"\ngo_rules_dependencies()\n")
+	defer restore()
+	if err := bazel_testing.RunBazel("fetch", "@go_src//:files"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := bazel_testing.BazelOutput("info", "output_base")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srcPath := filepath.ToSlash(filepath.Join(strings.TrimSpace(string(out)), "external", "go_src"))
+
+	// sourceArchive is kept, since it's before go_rules_dependencies() now.
+	replaceSDK(t, fmt.Sprintf(sourceSDK, srcPath, bootstrap))
+	if err := bazel_testing.RunBazel("test", "//:version_test", "--test_arg=-version=go1.14.2"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// bootstrapGOROOT returns the absolute path of the SDK the test workspace
+// was configured with, which make.bash can bootstrap from. It returns an
+// empty string if the workspace uses the host SDK, which go_source_sdk finds
+// on its own.
+func bootstrapGOROOT() (string, error) {
+	workspaceData, err := ioutil.ReadFile("WORKSPACE")
+	if err != nil {
+		return "", err
+	}
+	m := regexp.MustCompile(`name = "local_go_sdk",\s*path = "([^"]*)"`).FindSubmatch(workspaceData)
+	if m == nil {
+		return "", nil
+	}
+	path, err := filepath.Abs(filepath.FromSlash(string(m[1])))
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(path), nil
+}
+
+// replaceSDK replaces the part of WORKSPACE that declares the Go SDK and
+// toolchains with sdk. It returns a function that restores the original.
+func replaceSDK(t *testing.T, sdk string) func() {
+	origWorkspaceData, err := ioutil.ReadFile("WORKSPACE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := bytes.Index(origWorkspaceData, []byte("go_rules_dependencies()"))
+	if i < 0 {
+		t.Fatal("could not find call to go_rules_dependencies()")
+	}
+	workspaceData := append(origWorkspaceData[:i:i], sdk...)
+	if err := ioutil.WriteFile("WORKSPACE", workspaceData, 0666); err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		if err := ioutil.WriteFile("WORKSPACE", origWorkspaceData, 0666); err != nil {
+			t.Errorf("error restoring WORKSPACE: %v", err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package benchmark_test
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_benchmark")
+
+go_benchmark(
+    name = "bench",
+    srcs = ["bench_test.go"],
+    benchtime = "10x",
+    count = 2,
+)
+
+go_benchmark(
+    name = "faster_than_baseline",
+    srcs = ["bench_test.go"],
+    baseline = "slow_baseline.txt",
+    benchtime = "10x",
+)
+
+go_benchmark(
+    name = "slower_than_baseline",
+    srcs = ["bench_test.go"],
+    baseline = "fast_baseline.txt",
+    benchtime = "10x",
+    threshold = 50,
+)
+
+-- bench_test.go --
+package bench
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNotRun(t *testing.T) {
+	t.Fatal("tests should not run")
+}
+
+func BenchmarkSleep(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+-- slow_baseline.txt --
+BenchmarkSleep-64   	      10	1000000000 ns/op
+
+-- fast_baseline.txt --
+BenchmarkSleep-8   	      10	      1000 ns/op
+`,
+	})
+}
+
+func TestResults(t *testing.T) {
+	if err := bazel_testing.RunBazel("test", "//:bench"); err != nil {
+		t.Fatal(err)
+	}
+	results := readBenchmarkOutput(t, "bench")
+	if n := strings.Count(results, "BenchmarkSleep"); n != 2 {
+		t.Errorf("got %d BenchmarkSleep results; want 2:\n%s", n, results)
+	}
+	if strings.Contains(results, "PASS") {
+		t.Errorf("results contain test output:\n%s", results)
+	}
+}
+
+func TestBaseline(t *testing.T) {
+	if err := bazel_testing.RunBazel("test", "//:faster_than_baseline"); err != nil {
+		t.Fatal(err)
+	}
+	err := bazel_testing.RunBazel("test", "//:slower_than_baseline")
+	if err == nil {
+		t.Fatal("//:slower_than_baseline passed; want regression failure")
+	} else if xerr, ok := err.(*bazel_testing.StderrExitError); !ok || xerr.Err.ExitCode() != 3 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// readBenchmarkOutput returns benchmark.txt from the undeclared outputs of
+// a test, which Bazel may have zipped.
+func readBenchmarkOutput(t *testing.T, target string) string {
+	out, err := bazel_testing.BazelOutput("info", "bazel-testlogs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputsDir := filepath.Join(strings.TrimSpace(string(out)), target, "test.outputs")
+	if data, err := ioutil.ReadFile(filepath.Join(outputsDir, "benchmark.txt")); err == nil {
+		return string(data)
+	} else if !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	r, err := zip.OpenReader(filepath.Join(outputsDir, "outputs.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if f.Name != "benchmark.txt" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		data, err := ioutil.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	t.Fatal("benchmark.txt not found in test outputs")
+	return ""
+}
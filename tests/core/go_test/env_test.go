@@ -0,0 +1,42 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package env_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnv(t *testing.T) {
+	if got, want := os.Getenv("GREETING"), "hello"; got != want {
+		t.Errorf("GREETING: got %q; want %q", got, want)
+	}
+
+	dataPath := os.Getenv("DATA_PATH")
+	if want := "tests/core/go_test/x"; dataPath != want {
+		t.Errorf("DATA_PATH: got %q; want %q", dataPath, want)
+	}
+	runfilesPath := filepath.Join(os.Getenv("TEST_SRCDIR"), os.Getenv("TEST_WORKSPACE"), filepath.FromSlash(dataPath))
+	if _, err := os.Stat(runfilesPath); err != nil {
+		t.Errorf("DATA_PATH does not refer to a file in runfiles: %v", err)
+	}
+
+	switch mode := os.Getenv("COMPILATION_MODE"); mode {
+	case "fastbuild", "dbg", "opt":
+	default:
+		t.Errorf("COMPILATION_MODE: got %q; want a compilation mode", mode)
+	}
+}
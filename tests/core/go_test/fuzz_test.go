@@ -0,0 +1,163 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuzz_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"runtime"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_fuzz_test", "go_library", "go_test")
+
+go_library(
+    name = "reverse",
+    srcs = ["reverse.go"],
+    importpath = "example.com/reverse",
+)
+
+go_fuzz_test(
+    name = "reverse_fuzz_test",
+    srcs = ["reverse_test.go"],
+    embed = [":reverse"],
+    fuzz = "FuzzReverse",
+    fuzztime = "200x",
+)
+
+go_fuzz_test(
+    name = "seed_fuzz_test",
+    srcs = ["seed_test.go"],
+    fuzz = "FuzzSeed",
+    fuzztime = "1x",
+)
+
+go_test(
+    name = "seed_test",
+    srcs = ["seed_test.go"],
+    data = glob(["testdata/fuzz/**"]),
+)
+
+-- reverse.go --
+package reverse
+
+func Reverse(s string) string {
+	b := []byte(s)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
+
+-- reverse_test.go --
+package reverse
+
+import "testing"
+
+func FuzzReverse(f *testing.F) {
+	f.Add("abc")
+	f.Fuzz(func(t *testing.T, s string) {
+		if got := Reverse(Reverse(s)); got != s {
+			t.Errorf("Reverse(Reverse(%q)) = %q", s, got)
+		}
+	})
+}
+
+-- seed_test.go --
+package seed
+
+import "testing"
+
+func FuzzSeed(f *testing.F) {
+	f.Fuzz(func(t *testing.T, s string) {
+		if s == "boom" {
+			t.Fatal("found the seed")
+		}
+	})
+}
+
+-- testdata/fuzz/FuzzSeed/boom --
+go test fuzz v1
+string("boom")
+`,
+	})
+}
+
+// Fuzz targets need go1.18 or later, which is newer than the SDK the rest of
+// the tests use.
+const go118 = `
+load("@io_bazel_rules_go//go:deps.bzl", "go_download_sdk")
+
+go_download_sdk(
+    name = "go_sdk",
+    # Checksums are omitted, so Bazel only warns about them.
+    sdks = {
+        "darwin_amd64": ("go1.18.darwin-amd64.tar.gz", ""),
+        "linux_amd64": ("go1.18.linux-amd64.tar.gz", ""),
+    },
+)
+
+go_rules_dependencies()
+
+go_register_toolchains()
+`
+
+func Test(t *testing.T) {
+	if runtime.GOARCH != "amd64" || (runtime.GOOS != "linux" && runtime.GOOS != "darwin") {
+		t.Skipf("no go1.18 SDK configured for %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	origWorkspaceData, err := ioutil.ReadFile("WORKSPACE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := bytes.Index(origWorkspaceData, []byte("go_rules_dependencies()"))
+	if i < 0 {
+		t.Fatal("could not find call to go_rules_dependencies()")
+	}
+	workspaceData := append(origWorkspaceData[:i:i], go118...)
+	if err := ioutil.WriteFile("WORKSPACE", workspaceData, 0666); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := ioutil.WriteFile("WORKSPACE", origWorkspaceData, 0666); err != nil {
+			t.Errorf("error restoring WORKSPACE: %v", err)
+		}
+	}()
+
+	t.Run("fuzz", func(t *testing.T) {
+		if err := bazel_testing.RunBazel("test", "//:reverse_fuzz_test"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("seed", func(t *testing.T) {
+		// The checked-in seed makes the fuzz target fail, whether or not the
+		// test fuzzes.
+		for _, target := range []string{"//:seed_fuzz_test", "//:seed_test"} {
+			err := bazel_testing.RunBazel("test", target)
+			if err == nil {
+				t.Errorf("%s passed; want failure from seed input", target)
+			} else if xerr, ok := err.(*bazel_testing.StderrExitError); !ok || xerr.Err.ExitCode() != 3 {
+				t.Errorf("%s: unexpected error: %v", target, err)
+			}
+		}
+	})
+}
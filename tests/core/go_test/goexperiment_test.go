@@ -0,0 +1,197 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goexperiment_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_binary", "go_test")
+
+go_test(
+    name = "experiment_test",
+    srcs = [
+        "experiment_off_test.go",
+        "experiment_on_test.go",
+        "experiment_test.go",
+    ],
+)
+
+go_test(
+    name = "godebug_test",
+    srcs = ["godebug_test.go"],
+)
+
+go_test(
+    name = "godebug_env_test",
+    srcs = ["godebug_test.go"],
+    env = {"GODEBUG": "madvdontneed=0"},
+)
+
+go_binary(
+    name = "godebug_bin",
+    srcs = ["godebug_bin.go"],
+)
+
+go_binary(
+    name = "godebug_env_bin",
+    srcs = ["godebug_bin.go"],
+    env = {"GODEBUG": "madvdontneed=0"},
+)
+
+-- godebug_bin.go --
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	fmt.Println(os.Getenv("GODEBUG"))
+}
+
+-- experiment_on_test.go --
+// +build goexperiment.fieldtrack
+
+package experiment_test
+
+const fieldtrack = true
+
+-- experiment_off_test.go --
+// +build !goexperiment.fieldtrack
+
+package experiment_test
+
+const fieldtrack = false
+
+-- experiment_test.go --
+package experiment_test
+
+import (
+	"flag"
+	"testing"
+)
+
+var want = flag.Bool("fieldtrack", false, "")
+
+func Test(t *testing.T) {
+	if fieldtrack != *want {
+		t.Errorf("goexperiment.fieldtrack tag is %v; want %v", fieldtrack, *want)
+	}
+}
+
+-- godebug_test.go --
+package godebug_test
+
+import (
+	"flag"
+	"os"
+	"testing"
+)
+
+var want = flag.String("godebug", "", "")
+
+func Test(t *testing.T) {
+	if got := os.Getenv("GODEBUG"); got != *want {
+		t.Errorf("GODEBUG is %q; want %q", got, *want)
+	}
+}
+`,
+	})
+}
+
+func Test(t *testing.T) {
+	for _, test := range []struct {
+		desc string
+		args []string
+	}{
+		{
+			desc: "no_experiment",
+			args: []string{"//:experiment_test", "--test_arg=-fieldtrack=false"},
+		}, {
+			desc: "experiment",
+			args: []string{
+				"//:experiment_test",
+				"--@io_bazel_rules_go//go/config:goexperiment=fieldtrack",
+				"--test_arg=-fieldtrack=true",
+			},
+		}, {
+			desc: "disabled_experiment",
+			args: []string{
+				"//:experiment_test",
+				"--@io_bazel_rules_go//go/config:goexperiment=nofieldtrack",
+				"--test_arg=-fieldtrack=false",
+			},
+		}, {
+			desc: "no_godebug",
+			args: []string{"//:godebug_test", "--test_arg=-godebug="},
+		}, {
+			desc: "godebug",
+			args: []string{
+				"//:godebug_test",
+				"--@io_bazel_rules_go//go/config:godebug=madvdontneed=1,gctrace=0",
+				"--test_arg=-godebug=madvdontneed=1,gctrace=0",
+			},
+		}, {
+			desc: "godebug_env",
+			args: []string{
+				"//:godebug_env_test",
+				"--@io_bazel_rules_go//go/config:godebug=madvdontneed=1",
+				"--test_arg=-godebug=madvdontneed=0",
+			},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			args := append([]string{"test"}, test.args...)
+			if err := bazel_testing.RunBazel(args...); err != nil {
+				t.Fatalf("bazel %s: %v", strings.Join(args, " "), err)
+			}
+		})
+	}
+}
+
+func TestBinaryGODEBUG(t *testing.T) {
+	for _, test := range []struct {
+		desc, target, want string
+	}{
+		{
+			desc:   "godebug",
+			target: "//:godebug_bin",
+			want:   "madvdontneed=1,gctrace=0",
+		}, {
+			desc:   "godebug_env",
+			target: "//:godebug_env_bin",
+			want:   "madvdontneed=0",
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			out, err := bazel_testing.BazelOutput("run", "--@io_bazel_rules_go//go/config:godebug=madvdontneed=1,gctrace=0", test.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(string(out)); got != test.want {
+				t.Errorf("got GODEBUG %q; want %q", got, test.want)
+			}
+		})
+	}
+}
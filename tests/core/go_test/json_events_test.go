@@ -0,0 +1,181 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json_events_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_test")
+
+go_test(
+    name = "events_test",
+    srcs = ["events_test.go"],
+    importpath = "example.com/events",
+    json_events = True,
+)
+
+go_test(
+    name = "env_test",
+    srcs = ["events_test.go"],
+    importpath = "example.com/events",
+)
+
+-- events_test.go --
+package events
+
+import "testing"
+
+func TestPass(t *testing.T) {}
+
+func TestLog(t *testing.T) {
+	t.Log("hello from TestLog")
+}
+`,
+	})
+}
+
+type event struct {
+	Action  string
+	Package string
+	Test    string
+	Elapsed *float64
+	Output  string
+}
+
+func TestAttr(t *testing.T) {
+	if err := bazel_testing.RunBazel("test", "//:events_test"); err != nil {
+		t.Fatal(err)
+	}
+	checkEvents(t, readEvents(t, "events_test"))
+}
+
+func TestEnv(t *testing.T) {
+	if err := bazel_testing.RunBazel("test", "//:env_test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := readOutput(t, "env_test"); ok {
+		t.Error("test_events.json was written without json_events or GO_TEST_JSON")
+	}
+	if err := bazel_testing.RunBazel("test", "--test_env=GO_TEST_JSON=1", "//:env_test"); err != nil {
+		t.Fatal(err)
+	}
+	checkEvents(t, readEvents(t, "env_test"))
+}
+
+func checkEvents(t *testing.T, events []event) {
+	passed := make(map[string]bool)
+	var logged, pkgPassed bool
+	for _, e := range events {
+		if e.Package != "example.com/events" {
+			t.Errorf("got event for package %q; want example.com/events", e.Package)
+		}
+		switch e.Action {
+		case "pass":
+			if e.Elapsed == nil {
+				t.Errorf("pass event for %q has no elapsed time", e.Test)
+			}
+			if e.Test == "" {
+				pkgPassed = true
+			} else {
+				passed[e.Test] = true
+			}
+		case "output":
+			if e.Test == "TestLog" && strings.Contains(e.Output, "hello from TestLog") {
+				logged = true
+			}
+		}
+	}
+	if !passed["TestPass"] || !passed["TestLog"] {
+		t.Errorf("got pass events for %v; want TestPass and TestLog", passed)
+	}
+	if !logged {
+		t.Error("output of TestLog not found")
+	}
+	if !pkgPassed {
+		t.Error("pass event for the package not found")
+	}
+}
+
+func readEvents(t *testing.T, target string) []event {
+	data, ok := readOutput(t, target)
+	if !ok {
+		t.Fatal("test_events.json not found in test outputs")
+	}
+	var events []event
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var e event
+		if err := dec.Decode(&e); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+// readOutput returns test_events.json from the undeclared outputs of a test,
+// which Bazel may have zipped.
+func readOutput(t *testing.T, target string) ([]byte, bool) {
+	out, err := bazel_testing.BazelOutput("info", "bazel-testlogs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputsDir := filepath.Join(strings.TrimSpace(string(out)), target, "test.outputs")
+	if data, err := ioutil.ReadFile(filepath.Join(outputsDir, "test_events.json")); err == nil {
+		return data, true
+	} else if !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	r, err := zip.OpenReader(filepath.Join(outputsDir, "outputs.zip"))
+	if os.IsNotExist(err) {
+		return nil, false
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if f.Name != "test_events.json" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		data, err := ioutil.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data, true
+	}
+	return nil, false
+}
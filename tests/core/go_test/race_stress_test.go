@@ -0,0 +1,116 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package race_stress_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_test")
+
+go_test(
+    name = "count_test",
+    srcs = ["count_test.go"],
+    race_stress = 3,
+)
+
+go_test(
+    name = "racy_test",
+    srcs = ["racy_test.go"],
+    race_stress = 2,
+)
+
+go_test(
+    name = "no_race_test",
+    srcs = ["count_test.go"],
+    race = "off",
+    race_stress = 2,
+)
+
+-- count_test.go --
+package count
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestRuns(t *testing.T) {
+	t.Logf("run with GOMAXPROCS=%d", runtime.GOMAXPROCS(0))
+}
+
+-- racy_test.go --
+package racy
+
+import "testing"
+
+var shared int
+
+func TestRace(t *testing.T) {
+	done := make(chan bool)
+	go func() {
+		shared++
+		done <- true
+	}()
+	shared++
+	<-done
+}
+`,
+	})
+}
+
+func TestCount(t *testing.T) {
+	out, err := bazel_testing.BazelOutput("test", "--test_output=all", "--test_env=GO_TEST_WRAP_TESTV=1", "//:count_test")
+	if err != nil {
+		t.Fatalf("count_test failed: %v\n%s", err, out)
+	}
+	for _, procs := range []string{"1", "2", "4"} {
+		want := []byte("run with GOMAXPROCS=" + procs + "\n")
+		if n := bytes.Count(out, want); n != 3 {
+			t.Errorf("got %d runs with GOMAXPROCS=%s; want 3", n, procs)
+		}
+	}
+	if !bytes.Contains(out, []byte("race stress: no data races found")) {
+		t.Errorf("race stress summary not found:\n%s", out)
+	}
+}
+
+func TestRace(t *testing.T) {
+	out, err := bazel_testing.BazelOutput("test", "--test_output=errors", "//:racy_test")
+	if err == nil {
+		t.Fatal("racy_test passed; want a data race")
+	}
+	if !bytes.Contains(out, []byte("race stress: found 1 distinct data races")) {
+		t.Errorf("race stress summary not found:\n%s", out)
+	}
+}
+
+func TestRequiresRace(t *testing.T) {
+	err := bazel_testing.RunBazel("build", "//:no_race_test")
+	if err == nil {
+		t.Fatal("no_race_test built without the race detector")
+	}
+	if !strings.Contains(err.Error(), "race_stress requires the race detector") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rerun_test
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_test")
+
+go_test(
+    name = "flaky_test",
+    srcs = ["flaky_test.go"],
+    reruns = 2,
+)
+
+go_test(
+    name = "flaky_define_test",
+    srcs = ["flaky_test.go"],
+)
+
+go_test(
+    name = "broken_test",
+    srcs = ["broken_test.go"],
+    reruns = 2,
+)
+
+-- flaky_test.go --
+package flaky
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFlaky(t *testing.T) {
+	marker := filepath.Join(os.Getenv("TEST_TMPDIR"), "ran")
+	if _, err := os.Stat(marker); os.IsNotExist(err) {
+		ioutil.WriteFile(marker, nil, 0666)
+		t.Fatal("fails on the first attempt")
+	}
+}
+
+func TestPass(t *testing.T) {}
+
+-- broken_test.go --
+package broken
+
+import "testing"
+
+func TestBroken(t *testing.T) {
+	t.Fatal("always fails")
+}
+`,
+	})
+}
+
+type xmlMessage struct {
+	Contents string `xml:",chardata"`
+}
+
+type xmlTestCase struct {
+	Name          string       `xml:"name,attr"`
+	Failure       *xmlMessage  `xml:"failure"`
+	FlakyFailures []xmlMessage `xml:"flakyFailure"`
+	RerunFailures []xmlMessage `xml:"rerunFailure"`
+}
+
+type xmlTestSuites struct {
+	Suites []struct {
+		TestCases []xmlTestCase `xml:"testcase"`
+	} `xml:"testsuite"`
+}
+
+func readTestCases(t *testing.T, target string) map[string]xmlTestCase {
+	out, err := bazel_testing.BazelOutput("info", "bazel-testlogs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(strings.TrimSpace(string(out)), target, "test.xml")
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var suites xmlTestSuites
+	if err := xml.Unmarshal(data, &suites); err != nil {
+		t.Fatal(err)
+	}
+	cases := make(map[string]xmlTestCase)
+	for _, s := range suites.Suites {
+		for _, c := range s.TestCases {
+			cases[c.Name] = c
+		}
+	}
+	return cases
+}
+
+func TestFlakyAttr(t *testing.T) {
+	if err := bazel_testing.RunBazel("test", "--test_env=GO_TEST_WRAP_TESTV=1", "//:flaky_test"); err != nil {
+		t.Fatal(err)
+	}
+	cases := readTestCases(t, "flaky_test")
+	if c := cases["TestFlaky"]; c.Failure != nil || len(c.FlakyFailures) != 1 || !strings.Contains(c.FlakyFailures[0].Contents, "fails on the first attempt") {
+		t.Errorf("TestFlaky: got %+v; want one flaky failure", c)
+	}
+	if c := cases["TestPass"]; c.Failure != nil || len(c.FlakyFailures) != 0 {
+		t.Errorf("TestPass: got %+v; want a pass", c)
+	}
+}
+
+func TestFlakyDefine(t *testing.T) {
+	if err := bazel_testing.RunBazel("test", "//:flaky_define_test"); err == nil {
+		t.Fatal("flaky test passed without reruns")
+	}
+	if err := bazel_testing.RunBazel("test", "--define=gotest_reruns=1", "//:flaky_define_test"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBroken(t *testing.T) {
+	if err := bazel_testing.RunBazel("test", "//:broken_test"); err == nil {
+		t.Fatal("broken test passed")
+	}
+	c := readTestCases(t, "broken_test")["TestBroken"]
+	if c.Failure == nil || len(c.RerunFailures) != 2 {
+		t.Errorf("TestBroken: got %+v; want a failure and two rerun failures", c)
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sandbox_checks_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_test")
+
+go_test(
+    name = "remote_test",
+    srcs = ["remote_test.go"],
+    sandbox_checks = ["network"],
+)
+
+go_test(
+    name = "lookup_test",
+    srcs = ["lookup_test.go"],
+    sandbox_checks = ["network"],
+)
+
+go_test(
+    name = "loopback_test",
+    srcs = ["loopback_test.go"],
+    sandbox_checks = ["network"],
+)
+
+go_test(
+    name = "write_test",
+    srcs = ["write_test.go"],
+    sandbox_checks = ["filesystem"],
+)
+
+go_test(
+    name = "write_tmp_test",
+    srcs = ["write_tmp_test.go"],
+    sandbox_checks = ["filesystem"],
+)
+
+go_test(
+    name = "unchecked_write_test",
+    srcs = ["write_test.go"],
+)
+
+go_test(
+    name = "unknown_check_test",
+    srcs = ["write_tmp_test.go"],
+    sandbox_checks = ["disk"],
+    tags = ["manual"],
+)
+
+-- remote_test.go --
+package remote_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRemote(t *testing.T) {
+	// The error is ignored, as tests with an offline fallback do.
+	if resp, err := http.Get("http://203.0.113.1/"); err == nil {
+		resp.Body.Close()
+	}
+}
+
+-- lookup_test.go --
+package lookup_test
+
+import (
+	"net"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	net.LookupHost("example.com")
+}
+
+-- loopback_test.go --
+package loopback_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+-- write_test.go --
+package write_test
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	// The test runs in its runfiles directory.
+	if err := ioutil.WriteFile("golden.txt", []byte("updated"), 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+-- write_tmp_test.go --
+package write_tmp_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteTmp(t *testing.T) {
+	for _, dir := range []string{os.Getenv("TEST_TMPDIR"), os.TempDir()} {
+		if err := ioutil.WriteFile(filepath.Join(dir, "scratch.txt"), nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+`,
+	})
+}
+
+func Test(t *testing.T) {
+	for _, test := range []struct {
+		target   string
+		wantLogs []string
+	}{
+		{
+			target:   "//:remote_test",
+			wantLogs: []string{"test is not hermetic", "blocked tcp connection to 203.0.113.1:80"},
+		}, {
+			target:   "//:lookup_test",
+			wantLogs: []string{"test is not hermetic", "blocked DNS lookup"},
+		}, {
+			target: "//:loopback_test",
+		}, {
+			target:   "//:write_test",
+			wantLogs: []string{"test is not hermetic", "golden.txt in the runfiles tree"},
+		}, {
+			target: "//:write_tmp_test",
+		}, {
+			target: "//:unchecked_write_test",
+		},
+	} {
+		t.Run(strings.TrimPrefix(test.target, "//:"), func(t *testing.T) {
+			err := bazel_testing.RunBazel("test", test.target)
+			if len(test.wantLogs) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("test passed; want it to fail")
+			}
+			logPath := filepath.FromSlash("bazel-testlogs/" + strings.TrimPrefix(test.target, "//:") + "/test.log")
+			log, err := ioutil.ReadFile(logPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range test.wantLogs {
+				if !bytes.Contains(log, []byte(want)) {
+					t.Errorf("%s does not contain %q:\n%s", logPath, want, log)
+				}
+			}
+		})
+	}
+
+	t.Run("unknown_check", func(t *testing.T) {
+		cmd := bazel_testing.BazelCmd("build", "//:unknown_check_test")
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err == nil {
+			t.Fatal("build succeeded; want an error for the unknown check")
+		}
+		if want := `unknown sandbox check "disk"`; !strings.Contains(stderr.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, &stderr)
+		}
+	})
+}
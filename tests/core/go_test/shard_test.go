@@ -0,0 +1,99 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shard_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_test")
+
+go_test(
+    name = "shard_test",
+    srcs = ["shard_test.go"],
+    args = ["-test.v"],
+    shard_count = 3,
+)
+
+-- shard_test.go --
+package shard
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestA(t *testing.T) {}
+
+func TestB(t *testing.T) {}
+
+func TestC(t *testing.T) {}
+
+func TestD(t *testing.T) {}
+
+func ExampleE() {
+	fmt.Println("E")
+	// Output: E
+}
+`,
+	})
+}
+
+func Test(t *testing.T) {
+	if err := bazel_testing.RunBazel("test", "//:shard_test"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := bazel_testing.BazelOutput("info", "bazel-testlogs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testlogs := strings.TrimSpace(string(out))
+
+	// Each test and example should run in exactly one shard, and each shard
+	// should run something.
+	runs := map[string]int{}
+	for i := 1; i <= 3; i++ {
+		logPath := filepath.Join(testlogs, "shard_test", fmt.Sprintf("shard_%d_of_3", i), "test.log")
+		data, err := ioutil.ReadFile(logPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.HasPrefix(line, "=== RUN   ") {
+				runs[strings.TrimPrefix(line, "=== RUN   ")]++
+				n++
+			}
+		}
+		if n == 0 {
+			t.Errorf("shard %d of 3 ran no tests:\n%s", i, data)
+		}
+	}
+	for _, name := range []string{"TestA", "TestB", "TestC", "TestD", "ExampleE"} {
+		if runs[name] != 1 {
+			t.Errorf("%s ran in %d shards; want 1", name, runs[name])
+		}
+	}
+}
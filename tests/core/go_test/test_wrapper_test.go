@@ -0,0 +1,125 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test_wrapper_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_binary", "go_test")
+
+go_binary(
+    name = "wrapper",
+    srcs = ["wrapper.go"],
+)
+
+go_test(
+    name = "pass_test",
+    srcs = ["pass_test.go"],
+    args = ["-wrapped"],
+    test_wrapper = ":wrapper",
+)
+
+go_test(
+    name = "ignore_test",
+    srcs = ["fail_test.go"],
+    env = {"WRAPPER_IGNORE_FAILURE": "1"},
+    test_wrapper = ":wrapper",
+)
+
+go_test(
+    name = "fail_test",
+    srcs = ["fail_test.go"],
+    test_wrapper = ":wrapper",
+)
+
+-- wrapper.go --
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+func main() {
+	cmd := exec.Command(os.Args[1], os.Args[2:]...)
+	cmd.Env = append(os.Environ(), "WRAPPED_BY=wrapper")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	fmt.Println("wrapper: ran", os.Args[1])
+	if err != nil && os.Getenv("WRAPPER_IGNORE_FAILURE") == "" {
+		os.Exit(1)
+	}
+}
+
+-- pass_test.go --
+package pass
+
+import (
+	"flag"
+	"os"
+	"testing"
+)
+
+var wrapped = flag.Bool("wrapped", false, "")
+
+func TestWrapped(t *testing.T) {
+	if got := os.Getenv("WRAPPED_BY"); got != "wrapper" {
+		t.Errorf("WRAPPED_BY = %q; want wrapper", got)
+	}
+	if !*wrapped {
+		t.Error("-wrapped was not forwarded to the test binary")
+	}
+}
+
+-- fail_test.go --
+package fail
+
+import "testing"
+
+func TestFail(t *testing.T) {
+	t.Fatal("failed")
+}
+`,
+	})
+}
+
+func TestWrapper(t *testing.T) {
+	out, err := bazel_testing.BazelOutput("test", "--test_output=all", "//:pass_test")
+	if err != nil {
+		t.Fatalf("pass_test failed: %v\n%s", err, out)
+	}
+	if !bytes.Contains(out, []byte("wrapper: ran")) {
+		t.Errorf("wrapper output not found:\n%s", out)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	if err := bazel_testing.RunBazel("test", "//:ignore_test"); err != nil {
+		t.Errorf("ignore_test failed, but its wrapper ignores failures: %v", err)
+	}
+	if err := bazel_testing.RunBazel("test", "//:fail_test"); err == nil {
+		t.Error("fail_test passed, but its wrapper reports failures")
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timeout_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_test")
+
+go_test(
+    name = "go_timeout_test",
+    srcs = ["hang_test.go"],
+    test_timeout = "2s",
+)
+
+go_test(
+    name = "bazel_timeout_test",
+    srcs = ["hang_test.go"],
+)
+
+-- hang_test.go --
+package hang
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHang(t *testing.T) {
+	time.Sleep(time.Hour)
+}
+`,
+	})
+}
+
+func TestGoTimeout(t *testing.T) {
+	out, err := bazel_testing.BazelOutput("test", "--test_output=errors", "//:go_timeout_test")
+	if err == nil {
+		t.Fatal("go_timeout_test passed; want a timeout")
+	}
+	if !bytes.Contains(out, []byte("panic: test timed out after 2s")) {
+		t.Errorf("timeout panic not found:\n%s", out)
+	}
+
+	testlogs, err := bazel_testing.BazelOutput("info", "bazel-testlogs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputsZip := filepath.Join(strings.TrimSpace(string(testlogs)), "go_timeout_test", "test.outputs", "outputs.zip")
+	r, err := zip.OpenReader(outputsZip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if f.Name != "goroutines.txt" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		stacks, err := ioutil.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(stacks, []byte("panic: test timed out after 2s")) || !bytes.Contains(stacks, []byte("hang.TestHang")) {
+			t.Errorf("unexpected goroutines.txt:\n%s", stacks)
+		}
+		return
+	}
+	t.Errorf("goroutines.txt not found in %s", outputsZip)
+}
+
+func TestBazelTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SIGQUIT is not supported on Windows")
+	}
+	out, err := bazel_testing.BazelOutput("test", "--test_output=errors", "--test_timeout=5", "//:bazel_timeout_test")
+	if err == nil {
+		t.Fatal("bazel_timeout_test passed; want a timeout")
+	}
+	for _, want := range []string{"SIGQUIT: quit", "hang.TestHang"} {
+		if !bytes.Contains(out, []byte(want)) {
+			t.Errorf("%q not found in test log:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analyze_tests_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+const origAnalyzeTests = `# analyze_tests = False,`
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Nogo: "@//:nogo",
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test", "go_tool_library", "nogo")
+
+nogo(
+    name = "nogo",
+    deps = [":noprint"],
+    # analyze_tests = False,
+    visibility = ["//visibility:public"],
+)
+
+go_tool_library(
+    name = "noprint",
+    srcs = ["noprint.go"],
+    importpath = "noprint",
+    deps = ["@org_golang_x_tools//go/analysis:go_tool_library"],
+    visibility = ["//visibility:public"],
+)
+
+go_library(
+    name = "lib",
+    srcs = ["lib.go"],
+    importpath = "lib",
+)
+
+go_test(
+    name = "lib_test",
+    srcs = [
+        "internal_test.go",
+        "external_test.go",
+    ],
+    embed = [":lib"],
+)
+
+-- noprint.go --
+package noprint
+
+import (
+	"go/ast"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name: "noprint",
+	Doc:  "reports calls to print",
+	Run:  run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	for _, f := range pass.Files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "print" {
+					pass.Reportf(call.Pos(), "call to print")
+				}
+			}
+			return true
+		})
+	}
+	return nil, nil
+}
+
+-- lib.go --
+package lib
+
+func Lib() {}
+
+-- internal_test.go --
+package lib
+
+import "testing"
+
+func TestInternal(t *testing.T) {
+	print("internal")
+}
+
+-- external_test.go --
+package lib_test
+
+import (
+	"testing"
+
+	"lib"
+)
+
+func TestExternal(t *testing.T) {
+	lib.Lib()
+	print("external")
+}
+`,
+	})
+}
+
+func TestAnalyzeTests(t *testing.T) {
+	if err := bazel_testing.RunBazel("build", "//:lib"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("internal", func(t *testing.T) {
+		err := bazel_testing.RunBazel("build", "//:lib_test")
+		if err == nil {
+			t.Fatal("unexpected success")
+		}
+		if !strings.Contains(err.Error(), "internal_test.go") {
+			t.Errorf("error did not mention finding in internal_test.go: %v", err)
+		}
+	})
+
+	t.Run("external", func(t *testing.T) {
+		// The external test package can only be compiled after the internal
+		// one passes.
+		if err := replaceInFile("internal_test.go", `print("internal")`, ""); err != nil {
+			t.Fatal(err)
+		}
+		defer replaceInFile("internal_test.go", "\t\n}", "\tprint(\"internal\")\n}")
+		err := bazel_testing.RunBazel("build", "//:lib_test")
+		if err == nil {
+			t.Fatal("unexpected success")
+		}
+		if !strings.Contains(err.Error(), "external_test.go") {
+			t.Errorf("error did not mention finding in external_test.go: %v", err)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		if err := replaceInFile("BUILD.bazel", origAnalyzeTests, "analyze_tests = False,"); err != nil {
+			t.Fatal(err)
+		}
+		defer replaceInFile("BUILD.bazel", "analyze_tests = False,", origAnalyzeTests)
+		if err := bazel_testing.RunBazel("build", "//:lib_test"); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func replaceInFile(path, old, new string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	data = bytes.ReplaceAll(data, []byte(old), []byte(new))
+	return ioutil.WriteFile(path, data, 0666)
+}
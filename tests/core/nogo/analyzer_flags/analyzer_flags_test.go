@@ -0,0 +1,143 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analyzer_flags_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Nogo: "@//:nogo",
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_tool_library", "nogo")
+
+nogo(
+    name = "nogo",
+    deps = [":nocall"],
+    config = "config.json",
+    visibility = ["//visibility:public"],
+)
+
+go_tool_library(
+    name = "nocall",
+    srcs = ["nocall.go"],
+    importpath = "nocall",
+    deps = ["@org_golang_x_tools//go/analysis:go_tool_library"],
+    visibility = ["//visibility:public"],
+)
+
+go_library(
+    name = "lib",
+    srcs = ["lib.go"],
+    importpath = "lib",
+)
+
+-- config.json --
+{
+  "nocall": {
+    "analyzer_flags": {
+      "funcs": "println"
+    }
+  }
+}
+
+-- nocall.go --
+package nocall
+
+import (
+	"go/ast"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name: "nocall",
+	Doc:  "reports calls to the functions named by -funcs",
+	Run:  run,
+}
+
+var funcs string
+
+func init() {
+	Analyzer.Flags.StringVar(&funcs, "funcs", "print", "comma-separated list of functions that must not be called")
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	banned := make(map[string]bool)
+	for _, name := range strings.Split(funcs, ",") {
+		banned[name] = true
+	}
+	for _, f := range pass.Files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				if id, ok := call.Fun.(*ast.Ident); ok && banned[id.Name] {
+					pass.Reportf(call.Pos(), "call to %s", id.Name)
+				}
+			}
+			return true
+		})
+	}
+	return nil, nil
+}
+
+-- lib.go --
+package lib
+
+func Lib() {
+	print("allowed")
+	println("banned")
+}
+`,
+	})
+}
+
+func TestAnalyzerFlags(t *testing.T) {
+	err := bazel_testing.RunBazel("build", "//:lib")
+	if err == nil {
+		t.Fatal("unexpected success")
+	}
+	if msg := err.Error(); !strings.Contains(msg, "call to println") {
+		t.Errorf("error did not mention call to println: %v", err)
+	} else if strings.Contains(msg, "call to print\n") {
+		t.Errorf("error mentioned call to print, which should be allowed: %v", err)
+	}
+}
+
+func TestUnknownFlag(t *testing.T) {
+	data, err := ioutil.ReadFile("config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ioutil.WriteFile("config.json", data, 0666)
+	if err := ioutil.WriteFile("config.json", bytes.Replace(data, []byte(`"funcs"`), []byte(`"bogus"`), 1), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	err = bazel_testing.RunBazel("build", "//:lib")
+	if err == nil {
+		t.Fatal("unexpected success")
+	}
+	if !strings.Contains(err.Error(), `has no flag "bogus"`) {
+		t.Errorf("error did not mention unknown flag: %v", err)
+	}
+}
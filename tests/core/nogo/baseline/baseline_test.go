@@ -0,0 +1,174 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package baseline_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+const origBaseline = `# baseline = "",`
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Nogo: "@//:nogo",
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_tool_library", "nogo")
+
+nogo(
+    name = "nogo",
+    deps = [":noprint"],
+    # baseline = "",
+    visibility = ["//visibility:public"],
+)
+
+go_tool_library(
+    name = "noprint",
+    srcs = ["noprint.go"],
+    importpath = "noprint",
+    deps = ["@org_golang_x_tools//go/analysis:go_tool_library"],
+    visibility = ["//visibility:public"],
+)
+
+go_library(
+    name = "legacy",
+    srcs = ["legacy.go"],
+    importpath = "legacy",
+)
+
+go_library(
+    name = "other",
+    srcs = ["other.go"],
+    importpath = "other",
+)
+
+-- noprint.go --
+package noprint
+
+import (
+	"go/ast"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name: "noprint",
+	Doc:  "reports calls to print",
+	Run:  run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	for _, f := range pass.Files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "print" {
+					pass.Reportf(call.Pos(), "call to print")
+				}
+			}
+			return true
+		})
+	}
+	return nil, nil
+}
+
+-- legacy.go --
+package legacy
+
+func Legacy() {
+	print("legacy")
+}
+-- other.go --
+package other
+
+func Other() {
+	print("other")
+}
+`,
+	})
+}
+
+func TestBaseline(t *testing.T) {
+	if err := bazel_testing.RunBazel("build", "//:legacy"); err == nil {
+		t.Fatal("unexpected success building without a baseline")
+	}
+
+	// The report for //:other is left in bazel-bin, but it's not part of the
+	// build write_baseline runs, so its finding must not be collected.
+	if err := bazel_testing.RunBazel("build", "--@io_bazel_rules_go//go/config:nogo_write_baseline", "--output_groups=nogo_sarif", "//:other"); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	baselinePath := filepath.Join(wd, "baseline.json")
+	if err := bazel_testing.RunBazel("run", "@io_bazel_rules_go//go/tools/nogo:write_baseline", "--", "-o", baselinePath, "//:legacy"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(baselinePath); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Contains(data, []byte("legacy.go")) {
+		t.Fatalf("baseline does not contain finding:\n%s", data)
+	} else if bytes.Contains(data, []byte("other.go")) {
+		t.Fatalf("baseline contains a finding from a target that wasn't built:\n%s", data)
+	}
+	if err := replaceInFile("BUILD.bazel", origBaseline, `baseline = "baseline.json",`); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("baselined", func(t *testing.T) {
+		if err := bazel_testing.RunBazel("build", "//:legacy"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("moved", func(t *testing.T) {
+		if err := replaceInFile("legacy.go", "func Legacy() {", "// Legacy is old.\nfunc Legacy() {\n\n"); err != nil {
+			t.Fatal(err)
+		}
+		if err := bazel_testing.RunBazel("build", "//:legacy"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("new", func(t *testing.T) {
+		if err := replaceInFile("legacy.go", `print("legacy")`, "print(\"legacy\")\n\tprint(\"new\")"); err != nil {
+			t.Fatal(err)
+		}
+		err := bazel_testing.RunBazel("build", "//:legacy")
+		if err == nil {
+			t.Fatal("unexpected success")
+		}
+		if !strings.Contains(err.Error(), "call to print") {
+			t.Errorf("error did not mention finding: %v", err)
+		}
+	})
+}
+
+func replaceInFile(path, old, new string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	data = bytes.ReplaceAll(data, []byte(old), []byte(new))
+	return ioutil.WriteFile(path, data, 0666)
+}
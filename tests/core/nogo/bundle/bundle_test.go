@@ -0,0 +1,233 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bundle_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Nogo: "@//:nogo",
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_tool_library", "nogo", "nogo_analyzer_bundle")
+
+nogo(
+    name = "nogo",
+    deps = [
+        ":builtins_bundle",
+        ":facts_bundle",
+    ],
+    visibility = ["//visibility:public"],
+)
+
+nogo_analyzer_bundle(
+    name = "builtins_bundle",
+    library = ":builtins",
+    analyzers = ["noprint"],
+)
+
+nogo_analyzer_bundle(
+    name = "facts_bundle",
+    library = ":facts",
+    variable = "Checks",
+)
+
+go_tool_library(
+    name = "builtins",
+    srcs = ["builtins.go"],
+    importpath = "builtins",
+    deps = ["@org_golang_x_tools//go/analysis:go_tool_library"],
+)
+
+go_tool_library(
+    name = "facts",
+    srcs = ["facts.go"],
+    importpath = "facts",
+    deps = ["@org_golang_x_tools//go/analysis:go_tool_library"],
+)
+
+go_library(
+    name = "has_print",
+    srcs = ["has_print.go"],
+    importpath = "hasprint",
+)
+
+go_library(
+    name = "has_panic",
+    srcs = ["has_panic.go"],
+    importpath = "haspanic",
+)
+
+go_library(
+    name = "bad",
+    srcs = ["bad.go"],
+    importpath = "bad",
+)
+
+go_library(
+    name = "calls_bad",
+    srcs = ["calls_bad.go"],
+    importpath = "callsbad",
+    deps = [":bad"],
+)
+
+-- builtins.go --
+package builtins
+
+import (
+	"go/ast"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+var Analyzers = map[string]*analysis.Analyzer{
+	"noprint": newAnalyzer("print"),
+	"nopanic": newAnalyzer("panic"),
+}
+
+func newAnalyzer(builtin string) *analysis.Analyzer {
+	return &analysis.Analyzer{
+		Name: "no" + builtin,
+		Doc:  "reports calls to " + builtin,
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			for _, f := range pass.Files {
+				ast.Inspect(f, func(n ast.Node) bool {
+					if call, ok := n.(*ast.CallExpr); ok {
+						if id, ok := call.Fun.(*ast.Ident); ok && id.Name == builtin {
+							pass.Reportf(call.Pos(), "call to %s", builtin)
+						}
+					}
+					return true
+				})
+			}
+			return nil, nil
+		},
+	}
+}
+
+-- facts.go --
+package facts
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+type Check struct {
+	Analyzer *analysis.Analyzer
+	Doc      string
+}
+
+var Checks = []*Check{{Analyzer: Analyzer, Doc: "reports calls to bad functions"}}
+
+// isBad is a fact exported for functions named Bad. Facts are encoded with
+// gob, so they need an exported field.
+type isBad struct{ Name string }
+
+func (*isBad) AFact() {}
+
+var Analyzer = &analysis.Analyzer{
+	Name:      "nobad",
+	Doc:       "reports calls to functions named Bad in other packages",
+	Run:       run,
+	FactTypes: []analysis.Fact{new(isBad)},
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if obj, ok := pass.Pkg.Scope().Lookup("Bad").(*types.Func); ok {
+		pass.ExportObjectFact(obj, &isBad{Name: obj.Name()})
+	}
+	for _, f := range pass.Files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			sel, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			if fn, ok := pass.TypesInfo.Uses[sel.Sel].(*types.Func); ok && pass.ImportObjectFact(fn, new(isBad)) {
+				pass.Reportf(sel.Pos(), "call to bad function %s", fn.FullName())
+			}
+			return true
+		})
+	}
+	return nil, nil
+}
+
+-- has_print.go --
+package hasprint
+
+func HasPrint() {
+	print("hi")
+}
+
+-- has_panic.go --
+package haspanic
+
+func HasPanic() {
+	panic("hi")
+}
+
+-- bad.go --
+package bad
+
+func Bad() {}
+
+-- calls_bad.go --
+package callsbad
+
+import "bad"
+
+func CallsBad() {
+	bad.Bad()
+}
+`,
+	})
+}
+
+func TestBundle(t *testing.T) {
+	for _, test := range []struct {
+		target, want string
+	}{
+		{target: "//:has_print", want: "call to print"},
+		{target: "//:has_panic"},
+		{target: "//:bad"},
+		{target: "//:calls_bad", want: "call to bad function bad.Bad"},
+	} {
+		t.Run(test.target, func(t *testing.T) {
+			cmd := bazel_testing.BazelCmd("build", test.target)
+			stderr := &bytes.Buffer{}
+			cmd.Stderr = stderr
+			err := cmd.Run()
+			if test.want == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v\n%s", err, stderr.Bytes())
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("unexpected success")
+			}
+			if !bytes.Contains(stderr.Bytes(), []byte(test.want)) {
+				t.Errorf("did not find %q in output:\n%s", test.want, stderr.Bytes())
+			}
+		})
+	}
+}
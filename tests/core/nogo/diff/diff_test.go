@@ -0,0 +1,208 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Nogo: "@//:nogo",
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_tool_library", "nogo")
+
+nogo(
+    name = "nogo",
+    deps = [":noprint"],
+    visibility = ["//visibility:public"],
+)
+
+go_tool_library(
+    name = "noprint",
+    srcs = ["noprint.go"],
+    importpath = "noprint",
+    deps = ["@org_golang_x_tools//go/analysis:go_tool_library"],
+    visibility = ["//visibility:public"],
+)
+
+go_library(
+    name = "lib",
+    srcs = ["lib.go"],
+    importpath = "lib",
+)
+
+-- noprint.go --
+package noprint
+
+import (
+	"go/ast"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name: "noprint",
+	Doc:  "reports calls to print",
+	Run:  run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	for _, f := range pass.Files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "print" {
+					pass.Reportf(call.Pos(), "call to print")
+				}
+			}
+			return true
+		})
+	}
+	return nil, nil
+}
+
+-- lib.go --
+package lib
+
+func Lib() {
+	print("old")
+	print("new")
+}
+
+func Other() {}
+
+-- second_line.diff --
+diff --git a/lib.go b/lib.go
+--- a/lib.go
++++ b/lib.go
+@@ -3,3 +3,4 @@ package lib
+ func Lib() {
+ 	print("old")
++	print("new")
+ }
+
+-- no_findings.diff --
+--- a/lib.go
++++ b/lib.go
+@@ -7,1 +7,2 @@
+ 
++func Other() {}
+
+-- first_line.txt --
+lib.go:4
+
+-- whole_file.txt --
+# every line in lib.go changed
+lib.go
+`,
+	})
+}
+
+func TestDiff(t *testing.T) {
+	for _, test := range []struct {
+		diff               string
+		includes, excludes []string
+	}{
+		{
+			diff:     "second_line.diff",
+			includes: []string{"lib.go:5:2: call to print"},
+			excludes: []string{"lib.go:4:2"},
+		}, {
+			diff: "no_findings.diff",
+		}, {
+			diff:     "first_line.txt",
+			includes: []string{"lib.go:4:2: call to print"},
+			excludes: []string{"lib.go:5:2"},
+		}, {
+			diff:     "whole_file.txt",
+			includes: []string{"lib.go:4:2: call to print", "lib.go:5:2: call to print"},
+		},
+	} {
+		t.Run(test.diff, func(t *testing.T) {
+			cmd := bazel_testing.BazelCmd("build", "--@io_bazel_rules_go//go/config:nogo_diff=//:"+test.diff, "//:lib")
+			stderr := &bytes.Buffer{}
+			cmd.Stderr = stderr
+			err := cmd.Run()
+			if len(test.includes) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v\n%s", err, stderr.Bytes())
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("unexpected success")
+			}
+			for _, want := range test.includes {
+				if !bytes.Contains(stderr.Bytes(), []byte(want)) {
+					t.Errorf("did not find %q in output:\n%s", want, stderr.Bytes())
+				}
+			}
+			for _, notWant := range test.excludes {
+				if bytes.Contains(stderr.Bytes(), []byte(notWant)) {
+					t.Errorf("found %q in output:\n%s", notWant, stderr.Bytes())
+				}
+			}
+		})
+	}
+}
+
+func TestDiffOnlyRerunsValidation(t *testing.T) {
+	if err := bazel_testing.RunBazel("build", "--@io_bazel_rules_go//go/config:nogo_diff=//:no_findings.diff", "//:lib"); err != nil {
+		t.Fatal(err)
+	}
+
+	logFile, err := ioutil.TempFile("", "exec_log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logPath := logFile.Name()
+	logFile.Close()
+	defer os.Remove(logPath)
+	if err := bazel_testing.RunBazel("build", "--execution_log_json_file="+logPath, "--@io_bazel_rules_go//go/config:nogo_diff=//:whole_file.txt", "//:lib"); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	// Only the action that checks findings should run again.
+	data, err := ioutil.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var spawn struct {
+			ListedOutputs []string
+		}
+		if err := dec.Decode(&spawn); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		for _, out := range spawn.ListedOutputs {
+			if !strings.HasSuffix(out, ".nogo_validation") {
+				t.Errorf("changing nogo_diff reran an action that wrote %s", filepath.Base(out))
+			}
+		}
+	}
+}
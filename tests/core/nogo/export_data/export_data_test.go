@@ -0,0 +1,212 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export_data_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Nogo: "@//:nogo",
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_tool_library", "nogo")
+
+nogo(
+    name = "nogo",
+    deps = [":noprint"],
+    visibility = ["//visibility:public"],
+)
+
+go_tool_library(
+    name = "noprint",
+    srcs = ["noprint.go"],
+    importpath = "noprint",
+    deps = ["@org_golang_x_tools//go/analysis:go_tool_library"],
+    visibility = ["//visibility:public"],
+)
+
+go_library(
+    name = "lib",
+    srcs = ["lib.go"],
+    importpath = "lib",
+    deps = [":dep"],
+)
+
+go_library(
+    name = "dep",
+    srcs = ["dep.go"],
+    importpath = "dep",
+)
+
+-- noprint.go --
+package noprint
+
+import (
+	"go/ast"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name: "noprint",
+	Doc:  "reports calls to print",
+	Run:  run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	for _, f := range pass.Files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "print" {
+					pass.Reportf(call.Pos(), "call to print")
+				}
+			}
+			return true
+		})
+	}
+	return nil, nil
+}
+
+-- lib.go --
+package lib
+
+import "dep"
+
+func Lib() int {
+	return dep.Dep()
+}
+
+-- dep.go --
+package dep
+
+//go:noinline
+func Dep() int {
+	return 1
+}
+`,
+	})
+}
+
+func TestExportData(t *testing.T) {
+	if _, err := buildAndListNogoOutputs(t); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("body", func(t *testing.T) {
+		// Dep is the last declaration in the file, so adding lines to its body
+		// doesn't move any declaration.
+		if err := replaceInFile("dep.go", "return 1", "x := 1\n\tx++\n\treturn x"); err != nil {
+			t.Fatal(err)
+		}
+		outputs, err := buildAndListNogoOutputs(t)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !outputs["dep.x"] {
+			t.Errorf("nogo did not run on dep; ran for %v", outputs)
+		}
+		if outputs["lib.x"] {
+			t.Error("nogo ran on lib after a change that did not affect the export data of dep")
+		}
+	})
+
+	t.Run("position", func(t *testing.T) {
+		// Export data records the positions of declarations, so moving Dep to
+		// another line changes it.
+		if err := replaceInFile("dep.go", "package dep\n", "package dep\n\n// Moves Dep down.\n"); err != nil {
+			t.Fatal(err)
+		}
+		outputs, err := buildAndListNogoOutputs(t)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !outputs["lib.x"] {
+			t.Errorf("nogo did not run on lib after a declaration in dep moved; ran for %v", outputs)
+		}
+	})
+
+	t.Run("api", func(t *testing.T) {
+		if err := replaceInFile("dep.go", "func Dep() int {", "func Dep() int {\n\treturn Other()\n}\n\nfunc Other() int {"); err != nil {
+			t.Fatal(err)
+		}
+		outputs, err := buildAndListNogoOutputs(t)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !outputs["lib.x"] {
+			t.Errorf("nogo did not run on lib after the API of dep changed; ran for %v", outputs)
+		}
+	})
+}
+
+// buildAndListNogoOutputs builds //:lib and returns the base names of the
+// facts files written by actions that were executed, as opposed to being
+// found in the action cache.
+func buildAndListNogoOutputs(t *testing.T) (map[string]bool, error) {
+	logFile, err := ioutil.TempFile("", "exec_log")
+	if err != nil {
+		return nil, err
+	}
+	logPath := logFile.Name()
+	logFile.Close()
+	defer os.Remove(logPath)
+
+	if err := bazel_testing.RunBazel("build", "--execution_log_json_file="+logPath, "//:lib"); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(logPath)
+	if err != nil {
+		return nil, err
+	}
+	outputs := make(map[string]bool)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var spawn struct {
+			ListedOutputs []string
+		}
+		if err := dec.Decode(&spawn); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		for _, out := range spawn.ListedOutputs {
+			if strings.HasSuffix(out, ".x") {
+				outputs[filepath.Base(out)] = true
+			}
+		}
+	}
+	t.Logf("nogo outputs written: %v", outputs)
+	return outputs, nil
+}
+
+func replaceInFile(path, old, new string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	data = bytes.ReplaceAll(data, []byte(old), []byte(new))
+	return ioutil.WriteFile(path, data, 0666)
+}
@@ -0,0 +1,252 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findings_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Nogo: "@//:nogo",
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_tool_library", "nogo")
+
+nogo(
+    name = "nogo",
+    deps = [
+        ":nopanic",
+        ":noprint",
+    ],
+    config = "config.json",
+    visibility = ["//visibility:public"],
+)
+
+go_tool_library(
+    name = "noprint",
+    srcs = ["noprint.go"],
+    importpath = "noprint",
+    deps = ["@org_golang_x_tools//go/analysis:go_tool_library"],
+    visibility = ["//visibility:public"],
+)
+
+go_tool_library(
+    name = "nopanic",
+    srcs = ["nopanic.go"],
+    importpath = "nopanic",
+    deps = ["@org_golang_x_tools//go/analysis:go_tool_library"],
+    visibility = ["//visibility:public"],
+)
+
+go_library(
+    name = "clean",
+    srcs = ["clean.go"],
+    importpath = "clean",
+)
+
+go_library(
+    name = "warns",
+    srcs = ["warns.go"],
+    importpath = "warns",
+)
+
+go_library(
+    name = "errs",
+    srcs = ["errs.go"],
+    importpath = "errs",
+)
+
+-- noprint.go --
+package noprint
+
+import (
+	"go/ast"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name: "noprint",
+	Doc:  "reports calls to print",
+	Run:  run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	for _, f := range pass.Files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "print" {
+					pass.Reportf(call.Pos(), "call to print")
+				}
+			}
+			return true
+		})
+	}
+	return nil, nil
+}
+
+-- nopanic.go --
+package nopanic
+
+import (
+	"go/ast"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name: "nopanic",
+	Doc:  "reports calls to panic",
+	Run:  run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	for _, f := range pass.Files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "panic" {
+					pass.Reportf(call.Pos(), "call to panic")
+				}
+			}
+			return true
+		})
+	}
+	return nil, nil
+}
+
+-- config.json --
+{
+  "noprint": {
+    "severity": "warning"
+  }
+}
+
+-- clean.go --
+package clean
+
+func Clean() {}
+
+-- warns.go --
+package warns
+
+func Warns() {
+	print("hi")
+}
+
+-- errs.go --
+package errs
+
+func Errs() {
+	print("hi")
+	panic("oops")
+}
+`,
+	})
+}
+
+type findingsFile struct {
+	Package  string
+	Findings []struct {
+		Analyzer, Severity, Message, File string
+		Line, Column                      int
+		Baselined                         bool
+		Fingerprint                       string
+	}
+}
+
+func TestFindings(t *testing.T) {
+	if err := bazel_testing.RunBazel("build", "--output_groups=nogo_findings", "//:clean", "//:warns"); err != nil {
+		t.Fatal(err)
+	}
+
+	clean, err := readFindings("bazel-bin/clean.nogo.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clean.Package != "clean" {
+		t.Errorf("got package %q; want %q", clean.Package, "clean")
+	}
+	if len(clean.Findings) != 0 {
+		t.Errorf("got findings %v for clean; want none", clean.Findings)
+	}
+
+	warns, err := readFindings("bazel-bin/warns.nogo.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warns.Findings) != 1 {
+		t.Fatalf("got findings %v for warns; want 1", warns.Findings)
+	}
+	f := warns.Findings[0]
+	if f.Analyzer != "noprint" || f.Severity != "warning" || f.Message != "call to print" {
+		t.Errorf("got analyzer %q, severity %q, message %q; want noprint, warning, call to print", f.Analyzer, f.Severity, f.Message)
+	}
+	if f.File != "warns.go" || f.Line != 4 || f.Column != 2 {
+		t.Errorf("got position %s:%d:%d; want warns.go:4:2", f.File, f.Line, f.Column)
+	}
+	if f.Baselined {
+		t.Error("finding is baselined; want not baselined")
+	}
+	if f.Fingerprint == "" {
+		t.Error("finding has no fingerprint")
+	}
+}
+
+func TestFindingsWithErrors(t *testing.T) {
+	// The error fails the build, but the findings file is still written.
+	err := bazel_testing.RunBazel("build", "--output_groups=nogo_findings,_validation", "//:errs")
+	if err == nil {
+		t.Fatal("build succeeded; want it to fail because of a nogo finding")
+	}
+	if !strings.Contains(err.Error(), "errs.go:5:2: call to panic") {
+		t.Errorf("build failed without reporting the finding:\n%v", err)
+	}
+
+	errs, err := readFindings("bazel-bin/errs.nogo.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs.Findings) != 2 {
+		t.Fatalf("got findings %v for errs; want 2", errs.Findings)
+	}
+	for i, want := range []struct {
+		analyzer, severity string
+		line               int
+	}{
+		{"noprint", "warning", 4},
+		{"nopanic", "error", 5},
+	} {
+		f := errs.Findings[i]
+		if f.Analyzer != want.analyzer || f.Severity != want.severity || f.File != "errs.go" || f.Line != want.line {
+			t.Errorf("got finding %s %s at %s:%d; want %s %s at errs.go:%d", f.Analyzer, f.Severity, f.File, f.Line, want.analyzer, want.severity, want.line)
+		}
+	}
+}
+
+func readFindings(path string) (findingsFile, error) {
+	var ff findingsFile
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ff, err
+	}
+	err = json.Unmarshal(data, &ff)
+	return ff, err
+}
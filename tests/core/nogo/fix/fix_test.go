@@ -0,0 +1,154 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fix_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Nogo: "@//:nogo",
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_tool_library", "nogo")
+
+nogo(
+    name = "nogo",
+    deps = [":noprint"],
+    visibility = ["//visibility:public"],
+)
+
+go_tool_library(
+    name = "noprint",
+    srcs = ["noprint.go"],
+    importpath = "noprint",
+    deps = ["@org_golang_x_tools//go/analysis:go_tool_library"],
+    visibility = ["//visibility:public"],
+)
+
+go_library(
+    name = "lib",
+    srcs = ["lib.go"],
+    importpath = "lib",
+)
+
+-- noprint.go --
+package noprint
+
+import (
+	"go/ast"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name: "noprint",
+	Doc:  "reports calls to print",
+	Run:  run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	for _, f := range pass.Files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "print" {
+					pass.Report(analysis.Diagnostic{
+						Pos:     call.Pos(),
+						Message: "call to print",
+						SuggestedFixes: []analysis.SuggestedFix{{
+							Message:   "use println",
+							TextEdits: []analysis.TextEdit{{Pos: id.Pos(), End: id.End(), NewText: []byte("println")}},
+						}},
+					})
+				}
+			}
+			return true
+		})
+	}
+	return nil, nil
+}
+
+-- lib.go --
+package lib
+
+func Lib() {
+	print("lib")
+}
+`,
+	})
+}
+
+func TestFixInReport(t *testing.T) {
+	if err := bazel_testing.RunBazel("build", "--@io_bazel_rules_go//go/config:nogo_write_baseline", "--output_groups=nogo_sarif", "//:lib"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile("bazel-bin/lib.nogo.sarif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log struct {
+		Runs []struct {
+			Results []struct {
+				Fixes []struct {
+					Description     struct{ Text string }
+					ArtifactChanges []struct {
+						ArtifactLocation struct{ URI string }
+						Replacements     []struct {
+							DeletedRegion   struct{ ByteOffset, ByteLength int }
+							InsertedContent struct{ Text string }
+						}
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatal(err)
+	}
+	if len(log.Runs) != 1 || len(log.Runs[0].Results) != 1 {
+		t.Fatalf("want exactly one result in report:\n%s", data)
+	}
+	fixes := log.Runs[0].Results[0].Fixes
+	if len(fixes) != 1 || len(fixes[0].ArtifactChanges) != 1 || len(fixes[0].ArtifactChanges[0].Replacements) != 1 {
+		t.Fatalf("want exactly one replacement in report:\n%s", data)
+	}
+	if got, want := fixes[0].Description.Text, "use println"; got != want {
+		t.Errorf("got description %q; want %q", got, want)
+	}
+	change := fixes[0].ArtifactChanges[0]
+	if got, want := change.ArtifactLocation.URI, "lib.go"; got != want {
+		t.Errorf("got uri %q; want %q", got, want)
+	}
+	src, err := ioutil.ReadFile("lib.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := change.Replacements[0]
+	if got, want := r.DeletedRegion.ByteOffset, strings.Index(string(src), "print("); got != want {
+		t.Errorf("got byte offset %d; want %d", got, want)
+	}
+	if got, want := r.DeletedRegion.ByteLength, len("print"); got != want {
+		t.Errorf("got byte length %d; want %d", got, want)
+	}
+	if got, want := r.InsertedContent.Text, "println"; got != want {
+		t.Errorf("got inserted text %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generated_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Nogo: "@//:nogo",
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_tool_library", "nogo")
+
+nogo(
+    name = "nogo",
+    deps = [":noprint"],
+    config = "config.json",
+    visibility = ["//visibility:public"],
+)
+
+go_tool_library(
+    name = "noprint",
+    srcs = ["noprint.go"],
+    importpath = "noprint",
+    deps = ["@org_golang_x_tools//go/analysis:go_tool_library"],
+    visibility = ["//visibility:public"],
+)
+
+go_library(
+    name = "gen",
+    srcs = [
+        "lib.go",
+        ":gen_go",
+    ],
+    importpath = "gen",
+)
+
+genrule(
+    name = "gen_go",
+    outs = ["gen.go"],
+    cmd = "printf 'package gen\\n\\nfunc Gen() {\\n\\tprint(\"gen\")\\n}\\n' >$@",
+)
+
+go_library(
+    name = "hand",
+    srcs = ["hand.go"],
+    importpath = "hand",
+)
+
+-- config.json --
+{
+  "noprint": {
+    "generated_files": "exclude"
+  }
+}
+
+-- noprint.go --
+package noprint
+
+import (
+	"go/ast"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name: "noprint",
+	Doc:  "reports calls to print",
+	Run:  run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	for _, f := range pass.Files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "print" {
+					pass.Reportf(call.Pos(), "call to print")
+				}
+			}
+			return true
+		})
+	}
+	return nil, nil
+}
+
+-- lib.go --
+package gen
+
+-- hand.go --
+package hand
+
+func Hand() {
+	print("hand")
+}
+`,
+	})
+}
+
+func TestExclude(t *testing.T) {
+	if err := bazel_testing.RunBazel("build", "//:gen"); err != nil {
+		t.Fatalf("finding in generated file was not excluded: %v", err)
+	}
+	err := bazel_testing.RunBazel("build", "//:hand")
+	if err == nil {
+		t.Fatal("unexpected success building //:hand")
+	}
+	if !strings.Contains(err.Error(), "call to print") {
+		t.Errorf("error did not mention finding: %v", err)
+	}
+}
+
+func TestWarning(t *testing.T) {
+	defer replaceInConfig(t, `"exclude"`, `"warning"`)()
+
+	cmd := bazel_testing.BazelCmd("build", "--output_groups=nogo_findings", "//:gen")
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, stderr)
+	}
+	if !strings.Contains(stderr.String(), "call to print") {
+		t.Errorf("warning was not printed:\n%s", stderr)
+	}
+
+	data, err := ioutil.ReadFile("bazel-bin/gen.nogo.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var findings struct {
+		Findings []struct {
+			Severity, File string
+			Generated      bool
+		}
+	}
+	if err := json.Unmarshal(data, &findings); err != nil {
+		t.Fatal(err)
+	}
+	if len(findings.Findings) != 1 {
+		t.Fatalf("got findings %v; want 1", findings.Findings)
+	}
+	if f := findings.Findings[0]; f.Severity != "warning" || !f.Generated || !strings.HasSuffix(f.File, "gen.go") {
+		t.Errorf("got finding %+v; want a generated warning in gen.go", f)
+	}
+}
+
+func TestReport(t *testing.T) {
+	defer replaceInConfig(t, `"exclude"`, `"report"`)()
+
+	err := bazel_testing.RunBazel("build", "//:gen")
+	if err == nil {
+		t.Fatal("unexpected success")
+	}
+	if !strings.Contains(err.Error(), "call to print") {
+		t.Errorf("error did not mention finding: %v", err)
+	}
+}
+
+// replaceInConfig replaces old with new in config.json and returns a function
+// that restores the original file.
+func replaceInConfig(t *testing.T, old, new string) func() {
+	data, err := ioutil.ReadFile("config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile("config.json", bytes.Replace(data, []byte(old), []byte(new), 1), 0666); err != nil {
+		t.Fatal(err)
+	}
+	return func() { ioutil.WriteFile("config.json", data, 0666) }
+}
@@ -0,0 +1,198 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sarif_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Nogo: "@//:nogo",
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_tool_library", "nogo")
+
+nogo(
+    name = "nogo",
+    deps = [":noprint"],
+    visibility = ["//visibility:public"],
+)
+
+go_tool_library(
+    name = "noprint",
+    srcs = ["noprint.go"],
+    importpath = "noprint",
+    deps = ["@org_golang_x_tools//go/analysis:go_tool_library"],
+    visibility = ["//visibility:public"],
+)
+
+go_library(
+    name = "clean",
+    srcs = ["clean.go"],
+    importpath = "clean",
+)
+
+go_library(
+    name = "dirty",
+    srcs = ["dirty.go"],
+    importpath = "dirty",
+)
+
+-- noprint.go --
+package noprint
+
+import (
+	"go/ast"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name: "noprint",
+	Doc:  "reports calls to print\n\nThe noprint analyzer reports calls to the builtin print function.",
+	Run:  run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	for _, f := range pass.Files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "print" {
+					pass.Reportf(call.Pos(), "call to print")
+				}
+			}
+			return true
+		})
+	}
+	return nil, nil
+}
+
+-- clean.go --
+package clean
+
+func Clean() {}
+
+-- dirty.go --
+package dirty
+
+func Dirty() {
+	print("dirty")
+}
+`,
+	})
+}
+
+func TestSARIF(t *testing.T) {
+	if err := bazel_testing.RunBazel("build", "--output_groups=nogo_sarif", "//:clean"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile("bazel-bin/clean.nogo.sarif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log struct {
+		Version string
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Name  string
+					Rules []struct {
+						ID               string
+						ShortDescription struct{ Text string }
+					}
+				}
+			}
+			Results []interface{}
+		}
+	}
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" {
+		t.Errorf("got version %q; want %q", log.Version, "2.1.0")
+	}
+	if len(log.Runs) != 1 {
+		t.Fatalf("got %d runs; want 1", len(log.Runs))
+	}
+	run := log.Runs[0]
+	if run.Tool.Driver.Name != "nogo" {
+		t.Errorf("got tool name %q; want %q", run.Tool.Driver.Name, "nogo")
+	}
+	if len(run.Tool.Driver.Rules) != 1 || run.Tool.Driver.Rules[0].ID != "noprint" {
+		t.Errorf("got rules %v; want only noprint", run.Tool.Driver.Rules)
+	} else if got, want := run.Tool.Driver.Rules[0].ShortDescription.Text, "reports calls to print"; got != want {
+		t.Errorf("got short description %q; want %q", got, want)
+	}
+	if len(run.Results) != 0 {
+		t.Errorf("got %d results; want 0", len(run.Results))
+	}
+}
+
+func TestSARIFWithFindings(t *testing.T) {
+	// The finding fails the build, but the report is still written.
+	err := bazel_testing.RunBazel("build", "--output_groups=nogo_sarif,_validation", "//:dirty")
+	if err == nil {
+		t.Fatal("build succeeded; want it to fail because of a nogo finding")
+	}
+	if !strings.Contains(err.Error(), "call to print") {
+		t.Errorf("build failed without reporting the finding:\n%v", err)
+	}
+	data, err := ioutil.ReadFile("bazel-bin/dirty.nogo.sarif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log struct {
+		Runs []struct {
+			Results []struct {
+				RuleID    string
+				Level     string
+				Message   struct{ Text string }
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct{ URI string }
+						Region           struct{ StartLine, StartColumn int }
+					}
+				}
+				PartialFingerprints map[string]string
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatal(err)
+	}
+	if len(log.Runs) != 1 || len(log.Runs[0].Results) != 1 {
+		t.Fatalf("got report:\n%s\nwant one run with one result", data)
+	}
+	r := log.Runs[0].Results[0]
+	if r.RuleID != "noprint" || r.Level != "error" || r.Message.Text != "call to print" {
+		t.Errorf("got rule %q, level %q, message %q; want noprint, error, call to print", r.RuleID, r.Level, r.Message.Text)
+	}
+	if len(r.Locations) != 1 {
+		t.Fatalf("got %d locations; want 1", len(r.Locations))
+	}
+	loc := r.Locations[0].PhysicalLocation
+	if loc.ArtifactLocation.URI != "dirty.go" || loc.Region.StartLine != 4 || loc.Region.StartColumn != 2 {
+		t.Errorf("got location %s:%d:%d; want dirty.go:4:2", loc.ArtifactLocation.URI, loc.Region.StartLine, loc.Region.StartColumn)
+	}
+	if r.PartialFingerprints["nogo/v1"] == "" {
+		t.Error("result has no nogo/v1 fingerprint")
+	}
+}
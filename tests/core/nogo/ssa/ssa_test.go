@@ -0,0 +1,224 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssa_test
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Nogo: "@//:nogo",
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_tool_library", "nogo")
+
+nogo(
+    name = "nogo",
+    deps = [
+        ":ssaprint",
+        ":ssaonce",
+    ],
+    visibility = ["//visibility:public"],
+)
+
+go_tool_library(
+    name = "counter",
+    srcs = ["counter.go"],
+    importpath = "counter",
+    deps = ["@org_golang_x_tools//go/analysis:go_tool_library"],
+)
+
+go_tool_library(
+    name = "ssaprint",
+    srcs = ["ssaprint.go"],
+    importpath = "ssaprint",
+    deps = [
+        ":counter",
+        "@org_golang_x_tools//go/analysis:go_tool_library",
+        "@org_golang_x_tools//go/analysis/passes/buildssa:go_tool_library",
+        "@org_golang_x_tools//go/ssa:go_tool_library",
+    ],
+)
+
+go_tool_library(
+    name = "ssaonce",
+    srcs = ["ssaonce.go"],
+    importpath = "ssaonce",
+    deps = [
+        ":counter",
+        "@org_golang_x_tools//go/analysis:go_tool_library",
+        "@org_golang_x_tools//go/analysis/passes/buildssa:go_tool_library",
+    ],
+)
+
+go_library(
+    name = "has_print",
+    srcs = ["has_print.go"],
+    importpath = "hasprint",
+)
+
+go_library(
+    name = "clean",
+    srcs = ["clean.go"],
+    importpath = "clean",
+)
+
+-- counter.go --
+// Package counter provides an analyzer that counts how many times it has run
+// on each package.
+package counter
+
+import (
+	"reflect"
+	"sync"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name:       "counter",
+	Doc:        "counts runs per package",
+	Run:        run,
+	ResultType: reflect.TypeOf(0),
+}
+
+var (
+	mu   sync.Mutex
+	runs = make(map[string]int)
+)
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	runs[pass.Pkg.Path()]++
+	return runs[pass.Pkg.Path()], nil
+}
+
+-- ssaprint.go --
+package ssaprint
+
+import (
+	"counter"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/buildssa"
+	"golang.org/x/tools/go/ssa"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "ssaprint",
+	Doc:      "reports calls to print using SSA",
+	Run:      run,
+	Requires: []*analysis.Analyzer{buildssa.Analyzer, counter.Analyzer},
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if n := pass.ResultOf[counter.Analyzer].(int); n != 1 {
+		pass.Reportf(pass.Files[0].Package, "prerequisite ran %d times", n)
+	}
+	for _, fn := range pass.ResultOf[buildssa.Analyzer].(*buildssa.SSA).SrcFuncs {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				call, ok := instr.(*ssa.Call)
+				if !ok {
+					continue
+				}
+				if builtin, ok := call.Call.Value.(*ssa.Builtin); ok && builtin.Name() == "print" {
+					pass.Reportf(call.Pos(), "call to print in %s", fn.Name())
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+-- ssaonce.go --
+package ssaonce
+
+import (
+	"counter"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/buildssa"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "ssaonce",
+	Doc:      "checks that prerequisites are shared",
+	Run:      run,
+	Requires: []*analysis.Analyzer{buildssa.Analyzer, counter.Analyzer},
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if n := pass.ResultOf[counter.Analyzer].(int); n != 1 {
+		pass.Reportf(pass.Files[0].Package, "prerequisite ran %d times", n)
+	}
+	return nil, nil
+}
+
+-- has_print.go --
+package hasprint
+
+func HasPrint() {
+	print("hi")
+}
+
+-- clean.go --
+package clean
+
+func Clean() {}
+`,
+	})
+}
+
+func TestSSA(t *testing.T) {
+	cmd := bazel_testing.BazelCmd("build", "//:has_print")
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err == nil {
+		t.Fatal("unexpected success")
+	}
+	if !bytes.Contains(stderr.Bytes(), []byte("call to print in HasPrint")) {
+		t.Errorf("did not find SSA finding in output:\n%s", stderr.Bytes())
+	}
+	if bytes.Contains(stderr.Bytes(), []byte("prerequisite ran")) {
+		t.Errorf("prerequisite ran more than once:\n%s", stderr.Bytes())
+	}
+}
+
+func TestTiming(t *testing.T) {
+	cmd := bazel_testing.BazelCmd("build", "--@io_bazel_rules_go//go/config:nogo_timing", "//:clean")
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, stderr.Bytes())
+	}
+	for _, want := range []string{
+		`nogo analyzer timings for clean:`,
+		`\d+\.\d+ms  ssaprint\n`,
+		`\d+\.\d+ms  ssaonce\n`,
+		`\d+\.\d+ms  buildssa \(prerequisite\)\n`,
+		`\d+\.\d+ms  counter \(prerequisite\)\n`,
+	} {
+		if !regexp.MustCompile(want).Match(stderr.Bytes()) {
+			t.Errorf("did not find %q in output:\n%s", want, stderr.Bytes())
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2020 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package targets_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Nogo: "@//:nogo",
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_tool_library", "nogo")
+
+nogo(
+    name = "nogo",
+    deps = [":noprint"],
+    config = "config.json",
+    visibility = ["//visibility:public"],
+)
+
+go_tool_library(
+    name = "noprint",
+    srcs = ["noprint.go"],
+    importpath = "noprint",
+    deps = ["@org_golang_x_tools//go/analysis:go_tool_library"],
+    visibility = ["//visibility:public"],
+)
+
+-- config.json --
+{
+  "noprint": {
+    "targets": {
+      "//third_party/...": "exclude",
+      "//third_party/ok:all": "include"
+    }
+  }
+}
+
+-- noprint.go --
+package noprint
+
+import (
+	"go/ast"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name: "noprint",
+	Doc:  "reports calls to print",
+	Run:  run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	for _, f := range pass.Files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "print" {
+					pass.Reportf(call.Pos(), "call to print")
+				}
+			}
+			return true
+		})
+	}
+	return nil, nil
+}
+
+-- app/BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "app",
+    srcs = ["app.go"],
+    importpath = "app",
+)
+
+-- app/app.go --
+package app
+
+func App() {
+	print("app")
+}
+
+-- third_party/foo/BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "foo",
+    srcs = ["foo.go"],
+    importpath = "foo",
+)
+
+-- third_party/foo/foo.go --
+package foo
+
+func Foo() {
+	print("foo")
+}
+
+-- third_party/ok/BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "ok",
+    srcs = ["ok.go"],
+    importpath = "ok",
+)
+
+-- third_party/ok/ok.go --
+package ok
+
+func Ok() {
+	print("ok")
+}
+`,
+	})
+}
+
+func TestTargets(t *testing.T) {
+	for _, test := range []struct {
+		target      string
+		wantSuccess bool
+	}{
+		{target: "//app", wantSuccess: false},
+		{target: "//third_party/foo", wantSuccess: true},
+		{target: "//third_party/ok", wantSuccess: false},
+	} {
+		t.Run(test.target, func(t *testing.T) {
+			cmd := bazel_testing.BazelCmd("build", test.target)
+			stderr := &bytes.Buffer{}
+			cmd.Stderr = stderr
+			err := cmd.Run()
+			if test.wantSuccess {
+				if err != nil {
+					t.Fatalf("unexpected error: %v\n%s", err, stderr.Bytes())
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("unexpected success")
+			}
+			if !bytes.Contains(stderr.Bytes(), []byte("call to print")) {
+				t.Errorf("build failed without a nogo error:\n%s", stderr.Bytes())
+			}
+		})
+	}
+}
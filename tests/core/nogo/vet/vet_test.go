@@ -68,6 +68,10 @@ import (
 
 func F() {}
 
+type T struct {
+	X int "json:x" // structtag error.
+}
+
 func Foo() bool {
 	x := uint64(1)
 	_ = atomic.AddUint64(&x, 1)
@@ -115,6 +119,7 @@ func Test(t *testing.T) {
 				"comparison of function F == nil is always false",
 				"Printf format %b has arg \"hi\" of wrong type string",
 				"redundant or: true \\|\\| true",
+				"struct field tag `json:x` not compatible with reflect.StructTag.Get",
 			},
 		}, {
 			desc:        "enabled_no_errors",
@@ -129,6 +134,7 @@ func Test(t *testing.T) {
 				"comparison of function F == nil is always false",
 				"Printf format %b has arg \"hi\" of wrong type string",
 				"redundant or: true \\|\\| true",
+				"struct field tag `json:x` not compatible with reflect.StructTag.Get",
 			},
 		},
 	} {